@@ -0,0 +1,214 @@
+package create
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/negentropy/cli/internal/consts"
+	"github.com/flant/negentropy/cli/internal/manage"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+)
+
+func NewCMD() *cobra.Command {
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create IAM objects of the tenant",
+		Long: `Create users, groups, service accounts, role bindings, multipasses and passwords of service accounts,
+members and owners are passed by identifiers or uuids,
+using: create TYPE -t TENANT [IDENTIFIER] [FLAGS]`,
+	}
+	manage.AddTenantFlag(createCmd.PersistentFlags())
+	manage.AddOutputFlag(createCmd.PersistentFlags())
+
+	createCmd.AddCommand(userCMD(),
+		groupCMD(),
+		serviceAccountCMD(),
+		roleBindingCMD(),
+		multipassCMD(),
+		passwordCMD())
+	return createCmd
+}
+
+func userCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:   "user IDENTIFIER",
+		Short: "Create the user",
+		Args:  cobra.ExactArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		user := iam.User{TenantUUID: r.TenantUUID, Identifier: args[0]}
+		if err = manage.ApplyUserFlags(flags, &user); err != nil {
+			return err
+		}
+		created, err := r.Client.CreateUser(user)
+		if err != nil {
+			return err
+		}
+		return manage.PrintObject(flags, created)
+	})
+	manage.AddUserFlags(cmd.Flags())
+	return cmd
+}
+
+func groupCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:   "group IDENTIFIER",
+		Short: "Create the group",
+		Args:  cobra.ExactArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		group := iam.Group{TenantUUID: r.TenantUUID, Identifier: args[0]}
+		if err = manage.ApplyGroupFlags(flags, r, &group); err != nil {
+			return err
+		}
+		created, err := r.Client.CreateGroup(group)
+		if err != nil {
+			return err
+		}
+		return manage.PrintObject(flags, created)
+	})
+	manage.AddGroupFlags(cmd.Flags())
+	return cmd
+}
+
+func serviceAccountCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:     "service-account IDENTIFIER",
+		Aliases: []string{"service_account", "sa"},
+		Short:   "Create the service account",
+		Args:    cobra.ExactArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		serviceAccount := iam.ServiceAccount{TenantUUID: r.TenantUUID, Identifier: args[0]}
+		if err = manage.ApplyServiceAccountFlags(flags, &serviceAccount); err != nil {
+			return err
+		}
+		created, err := r.Client.CreateServiceAccount(serviceAccount)
+		if err != nil {
+			return err
+		}
+		return manage.PrintObject(flags, created)
+	})
+	manage.AddServiceAccountFlags(cmd.Flags())
+	return cmd
+}
+
+func roleBindingCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:     "role-binding",
+		Aliases: []string{"role_binding", "rolebinding", "rb"},
+		Short:   "Create the role binding",
+		Example: "create role-binding -t first_tenant --user alice --group admins --role ssh.open --project main",
+		Args:    cobra.NoArgs,
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		roleBinding := iam.RoleBinding{TenantUUID: r.TenantUUID}
+		if err = manage.ApplyRoleBindingFlags(flags, r, &roleBinding); err != nil {
+			return err
+		}
+		ttl, err := flags.GetDuration(consts.TTLFlagName)
+		if err != nil {
+			return err
+		}
+		created, err := r.Client.CreateRoleBinding(roleBinding, ttl)
+		if err != nil {
+			return err
+		}
+		return manage.PrintObject(flags, created)
+	})
+	manage.AddRoleBindingFlags(cmd.Flags())
+	return cmd
+}
+
+func multipassCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:   "multipass",
+		Short: "Issue the multipass of the user or of the service account, the token is shown only once",
+		Args:  cobra.NoArgs,
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		ownerType, ownerUUID, err := manage.Owner(flags, r)
+		if err != nil {
+			return err
+		}
+		credential, err := manage.CredentialFromFlags(flags)
+		if err != nil {
+			return err
+		}
+		created, token, err := r.Client.CreateMultipass(iam.Multipass{
+			TenantUUID:  r.TenantUUID,
+			OwnerUUID:   ownerUUID,
+			OwnerType:   ownerType,
+			Description: credential.Description,
+			TTL:         credential.TTL,
+			MaxTTL:      credential.MaxTTL,
+			CIDRs:       credential.CIDRs,
+			Roles:       credential.Roles,
+		})
+		if err != nil {
+			return err
+		}
+		return manage.PrintObject(flags, manage.MultipassWithToken{Multipass: *created, Token: token})
+	})
+	manage.AddOwnerFlags(cmd.Flags(), true)
+	manage.AddCredentialFlags(cmd.Flags(), 30*24*time.Hour, true)
+	return cmd
+}
+
+func passwordCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:   "password",
+		Short: "Generate the password of the service account, the secret is shown only once",
+		Args:  cobra.NoArgs,
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		_, ownerUUID, err := manage.Owner(flags, r)
+		if err != nil {
+			return err
+		}
+		credential, err := manage.CredentialFromFlags(flags)
+		if err != nil {
+			return err
+		}
+		created, err := r.Client.CreateServiceAccountPassword(iam.ServiceAccountPassword{
+			TenantUUID:  r.TenantUUID,
+			OwnerUUID:   ownerUUID,
+			Description: credential.Description,
+			TTL:         credential.TTL,
+			CIDRs:       credential.CIDRs,
+			Roles:       credential.Roles,
+		})
+		if err != nil {
+			return err
+		}
+		return manage.PrintObject(flags, created)
+	})
+	manage.AddOwnerFlags(cmd.Flags(), false)
+	manage.AddCredentialFlags(cmd.Flags(), 30*24*time.Hour, false)
+	return cmd
+}
@@ -0,0 +1,164 @@
+package delete
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/negentropy/cli/internal/manage"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+)
+
+func NewCMD() *cobra.Command {
+	deleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete IAM objects of the tenant",
+		Long: `Delete users, groups, service accounts, role bindings, multipasses and passwords of service accounts,
+using: delete TYPE -t TENANT IDENTIFIER...`,
+	}
+	manage.AddTenantFlag(deleteCmd.PersistentFlags())
+
+	deleteCmd.AddCommand(userCMD(),
+		groupCMD(),
+		serviceAccountCMD(),
+		roleBindingCMD(),
+		multipassCMD(),
+		passwordCMD())
+	return deleteCmd
+}
+
+func userCMD() *cobra.Command {
+	return manage.NewCommand(&cobra.Command{
+		Use:   "user USER...",
+		Short: "Delete users passed by identifiers or uuids",
+		Args:  cobra.MinimumNArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		r, err := manage.Open(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		return deleteEach(iam.UserType, args, r.UserUUID, func(uuid string) error {
+			return r.Client.DeleteUser(r.TenantUUID, uuid)
+		})
+	})
+}
+
+func groupCMD() *cobra.Command {
+	return manage.NewCommand(&cobra.Command{
+		Use:   "group GROUP...",
+		Short: "Delete groups passed by identifiers or uuids",
+		Args:  cobra.MinimumNArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		r, err := manage.Open(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		return deleteEach(iam.GroupType, args, r.GroupUUID, func(uuid string) error {
+			return r.Client.DeleteGroup(r.TenantUUID, uuid)
+		})
+	})
+}
+
+func serviceAccountCMD() *cobra.Command {
+	return manage.NewCommand(&cobra.Command{
+		Use:     "service-account SERVICE_ACCOUNT...",
+		Aliases: []string{"service_account", "sa"},
+		Short:   "Delete service accounts passed by identifiers or uuids",
+		Args:    cobra.MinimumNArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		r, err := manage.Open(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		return deleteEach(iam.ServiceAccountType, args, r.ServiceAccountUUID, func(uuid string) error {
+			return r.Client.DeleteServiceAccount(r.TenantUUID, uuid)
+		})
+	})
+}
+
+func roleBindingCMD() *cobra.Command {
+	return manage.NewCommand(&cobra.Command{
+		Use:     "role-binding UUID...",
+		Aliases: []string{"role_binding", "rolebinding", "rb"},
+		Short:   "Delete role bindings",
+		Args:    cobra.MinimumNArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		r, err := manage.Open(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		return deleteEach(iam.RoleBindingType, args, byUUID, func(uuid string) error {
+			return r.Client.DeleteRoleBinding(r.TenantUUID, uuid)
+		})
+	})
+}
+
+func multipassCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:   "multipass UUID...",
+		Short: "Delete multipasses of the user or of the service account",
+		Args:  cobra.MinimumNArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		ownerType, ownerUUID, err := manage.Owner(flags, r)
+		if err != nil {
+			return err
+		}
+		return deleteEach(iam.MultipassType, args, byUUID, func(uuid string) error {
+			return r.Client.DeleteMultipass(r.TenantUUID, ownerType, ownerUUID, uuid)
+		})
+	})
+	manage.AddOwnerFlags(cmd.Flags(), true)
+	return cmd
+}
+
+func passwordCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:   "password UUID...",
+		Short: "Delete passwords of the service account",
+		Args:  cobra.MinimumNArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		_, ownerUUID, err := manage.Owner(flags, r)
+		if err != nil {
+			return err
+		}
+		return deleteEach(iam.ServiceAccountPasswordType, args, byUUID, func(uuid string) error {
+			return r.Client.DeleteServiceAccountPassword(r.TenantUUID, ownerUUID, uuid)
+		})
+	})
+	manage.AddOwnerFlags(cmd.Flags(), false)
+	return cmd
+}
+
+// deleteEach resolves all identifiers before deleting, so nothing is deleted if one of them is wrong
+func deleteEach(objectType string, identifiers []string, resolve func(string) (string, error),
+	deleteFn func(uuid string) error) error {
+	uuids := make([]string, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		uuid, err := resolve(identifier)
+		if err != nil {
+			return err
+		}
+		uuids = append(uuids, uuid)
+	}
+	for _, uuid := range uuids {
+		if err := deleteFn(uuid); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s is deleted\n", objectType, uuid)
+	}
+	return nil
+}
+
+func byUUID(uuid string) (string, error) {
+	return uuid, nil
+}
@@ -0,0 +1,229 @@
+package describe
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/flant/negentropy/cli/internal/manage"
+	"github.com/flant/negentropy/cli/pkg"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+)
+
+func NewCMD() *cobra.Command {
+	describeCmd := &cobra.Command{
+		Use:   "describe",
+		Short: "Describe IAM objects of the tenant",
+		Long: `Describe users, groups, service accounts, role bindings, multipasses and passwords of service accounts,
+all objects of the type are described, if identifiers are not passed,
+using: describe TYPE -t TENANT [IDENTIFIER...]`,
+	}
+	manage.AddTenantFlag(describeCmd.PersistentFlags())
+	manage.AddOutputFlag(describeCmd.PersistentFlags())
+
+	describeCmd.AddCommand(userCMD(),
+		groupCMD(),
+		serviceAccountCMD(),
+		roleBindingCMD(),
+		multipassCMD(),
+		passwordCMD())
+	return describeCmd
+}
+
+func userCMD() *cobra.Command {
+	return manage.NewCommand(&cobra.Command{
+		Use:   "user [USER...]",
+		Short: "Describe users passed by identifiers or uuids",
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			users, err := r.Client.GetUsers(r.TenantUUID)
+			if err != nil {
+				return err
+			}
+			return manage.PrintObject(flags, users)
+		}
+		users := make([]iam.User, 0, len(args))
+		for _, arg := range args {
+			uuid, err := r.UserUUID(arg)
+			if err != nil {
+				return err
+			}
+			user, err := r.Client.GetUserByUUID(r.TenantUUID, uuid)
+			if err != nil {
+				return err
+			}
+			users = append(users, *user)
+		}
+		return manage.PrintRequested(flags, users)
+	})
+}
+
+func groupCMD() *cobra.Command {
+	return manage.NewCommand(&cobra.Command{
+		Use:   "group [GROUP...]",
+		Short: "Describe groups passed by identifiers or uuids",
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			groups, err := r.Client.GetGroups(r.TenantUUID)
+			if err != nil {
+				return err
+			}
+			return manage.PrintObject(flags, groups)
+		}
+		groups := make([]iam.Group, 0, len(args))
+		for _, arg := range args {
+			uuid, err := r.GroupUUID(arg)
+			if err != nil {
+				return err
+			}
+			group, err := r.Client.GetGroupByUUID(r.TenantUUID, uuid)
+			if err != nil {
+				return err
+			}
+			groups = append(groups, *group)
+		}
+		return manage.PrintRequested(flags, groups)
+	})
+}
+
+func serviceAccountCMD() *cobra.Command {
+	return manage.NewCommand(&cobra.Command{
+		Use:     "service-account [SERVICE_ACCOUNT...]",
+		Aliases: []string{"service_account", "sa"},
+		Short:   "Describe service accounts passed by identifiers or uuids",
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			serviceAccounts, err := r.Client.GetServiceAccounts(r.TenantUUID)
+			if err != nil {
+				return err
+			}
+			return manage.PrintObject(flags, serviceAccounts)
+		}
+		serviceAccounts := make([]iam.ServiceAccount, 0, len(args))
+		for _, arg := range args {
+			uuid, err := r.ServiceAccountUUID(arg)
+			if err != nil {
+				return err
+			}
+			serviceAccount, err := r.Client.GetServiceAccountByUUID(r.TenantUUID, uuid)
+			if err != nil {
+				return err
+			}
+			serviceAccounts = append(serviceAccounts, *serviceAccount)
+		}
+		return manage.PrintRequested(flags, serviceAccounts)
+	})
+}
+
+func roleBindingCMD() *cobra.Command {
+	return manage.NewCommand(&cobra.Command{
+		Use:     "role-binding [UUID...]",
+		Aliases: []string{"role_binding", "rolebinding", "rb"},
+		Short:   "Describe role bindings",
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			roleBindings, err := r.Client.GetRoleBindings(r.TenantUUID)
+			if err != nil {
+				return err
+			}
+			return manage.PrintObject(flags, roleBindings)
+		}
+		roleBindings := make([]pkg.RoleBinding, 0, len(args))
+		for _, uuid := range args {
+			roleBinding, err := r.Client.GetRoleBindingByUUID(r.TenantUUID, uuid)
+			if err != nil {
+				return err
+			}
+			roleBindings = append(roleBindings, *roleBinding)
+		}
+		return manage.PrintRequested(flags, roleBindings)
+	})
+}
+
+func multipassCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:   "multipass [UUID...]",
+		Short: "Describe multipasses of the user or of the service account",
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		ownerType, ownerUUID, err := manage.Owner(flags, r)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			multipasses, err := r.Client.GetMultipasses(r.TenantUUID, ownerType, ownerUUID)
+			if err != nil {
+				return err
+			}
+			return manage.PrintObject(flags, multipasses)
+		}
+		multipasses := make([]iam.Multipass, 0, len(args))
+		for _, uuid := range args {
+			multipass, err := r.Client.GetMultipassByUUID(r.TenantUUID, ownerType, ownerUUID, uuid)
+			if err != nil {
+				return err
+			}
+			multipasses = append(multipasses, *multipass)
+		}
+		return manage.PrintRequested(flags, multipasses)
+	})
+	manage.AddOwnerFlags(cmd.Flags(), true)
+	return cmd
+}
+
+func passwordCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:   "password [UUID...]",
+		Short: "Describe passwords of the service account",
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		_, ownerUUID, err := manage.Owner(flags, r)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			passwords, err := r.Client.GetServiceAccountPasswords(r.TenantUUID, ownerUUID)
+			if err != nil {
+				return err
+			}
+			return manage.PrintObject(flags, passwords)
+		}
+		passwords := make([]iam.ServiceAccountPassword, 0, len(args))
+		for _, uuid := range args {
+			password, err := r.Client.GetServiceAccountPasswordByUUID(r.TenantUUID, ownerUUID, uuid)
+			if err != nil {
+				return err
+			}
+			passwords = append(passwords, *password)
+		}
+		return manage.PrintRequested(flags, passwords)
+	})
+	manage.AddOwnerFlags(cmd.Flags(), false)
+	return cmd
+}
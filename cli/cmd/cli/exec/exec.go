@@ -0,0 +1,118 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/negentropy/cli/internal/consts"
+	"github.com/flant/negentropy/cli/internal/model"
+	session "github.com/flant/negentropy/cli/internal/ssh-session"
+	"github.com/flant/negentropy/cli/internal/vault"
+)
+
+const defaultParallel = 10
+
+func NewCMD() *cobra.Command {
+	var errExec error
+	execCmd := &cobra.Command{
+		Use:   "exec",
+		Short: "Run a command at set of servers",
+		Long: `Run a non-interactive command at servers specified by flags in parallel,
+output and exit codes are printed per server, it fails if the command fails at any server.
+using: exec -t tenant -p project -l role=db 'COMMAND'`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  Executor(&errExec),
+		PostRunE: func(command *cobra.Command, args []string) error {
+			return errExec
+		},
+	}
+	AddServerFlags(execCmd)
+	execCmd.PersistentFlags().StringP(consts.OutputFlagName, string(consts.OutputFlagName[0]), "",
+		"specify output format, available values: [ text | json ]")
+
+	return execCmd
+}
+
+// AddServerFlags adds flags to choose servers and parallelism of non-interactive commands
+func AddServerFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().Bool(consts.AllTenantsFlagName, false, "address all tenants of the user: --all-tenants")
+	cmd.PersistentFlags().StringP(consts.TenantFlagName, string(consts.TenantFlagName[0]), "", "specify one of user tenant: -t first_tenant")
+	cmd.PersistentFlags().Bool(consts.AllProjectsFlagName, false, "address all projects of the user: --all-projects")
+	cmd.PersistentFlags().StringP(consts.ProjectFlagName, string(consts.ProjectFlagName[0]), "", "specify one of user project at specific tenant: -t first tenant -p main")
+	cmd.PersistentFlags().StringP(consts.LabelsFlagName, string(consts.LabelsFlagName[0]), "", "specify labels of desired servers: --all-tenants -l cloud=aws")
+	cmd.PersistentFlags().Bool(consts.AllServersFlagName, false, "address all servers: --all")
+	cmd.PersistentFlags().StringSlice(consts.ServersFlagName, nil, "specify identifiers of servers: --servers db-1,db-2")
+	cmd.PersistentFlags().Int(consts.ParallelFlagName, defaultParallel, "max number of servers processed at once")
+}
+
+// OpenSession opens the ssh-session to servers, specified by flags added by AddServerFlags
+func OpenSession(cmd *cobra.Command) (*session.Session, error) {
+	flags := cmd.Flags()
+	serverIdentifiers, err := flags.GetStringSlice(consts.ServersFlagName)
+	if err != nil {
+		return nil, err
+	}
+	serverFilter, err := model.ServerFilterFromFlags(flags, serverIdentifiers)
+	if err != nil {
+		return nil, err
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	var permanentCacheFilePath string
+	if permanentCacheFilePath = os.Getenv("CACHE_PATH"); permanentCacheFilePath == "" {
+		permanentCacheFilePath = path.Join(homeDir, ".flant", "cli", "ssh", "cache")
+	}
+	vaultService, err := vault.NewService()
+	if err != nil {
+		return nil, err
+	}
+	s, err := session.New(vaultService, serverFilter, permanentCacheFilePath, consts.CacheTTL)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Open()
+	if err != nil {
+		return nil, err
+	}
+	if len(s.ServerList.Servers) == 0 {
+		_ = s.Finish()
+		return nil, fmt.Errorf("no servers are found")
+	}
+	return s, nil
+}
+
+func Executor(err *error) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		var (
+			parallel int
+			output   string
+			s        *session.Session
+		)
+		parallel, *err = flags.GetInt(consts.ParallelFlagName)
+		if *err != nil {
+			return
+		}
+		output, *err = flags.GetString(consts.OutputFlagName)
+		if *err != nil {
+			return
+		}
+		s, *err = OpenSession(cmd)
+		if *err != nil {
+			return
+		}
+
+		results := s.Exec(strings.Join(args, " "), parallel)
+		*err = session.PrintHostResults(os.Stdout, results, output)
+		if finishErr := s.Finish(); *err == nil {
+			*err = finishErr
+		}
+	}
+}
@@ -7,8 +7,14 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/flant/negentropy/cli/cmd/cli/create"
+	"github.com/flant/negentropy/cli/cmd/cli/delete"
+	"github.com/flant/negentropy/cli/cmd/cli/describe"
+	"github.com/flant/negentropy/cli/cmd/cli/exec"
 	"github.com/flant/negentropy/cli/cmd/cli/get"
+	"github.com/flant/negentropy/cli/cmd/cli/scp"
 	"github.com/flant/negentropy/cli/cmd/cli/ssh"
+	"github.com/flant/negentropy/cli/cmd/cli/update"
 	"github.com/flant/negentropy/cli/internal/consts"
 )
 
@@ -25,7 +31,13 @@ Find more information at https://flant.com`,
 	rootCmd.PersistentFlags().Bool(consts.AllProjectsFlagName, false, "address all projects of the user: --all-projects")
 
 	rootCmd.AddCommand(ssh.NewCMD(),
-		get.NewCMD())
+		exec.NewCMD(),
+		scp.NewCMD(),
+		get.NewCMD(),
+		create.NewCMD(),
+		update.NewCMD(),
+		delete.NewCMD(),
+		describe.NewCMD())
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
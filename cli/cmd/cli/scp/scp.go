@@ -0,0 +1,101 @@
+package scp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/negentropy/cli/cmd/cli/exec"
+	"github.com/flant/negentropy/cli/internal/consts"
+	session "github.com/flant/negentropy/cli/internal/ssh-session"
+)
+
+func NewCMD() *cobra.Command {
+	var errSCP error
+	scpCmd := &cobra.Command{
+		Use:   "scp",
+		Short: "Copy files to or from set of servers",
+		Long: `Copy files to or from servers specified by flags in parallel, remote paths start with ':'.
+Upload into all servers: scp -t tenant -p project -l role=db LOCAL_PATH... :REMOTE_PATH
+Download from all servers into LOCAL_DIR/PROJECT_IDENTIFIER.SERVER_IDENTIFIER: scp -t tenant -p project :REMOTE_PATH LOCAL_DIR`,
+		Args: cobra.MinimumNArgs(2),
+		Run:  Copier(&errSCP),
+		PostRunE: func(command *cobra.Command, args []string) error {
+			return errSCP
+		},
+	}
+	exec.AddServerFlags(scpCmd)
+	scpCmd.PersistentFlags().BoolP(consts.RecursiveFlagName, string(consts.RecursiveFlagName[0]), false, "copy directories recursively")
+	scpCmd.PersistentFlags().StringP(consts.OutputFlagName, string(consts.OutputFlagName[0]), "",
+		"specify output format, available values: [ text | json ]")
+
+	return scpCmd
+}
+
+// splitPaths checks that only the last or only the first path is remote
+func splitPaths(args []string) (upload bool, local []string, remote string, err error) {
+	last := args[len(args)-1]
+	switch {
+	case strings.HasPrefix(last, ":"):
+		for _, arg := range args[:len(args)-1] {
+			if strings.HasPrefix(arg, ":") {
+				return false, nil, "", fmt.Errorf("only the last path should be remote for upload: %s", arg)
+			}
+		}
+		return true, args[:len(args)-1], strings.TrimPrefix(last, ":"), nil
+	case strings.HasPrefix(args[0], ":"):
+		if len(args) != 2 || strings.HasPrefix(last, ":") {
+			return false, nil, "", fmt.Errorf("download needs :REMOTE_PATH and LOCAL_DIR")
+		}
+		return false, []string{last}, strings.TrimPrefix(args[0], ":"), nil
+	}
+	return false, nil, "", fmt.Errorf("one of paths should be remote, remote paths start with ':'")
+}
+
+func Copier(err *error) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		var (
+			parallel  int
+			recursive bool
+			output    string
+			upload    bool
+			local     []string
+			remote    string
+			s         *session.Session
+		)
+		upload, local, remote, *err = splitPaths(args)
+		if *err != nil {
+			return
+		}
+		parallel, *err = flags.GetInt(consts.ParallelFlagName)
+		if *err != nil {
+			return
+		}
+		recursive, *err = flags.GetBool(consts.RecursiveFlagName)
+		if *err != nil {
+			return
+		}
+		output, *err = flags.GetString(consts.OutputFlagName)
+		if *err != nil {
+			return
+		}
+		s, *err = exec.OpenSession(cmd)
+		if *err != nil {
+			return
+		}
+
+		var results []session.HostResult
+		if upload {
+			results = s.Upload(local, remote, recursive, parallel)
+		} else {
+			results = s.Download(remote, local[0], recursive, parallel)
+		}
+		*err = session.PrintHostResults(os.Stdout, results, output)
+		if finishErr := s.Finish(); *err == nil {
+			*err = finishErr
+		}
+	}
+}
@@ -30,6 +30,8 @@ To establish connection at child session run 'ssh SERVER_IDENTIFIER@TENANT_IDENT
 	sshCmd.PersistentFlags().StringP(consts.ProjectFlagName, string(consts.ProjectFlagName[0]), "", "specify one of user project at specific tenant: -t first tenant -p main")
 	sshCmd.PersistentFlags().StringP(consts.LabelsFlagName, string(consts.LabelsFlagName[0]), "", "specify labels of desired servers: --all-tenants -l cloud=aws")
 	sshCmd.PersistentFlags().Bool(consts.AllServersFlagName, false, "address all servers: --all")
+	sshCmd.PersistentFlags().Bool(consts.RecordFlagName, false, "record the session in asciicast format and upload it for audit: --record")
+	sshCmd.PersistentFlags().String(consts.RecordingsFlagName, "", "directory for recordings until they are uploaded, default ~/.flant/cli/ssh/recordings")
 
 	return sshCmd
 }
@@ -37,49 +39,32 @@ To establish connection at child session run 'ssh SERVER_IDENTIFIER@TENANT_IDENT
 func SSHSessionStarter(err *error) func(*cobra.Command, []string) {
 	return func(cmd *cobra.Command, args []string) {
 		flags := cmd.Flags()
-		serverFilter := model.ServerFilter{}
-		serverFilter.AllTenants, *err = flags.GetBool(consts.AllTenantsFlagName)
+		var serverFilter model.ServerFilter
+		serverFilter, *err = model.ServerFilterFromFlags(flags, args)
 		if *err != nil {
 			return
 		}
-		serverFilter.AllProjects, *err = flags.GetBool(consts.AllProjectsFlagName)
-		if *err != nil {
-			return
-		}
-		serverFilter.TenantIdentifiers, *err = model.StringSetFromStringFlag(flags, consts.TenantFlagName)
-		if *err != nil {
-			return
-		}
-		serverFilter.ProjectIdentifiers, *err = model.StringSetFromStringFlag(flags, consts.ProjectFlagName)
-		if *err != nil {
-			return
-		}
-		serverFilter.LabelSelector, *err = flags.GetString(consts.LabelsFlagName)
+
+		var (
+			s             *session.Session
+			homeDir       string
+			record        bool
+			recordingsDir string
+		)
+		homeDir, *err = os.UserHomeDir()
 		if *err != nil {
 			return
 		}
-		serverFilter.AllServers, *err = flags.GetBool(consts.AllServersFlagName)
+		record, *err = flags.GetBool(consts.RecordFlagName)
 		if *err != nil {
 			return
 		}
-		serverFilter.ServerIdentifiers = args
-
-		if len(serverFilter.ServerIdentifiers) == 0 {
-			serverFilter.AllServers = true
-		}
-
-		*err = serverFilter.Validate()
+		recordingsDir, *err = flags.GetString(consts.RecordingsFlagName)
 		if *err != nil {
 			return
 		}
-
-		var (
-			s       *session.Session
-			homeDir string
-		)
-		homeDir, *err = os.UserHomeDir()
-		if *err != nil {
-			return
+		if recordingsDir == "" {
+			recordingsDir = path.Join(homeDir, ".flant", "cli", "ssh", "recordings")
 		}
 		var permanentCacheFilePath string
 		if permanentCacheFilePath = os.Getenv("CACHE_PATH"); permanentCacheFilePath == "" {
@@ -94,6 +79,9 @@ func SSHSessionStarter(err *error) func(*cobra.Command, []string) {
 		if *err != nil {
 			return
 		}
+		if record {
+			s.RecordingsDir = recordingsDir
+		}
 		*err = s.Start()
 	}
 }
@@ -0,0 +1,171 @@
+package update
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/flant/negentropy/cli/internal/consts"
+	"github.com/flant/negentropy/cli/internal/manage"
+)
+
+func NewCMD() *cobra.Command {
+	updateCmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update IAM objects of the tenant",
+		Long: `Update users, groups, service accounts and role bindings,
+only fields of passed flags are changed, members and owners are passed by identifiers or uuids,
+using: update TYPE -t TENANT IDENTIFIER [FLAGS]`,
+	}
+	manage.AddTenantFlag(updateCmd.PersistentFlags())
+	manage.AddOutputFlag(updateCmd.PersistentFlags())
+
+	updateCmd.AddCommand(userCMD(),
+		groupCMD(),
+		serviceAccountCMD(),
+		roleBindingCMD())
+	return updateCmd
+}
+
+func userCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:   "user USER",
+		Short: "Update the user passed by identifier or uuid",
+		Args:  cobra.ExactArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		userUUID, err := r.UserUUID(args[0])
+		if err != nil {
+			return err
+		}
+		user, err := r.Client.GetUserByUUID(r.TenantUUID, userUUID)
+		if err != nil {
+			return err
+		}
+		if err = manage.ApplyUserFlags(flags, user); err != nil {
+			return err
+		}
+		updated, err := r.Client.UpdateUser(*user)
+		if err != nil {
+			return err
+		}
+		return manage.PrintObject(flags, updated)
+	})
+	manage.AddIdentifierFlag(cmd.Flags())
+	manage.AddUserFlags(cmd.Flags())
+	return cmd
+}
+
+func groupCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:   "group GROUP",
+		Short: "Update the group passed by identifier or uuid",
+		Args:  cobra.ExactArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		groupUUID, err := r.GroupUUID(args[0])
+		if err != nil {
+			return err
+		}
+		group, err := r.Client.GetGroupByUUID(r.TenantUUID, groupUUID)
+		if err != nil {
+			return err
+		}
+		if err = manage.ApplyGroupFlags(flags, r, group); err != nil {
+			return err
+		}
+		updated, err := r.Client.UpdateGroup(*group)
+		if err != nil {
+			return err
+		}
+		return manage.PrintObject(flags, updated)
+	})
+	manage.AddIdentifierFlag(cmd.Flags())
+	manage.AddGroupFlags(cmd.Flags())
+	return cmd
+}
+
+func serviceAccountCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:     "service-account SERVICE_ACCOUNT",
+		Aliases: []string{"service_account", "sa"},
+		Short:   "Update the service account passed by identifier or uuid",
+		Args:    cobra.ExactArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		serviceAccountUUID, err := r.ServiceAccountUUID(args[0])
+		if err != nil {
+			return err
+		}
+		serviceAccount, err := r.Client.GetServiceAccountByUUID(r.TenantUUID, serviceAccountUUID)
+		if err != nil {
+			return err
+		}
+		if err = manage.ApplyServiceAccountFlags(flags, serviceAccount); err != nil {
+			return err
+		}
+		updated, err := r.Client.UpdateServiceAccount(*serviceAccount)
+		if err != nil {
+			return err
+		}
+		return manage.PrintObject(flags, updated)
+	})
+	manage.AddIdentifierFlag(cmd.Flags())
+	manage.AddServiceAccountFlags(cmd.Flags())
+	return cmd
+}
+
+func roleBindingCMD() *cobra.Command {
+	cmd := manage.NewCommand(&cobra.Command{
+		Use:     "role-binding UUID",
+		Aliases: []string{"role_binding", "rolebinding", "rb"},
+		Short:   "Update the role binding, if TTL is not passed, the role binding keeps its expiration",
+		Args:    cobra.ExactArgs(1),
+	}, func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		r, err := manage.Open(flags)
+		if err != nil {
+			return err
+		}
+		current, err := r.Client.GetRoleBindingByUUID(r.TenantUUID, args[0])
+		if err != nil {
+			return err
+		}
+		roleBinding := current.Model()
+		if err = manage.ApplyRoleBindingFlags(flags, r, &roleBinding); err != nil {
+			return err
+		}
+		var ttl time.Duration
+		switch {
+		case flags.Changed(consts.TTLFlagName):
+			if ttl, err = flags.GetDuration(consts.TTLFlagName); err != nil {
+				return err
+			}
+		case roleBinding.ValidTill > 0:
+			ttl = time.Until(time.Unix(roleBinding.ValidTill, 0)).Truncate(time.Second)
+			if ttl <= 0 {
+				return fmt.Errorf("role binding %s is expired, pass new --%s", roleBinding.UUID, consts.TTLFlagName)
+			}
+		}
+		updated, err := r.Client.UpdateRoleBinding(roleBinding, ttl)
+		if err != nil {
+			return err
+		}
+		return manage.PrintObject(flags, updated)
+	})
+	manage.AddRoleBindingFlags(cmd.Flags())
+	return cmd
+}
@@ -9,4 +9,32 @@ const (
 	AllServersFlagName  = "all"
 	OutputFlagName      = "output"
 	OnlyCacheFlagName   = "only-from-cache"
+	RecordFlagName      = "record"
+	RecordingsFlagName  = "recordings-dir"
+	ServersFlagName     = "servers"
+	ParallelFlagName    = "parallel"
+	RecursiveFlagName   = "recursive"
+
+	// flags of IAM objects
+	IdentifierFlagName      = "identifier"
+	FirstNameFlagName       = "first-name"
+	LastNameFlagName        = "last-name"
+	DisplayNameFlagName     = "display-name"
+	EmailFlagName           = "email"
+	AdditionalEmailFlagName = "additional-email"
+	MobilePhoneFlagName     = "mobile-phone"
+	AdditionalPhoneFlagName = "additional-phone"
+	LanguageFlagName        = "language"
+	UserFlagName            = "user"
+	GroupFlagName           = "group"
+	ServiceAccountFlagName  = "service-account"
+	DescriptionFlagName     = "description"
+	RoleFlagName            = "role"
+	AnyProjectFlagName      = "any-project"
+	RequireMFAFlagName      = "require-mfa"
+	DenyFlagName            = "deny"
+	TTLFlagName             = "ttl"
+	MaxTTLFlagName          = "max-ttl"
+	AllowedCIDRFlagName     = "allowed-cidr"
+	AllowedRoleFlagName     = "allowed-role"
 )
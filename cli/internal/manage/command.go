@@ -0,0 +1,74 @@
+package manage
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/flant/negentropy/cli/internal/consts"
+	"github.com/flant/negentropy/cli/pkg"
+)
+
+// NewCommand sets run to the command, the error of run is returned after the run, as at other commands of the cli
+func NewCommand(cmd *cobra.Command, run func(cmd *cobra.Command, args []string) error) *cobra.Command {
+	var runErr error
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		runErr = run(cmd, args)
+	}
+	cmd.PostRunE = func(*cobra.Command, []string) error {
+		return runErr
+	}
+	return cmd
+}
+
+func AddTenantFlag(flags *pflag.FlagSet) {
+	flags.StringP(consts.TenantFlagName, string(consts.TenantFlagName[0]), "",
+		"identifier or uuid of the tenant of objects: -t first_tenant")
+}
+
+func AddOutputFlag(flags *pflag.FlagSet) {
+	flags.StringP(consts.OutputFlagName, string(consts.OutputFlagName[0]), "",
+		"specify output format, available values: [ table | json | yaml ]")
+}
+
+// AddIdentifierFlag adds the flag to change identifiers of users, groups and service accounts
+func AddIdentifierFlag(flags *pflag.FlagSet) {
+	flags.String(consts.IdentifierFlagName, "", "new identifier, unique at the tenant")
+}
+
+// PrintObject writes the object into stdout in the format passed by the output flag
+func PrintObject(flags *pflag.FlagSet, object interface{}) error {
+	output, err := flags.GetString(consts.OutputFlagName)
+	if err != nil {
+		return err
+	}
+	return Print(os.Stdout, output, object)
+}
+
+// PrintRequested writes objects requested by arguments, the only requested object is written as an object, not as a list
+func PrintRequested(flags *pflag.FlagSet, objects interface{}) error {
+	value := reflect.ValueOf(objects)
+	if value.Kind() == reflect.Slice && value.Len() == 1 {
+		return PrintObject(flags, value.Index(0).Interface())
+	}
+	return PrintObject(flags, objects)
+}
+
+// Open logins into the root source vault, where IAM objects are managed, and finds the tenant passed by flags
+func Open(flags *pflag.FlagSet) (*Resolver, error) {
+	tenant, err := flags.GetString(consts.TenantFlagName)
+	if err != nil {
+		return nil, err
+	}
+	if tenant == "" {
+		return nil, fmt.Errorf("pass the tenant by --%s", consts.TenantFlagName)
+	}
+	cl, err := pkg.DefaultRootSourceVaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return NewResolver(cl, tenant)
+}
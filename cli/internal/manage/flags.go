@@ -0,0 +1,288 @@
+package manage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/flant/negentropy/cli/internal/consts"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+)
+
+// Flags of objects are shared by create and update commands, Apply* functions change only fields of passed flags,
+// identifiers are passed by arguments of create commands and by the flag of update commands
+
+func AddUserFlags(flags *pflag.FlagSet) {
+	flags.String(consts.FirstNameFlagName, "", "first name")
+	flags.String(consts.LastNameFlagName, "", "last name")
+	flags.String(consts.DisplayNameFlagName, "", "display name")
+	flags.String(consts.EmailFlagName, "", "email")
+	flags.StringSlice(consts.AdditionalEmailFlagName, nil, "additional email, can be repeated")
+	flags.String(consts.MobilePhoneFlagName, "", "mobile phone")
+	flags.StringSlice(consts.AdditionalPhoneFlagName, nil, "additional phone, can be repeated")
+	flags.String(consts.LanguageFlagName, "", "preferred language")
+}
+
+func ApplyUserFlags(flags *pflag.FlagSet, user *iam.User) error {
+	for flag, field := range map[string]*string{
+		consts.IdentifierFlagName:  &user.Identifier,
+		consts.FirstNameFlagName:   &user.FirstName,
+		consts.LastNameFlagName:    &user.LastName,
+		consts.DisplayNameFlagName: &user.DisplayName,
+		consts.EmailFlagName:       &user.Email,
+		consts.MobilePhoneFlagName: &user.MobilePhone,
+		consts.LanguageFlagName:    &user.Language,
+	} {
+		if err := applyString(flags, flag, field); err != nil {
+			return err
+		}
+	}
+	if err := applyStringSlice(flags, consts.AdditionalEmailFlagName, &user.AdditionalEmails); err != nil {
+		return err
+	}
+	return applyStringSlice(flags, consts.AdditionalPhoneFlagName, &user.AdditionalPhones)
+}
+
+func AddGroupFlags(flags *pflag.FlagSet) {
+	addMembersFlags(flags, "group")
+}
+
+func ApplyGroupFlags(flags *pflag.FlagSet, r *Resolver, group *iam.Group) error {
+	if err := applyString(flags, consts.IdentifierFlagName, &group.Identifier); err != nil {
+		return err
+	}
+	return applyMembersFlags(flags, r, &group.Members)
+}
+
+func AddServiceAccountFlags(flags *pflag.FlagSet) {
+	flags.StringSlice(consts.AllowedCIDRFlagName, nil, "CIDR which the service account is allowed to login from, can be repeated")
+	flags.Duration(consts.TTLFlagName, 0, "TTL of tokens of the service account")
+	flags.Duration(consts.MaxTTLFlagName, 0, "max TTL of tokens of the service account")
+}
+
+func ApplyServiceAccountFlags(flags *pflag.FlagSet, serviceAccount *iam.ServiceAccount) error {
+	if err := applyString(flags, consts.IdentifierFlagName, &serviceAccount.Identifier); err != nil {
+		return err
+	}
+	if err := applyStringSlice(flags, consts.AllowedCIDRFlagName, &serviceAccount.CIDRs); err != nil {
+		return err
+	}
+	if err := applyDuration(flags, consts.TTLFlagName, &serviceAccount.TokenTTL); err != nil {
+		return err
+	}
+	return applyDuration(flags, consts.MaxTTLFlagName, &serviceAccount.TokenMaxTTL)
+}
+
+func AddRoleBindingFlags(flags *pflag.FlagSet) {
+	flags.String(consts.DescriptionFlagName, "", "description of the role binding")
+	addMembersFlags(flags, "role binding")
+	flags.StringSlice(consts.RoleFlagName, nil, "name of the bound role, can be repeated, replaces all roles")
+	flags.StringSlice(consts.ProjectFlagName, nil, "identifier or uuid of the project, can be repeated, replaces all projects")
+	flags.Bool(consts.AnyProjectFlagName, false, "bind roles at all projects of the tenant")
+	flags.Bool(consts.RequireMFAFlagName, false, "require MFA")
+	flags.Bool(consts.DenyFlagName, false, "take bound roles away from members instead of granting them")
+	flags.Duration(consts.TTLFlagName, 0, "the role binding is valid for TTL, 0 means forever")
+}
+
+// ApplyRoleBindingFlags changes the role binding, TTL is not applied, as it is passed to vault separately
+func ApplyRoleBindingFlags(flags *pflag.FlagSet, r *Resolver, roleBinding *iam.RoleBinding) error {
+	if err := applyString(flags, consts.DescriptionFlagName, &roleBinding.Description); err != nil {
+		return err
+	}
+	if err := applyMembersFlags(flags, r, &roleBinding.Members); err != nil {
+		return err
+	}
+	if flags.Changed(consts.RoleFlagName) {
+		names, err := flags.GetStringSlice(consts.RoleFlagName)
+		if err != nil {
+			return err
+		}
+		roleBinding.Roles = make([]iam.BoundRole, 0, len(names))
+		for _, name := range names {
+			roleBinding.Roles = append(roleBinding.Roles, iam.BoundRole{Name: name, Options: map[string]interface{}{}})
+		}
+	}
+	if flags.Changed(consts.ProjectFlagName) {
+		projects, err := flags.GetStringSlice(consts.ProjectFlagName)
+		if err != nil {
+			return err
+		}
+		if roleBinding.Projects, err = r.ProjectUUIDs(projects); err != nil {
+			return err
+		}
+	}
+	for flag, field := range map[string]*bool{
+		consts.AnyProjectFlagName: &roleBinding.AnyProject,
+		consts.RequireMFAFlagName: &roleBinding.RequireMFA,
+		consts.DenyFlagName:       &roleBinding.Deny,
+	} {
+		if err := applyBool(flags, flag, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddOwnerFlags adds flags of the owner of multipasses or passwords
+func AddOwnerFlags(flags *pflag.FlagSet, withUser bool) {
+	if withUser {
+		flags.String(consts.UserFlagName, "", "identifier or uuid of the owner user")
+	}
+	flags.String(consts.ServiceAccountFlagName, "", "identifier or uuid of the owner service account")
+}
+
+// Owner returns the owner passed by flags added by AddOwnerFlags
+func Owner(flags *pflag.FlagSet, r *Resolver) (iam.MultipassOwnerType, iam.OwnerUUID, error) {
+	serviceAccount, err := flags.GetString(consts.ServiceAccountFlagName)
+	if err != nil {
+		return "", "", err
+	}
+	if flags.Lookup(consts.UserFlagName) == nil {
+		if serviceAccount == "" {
+			return "", "", fmt.Errorf("pass the owner by --%s", consts.ServiceAccountFlagName)
+		}
+		uuid, err := r.ServiceAccountUUID(serviceAccount)
+		return iam.MultipassOwnerServiceAccount, uuid, err
+	}
+	user, err := flags.GetString(consts.UserFlagName)
+	if err != nil {
+		return "", "", err
+	}
+	switch {
+	case user != "" && serviceAccount == "":
+		uuid, err := r.UserUUID(user)
+		return iam.MultipassOwnerUser, uuid, err
+	case user == "" && serviceAccount != "":
+		uuid, err := r.ServiceAccountUUID(serviceAccount)
+		return iam.MultipassOwnerServiceAccount, uuid, err
+	default:
+		return "", "", fmt.Errorf("pass the owner by --%s or by --%s", consts.UserFlagName, consts.ServiceAccountFlagName)
+	}
+}
+
+// Credential is common fields of multipasses and passwords
+type Credential struct {
+	Description string
+	CIDRs       []string
+	Roles       []string
+	TTL         time.Duration
+	// MaxTTL is zero, if the flag is not added
+	MaxTTL time.Duration
+}
+
+// AddCredentialFlags adds flags of multipasses and passwords
+func AddCredentialFlags(flags *pflag.FlagSet, ttl time.Duration, withMaxTTL bool) {
+	flags.String(consts.DescriptionFlagName, "", "the purpose of issuing")
+	flags.StringSlice(consts.AllowedCIDRFlagName, nil, "CIDR which the credential is allowed to be used from, can be repeated")
+	flags.StringSlice(consts.AllowedRoleFlagName, nil, "role which the credential is allowed to be used with, can be repeated")
+	flags.Duration(consts.TTLFlagName, ttl, "TTL of the credential")
+	if withMaxTTL {
+		flags.Duration(consts.MaxTTLFlagName, ttl, "max TTL of the credential")
+	}
+}
+
+// CredentialFromFlags returns fields passed by flags added by AddCredentialFlags
+func CredentialFromFlags(flags *pflag.FlagSet) (*Credential, error) {
+	c := &Credential{}
+	var err error
+	if c.Description, err = flags.GetString(consts.DescriptionFlagName); err != nil {
+		return nil, err
+	}
+	if c.CIDRs, err = flags.GetStringSlice(consts.AllowedCIDRFlagName); err != nil {
+		return nil, err
+	}
+	if c.Roles, err = flags.GetStringSlice(consts.AllowedRoleFlagName); err != nil {
+		return nil, err
+	}
+	if c.TTL, err = flags.GetDuration(consts.TTLFlagName); err != nil {
+		return nil, err
+	}
+	if flags.Lookup(consts.MaxTTLFlagName) != nil {
+		if c.MaxTTL, err = flags.GetDuration(consts.MaxTTLFlagName); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func addMembersFlags(flags *pflag.FlagSet, of string) {
+	flags.StringSlice(consts.UserFlagName, nil, "identifier or uuid of the user member of the "+of+
+		", can be repeated, member flags replace all members")
+	flags.StringSlice(consts.GroupFlagName, nil, "identifier or uuid of the group member of the "+of+", can be repeated")
+	flags.StringSlice(consts.ServiceAccountFlagName, nil, "identifier or uuid of the service account member of the "+of+
+		", can be repeated")
+}
+
+func applyMembersFlags(flags *pflag.FlagSet, r *Resolver, members *[]iam.MemberNotation) error {
+	if !flags.Changed(consts.UserFlagName) && !flags.Changed(consts.GroupFlagName) &&
+		!flags.Changed(consts.ServiceAccountFlagName) {
+		return nil
+	}
+	users, err := flags.GetStringSlice(consts.UserFlagName)
+	if err != nil {
+		return err
+	}
+	groups, err := flags.GetStringSlice(consts.GroupFlagName)
+	if err != nil {
+		return err
+	}
+	serviceAccounts, err := flags.GetStringSlice(consts.ServiceAccountFlagName)
+	if err != nil {
+		return err
+	}
+	resolved, err := r.Members(users, groups, serviceAccounts)
+	if err != nil {
+		return err
+	}
+	*members = resolved
+	return nil
+}
+
+func applyString(flags *pflag.FlagSet, name string, field *string) error {
+	if !flags.Changed(name) {
+		return nil
+	}
+	value, err := flags.GetString(name)
+	if err != nil {
+		return err
+	}
+	*field = value
+	return nil
+}
+
+func applyStringSlice(flags *pflag.FlagSet, name string, field *[]string) error {
+	if !flags.Changed(name) {
+		return nil
+	}
+	value, err := flags.GetStringSlice(name)
+	if err != nil {
+		return err
+	}
+	*field = value
+	return nil
+}
+
+func applyBool(flags *pflag.FlagSet, name string, field *bool) error {
+	if !flags.Changed(name) {
+		return nil
+	}
+	value, err := flags.GetBool(name)
+	if err != nil {
+		return err
+	}
+	*field = value
+	return nil
+}
+
+func applyDuration(flags *pflag.FlagSet, name string, field *time.Duration) error {
+	if !flags.Changed(name) {
+		return nil
+	}
+	value, err := flags.GetDuration(name)
+	if err != nil {
+		return err
+	}
+	*field = value
+	return nil
+}
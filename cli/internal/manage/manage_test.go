@@ -0,0 +1,68 @@
+package manage
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/cli/pkg"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+)
+
+type fakeClient struct {
+	pkg.VaultClient
+	requests int
+}
+
+func (c *fakeClient) GetTenants() ([]iam.Tenant, error) {
+	return []iam.Tenant{{UUID: "t1", Identifier: "first_tenant"}}, nil
+}
+
+func (c *fakeClient) GetUsers(iam.TenantUUID) ([]iam.User, error) {
+	c.requests++
+	return []iam.User{{UUID: "u1", Identifier: "alice", FullIdentifier: "alice@first_tenant"}}, nil
+}
+
+func (c *fakeClient) GetGroups(iam.TenantUUID) ([]iam.Group, error) {
+	return []iam.Group{{UUID: "g1", Identifier: "admins", FullIdentifier: "group.admins@first_tenant"}}, nil
+}
+
+func Test_Resolver(t *testing.T) {
+	cl := &fakeClient{}
+	_, err := NewResolver(cl, "unknown")
+	require.True(t, errors.Is(err, consts.ErrNotFound))
+	r, err := NewResolver(cl, "first_tenant")
+	require.NoError(t, err)
+	assert.Equal(t, "t1", r.TenantUUID)
+
+	members, err := r.Members([]string{"alice", "u1", "alice@first_tenant"}, []string{"admins"}, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, []iam.MemberNotation{{Type: iam.UserType, UUID: "u1"}, {Type: iam.GroupType, UUID: "g1"}}, members)
+	assert.Equal(t, 1, cl.requests)
+	_, err = r.UserUUID("bob")
+	require.EqualError(t, err, `user "bob": not found`)
+}
+
+func Test_Print(t *testing.T) {
+	users := []iam.User{
+		{UUID: "u1", FullIdentifier: "alice@first_tenant", Email: "alice@example.com"},
+		{UUID: "u2", FullIdentifier: "bob@first_tenant"},
+	}
+
+	out := &bytes.Buffer{}
+	require.NoError(t, Print(out, "table", users))
+	assert.Equal(t, "UUID  FULL_IDENTIFIER     DISPLAY_NAME  EMAIL\n"+
+		"u1    alice@first_tenant                alice@example.com\n"+
+		"u2    bob@first_tenant                  \n", out.String())
+
+	out.Reset()
+	require.NoError(t, Print(out, "yaml", &users[1]))
+	assert.Contains(t, out.String(), "full_identifier: bob@first_tenant\n")
+
+	require.EqualError(t, Print(out, "xml", users), `wrong output format "xml", available values: [ table | json | yaml ]`)
+}
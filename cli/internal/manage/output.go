@@ -0,0 +1,155 @@
+package manage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/invopop/yaml"
+
+	"github.com/flant/negentropy/cli/pkg"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+)
+
+// MultipassWithToken is a just issued multipass, the token is shown only once
+type MultipassWithToken struct {
+	iam.Multipass
+	Token iam.MultipassJWT `json:"token"`
+}
+
+// Print writes the object or the slice of objects in the output format: [ table | json | yaml ], table is the default
+func Print(w io.Writer, output string, objects interface{}) error {
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(objects, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+	case "yaml":
+		data, err := yaml.Marshal(objects)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, string(data))
+	case "", "table":
+		return printTable(w, objects)
+	default:
+		return fmt.Errorf("wrong output format %q, available values: [ table | json | yaml ]", output)
+	}
+	return nil
+}
+
+func printTable(w io.Writer, objects interface{}) error {
+	value := reflect.ValueOf(objects)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	items := []interface{}{value.Interface()}
+	if value.Kind() == reflect.Slice {
+		items = make([]interface{}, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, value.Index(i).Interface())
+		}
+	}
+
+	var header []string
+	var rows [][]string
+	for _, item := range items {
+		h, row, err := tableRow(item)
+		if err != nil {
+			return err
+		}
+		header = h
+		rows = append(rows, row)
+	}
+	if header == nil {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// tableRow returns columns and values of the object
+func tableRow(object interface{}) ([]string, []string, error) {
+	switch o := object.(type) {
+	case iam.User:
+		return []string{"UUID", "FULL_IDENTIFIER", "DISPLAY_NAME", "EMAIL"},
+			[]string{o.UUID, o.FullIdentifier, o.DisplayName, o.Email}, nil
+	case iam.Group:
+		return []string{"UUID", "FULL_IDENTIFIER", "MEMBERS"},
+			[]string{o.UUID, o.FullIdentifier, strconv.Itoa(len(o.Members))}, nil
+	case iam.ServiceAccount:
+		return []string{"UUID", "FULL_IDENTIFIER", "ALLOWED_CIDRS", "TOKEN_TTL", "TOKEN_MAX_TTL"},
+			[]string{o.UUID, o.FullIdentifier, list(o.CIDRs), o.TokenTTL.String(), o.TokenMaxTTL.String()}, nil
+	case pkg.RoleBinding:
+		members := make([]string, 0, len(o.Members))
+		for _, m := range o.Members {
+			members = append(members, m.Type+":"+firstNotEmpty(m.FullIdentifier, m.UUID))
+		}
+		roles := make([]string, 0, len(o.Roles))
+		for _, r := range o.Roles {
+			roles = append(roles, r.Name)
+		}
+		projects := "any"
+		if !o.AnyProject {
+			names := make([]string, 0, len(o.Projects))
+			for _, p := range o.Projects {
+				names = append(names, firstNotEmpty(p.Identifier, p.UUID))
+			}
+			projects = list(names)
+		}
+		return []string{"UUID", "DESCRIPTION", "MEMBERS", "ROLES", "PROJECTS", "DENY", "VALID_TILL"},
+			[]string{o.UUID, o.Description, list(members), list(roles), projects, strconv.FormatBool(o.Deny),
+				validTill(o.ValidTill)}, nil
+	case iam.Multipass:
+		return []string{"UUID", "OWNER", "DESCRIPTION", "ALLOWED_ROLES", "ALLOWED_CIDRS", "VALID_TILL"},
+			[]string{o.UUID, o.OwnerType + ":" + o.OwnerUUID, o.Description, list(o.Roles), list(o.CIDRs),
+				validTill(o.ValidTill)}, nil
+	case MultipassWithToken:
+		header, row, _ := tableRow(o.Multipass)
+		return append(header, "TOKEN"), append(row, o.Token), nil
+	case iam.ServiceAccountPassword:
+		header := []string{"UUID", "DESCRIPTION", "ALLOWED_ROLES", "ALLOWED_CIDRS", "VALID_TILL"}
+		row := []string{o.UUID, o.Description, list(o.Roles), list(o.CIDRs), validTill(o.ValidTill)}
+		if o.Secret != "" {
+			return append(header, "SECRET"), append(row, o.Secret), nil
+		}
+		return header, row, nil
+	default:
+		return nil, nil, fmt.Errorf("table output is not supported for %T", object)
+	}
+}
+
+func list(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return strings.Join(items, ",")
+}
+
+func firstNotEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func validTill(unix int64) string {
+	if unix == 0 {
+		return "forever"
+	}
+	return time.Unix(unix, 0).Format(time.RFC3339)
+}
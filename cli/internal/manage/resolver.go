@@ -0,0 +1,149 @@
+package manage
+
+import (
+	"fmt"
+
+	"github.com/flant/negentropy/cli/pkg"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	auth "github.com/flant/negentropy/vault-plugins/flant_iam_auth/extensions/extension_server_access/model"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+)
+
+// Resolver resolves identifiers of objects of the tenant into their UUIDs,
+// every kind of objects is requested from vault only once
+type Resolver struct {
+	Client     pkg.VaultClient
+	TenantUUID iam.TenantUUID
+
+	users           []iam.User
+	groups          []iam.Group
+	serviceAccounts []iam.ServiceAccount
+	projects        []auth.Project
+}
+
+// NewResolver finds the tenant by its identifier or uuid
+func NewResolver(cl pkg.VaultClient, tenant string) (*Resolver, error) {
+	tenants, err := cl.GetTenants()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tenants {
+		if t.UUID == tenant || t.Identifier == tenant {
+			return &Resolver{Client: cl, TenantUUID: t.UUID}, nil
+		}
+	}
+	return nil, fmt.Errorf("tenant %q: %w", tenant, consts.ErrNotFound)
+}
+
+// UserUUID accepts uuid, identifier or full identifier of the user
+func (r *Resolver) UserUUID(user string) (iam.UserUUID, error) {
+	if r.users == nil {
+		users, err := r.Client.GetUsers(r.TenantUUID)
+		if err != nil {
+			return "", err
+		}
+		r.users = users
+	}
+	for _, u := range r.users {
+		if u.UUID == user || u.Identifier == user || u.FullIdentifier == user {
+			return u.UUID, nil
+		}
+	}
+	return "", fmt.Errorf("user %q: %w", user, consts.ErrNotFound)
+}
+
+// GroupUUID accepts uuid, identifier or full identifier of the group
+func (r *Resolver) GroupUUID(group string) (iam.GroupUUID, error) {
+	if r.groups == nil {
+		groups, err := r.Client.GetGroups(r.TenantUUID)
+		if err != nil {
+			return "", err
+		}
+		r.groups = groups
+	}
+	for _, g := range r.groups {
+		if g.UUID == group || g.Identifier == group || g.FullIdentifier == group {
+			return g.UUID, nil
+		}
+	}
+	return "", fmt.Errorf("group %q: %w", group, consts.ErrNotFound)
+}
+
+// ServiceAccountUUID accepts uuid, identifier or full identifier of the service account
+func (r *Resolver) ServiceAccountUUID(serviceAccount string) (iam.ServiceAccountUUID, error) {
+	if r.serviceAccounts == nil {
+		serviceAccounts, err := r.Client.GetServiceAccounts(r.TenantUUID)
+		if err != nil {
+			return "", err
+		}
+		r.serviceAccounts = serviceAccounts
+	}
+	for _, sa := range r.serviceAccounts {
+		if sa.UUID == serviceAccount || sa.Identifier == serviceAccount || sa.FullIdentifier == serviceAccount {
+			return sa.UUID, nil
+		}
+	}
+	return "", fmt.Errorf("service account %q: %w", serviceAccount, consts.ErrNotFound)
+}
+
+// ProjectUUIDs accepts uuids or identifiers of projects of the tenant
+func (r *Resolver) ProjectUUIDs(projects []string) ([]iam.ProjectUUID, error) {
+	if r.projects == nil && len(projects) > 0 {
+		tenantProjects, err := r.Client.GetProjects(r.TenantUUID)
+		if err != nil {
+			return nil, err
+		}
+		r.projects = tenantProjects
+	}
+	uuids := make([]iam.ProjectUUID, 0, len(projects))
+	for _, project := range projects {
+		found := false
+		for _, p := range r.projects {
+			if p.UUID == project || p.Identifier == project {
+				uuids = append(uuids, p.UUID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("project %q: %w", project, consts.ErrNotFound)
+		}
+	}
+	return uuids, nil
+}
+
+// Members returns notations of members passed by their uuids or identifiers, a member is returned once,
+// even if it is passed several times
+func (r *Resolver) Members(users, groups, serviceAccounts []string) ([]iam.MemberNotation, error) {
+	members := make([]iam.MemberNotation, 0, len(users)+len(groups)+len(serviceAccounts))
+	added := map[iam.MemberNotation]struct{}{}
+	add := func(memberType string, uuid string) {
+		member := iam.MemberNotation{Type: memberType, UUID: uuid}
+		if _, ok := added[member]; !ok {
+			added[member] = struct{}{}
+			members = append(members, member)
+		}
+	}
+	for _, user := range users {
+		uuid, err := r.UserUUID(user)
+		if err != nil {
+			return nil, err
+		}
+		add(iam.UserType, uuid)
+	}
+	for _, group := range groups {
+		uuid, err := r.GroupUUID(group)
+		if err != nil {
+			return nil, err
+		}
+		add(iam.GroupType, uuid)
+	}
+	for _, serviceAccount := range serviceAccounts {
+		uuid, err := r.ServiceAccountUUID(serviceAccount)
+		if err != nil {
+			return nil, err
+		}
+		add(iam.ServiceAccountType, uuid)
+	}
+	return members, nil
+}
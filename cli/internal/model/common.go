@@ -104,6 +104,46 @@ func (s *StringSet) IsEmpty() bool {
 	return len(*s) == 0
 }
 
+// ServerFilterFromFlags collects the ServerFilter from flags of commands working with servers,
+// all servers are addressed if serverIdentifiers are not passed
+func ServerFilterFromFlags(flags *pflag.FlagSet, serverIdentifiers []string) (ServerFilter, error) {
+	var (
+		filter ServerFilter
+		err    error
+	)
+	filter.AllTenants, err = flags.GetBool(consts.AllTenantsFlagName)
+	if err != nil {
+		return ServerFilter{}, err
+	}
+	filter.AllProjects, err = flags.GetBool(consts.AllProjectsFlagName)
+	if err != nil {
+		return ServerFilter{}, err
+	}
+	filter.TenantIdentifiers, err = StringSetFromStringFlag(flags, consts.TenantFlagName)
+	if err != nil {
+		return ServerFilter{}, err
+	}
+	filter.ProjectIdentifiers, err = StringSetFromStringFlag(flags, consts.ProjectFlagName)
+	if err != nil {
+		return ServerFilter{}, err
+	}
+	filter.LabelSelector, err = flags.GetString(consts.LabelsFlagName)
+	if err != nil {
+		return ServerFilter{}, err
+	}
+	filter.AllServers, err = flags.GetBool(consts.AllServersFlagName)
+	if err != nil {
+		return ServerFilter{}, err
+	}
+	filter.ServerIdentifiers = serverIdentifiers
+
+	if len(filter.ServerIdentifiers) == 0 {
+		filter.AllServers = true
+	}
+
+	return filter, filter.Validate()
+}
+
 func StringSetFromStringFlag(flags *pflag.FlagSet, flagName string) (StringSet, error) {
 	s, err := flags.GetString(flagName)
 	if err != nil {
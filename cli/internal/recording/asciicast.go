@@ -0,0 +1,88 @@
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Header is the first line of the asciicast v2 file: https://github.com/asciinema/asciinema/blob/develop/doc/asciicast-v2.md
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Writer writes the terminal output as events of asciicast v2, it is safe for concurrent use
+type Writer struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	// incomplete is the tail of the previous output, which is not a full utf-8 rune
+	incomplete []byte
+}
+
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	start := time.Now()
+	header.Version = 2
+	if header.Timestamp == 0 {
+		header.Timestamp = start.Unix()
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("writing asciicast header: %w", err)
+	}
+	return &Writer{w: w, start: start}, nil
+}
+
+// Write writes p as an output event, a rune split between calls is written with the next event
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data := append(w.incomplete, p...)
+	cut := len(data)
+	for i := 0; i < utf8.UTFMax && cut-i > 0; i++ {
+		if utf8.RuneStart(data[cut-i-1]) {
+			if !utf8.FullRune(data[cut-i-1:]) {
+				cut = cut - i - 1
+			}
+			break
+		}
+	}
+	w.incomplete = append([]byte{}, data[cut:]...)
+	if cut == 0 {
+		return len(p), nil
+	}
+
+	if err := w.event("o", string(data[:cut])); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Resize writes the resize event of the terminal
+func (w *Writer) Resize(width, height int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+func (w *Writer) event(code, data string) error {
+	elapsed := time.Since(w.start).Seconds()
+	line, err := json.Marshal([]interface{}{elapsed, code, data})
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(append(line, '\n'))
+	return err
+}
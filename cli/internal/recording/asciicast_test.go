@@ -0,0 +1,47 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WriterEvents(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, Header{Width: 80, Height: 24, Title: "session"})
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("ls\r\n"))
+	require.NoError(t, err)
+	// "привет" split in the middle of the second rune
+	greeting := []byte("привет")
+	_, err = w.Write(greeting[:3])
+	require.NoError(t, err)
+	_, err = w.Write(greeting[3:])
+	require.NoError(t, err)
+	require.NoError(t, w.Resize(100, 30))
+
+	scanner := bufio.NewScanner(buf)
+	require.True(t, scanner.Scan())
+	var header Header
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &header))
+	assert.Equal(t, 2, header.Version)
+	assert.Equal(t, 80, header.Width)
+	assert.NotZero(t, header.Timestamp)
+
+	var events [][]interface{}
+	for scanner.Scan() {
+		var event []interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.Len(t, events, 4)
+	assert.Equal(t, []interface{}{"o", "ls\r\n"}, events[0][1:])
+	assert.Equal(t, []interface{}{"o", "п"}, events[1][1:])
+	assert.Equal(t, []interface{}{"o", "ривет"}, events[2][1:])
+	assert.Equal(t, []interface{}{"r", "100x30"}, events[3][1:])
+}
@@ -0,0 +1,141 @@
+//go:build linux
+// +build linux
+
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Run starts cmd at a new pseudo terminal: stdin is passed into the terminal in the raw mode,
+// the output of the terminal is written into stdout and the recording
+func Run(cmd *exec.Cmd, rec *Writer) error {
+	master, slave, err := openPTY()
+	if err != nil {
+		return fmt.Errorf("open pty: %w", err)
+	}
+	defer master.Close()
+
+	stdin := int(os.Stdin.Fd())
+	isTerminal := true
+	if err = copyWinsize(stdin, int(master.Fd()), nil); err != nil {
+		isTerminal = false
+	}
+
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	err = cmd.Start()
+	slave.Close()
+	if err != nil {
+		return err
+	}
+
+	if isTerminal {
+		restore, err := makeRaw(stdin)
+		if err != nil {
+			return fmt.Errorf("terminal raw mode: %w", err)
+		}
+		defer restore()
+
+		resized := make(chan os.Signal, 1)
+		signal.Notify(resized, syscall.SIGWINCH)
+		defer signal.Stop(resized)
+		go func() {
+			for range resized {
+				_ = copyWinsize(stdin, int(master.Fd()), rec)
+			}
+		}()
+	}
+
+	go func() {
+		_, _ = io.Copy(master, os.Stdin)
+	}()
+
+	_, err = io.Copy(io.MultiWriter(os.Stdout, rec), master)
+	// the master returns EIO after the last process of the session closes the terminal
+	if err != nil && !errors.Is(err, syscall.EIO) {
+		_ = cmd.Wait()
+		return fmt.Errorf("copy output: %w", err)
+	}
+	return cmd.Wait()
+}
+
+// Size returns the width and height of the terminal at stdin, or 80x24 if stdin is not a terminal
+func Size() (int, int) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdin.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 80, 24
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fd := int(master.Fd())
+	if err = unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+func copyWinsize(from, to int, rec *Writer) error {
+	ws, err := unix.IoctlGetWinsize(from, unix.TIOCGWINSZ)
+	if err != nil {
+		return err
+	}
+	if err = unix.IoctlSetWinsize(to, unix.TIOCSWINSZ, ws); err != nil {
+		return err
+	}
+	if rec != nil {
+		return rec.Resize(int(ws.Col), int(ws.Row))
+	}
+	return nil
+}
+
+// makeRaw switches the terminal into the raw mode as cfmakeraw(3), and returns the function to restore the mode
+func makeRaw(fd int) (func(), error) {
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+	saved := *termios
+
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB
+	termios.Cflag |= unix.CS8
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err = unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = unix.IoctlSetTermios(fd, unix.TCSETS, &saved)
+	}, nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package recording
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Run is not implemented for this platform, pseudo terminals are opened only at linux
+func Run(_ *exec.Cmd, _ *Writer) error {
+	return errors.New("recording of sessions is supported only at linux")
+}
+
+// Size returns the default size of the terminal
+func Size() (int, int) {
+	return 80, 24
+}
@@ -0,0 +1,132 @@
+package ssh_session
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/flant/negentropy/cli/internal/recording"
+	"github.com/flant/negentropy/cli/internal/vault"
+	"github.com/flant/negentropy/cli/pkg"
+	ext "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/model"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	auth "github.com/flant/negentropy/vault-plugins/flant_iam_auth/extensions/extension_server_access/model"
+)
+
+func (s *Session) addCertificate(cert *ssh.Certificate, tenantUUID iam.TenantUUID, projectUUID iam.ProjectUUID,
+	serverUUIDs []ext.ServerUUID) {
+	s.certificatesMutex.Lock()
+	defer s.certificatesMutex.Unlock()
+	s.certificates = append(s.certificates, auth.SSHSessionCertificate{
+		Serial:      strconv.FormatUint(cert.Serial, 10),
+		TenantUUID:  tenantUUID,
+		ProjectUUID: projectUUID,
+		ServerUUIDs: append([]ext.ServerUUID{}, serverUUIDs...),
+		ValidBefore: time.Unix(int64(cert.ValidBefore), 0).UTC(),
+		Certificate: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert))),
+	})
+}
+
+// reportSession passes new certificates to the audit, and the recording at the end of the session.
+// Errors are only logged, the session is not interrupted if the audit is not available
+func (s *Session) reportSession(finishedAt *time.Time) {
+	s.certificatesMutex.Lock()
+	pending := append([]auth.SSHSessionCertificate{}, s.certificates[s.reportedCertificates:]...)
+	if len(pending) == 0 && finishedAt != nil && len(s.certificates) > 0 {
+		// the last certificate is needed to claim the role for the final report
+		pending = append(pending, s.certificates[len(s.certificates)-1])
+	}
+	total := len(s.certificates)
+	s.certificatesMutex.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	report := pkg.SSHSessionReport{
+		StartedAt:    s.StartedAt,
+		FinishedAt:   finishedAt,
+		Certificates: pending,
+	}
+	var recordingPath string
+	if finishedAt != nil && s.RecordingsDir != "" {
+		recordingPath = s.recordingPath()
+		data, err := os.ReadFile(recordingPath)
+		if err != nil {
+			log.Printf("WARNING: reading the recording: %s", err)
+			recordingPath = ""
+		} else {
+			report.Recording = base64.StdEncoding.EncodeToString(data)
+		}
+	}
+
+	vaultService, err := vault.NewService()
+	if err != nil {
+		log.Printf("WARNING: reporting the ssh session: %s", err)
+		return
+	}
+	stored, warnings, err := vaultService.ReportSSHSession(s.UUID, report)
+	if err != nil {
+		log.Printf("WARNING: reporting the ssh session: %s", err)
+		if recordingPath != "" {
+			log.Printf("The recording is kept at %s", recordingPath)
+		}
+		return
+	}
+	for _, warning := range warnings {
+		log.Printf("WARNING: reporting the ssh session: %s", warning)
+	}
+
+	s.certificatesMutex.Lock()
+	s.reportedCertificates = total
+	s.certificatesMutex.Unlock()
+
+	if recordingPath == "" {
+		return
+	}
+	if stored.Recording == nil {
+		log.Printf("The recording is kept at %s", recordingPath)
+		return
+	}
+	if err = os.Remove(recordingPath); err != nil {
+		log.Printf("WARNING: removing the uploaded recording: %s", err)
+	}
+}
+
+func (s *Session) recordShell(cmd *exec.Cmd) error {
+	err := os.MkdirAll(s.RecordingsDir, 0o700)
+	if err != nil {
+		return fmt.Errorf("recordShell: %w", err)
+	}
+	file, err := os.OpenFile(s.recordingPath(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("recordShell: %w", err)
+	}
+	defer file.Close()
+
+	width, height := recording.Size()
+	rec, err := recording.NewWriter(file, recording.Header{
+		Width:     width,
+		Height:    height,
+		Timestamp: s.StartedAt.Unix(),
+		Title:     fmt.Sprintf("flint ssh session %s of %s", s.UUID, s.User.FullIdentifier),
+		Env:       map[string]string{"SHELL": "/bin/bash", "TERM": os.Getenv("TERM")},
+	})
+	if err != nil {
+		return fmt.Errorf("recordShell: %w", err)
+	}
+
+	log.Printf("The session is recorded")
+	return recording.Run(cmd, rec)
+}
+
+func (s *Session) recordingPath() string {
+	return filepath.Join(s.RecordingsDir, s.UUID+".cast")
+}
@@ -0,0 +1,180 @@
+package ssh_session
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	ext "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/model"
+)
+
+// HostResult is the result of the non-interactive command at one server
+type HostResult struct {
+	// Host is the alias of the server at the ssh config: PROJECT_IDENTIFIER.SERVER_IDENTIFIER
+	Host       string         `json:"host"`
+	ServerUUID ext.ServerUUID `json:"server_uuid"`
+	ExitCode   int            `json:"exit_code"`
+	Stdout     string         `json:"stdout"`
+	Stderr     string         `json:"stderr"`
+	// Error is set, if ssh or scp is not started
+	Error string `json:"error,omitempty"`
+}
+
+func (r HostResult) Failed() bool {
+	return r.ExitCode != 0 || r.Error != ""
+}
+
+// PrintHostResults writes results as json or as blocks of the output per host, and returns an error if some hosts are failed
+func PrintHostResults(w io.Writer, results []HostResult, output string) error {
+	switch output {
+	case "json":
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+	case "", "text":
+		for _, r := range results {
+			fmt.Fprintf(w, "==> %s (exit code %d)\n", r.Host, r.ExitCode)
+			fmt.Fprint(w, r.Stdout)
+			fmt.Fprint(w, r.Stderr)
+			if r.Error != "" {
+				fmt.Fprintln(w, r.Error)
+			}
+		}
+	default:
+		return fmt.Errorf("wrong output format %q, available values: [ text | json ]", output)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed at %d of %d servers", failed, len(results))
+	}
+	return nil
+}
+
+// Exec runs the command at all servers of the opened session, no more than parallel at once
+func (s *Session) Exec(command string, parallel int) []HostResult {
+	return s.runAtHosts(parallel, func(host string) (*exec.Cmd, error) {
+		args := append(s.sshOptions(), host, "--", command)
+		return exec.Command("ssh", args...), nil
+	})
+}
+
+// Upload copies local sources into remotePath at all servers of the opened session
+func (s *Session) Upload(sources []string, remotePath string, recursive bool, parallel int) []HostResult {
+	return s.runAtHosts(parallel, func(host string) (*exec.Cmd, error) {
+		args := s.scpOptions(recursive)
+		args = append(args, sources...)
+		args = append(args, host+":"+remotePath)
+		return exec.Command("scp", args...), nil
+	})
+}
+
+// Download copies remotePath from all servers of the opened session into localDir/<host>
+func (s *Session) Download(remotePath string, localDir string, recursive bool, parallel int) []HostResult {
+	return s.runAtHosts(parallel, func(host string) (*exec.Cmd, error) {
+		hostDir := filepath.Join(localDir, host)
+		if err := os.MkdirAll(hostDir, os.ModePerm); err != nil {
+			return nil, err
+		}
+		args := append(s.scpOptions(recursive), host+":"+remotePath, hostDir+"/")
+		return exec.Command("scp", args...), nil
+	})
+}
+
+// Hosts returns aliases of servers of the session by their UUIDs
+func (s *Session) Hosts() map[string]ext.ServerUUID {
+	hosts := make(map[string]ext.ServerUUID, len(s.ServerList.Servers))
+	for uuid, server := range s.ServerList.Servers {
+		project := s.ServerList.Projects[server.ProjectUUID]
+		hosts[project.Identifier+"."+server.Identifier] = uuid
+	}
+	return hosts
+}
+
+func (s *Session) runAtHosts(parallel int, newCmd func(host string) (*exec.Cmd, error)) []HostResult {
+	hosts := s.Hosts()
+	names := make([]string, 0, len(hosts))
+	for host := range hosts {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]HostResult, len(names))
+	semaphore := make(chan struct{}, parallel)
+	wg := sync.WaitGroup{}
+	for i, host := range names {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, host string) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			results[i] = s.runAtHost(host, hosts[host], newCmd)
+		}(i, host)
+	}
+	wg.Wait()
+	return results
+}
+
+func (s *Session) runAtHost(host string, serverUUID ext.ServerUUID, newCmd func(host string) (*exec.Cmd, error)) HostResult {
+	result := HostResult{Host: host, ServerUUID: serverUUID}
+	cmd, err := newCmd(host)
+	if err != nil {
+		result.ExitCode = -1
+		result.Error = err.Error()
+		return result
+	}
+
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "SSH_AUTH_SOCK="+s.SSHAgentSocketPath)
+	err = cmd.Run()
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		result.ExitCode = -1
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// sshOptions makes ssh and scp use the config and known hosts of the session, and never ask for passwords
+func (s *Session) sshOptions() []string {
+	return []string{
+		"-F", s.SSHConfigFile.Name(),
+		"-o", "UserKnownHostsFile=" + s.KnownHostsFile.Name(),
+		"-o", "BatchMode=yes",
+	}
+}
+
+func (s *Session) scpOptions(recursive bool) []string {
+	args := append(s.sshOptions(), "-q")
+	if recursive {
+		args = append(args, "-r")
+	}
+	return args
+}
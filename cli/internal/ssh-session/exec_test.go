@@ -0,0 +1,43 @@
+package ssh_session
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/cli/internal/model"
+	ext "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/model"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+)
+
+func Test_RunAtHosts(t *testing.T) {
+	s := Session{ServerList: &model.ServerList{
+		Projects: map[iam.ProjectUUID]iam.Project{"p1": {UUID: "p1", Identifier: "main"}},
+		Servers: map[ext.ServerUUID]ext.Server{
+			"s1": {UUID: "s1", ProjectUUID: "p1", Identifier: "db-1"},
+			"s2": {UUID: "s2", ProjectUUID: "p1", Identifier: "db-2"},
+			"s3": {UUID: "s3", ProjectUUID: "p1", Identifier: "web-1"},
+		},
+	}}
+
+	results := s.runAtHosts(2, func(host string) (*exec.Cmd, error) {
+		if host == "main.web-1" {
+			return exec.Command("sh", "-c", "echo failed >&2; exit 3"), nil
+		}
+		return exec.Command("sh", "-c", "echo $0", host), nil
+	})
+
+	require.Len(t, results, 3)
+	assert.Equal(t, HostResult{Host: "main.db-1", ServerUUID: "s1", Stdout: "main.db-1\n"}, results[0])
+	assert.Equal(t, HostResult{Host: "main.db-2", ServerUUID: "s2", Stdout: "main.db-2\n"}, results[1])
+	assert.Equal(t, HostResult{Host: "main.web-1", ServerUUID: "s3", ExitCode: 3, Stderr: "failed\n"}, results[2])
+
+	out := &bytes.Buffer{}
+	err := PrintHostResults(out, results, "text")
+	require.EqualError(t, err, "failed at 1 of 3 servers")
+	assert.Equal(t, "==> main.db-1 (exit code 0)\nmain.db-1\n==> main.db-2 (exit code 0)\nmain.db-2\n"+
+		"==> main.web-1 (exit code 3)\nfailed\n", out.String())
+}
@@ -38,7 +38,7 @@ Host {{.Project.Identifier}}.{{.Server.Identifier}}
   Port {{.Server.ConnectionInfo.Port}}
 {{- end }}
 {{- if .Server.ConnectionInfo.JumpHostname }}
-  ProxyCommand ssh {{.ServerConnectionInfo.JumpHostname}} -W %h:%p
+  ProxyCommand ssh {{.Server.ConnectionInfo.JumpHostname}} -W %h:%p
 {{- end }}
 
 `)
@@ -52,7 +52,7 @@ Host {{.Project.Identifier}}.{{.Server.Identifier}}
   Port {{.Server.ConnectionInfo.Port}}
 {{- end }}
 {{- if .Server.ConnectionInfo.JumpHostname }}
-  ProxyCommand ssh {{.ServerConnectionInfo.JumpHostname}} -W %h:%p
+  ProxyCommand ssh {{.Server.ConnectionInfo.JumpHostname}} -W %h:%p
 {{- end }}
 
 `)
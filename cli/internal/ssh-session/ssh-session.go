@@ -11,6 +11,7 @@ import (
 	"os/signal"
 	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -52,7 +53,18 @@ type Session struct {
 	SSHConfigFile      *os.File
 	KnownHostsFile     *os.File
 	BashRCFile         *os.File
-	cacheTTL           time.Duration
+	// RecordingsDir is set to record the shell into the asciicast file at this dir
+	RecordingsDir string
+	StartedAt     time.Time
+	cacheTTL      time.Duration
+
+	// certificates are signed during the session, they are reported for audit
+	certificatesMutex    sync.Mutex
+	certificates         []auth.SSHSessionCertificate
+	reportedCertificates int
+
+	finishOnce sync.Once
+	finishErr  error
 }
 
 func (s *Session) Close() error {
@@ -192,6 +204,7 @@ func (s *Session) signCertificate(privateRSA *rsa.PrivateKey, pubkey ssh.PublicK
 		return nil, fmt.Errorf("signCertificates: %w", err)
 	}
 	signedPublicSSHCert := ak.(*ssh.Certificate)
+	s.addCertificate(signedPublicSSHCert, tenantUUID, projectUUID, serverUUIDs)
 
 	return &agent.AddedKey{
 		PrivateKey:   privateRSA,
@@ -236,7 +249,7 @@ func (s *Session) generateAndSignSSHCertificateSetForServers(servers []ext.Serve
 				idBucket = serverIdentifiers[start:]
 			} else {
 				uuidsBucket = serverUUIDs[start : start+maxSize]
-				uuidsBucket = serverIdentifiers[start : start+maxSize]
+				idBucket = serverIdentifiers[start : start+maxSize]
 			}
 			key, err := s.signCertificate(privateRSA, pubkey, tenantUUID, projectUUID, uuidsBucket, idBucket)
 			if err != nil {
@@ -295,7 +308,7 @@ func (s *Session) StartSSHAgent() error {
 		sig := <-c
 		log.Printf("Caught signal %s: shutting down.", sig)
 		agentListener.Close()
-		s.Close()
+		s.Finish()
 		os.Exit(0)
 	}(sigc)
 
@@ -323,11 +336,18 @@ func (s *Session) StartShell() error {
 	os.Setenv("FLINT_SESSION_UUID", s.UUID)
 
 	cmd := exec.Command("/bin/bash", "--rcfile", s.BashRCFile.Name(), "-i")
+	cmd.Env = os.Environ()
+	if s.RecordingsDir != "" {
+		err = s.recordShell(cmd)
+		if err != nil {
+			return fmt.Errorf("StartShell: %w", err)
+		}
+		return nil
+	}
 
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	cmd.Env = os.Environ()
 	err = cmd.Run()
 	if err != nil {
 		return fmt.Errorf("StartShell: %w", err)
@@ -358,11 +378,17 @@ func (s *Session) syncRoutine() error {
 func (s *Session) syncRoutineEveryMinute() {
 	for {
 		time.Sleep(time.Minute)
-		s.syncRoutine()
+		err := s.syncRoutine()
+		if err == nil {
+			s.reportSession(nil)
+		}
 	}
 }
 
-func (s *Session) Start() error {
+// Open starts ssh-agent, renders ssh config and signs certificates for servers of the session,
+// certificates are refreshed every minute until the session is finished
+func (s *Session) Open() error {
+	s.StartedAt = time.Now().UTC()
 	err := s.StartSSHAgent()
 	if err != nil {
 		return err
@@ -371,14 +397,34 @@ func (s *Session) Start() error {
 	if err != nil {
 		return err
 	}
+	s.reportSession(nil)
 	go s.syncRoutineEveryMinute()
+	return nil
+}
+
+// Finish reports the end of the session and removes its temporal files, the session is finished once:
+// by the end of the shell, the command or by the signal
+func (s *Session) Finish() error {
+	s.finishOnce.Do(func() {
+		finishedAt := time.Now().UTC()
+		s.reportSession(&finishedAt)
+		s.finishErr = s.Close()
+	})
+	return s.finishErr
+}
+
+func (s *Session) Start() error {
+	err := s.Open()
+	if err != nil {
+		return err
+	}
 
 	err = s.StartShell()
 	if err != nil {
+		_ = s.Finish()
 		return err
 	}
-	err = s.Close()
-	return err
+	return s.Finish()
 }
 
 func (s *Session) updateCache() error {
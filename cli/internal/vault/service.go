@@ -18,6 +18,8 @@ type VaultService interface {
 	// UpdateServersByFilter returns ServerList synchronized with vault, according filter, using given ServerList as cache
 	UpdateServersByFilter(model.ServerFilter, *model.ServerList) (*model.ServerList, error)
 	SignPublicSSHCertificate(iam.TenantUUID, iam.ProjectUUID, []ext.ServerUUID, pkg.VaultSSHSignRequest) ([]byte, error)
+	// ReportSSHSession passes the audit record of the ssh session and its recording
+	ReportSSHSession(sessionUUID string, report pkg.SSHSessionReport) (*auth.SSHSession, []string, error)
 	// UpdateTenants update oldTenants by vault requests, according specified identifiers given by args
 	UpdateTenants(map[iam.TenantUUID]iam.Tenant, model.StringSet) (map[iam.TenantUUID]iam.Tenant, error)
 	// UpdateProjects update oldProjects by vault requests, according specified identifiers given by args
@@ -71,6 +73,10 @@ func (v *vaultService) SignPublicSSHCertificate(tenantUUID iam.TenantUUID, proje
 	return v.cl.SignPublicSSHCertificate(tenantUUID, projectUUID, serverUUIDs, req)
 }
 
+func (v *vaultService) ReportSSHSession(sessionUUID string, report pkg.SSHSessionReport) (*auth.SSHSession, []string, error) {
+	return v.cl.ReportSSHSession(sessionUUID, report)
+}
+
 func (v *vaultService) updateServerListByTenantAndProject(filter model.ServerFilter, oldServerlist *model.ServerList) (*model.ServerList, error) {
 	tenants, err := v.UpdateTenants(oldServerlist.Tenants, filter.TenantIdentifiers)
 	if err != nil {
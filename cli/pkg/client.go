@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	vault_api "github.com/hashicorp/vault/api"
 
@@ -40,6 +41,51 @@ type VaultClient interface {
 	RegisterServer(server ext.Server) (ext.ServerUUID, iam.MultipassJWT, error)
 	UpdateServerConnectionInfo(tenantUUID iam.TenantUUID, projectUUID iam.ProjectUUID,
 		serverUUID ext.ServerUUID, connInfo ext.ConnectionInfo) (*ext.Server, error)
+	ReportSSHSession(sessionUUID string, report SSHSessionReport) (*auth.SSHSession, []string, error)
+
+	// flant_iam objects of the tenant, reading needs tenant.read, changing needs tenant.manage
+
+	GetUsers(tenantUUID iam.TenantUUID) ([]iam.User, error)
+	GetUserByUUID(tenantUUID iam.TenantUUID, userUUID iam.UserUUID) (*iam.User, error)
+	CreateUser(user iam.User) (*iam.User, error)
+	UpdateUser(user iam.User) (*iam.User, error)
+	DeleteUser(tenantUUID iam.TenantUUID, userUUID iam.UserUUID) error
+
+	GetGroups(tenantUUID iam.TenantUUID) ([]iam.Group, error)
+	GetGroupByUUID(tenantUUID iam.TenantUUID, groupUUID iam.GroupUUID) (*iam.Group, error)
+	CreateGroup(group iam.Group) (*iam.Group, error)
+	UpdateGroup(group iam.Group) (*iam.Group, error)
+	DeleteGroup(tenantUUID iam.TenantUUID, groupUUID iam.GroupUUID) error
+
+	GetServiceAccounts(tenantUUID iam.TenantUUID) ([]iam.ServiceAccount, error)
+	GetServiceAccountByUUID(tenantUUID iam.TenantUUID, serviceAccountUUID iam.ServiceAccountUUID) (*iam.ServiceAccount, error)
+	CreateServiceAccount(serviceAccount iam.ServiceAccount) (*iam.ServiceAccount, error)
+	UpdateServiceAccount(serviceAccount iam.ServiceAccount) (*iam.ServiceAccount, error)
+	DeleteServiceAccount(tenantUUID iam.TenantUUID, serviceAccountUUID iam.ServiceAccountUUID) error
+
+	GetRoleBindings(tenantUUID iam.TenantUUID) ([]RoleBinding, error)
+	GetRoleBindingByUUID(tenantUUID iam.TenantUUID, roleBindingUUID iam.RoleBindingUUID) (*RoleBinding, error)
+	// CreateRoleBinding creates the rolebinding valid for ttl, zero ttl means forever
+	CreateRoleBinding(roleBinding iam.RoleBinding, ttl time.Duration) (*RoleBinding, error)
+	// UpdateRoleBinding updates the rolebinding and makes it valid for ttl, zero ttl means forever
+	UpdateRoleBinding(roleBinding iam.RoleBinding, ttl time.Duration) (*RoleBinding, error)
+	DeleteRoleBinding(tenantUUID iam.TenantUUID, roleBindingUUID iam.RoleBindingUUID) error
+
+	GetMultipasses(tenantUUID iam.TenantUUID, ownerType iam.MultipassOwnerType, ownerUUID iam.OwnerUUID) ([]iam.Multipass, error)
+	GetMultipassByUUID(tenantUUID iam.TenantUUID, ownerType iam.MultipassOwnerType, ownerUUID iam.OwnerUUID,
+		multipassUUID iam.MultipassUUID) (*iam.Multipass, error)
+	// CreateMultipass issues the multipass for the owner, the JWT is returned only once
+	CreateMultipass(multipass iam.Multipass) (*iam.Multipass, iam.MultipassJWT, error)
+	DeleteMultipass(tenantUUID iam.TenantUUID, ownerType iam.MultipassOwnerType, ownerUUID iam.OwnerUUID,
+		multipassUUID iam.MultipassUUID) error
+
+	GetServiceAccountPasswords(tenantUUID iam.TenantUUID, serviceAccountUUID iam.ServiceAccountUUID) ([]iam.ServiceAccountPassword, error)
+	GetServiceAccountPasswordByUUID(tenantUUID iam.TenantUUID, serviceAccountUUID iam.ServiceAccountUUID,
+		passwordUUID iam.ServiceAccountPasswordUUID) (*iam.ServiceAccountPassword, error)
+	// CreateServiceAccountPassword generates the password, the secret is returned only once
+	CreateServiceAccountPassword(password iam.ServiceAccountPassword) (*iam.ServiceAccountPassword, error)
+	DeleteServiceAccountPassword(tenantUUID iam.TenantUUID, serviceAccountUUID iam.ServiceAccountUUID,
+		passwordUUID iam.ServiceAccountPasswordUUID) error
 }
 
 type VaultSSHSignRequest struct {
@@ -47,10 +93,21 @@ type VaultSSHSignRequest struct {
 	ValidPrincipals string `json:"valid_principals"`
 }
 
+// SSHSessionReport is passed at the start of the ssh session, after signing new certificates and at the end of the session
+type SSHSessionReport struct {
+	StartedAt    time.Time                    `json:"started_at"`
+	FinishedAt   *time.Time                   `json:"finished_at,omitempty"`
+	Certificates []auth.SSHSessionCertificate `json:"certificates"`
+	// Recording is base64 encoded asciicast
+	Recording string `json:"recording,omitempty"`
+}
+
 type vaultClient struct {
 	*vault_api.Client  // authorized client
 	roles              []authdapi.RoleWithClaim
 	allowEscalateRoles bool
+	// serverType is the type of vault, which authd logins into
+	serverType string
 }
 
 func ConfiguredVaultClient(authorizedClient *vault_api.Client, roles []authdapi.RoleWithClaim) VaultClient {
@@ -58,11 +115,19 @@ func ConfiguredVaultClient(authorizedClient *vault_api.Client, roles []authdapi.
 		Client:             authorizedClient,
 		roles:              roles,
 		allowEscalateRoles: false,
+		serverType:         authdapi.AuthServer,
 	}
 }
 
 func DefaultVaultClient() (VaultClient, error) {
-	defaultClient := &vaultClient{allowEscalateRoles: true}
+	defaultClient := &vaultClient{allowEscalateRoles: true, serverType: authdapi.AuthServer}
+	err := defaultClient.checkForRolesAndUpdateClient()
+	return defaultClient, err
+}
+
+// DefaultRootSourceVaultClient logins into the root source vault, flant_iam objects are managed only there
+func DefaultRootSourceVaultClient() (VaultClient, error) {
+	defaultClient := &vaultClient{allowEscalateRoles: true, serverType: authdapi.RootSourceServer}
 	err := defaultClient.checkForRolesAndUpdateClient()
 	return defaultClient, err
 }
@@ -132,7 +197,7 @@ func (vc *vaultClient) checkForRolesAndUpdateClient(neededRoles ...authdapi.Role
 
 	req := authdapi.NewLoginRequest().
 		WithRoles(roles...).
-		WithServerType(authdapi.AuthServer)
+		WithServerType(vc.serverType)
 
 	err := authdClient.OpenVaultSession(req)
 	if err != nil {
@@ -150,6 +215,10 @@ func (vc *vaultClient) checkForRolesAndUpdateClient(neededRoles ...authdapi.Role
 
 func (vc vaultClient) makeRequest(method, requestPath string, params url.Values, bodyBytes []byte) ([]byte, error) {
 	req := vc.NewRequest(method, requestPath)
+	// NewRequest cleans the path, but flant_iam distinguishes lists of objects by the trailing slash
+	if strings.HasSuffix(requestPath, "/") {
+		req.URL.Path += "/"
+	}
 	if params != nil {
 		req.Params = params
 	}
@@ -547,3 +616,44 @@ func (vc *vaultClient) UpdateServerConnectionInfo(tenantUUID iam.TenantUUID, pro
 
 	return &(updateServerConnectionInfoResponse.Data.Server), nil
 }
+
+// ReportSSHSession passes the audit record of the ssh session, it is allowed by ssh.open,
+// so the role is claimed for the last certificate of the report
+func (vc *vaultClient) ReportSSHSession(sessionUUID string, report SSHSessionReport) (*auth.SSHSession, []string, error) {
+	if len(report.Certificates) == 0 {
+		return nil, nil, fmt.Errorf("ReportSSHSession: no certificates")
+	}
+	cert := report.Certificates[len(report.Certificates)-1]
+	err := vc.checkForRolesAndUpdateClient(authdapi.RoleWithClaim{
+		Role:        SSHOpenRole,
+		TenantUUID:  cert.TenantUUID,
+		ProjectUUID: cert.ProjectUUID,
+		Claim: map[string]interface{}{"ttl": "720m",
+			"max_ttl": "1440m",
+			"servers": cert.ServerUUIDs},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bodyBytes, err := json.Marshal(report)
+	if err != nil {
+		return nil, nil, err
+	}
+	respBytes, err := vc.makeRequest("POST", "/v1/auth/flant/ssh_session/"+sessionUUID, nil, bodyBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ReportSSHSession: %w", err)
+	}
+
+	var reportResponse struct {
+		Warnings []string `json:"warnings"`
+		Data     struct {
+			SSHSession auth.SSHSession `json:"ssh_session"`
+		} `json:"data"`
+	}
+	err = json.Unmarshal(respBytes, &reportResponse)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ReportSSHSession: %w", err)
+	}
+	return &reportResponse.Data.SSHSession, reportResponse.Warnings, nil
+}
@@ -0,0 +1,441 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	authdapi "github.com/flant/negentropy/authd/pkg/api/v1"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+)
+
+// RoleBinding is a rolebinding as flant_iam responds it: members and projects are passed with their identifiers,
+// except of reading by uuid, which passes projects only as uuids
+type RoleBinding struct {
+	iam.RoleBinding
+	Members  []RoleBindingMember  `json:"members"`
+	Projects []RoleBindingProject `json:"projects"`
+}
+
+type RoleBindingMember struct {
+	iam.MemberNotation
+	Identifier     string `json:"identifier,omitempty"`
+	FullIdentifier string `json:"full_identifier,omitempty"`
+}
+
+type RoleBindingProject struct {
+	UUID       iam.ProjectUUID `json:"uuid"`
+	Identifier string          `json:"identifier,omitempty"`
+}
+
+func (p *RoleBindingProject) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*p = RoleBindingProject{}
+		return json.Unmarshal(data, &p.UUID)
+	}
+	type plainProject RoleBindingProject
+	return json.Unmarshal(data, (*plainProject)(p))
+}
+
+// Model returns the rolebinding with members and projects referred only by uuids
+func (r RoleBinding) Model() iam.RoleBinding {
+	rb := r.RoleBinding
+	rb.Members = make([]iam.MemberNotation, 0, len(r.Members))
+	for _, m := range r.Members {
+		rb.Members = append(rb.Members, m.MemberNotation)
+	}
+	rb.Projects = make([]iam.ProjectUUID, 0, len(r.Projects))
+	for _, p := range r.Projects {
+		rb.Projects = append(rb.Projects, p.UUID)
+	}
+	return rb
+}
+
+func (vc *vaultClient) GetUsers(tenantUUID iam.TenantUUID) ([]iam.User, error) {
+	var users []iam.User
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET", "user/", nil, map[string]interface{}{"users": &users})
+	if err != nil {
+		return nil, fmt.Errorf("GetUsers: %w", err)
+	}
+	return users, nil
+}
+
+func (vc *vaultClient) GetUserByUUID(tenantUUID iam.TenantUUID, userUUID iam.UserUUID) (*iam.User, error) {
+	user := &iam.User{}
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET", "user/"+userUUID, nil, map[string]interface{}{"user": user})
+	if err != nil {
+		return nil, fmt.Errorf("GetUserByUUID: %w", err)
+	}
+	return user, nil
+}
+
+func (vc *vaultClient) CreateUser(user iam.User) (*iam.User, error) {
+	created := &iam.User{}
+	err := vc.iamRequest(TenantManageRole, user.TenantUUID, "POST", "user", userBody(user),
+		map[string]interface{}{"user": created})
+	if err != nil {
+		return nil, fmt.Errorf("CreateUser: %w", err)
+	}
+	return created, nil
+}
+
+func (vc *vaultClient) UpdateUser(user iam.User) (*iam.User, error) {
+	body := userBody(user)
+	body["resource_version"] = user.Version
+	updated := &iam.User{}
+	err := vc.iamRequest(TenantManageRole, user.TenantUUID, "POST", "user/"+user.UUID, body,
+		map[string]interface{}{"user": updated})
+	if err != nil {
+		return nil, fmt.Errorf("UpdateUser: %w", err)
+	}
+	return updated, nil
+}
+
+func (vc *vaultClient) DeleteUser(tenantUUID iam.TenantUUID, userUUID iam.UserUUID) error {
+	err := vc.iamRequest(TenantManageRole, tenantUUID, "DELETE", "user/"+userUUID, nil, nil)
+	if err != nil {
+		return fmt.Errorf("DeleteUser: %w", err)
+	}
+	return nil
+}
+
+func userBody(user iam.User) map[string]interface{} {
+	return map[string]interface{}{
+		"identifier":        user.Identifier,
+		"first_name":        user.FirstName,
+		"last_name":         user.LastName,
+		"display_name":      user.DisplayName,
+		"email":             user.Email,
+		"additional_emails": user.AdditionalEmails,
+		"mobile_phone":      user.MobilePhone,
+		"additional_phones": user.AdditionalPhones,
+		"language":          user.Language,
+	}
+}
+
+func (vc *vaultClient) GetGroups(tenantUUID iam.TenantUUID) ([]iam.Group, error) {
+	var groups []iam.Group
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET", "group/", nil, map[string]interface{}{"groups": &groups})
+	if err != nil {
+		return nil, fmt.Errorf("GetGroups: %w", err)
+	}
+	return groups, nil
+}
+
+func (vc *vaultClient) GetGroupByUUID(tenantUUID iam.TenantUUID, groupUUID iam.GroupUUID) (*iam.Group, error) {
+	group := &iam.Group{}
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET", "group/"+groupUUID, nil, map[string]interface{}{"group": group})
+	if err != nil {
+		return nil, fmt.Errorf("GetGroupByUUID: %w", err)
+	}
+	return group, nil
+}
+
+func (vc *vaultClient) CreateGroup(group iam.Group) (*iam.Group, error) {
+	created := &iam.Group{}
+	err := vc.iamRequest(TenantManageRole, group.TenantUUID, "POST", "group", groupBody(group),
+		map[string]interface{}{"group": created})
+	if err != nil {
+		return nil, fmt.Errorf("CreateGroup: %w", err)
+	}
+	return created, nil
+}
+
+func (vc *vaultClient) UpdateGroup(group iam.Group) (*iam.Group, error) {
+	body := groupBody(group)
+	body["resource_version"] = group.Version
+	updated := &iam.Group{}
+	err := vc.iamRequest(TenantManageRole, group.TenantUUID, "POST", "group/"+group.UUID, body,
+		map[string]interface{}{"group": updated})
+	if err != nil {
+		return nil, fmt.Errorf("UpdateGroup: %w", err)
+	}
+	return updated, nil
+}
+
+func (vc *vaultClient) DeleteGroup(tenantUUID iam.TenantUUID, groupUUID iam.GroupUUID) error {
+	err := vc.iamRequest(TenantManageRole, tenantUUID, "DELETE", "group/"+groupUUID, nil, nil)
+	if err != nil {
+		return fmt.Errorf("DeleteGroup: %w", err)
+	}
+	return nil
+}
+
+func groupBody(group iam.Group) map[string]interface{} {
+	return map[string]interface{}{
+		"identifier": group.Identifier,
+		"members":    group.Members,
+	}
+}
+
+func (vc *vaultClient) GetServiceAccounts(tenantUUID iam.TenantUUID) ([]iam.ServiceAccount, error) {
+	var serviceAccounts []iam.ServiceAccount
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET", "service_account/", nil,
+		map[string]interface{}{"service_accounts": &serviceAccounts})
+	if err != nil {
+		return nil, fmt.Errorf("GetServiceAccounts: %w", err)
+	}
+	return serviceAccounts, nil
+}
+
+func (vc *vaultClient) GetServiceAccountByUUID(tenantUUID iam.TenantUUID,
+	serviceAccountUUID iam.ServiceAccountUUID) (*iam.ServiceAccount, error) {
+	serviceAccount := &iam.ServiceAccount{}
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET", "service_account/"+serviceAccountUUID, nil,
+		map[string]interface{}{"service_account": serviceAccount})
+	if err != nil {
+		return nil, fmt.Errorf("GetServiceAccountByUUID: %w", err)
+	}
+	return serviceAccount, nil
+}
+
+func (vc *vaultClient) CreateServiceAccount(serviceAccount iam.ServiceAccount) (*iam.ServiceAccount, error) {
+	created := &iam.ServiceAccount{}
+	err := vc.iamRequest(TenantManageRole, serviceAccount.TenantUUID, "POST", "service_account",
+		serviceAccountBody(serviceAccount), map[string]interface{}{"service_account": created})
+	if err != nil {
+		return nil, fmt.Errorf("CreateServiceAccount: %w", err)
+	}
+	return created, nil
+}
+
+func (vc *vaultClient) UpdateServiceAccount(serviceAccount iam.ServiceAccount) (*iam.ServiceAccount, error) {
+	body := serviceAccountBody(serviceAccount)
+	body["resource_version"] = serviceAccount.Version
+	updated := &iam.ServiceAccount{}
+	err := vc.iamRequest(TenantManageRole, serviceAccount.TenantUUID, "POST", "service_account/"+serviceAccount.UUID,
+		body, map[string]interface{}{"service_account": updated})
+	if err != nil {
+		return nil, fmt.Errorf("UpdateServiceAccount: %w", err)
+	}
+	return updated, nil
+}
+
+func (vc *vaultClient) DeleteServiceAccount(tenantUUID iam.TenantUUID, serviceAccountUUID iam.ServiceAccountUUID) error {
+	err := vc.iamRequest(TenantManageRole, tenantUUID, "DELETE", "service_account/"+serviceAccountUUID, nil, nil)
+	if err != nil {
+		return fmt.Errorf("DeleteServiceAccount: %w", err)
+	}
+	return nil
+}
+
+func serviceAccountBody(serviceAccount iam.ServiceAccount) map[string]interface{} {
+	return map[string]interface{}{
+		"identifier":    serviceAccount.Identifier,
+		"allowed_cidrs": serviceAccount.CIDRs,
+		"token_ttl":     int(serviceAccount.TokenTTL / time.Second),
+		"token_max_ttl": int(serviceAccount.TokenMaxTTL / time.Second),
+	}
+}
+
+func (vc *vaultClient) GetRoleBindings(tenantUUID iam.TenantUUID) ([]RoleBinding, error) {
+	var roleBindings []RoleBinding
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET", "role_binding/", nil,
+		map[string]interface{}{"role_bindings": &roleBindings})
+	if err != nil {
+		return nil, fmt.Errorf("GetRoleBindings: %w", err)
+	}
+	return roleBindings, nil
+}
+
+func (vc *vaultClient) GetRoleBindingByUUID(tenantUUID iam.TenantUUID, roleBindingUUID iam.RoleBindingUUID) (*RoleBinding, error) {
+	roleBinding := &RoleBinding{}
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET", "role_binding/"+roleBindingUUID, nil,
+		map[string]interface{}{"role_binding": roleBinding})
+	if err != nil {
+		return nil, fmt.Errorf("GetRoleBindingByUUID: %w", err)
+	}
+	return roleBinding, nil
+}
+
+func (vc *vaultClient) CreateRoleBinding(roleBinding iam.RoleBinding, ttl time.Duration) (*RoleBinding, error) {
+	created := &RoleBinding{}
+	err := vc.iamRequest(TenantManageRole, roleBinding.TenantUUID, "POST", "role_binding",
+		roleBindingBody(roleBinding, ttl), map[string]interface{}{"role_binding": created})
+	if err != nil {
+		return nil, fmt.Errorf("CreateRoleBinding: %w", err)
+	}
+	return created, nil
+}
+
+func (vc *vaultClient) UpdateRoleBinding(roleBinding iam.RoleBinding, ttl time.Duration) (*RoleBinding, error) {
+	body := roleBindingBody(roleBinding, ttl)
+	body["resource_version"] = roleBinding.Version
+	updated := &RoleBinding{}
+	err := vc.iamRequest(TenantManageRole, roleBinding.TenantUUID, "POST", "role_binding/"+roleBinding.UUID,
+		body, map[string]interface{}{"role_binding": updated})
+	if err != nil {
+		return nil, fmt.Errorf("UpdateRoleBinding: %w", err)
+	}
+	return updated, nil
+}
+
+func (vc *vaultClient) DeleteRoleBinding(tenantUUID iam.TenantUUID, roleBindingUUID iam.RoleBindingUUID) error {
+	err := vc.iamRequest(TenantManageRole, tenantUUID, "DELETE", "role_binding/"+roleBindingUUID, nil, nil)
+	if err != nil {
+		return fmt.Errorf("DeleteRoleBinding: %w", err)
+	}
+	return nil
+}
+
+func roleBindingBody(roleBinding iam.RoleBinding, ttl time.Duration) map[string]interface{} {
+	body := map[string]interface{}{
+		"description": roleBinding.Description,
+		"members":     roleBinding.Members,
+		"roles":       roleBinding.Roles,
+		"ttl":         int(ttl / time.Second),
+		"require_mfa": roleBinding.RequireMFA,
+		"any_project": roleBinding.AnyProject,
+		"projects":    roleBinding.Projects,
+		"deny":        roleBinding.Deny,
+	}
+	if roleBinding.Condition != nil {
+		body["condition"] = roleBinding.Condition
+	}
+	return body
+}
+
+func (vc *vaultClient) GetMultipasses(tenantUUID iam.TenantUUID, ownerType iam.MultipassOwnerType,
+	ownerUUID iam.OwnerUUID) ([]iam.Multipass, error) {
+	var multipasses []iam.Multipass
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET", ownerType+"/"+ownerUUID+"/multipass/", nil,
+		map[string]interface{}{"multipasses": &multipasses})
+	if err != nil {
+		return nil, fmt.Errorf("GetMultipasses: %w", err)
+	}
+	return multipasses, nil
+}
+
+func (vc *vaultClient) GetMultipassByUUID(tenantUUID iam.TenantUUID, ownerType iam.MultipassOwnerType,
+	ownerUUID iam.OwnerUUID, multipassUUID iam.MultipassUUID) (*iam.Multipass, error) {
+	multipass := &iam.Multipass{}
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET", ownerType+"/"+ownerUUID+"/multipass/"+multipassUUID, nil,
+		map[string]interface{}{"multipass": multipass})
+	if err != nil {
+		return nil, fmt.Errorf("GetMultipassByUUID: %w", err)
+	}
+	return multipass, nil
+}
+
+func (vc *vaultClient) CreateMultipass(multipass iam.Multipass) (*iam.Multipass, iam.MultipassJWT, error) {
+	body := map[string]interface{}{
+		"ttl":           int(multipass.TTL / time.Second),
+		"max_ttl":       int(multipass.MaxTTL / time.Second),
+		"description":   multipass.Description,
+		"allowed_cidrs": multipass.CIDRs,
+		"allowed_roles": multipass.Roles,
+	}
+	created := &iam.Multipass{}
+	var token iam.MultipassJWT
+	err := vc.iamRequest(TenantManageRole, multipass.TenantUUID, "POST",
+		multipass.OwnerType+"/"+multipass.OwnerUUID+"/multipass", body,
+		map[string]interface{}{"multipass": created, "token": &token})
+	if err != nil {
+		return nil, "", fmt.Errorf("CreateMultipass: %w", err)
+	}
+	return created, token, nil
+}
+
+func (vc *vaultClient) DeleteMultipass(tenantUUID iam.TenantUUID, ownerType iam.MultipassOwnerType,
+	ownerUUID iam.OwnerUUID, multipassUUID iam.MultipassUUID) error {
+	err := vc.iamRequest(TenantManageRole, tenantUUID, "DELETE", ownerType+"/"+ownerUUID+"/multipass/"+multipassUUID,
+		nil, nil)
+	if err != nil {
+		return fmt.Errorf("DeleteMultipass: %w", err)
+	}
+	return nil
+}
+
+func (vc *vaultClient) GetServiceAccountPasswords(tenantUUID iam.TenantUUID,
+	serviceAccountUUID iam.ServiceAccountUUID) ([]iam.ServiceAccountPassword, error) {
+	var passwords []iam.ServiceAccountPassword
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET", "service_account/"+serviceAccountUUID+"/password/", nil,
+		map[string]interface{}{"passwords": &passwords})
+	if err != nil {
+		return nil, fmt.Errorf("GetServiceAccountPasswords: %w", err)
+	}
+	return passwords, nil
+}
+
+func (vc *vaultClient) GetServiceAccountPasswordByUUID(tenantUUID iam.TenantUUID, serviceAccountUUID iam.ServiceAccountUUID,
+	passwordUUID iam.ServiceAccountPasswordUUID) (*iam.ServiceAccountPassword, error) {
+	password := &iam.ServiceAccountPassword{}
+	err := vc.iamRequest(TenantReadRole, tenantUUID, "GET",
+		"service_account/"+serviceAccountUUID+"/password/"+passwordUUID, nil,
+		map[string]interface{}{"password": password})
+	if err != nil {
+		return nil, fmt.Errorf("GetServiceAccountPasswordByUUID: %w", err)
+	}
+	return password, nil
+}
+
+func (vc *vaultClient) CreateServiceAccountPassword(password iam.ServiceAccountPassword) (*iam.ServiceAccountPassword, error) {
+	body := map[string]interface{}{
+		"description":   password.Description,
+		"allowed_cidrs": password.CIDRs,
+		"allowed_roles": password.Roles,
+		"ttl":           int(password.TTL / time.Second),
+	}
+	created := &iam.ServiceAccountPassword{}
+	err := vc.iamRequest(TenantManageRole, password.TenantUUID, "POST", "service_account/"+password.OwnerUUID+"/password",
+		body, map[string]interface{}{"password": created})
+	if err != nil {
+		return nil, fmt.Errorf("CreateServiceAccountPassword: %w", err)
+	}
+	return created, nil
+}
+
+func (vc *vaultClient) DeleteServiceAccountPassword(tenantUUID iam.TenantUUID, serviceAccountUUID iam.ServiceAccountUUID,
+	passwordUUID iam.ServiceAccountPasswordUUID) error {
+	err := vc.iamRequest(TenantManageRole, tenantUUID, "DELETE",
+		"service_account/"+serviceAccountUUID+"/password/"+passwordUUID, nil, nil)
+	if err != nil {
+		return fmt.Errorf("DeleteServiceAccountPassword: %w", err)
+	}
+	return nil
+}
+
+// iamRequest claims the role for the tenant and makes request to flant/tenant/<tenant_uuid>/<requestPath>,
+// values of response data are unmarshalled into dataPtrs by their keys
+func (vc *vaultClient) iamRequest(role string, tenantUUID iam.TenantUUID, method string, requestPath string,
+	body map[string]interface{}, dataPtrs map[string]interface{}) error {
+	err := vc.checkForRolesAndUpdateClient(authdapi.RoleWithClaim{
+		Role:       role,
+		TenantUUID: tenantUUID,
+	})
+	if err != nil {
+		return err
+	}
+	var bodyBytes []byte
+	if body != nil {
+		bodyBytes, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	responseBytes, err := vc.makeRequest(method, "/v1/flant/tenant/"+tenantUUID+"/"+requestPath, nil, bodyBytes)
+	if err != nil {
+		return err
+	}
+	if len(dataPtrs) == 0 {
+		return nil
+	}
+
+	var response struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	err = json.Unmarshal(responseBytes, &response)
+	if err != nil {
+		return err
+	}
+	for key, ptr := range dataPtrs {
+		raw, ok := response.Data[key]
+		if !ok {
+			return fmt.Errorf("response has no %q", key)
+		}
+		if err = json.Unmarshal(raw, ptr); err != nil {
+			return fmt.Errorf("parsing %q: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+)
+
+func Test_RoleBindingModel(t *testing.T) {
+	for name, data := range map[string]string{
+		"denormalized": `{"uuid":"rb1","tenant_uuid":"t1","members":[{"type":"user","uuid":"u1","full_identifier":"alice@t"}],` +
+			`"projects":[{"uuid":"p1","identifier":"main"}]}`,
+		"read by uuid": `{"uuid":"rb1","tenant_uuid":"t1","members":[{"type":"user","uuid":"u1"}],"projects":["p1"]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var rb RoleBinding
+			require.NoError(t, json.Unmarshal([]byte(data), &rb))
+
+			require.Len(t, rb.Projects, 1)
+			assert.Equal(t, "p1", rb.Projects[0].UUID)
+			model := rb.Model()
+			assert.Equal(t, "rb1", model.UUID)
+			assert.Equal(t, []iam.MemberNotation{{Type: iam.UserType, UUID: "u1"}}, model.Members)
+			assert.Equal(t, []iam.ProjectUUID{"p1"}, model.Projects)
+		})
+	}
+}
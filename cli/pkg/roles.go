@@ -30,4 +30,16 @@ const (
 	// PUT at flant/tenant/<tenant_uuid>/project/<project_uuid>/register_server
 	// PUT at flant/tenant/<tenant_uuid>/project/<project_uuid>/server/+/connection_info
 	ServersRegisterRole = "servers.register"
+
+	// TenantReadRole is a tenant scoped role, definitely needs tenant, it is checked by the root source vault
+	// allows READ at flant_iam objects of the tenant and their lists:
+	// flant/tenant/<tenant_uuid>/{user,group,service_account,role_binding}/+
+	// flant/tenant/<tenant_uuid>/{user,service_account}/+/multipass/+
+	// flant/tenant/<tenant_uuid>/service_account/+/password/+
+	TenantReadRole = "tenant.read"
+
+	// TenantManageRole is a tenant scoped role, definitely needs tenant, it is checked by the root source vault
+	// includes tenant.read, and allows CREATE, UPDATE and DELETE at the same paths, logins by multipass are allowed
+	// for it only by the explicit opt-in at the login policy
+	TenantManageRole = "tenant.manage"
 )
@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 	specs2 "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_flant_flow/paths/tests/specs"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	auth_fixtures "github.com/flant/negentropy/vault-plugins/flant_iam_auth/fixtures"
 	api "github.com/flant/negentropy/vault-plugins/shared/tests"
 	"github.com/flant/negentropy/vault-plugins/shared/uuid"
 )
@@ -353,6 +355,7 @@ func authMethodCreator(_ *http.Client, store store) (objectIdentifier, object, o
 func policyCreator(_ *http.Client, store store) (objectIdentifier, object, objectGetter) {
 	role := store.getObject("role").(model.Role)
 	authClient := lib.NewConfiguredIamAuthVaultClient()
+	policyName := "policy_" + strings.ReplaceAll(uuid.New(), "-", "_")
 	var policy gjson.Result
 	err := api.Repeat(func() error {
 		var err error
@@ -362,10 +365,10 @@ func policyCreator(_ *http.Client, store store) (objectIdentifier, object, objec
 		}()
 		policy = lib.NewPolicyAPI(authClient).Create(api.Params{}, url.Values{},
 			map[string]interface{}{
-				"name":         "policy_" + uuid.New(),
-				"rego":         "not_real_rego",
+				"name":         policyName,
+				"rego":         auth_fixtures.PolicyRego(policyName),
 				"roles":        []string{role.Name},
-				"claim_schema": "not_real_schema",
+				"claim_schema": auth_fixtures.ClaimSchema,
 			}).Get("policy")
 		return err
 	}, 15)
@@ -5,6 +5,13 @@ import sys
 VERSION_KEY = 'migratorversion'
 UTC_LENGTH = 14
 
+# applied migrations, which create login policies with claim_schema 'TODO', rejected by flant_iam_auth now;
+# they are kept immutable, so at a new vault they are run with VALID_CLAIM_SCHEMA instead,
+# at vaults, which applied them, policies are rewritten by 20221018110000_all_policies_claim_schemas
+LEGACY_CLAIM_SCHEMA_MIGRATIONS = ['20220309173800', '20220523130100']
+LEGACY_CLAIM_SCHEMA = 'TODO'
+VALID_CLAIM_SCHEMA = '{"type": "object"}'
+
 multipass_file_path = "authd/dev/secret/authd.jwt"
 migration_dir = 'infra/vault_migrator/migrations'
 
@@ -0,0 +1,34 @@
+from typing import Type, TypedDict, List
+
+
+class Vault(TypedDict):
+    name: str
+    token: str
+    url: str
+    client: Type # hvac.Client()
+
+
+# ssh.open.rego is 20220309173800_all_policies_for_ssh_access/ssh.open.rego, allowing to report ssh sessions
+ssh_open_policy = {'roles': ['ssh.open'], 'claim_schema': '{"type": "object"}', 'allowed_auth_methods': ['multipass'],
+                   'rego_file': 'ssh.open.rego'}
+
+
+def upgrade(vault_name: str, vaults: List[Vault]):
+    import os
+    folder = os.path.dirname(os.path.realpath(__file__))
+    vault = next(v for v in vaults if v.name == vault_name)
+
+    # ssh.open allows reporting ssh sessions by the cli
+    with open(os.path.join(folder, ssh_open_policy['rego_file']), "r") as f:
+        print("INFO: update policy 'ssh.open' at '{}' vault".format(vault_name))
+        vault.client.write(path='auth/flant/login_policy/ssh.open', rego=f.read(), roles=ssh_open_policy['roles'],
+                           claim_schema=ssh_open_policy['claim_schema'],
+                           allowed_auth_methods=ssh_open_policy['allowed_auth_methods'])
+
+    # reported certificates are checked by the ca of the ssh secrets engine, which signs them
+    config = {'store': 'vault'}
+    if 'ssh/' in vault.client.sys.list_mounted_secrets_engines():
+        config['ssh_ca_public_key'] = vault.client.read('ssh/config/ca')['data']['public_key']
+
+    print("INFO: configure store of ssh session recordings at '{}' vault".format(vault_name))
+    vault.client.write(path='auth/flant/configure_extension/server_access/ssh_session_recordings', **config)
@@ -0,0 +1,37 @@
+import os
+from typing import Type, TypedDict, List
+
+
+class Vault(TypedDict):
+    name: str
+    token: str
+    url: str
+    client: Type # hvac.Client()
+
+
+# tenant.read and tenant.manage are also claimed by the cli through authd, which logins by multipass.
+# A multipass is kept at the host, so reading objects of the tenant by it is allowed only by the explicit opt-in:
+# CLI_READ_BY_MULTIPASS=true, and changing them: CLI_MANAGE_BY_MULTIPASS=true, which also allows reading
+manage_by_multipass = os.environ.get("CLI_MANAGE_BY_MULTIPASS") == "true"
+read_by_multipass = manage_by_multipass or os.environ.get("CLI_READ_BY_MULTIPASS") == "true"
+
+policies = [
+    {'name': 'tenant.manage', 'roles': ['tenant.manage'], 'claim_schema': '{"type": "object"}',
+     'allowed_auth_methods': ['okta-jwt', 'multipass'] if manage_by_multipass else ['okta-jwt'],
+     'rego_file': '../20220523130100_all_policies_for_web_access/tenant.manage.rego'},
+    {'name': 'tenant.read', 'roles': ['tenant.read'], 'claim_schema': '{"type": "object"}',
+     'allowed_auth_methods': ['okta-jwt', 'multipass'] if read_by_multipass else ['okta-jwt'],
+     'rego_file': '../20220523130100_all_policies_for_web_access/tenant.read.rego'}
+]
+
+
+def upgrade(vault_name: str, vaults: List[Vault]):
+    folder = os.path.dirname(os.path.realpath(__file__))
+    vault = next(v for v in vaults if v.name == vault_name)
+
+    for policy in policies:
+        with open(os.path.join(folder, policy['rego_file']), "r") as f:
+            print("INFO: update policy '{}' at '{}' vault".format(policy['name'], vault_name))
+            vault.client.write(path='auth/flant/login_policy/' + policy['name'], rego=f.read(),
+                               roles=policy['roles'], claim_schema=policy['claim_schema'],
+                               allowed_auth_methods=policy['allowed_auth_methods'])
@@ -0,0 +1,48 @@
+from typing import Type, TypedDict, List
+
+
+class Vault(TypedDict):
+    name: str
+    token: str
+    url: str
+    client: Type # hvac.Client()
+
+
+# policies, created with claim_schema 'TODO', which is not a valid openapi schema,
+# tenant.manage and tenant.read are rewritten by 20221018100000_all_policies_for_cli_management
+policies = [
+    {'name': 'ssh.open', 'roles': ['ssh.open'], 'allowed_auth_methods': ['multipass'],
+     'rego_file': '../20221017100000_all_ssh_session_recordings/ssh.open.rego'},
+    {'name': 'servers.query', 'roles': ['servers.query'], 'allowed_auth_methods': ['multipass', 'sapassword'],
+     'rego_file': '../20220309173800_all_policies_for_ssh_access/servers.query.rego'},
+    {'name': 'tenant.read.auth', 'roles': ['tenant.read.auth'], 'allowed_auth_methods': ['multipass', 'sapassword'],
+     'rego_file': '../20220309173800_all_policies_for_ssh_access/tenant.read.auth.rego'},
+    {'name': 'tenants.list.auth', 'roles': ['tenants.list.auth'],
+     'allowed_auth_methods': ['multipass', 'sapassword', 'okta-jwt'],
+     'rego_file': '../20220309173800_all_policies_for_ssh_access/tenants.list.auth.rego'},
+    {'name': 'servers.register', 'roles': ['servers.register'], 'allowed_auth_methods': ['sapassword'],
+     'rego_file': '../20220309173800_all_policies_for_ssh_access/servers.register.rego'},
+    {'name': 'server', 'roles': ['server'], 'allowed_auth_methods': ['multipass'],
+     'rego_file': '../20220309173800_all_policies_for_ssh_access/server.rego'},
+    {'name': 'flant.teammate', 'roles': ['flant.teammate'], 'allowed_auth_methods': ['okta-jwt'],
+     'rego_file': '../20220523130100_all_policies_for_web_access/flant.teammate.rego'},
+    {'name': 'flant.admin', 'roles': ['flant.admin'], 'allowed_auth_methods': ['okta-jwt'],
+     'rego_file': '../20220523130100_all_policies_for_web_access/flant.admin.rego'},
+    {'name': 'flant.client.manage', 'roles': ['flant.client.manage'], 'allowed_auth_methods': ['okta-jwt'],
+     'rego_file': '../20220523130100_all_policies_for_web_access/flant.client.manage.rego'},
+]
+
+claim_schema = '{"type": "object"}'
+
+
+def upgrade(vault_name: str, vaults: List[Vault]):
+    import os
+    folder = os.path.dirname(os.path.realpath(__file__))
+    vault = next(v for v in vaults if v.name == vault_name)
+
+    for policy in policies:
+        with open(os.path.join(folder, policy['rego_file']), "r") as f:
+            print("INFO: rewrite claim_schema of policy '{}' at '{}' vault".format(policy['name'], vault_name))
+            vault.client.write(path='auth/flant/login_policy/' + policy['name'], rego=f.read(),
+                               roles=policy['roles'], claim_schema=claim_schema,
+                               allowed_auth_methods=policy['allowed_auth_methods'])
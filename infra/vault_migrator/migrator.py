@@ -15,13 +15,13 @@ import datetime
 import sys
 
 # TODO: vault secrets enable -path migrator database
-from config import MIGRATION_TEMPLATE, log, migration_dir
+from config import MIGRATION_TEMPLATE, LEGACY_CLAIM_SCHEMA_MIGRATIONS, log, migration_dir
 from errors import Error
 
 from utils import prepare_user_multipass_jwt, split_vaults, write_tokens_to_file, create_teammate_for_webdev, single_mode_code_run
 
 from migration import Migration
-from vault import Vault
+from vault import Vault, LegacyClaimSchemaClient
 
 
 def load_migrations(directory):
@@ -118,7 +118,15 @@ def run_migration_at_vault(migration: Migration, vault: Vault, vaults: List[Vaul
     """run passed migration for specified vault"""
     loader = importlib.machinery.SourceFileLoader('migration_' + migration.get_version(), migration.path)
     module = loader.load_module()
-    module.upgrade(vault.name, vaults)
+    if migration.get_version() not in LEGACY_CLAIM_SCHEMA_MIGRATIONS:
+        module.upgrade(vault.name, vaults)
+        return
+    client = vault.client
+    vault.client = LegacyClaimSchemaClient(client)
+    try:
+        module.upgrade(vault.name, vaults)
+    finally:
+        vault.client = client
 
 
 def upgrade_vaults(vaults: List[Vault], migration_dir: str, version: str = None):
@@ -5,7 +5,7 @@ from typing import Dict, List
 
 import hvac
 
-from config import VERSION_KEY, log
+from config import VERSION_KEY, LEGACY_CLAIM_SCHEMA, VALID_CLAIM_SCHEMA, log
 
 
 class Vault:
@@ -186,4 +186,18 @@ class Vault:
         
     def __repr__(self):
         return 'Vault()'
-    
\ No newline at end of file
+    
+
+class LegacyClaimSchemaClient:
+    """Wraps hvac.Client for running of legacy migrations, replaces the legacy claim_schema of written login policies"""
+
+    def __init__(self, client: hvac.Client):
+        self._client = client
+
+    def write(self, *args, **kwargs):
+        if kwargs.get('claim_schema') == LEGACY_CLAIM_SCHEMA:
+            kwargs['claim_schema'] = VALID_CLAIM_SCHEMA
+        return self._client.write(*args, **kwargs)
+
+    def __getattr__(self, name):
+        return getattr(self._client, name)
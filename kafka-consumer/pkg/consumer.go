@@ -38,6 +38,8 @@ func (k *KafkaSource) Stop() {
 func NewKafkaSource(kafkaCFG sharedkafka.BrokerConfig, topicName, groupID string, parentLogger hclog.Logger, proceeder DecryptedMessageProceeder) *KafkaSource {
 	fakePluginCfg := sharedkafka.PluginConfig{
 		SelfTopicName: groupID, // need for valid committing reading
+		// the initial root public key, the next keys are passed by the root source
+		RootPublicKey: kafkaCFG.EncryptionPublicKey,
 	}
 
 	mb := &sharedkafka.MessageBroker{
@@ -58,12 +60,16 @@ func NewKafkaSource(kafkaCFG sharedkafka.BrokerConfig, topicName, groupID string
 		},
 		VerifySign: func(signature []byte, messageValue []byte) error {
 			hashed := sha256.Sum256(messageValue)
-			return sharedkafka.VerifySignature(signature, mb.EncryptionPublicKey(), hashed)
+			return mb.VerifyRootSignature(signature, hashed)
 		},
-		Decrypt: func(encryptedMessageValue []byte, chunked bool) ([]byte, error) {
-			return sharedkafka.NewEncrypter().Decrypt(encryptedMessageValue, mb.EncryptionPrivateKey(), chunked)
+		Decrypt: func(encryptedMessageValue []byte, chunked bool, keyID string) ([]byte, error) {
+			return mb.Decrypt(encryptedMessageValue, chunked, keyID)
 		},
 		ProcessRunMessage: func(_ io.Txn, msg io.MsgDecoded) error {
+			handled, err := io.HandleRootPublicKey(mb, nil, msg)
+			if err != nil || handled {
+				return err
+			}
 			return proceeder.ProceedMessage([]byte(msg.Key()), msg.Data)
 		},
 		IgnoreSourceInputMessageBody: true,
@@ -11,6 +11,7 @@ import (
 	ext_ff_repo "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_flant_flow/repo"
 	ext_sa_io "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/io"
 	ext_sa_repo "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/repo"
+	iam_io "github.com/flant/negentropy/vault-plugins/flant_iam/io"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_source"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
 	"github.com/flant/negentropy/vault-plugins/shared/consts"
@@ -22,7 +23,9 @@ import (
 
 type Daemon struct {
 	memstorage *sharedio.MemoryStore
-	logger     hclog.Logger
+	// state is nil if the watcher runs without persistent state
+	state  *State
+	logger hclog.Logger
 }
 
 func (d *Daemon) Run(userEffectiveRoleProcessor UserEffectiveRoleProcessor) {
@@ -36,23 +39,44 @@ func (d *Daemon) Run(userEffectiveRoleProcessor UserEffectiveRoleProcessor) {
 
 func (d *Daemon) Stop() {
 	d.memstorage.Close()
+	if d.state != nil {
+		if err := d.state.Close(); err != nil {
+			d.logger.Error(fmt.Sprintf("closing state: %s", err.Error()))
+		}
+	}
 }
 
+// NewDaemon creates the daemon, if statePath is passed, the daemon loads the state from it
+// and continues reading the topic after the last processed message
 func NewDaemon(kafkaCFG sharedkafka.BrokerConfig, topicName string,
-	runConsumerID string, parentLogger hclog.Logger) (*Daemon, error) {
+	runConsumerID string, statePath string, parentLogger hclog.Logger) (*Daemon, error) {
 	mb := messageBroker(kafkaCFG, runConsumerID, parentLogger)
 	storage, err := memStorage(mb, parentLogger)
 	if err != nil {
 		return nil, err
 	}
 
-	storage.AddKafkaSource(kafkaSource(mb, topicName, runConsumerID, parentLogger))
-	return &Daemon{memstorage: storage, logger: parentLogger.Named("daemon")}, nil
+	var state *State
+	if statePath != "" {
+		state, err = OpenState(statePath, topicName, iam_io.SchemaRegistry, parentLogger)
+		if err != nil {
+			return nil, err
+		}
+		if err = state.Load(storage, msgHandler(mb)); err != nil {
+			state.Close() // nolint: errcheck
+			return nil, fmt.Errorf("loading state: %w", err)
+		}
+	}
+
+	storage.AddKafkaSource(kafkaSource(mb, topicName, runConsumerID, state, parentLogger))
+	return &Daemon{memstorage: storage, state: state, logger: parentLogger.Named("daemon")}, nil
 }
 
 func messageBroker(kafkaCFG sharedkafka.BrokerConfig, consumerGroupID string, parentLogger hclog.Logger) *sharedkafka.MessageBroker {
 	fakePluginCfg := sharedkafka.PluginConfig{
 		SelfTopicName: consumerGroupID, // need for valid committing reading
+		// the initial root public key, the next keys are passed by the root source
+		RootPublicKey: kafkaCFG.EncryptionPublicKey,
 	}
 
 	mb := &sharedkafka.MessageBroker{
@@ -85,28 +109,36 @@ func memStorage(mb *sharedkafka.MessageBroker, parentLogger hclog.Logger) (*shar
 	return sharedio.NewMemoryStore(schema, mb, parentLogger)
 }
 
-func msgHandler(txn sharedio.Txn, msg sharedio.MsgDecoded) (err error) {
-	for _, r := range []kafka_source.RestoreFunc{
-		jwtkafka.SelfRestoreMessage,
-		ext_sa_io.HandleServerAccessObjects,
-		ext_ff_io.HandleFlantFlowObjects,
-		kafka_source.IamObjectsRestoreHandler,
-	} {
-		handled, err := r(txn, msg)
-		if err != nil {
+// msgHandler returns handler of messages, root public keys are trusted by mb
+func msgHandler(mb *sharedkafka.MessageBroker) func(txn sharedio.Txn, msg sharedio.MsgDecoded) error {
+	return func(txn sharedio.Txn, msg sharedio.MsgDecoded) error {
+		handled, err := sharedio.HandleRootPublicKey(mb, nil, msg)
+		if err != nil || handled {
 			return err
 		}
-
-		if handled {
-			return nil
+		for _, r := range []kafka_source.RestoreFunc{
+			jwtkafka.SelfRestoreMessage,
+			ext_sa_io.HandleServerAccessObjects,
+			ext_ff_io.HandleFlantFlowObjects,
+			kafka_source.IamObjectsRestoreHandler,
+		} {
+			handled, err := r(txn, msg)
+			if err != nil {
+				return err
+			}
+
+			if handled {
+				return nil
+			}
 		}
+		return fmt.Errorf("type= %s: %w", msg.Type, consts.ErrNotHandledObject)
 	}
-	return fmt.Errorf("type= %s: %w", msg.Type, consts.ErrNotHandledObject)
 }
 
 func kafkaSource(mb *sharedkafka.MessageBroker, topicName string,
-	runConsumerID string, parentLogger hclog.Logger) *sharedio.KafkaSourceImpl {
-	return &sharedio.KafkaSourceImpl{
+	runConsumerID string, state *State, parentLogger hclog.Logger) *sharedio.KafkaSourceImpl {
+	handler := msgHandler(mb)
+	source := &sharedio.KafkaSourceImpl{
 		NameOfSource: "rolebinding-watcher-consumer",
 		KafkaBroker:  mb,
 		Logger:       parentLogger.Named("rolebinding-watcher"),
@@ -118,17 +150,29 @@ func kafkaSource(mb *sharedkafka.MessageBroker, topicName string,
 		},
 		VerifySign: func(signature []byte, messageValue []byte) error {
 			hashed := sha256.Sum256(messageValue)
-			return sharedkafka.VerifySignature(signature, mb.EncryptionPublicKey(), hashed)
+			return mb.VerifyRootSignature(signature, hashed)
 		},
-		Decrypt: func(encryptedMessageValue []byte, chunked bool) ([]byte, error) {
-			return sharedkafka.NewEncrypter().Decrypt(encryptedMessageValue, mb.EncryptionPrivateKey(), chunked)
+		Decrypt: func(encryptedMessageValue []byte, chunked bool, keyID string) ([]byte, error) {
+			return mb.Decrypt(encryptedMessageValue, chunked, keyID)
 		},
+		SchemaRegistry: iam_io.SchemaRegistry,
 		ProcessRunMessage: func(txn sharedio.Txn, msg sharedio.MsgDecoded) error {
 			parentLogger.Debug("message", "key", msg.Key())
-			return msgHandler(txn, msg)
+			return handler(txn, msg)
 		},
-		ProcessRestoreMessage:        msgHandler,
+		ProcessRestoreMessage:        handler,
 		IgnoreSourceInputMessageBody: true,
 		Runnable:                     true,
 	}
+	if state != nil {
+		source.ProvideLastProcessedOffset = state.LastProcessedOffset
+		source.ProcessRunMessage = func(txn sharedio.Txn, msg sharedio.MsgDecoded) error {
+			parentLogger.Debug("message", "key", msg.Key(), "offset", msg.Offset)
+			if err := handler(txn, msg); err != nil {
+				return err
+			}
+			return state.RecordMessage(txn, msg)
+		}
+	}
+	return source
 }
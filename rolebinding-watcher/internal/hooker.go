@@ -5,7 +5,7 @@
 // Tenant: 1) New - new tenant hasn't any Rolebinding 2) Update - doesn't change anything 3) Archive/Delete - this tenant can't have any Active Rolebinding
 // Group: 1) New - new group hasn't any Rolebinding 2) Archive/Delete - this group can't have any Active Rolebinding 3) Update - if was changed set of users/or group it can change usereffectiveRoles,
 //        but if kafka will be compacted, 'new item' can be not new, but edited
-//        need to be processed all roles which are on old and new group.
+//        need to be processed all roles which are on old and new group, and on all groups containing it at any depth.
 // Project: 1) New/Archive - project can change userEffectiveRole under projectScopedRoles 2) Update/Delete doesn't produce any changes
 // Roles: 1) New/Archive/Delete doesn't affect 2) Change in IncludedRoles can produce changes at each child roles
 //        but if kafka will be compacted, 'new item' can be not new, but edited
@@ -128,7 +128,9 @@ func (h *Hooker) processGroup(txn *sharedio.MemoryStoreTxn, _ sharedio.HookEvent
 		return err
 	}
 
-	allPossibleChangedRoles, err := collectAllRolesOfGroups(txn, append(newGroup.Groups, oldGroup.Groups...))
+	// roles given to the changing group and all its ancestors at any depth
+	changedGroups := append([]iam_model.GroupUUID{newGroup.UUID}, newGroup.Groups...)
+	allPossibleChangedRoles, err := collectAllRolesOfGroups(txn, append(changedGroups, oldGroup.Groups...))
 	if err != nil {
 		return err
 	}
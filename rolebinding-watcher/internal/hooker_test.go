@@ -163,6 +163,40 @@ func Test_Rolebindings(t *testing.T) {
 		require.Nil(t, mock.CallsToDo())
 	})
 
+	denyRolebinding := iam_model.RoleBinding{
+		UUID:       iam_fixtures.RbUUID3,
+		TenantUUID: iam_fixtures.TenantUUID2,
+		Users:      []string{iam_fixtures.UserUUID1},
+		Roles: []iam_model.BoundRole{{
+			Name: iam_fixtures.RoleName1,
+		}},
+		AnyProject: true,
+		Deny:       true,
+	}
+
+	t.Run("new deny rolebinding", func(t *testing.T) {
+		mock.expectedCalls = []pkg.UserEffectiveRoles{emptyUser1Role1UER}
+		tx = store.Txn(true)
+		rb := denyRolebinding
+
+		require.NoError(t, tx.Insert(iam_model.RoleBindingType, &rb))
+		require.NoError(t, tx.Commit())
+
+		require.Nil(t, mock.CallsToDo())
+	})
+
+	t.Run("delete deny rolebinding", func(t *testing.T) {
+		mock.expectedCalls = []pkg.UserEffectiveRoles{baseUserEffectiveRoles}
+		tx = store.Txn(true)
+		rb := denyRolebinding
+		rb.Archive(memdb.NewArchiveMark())
+
+		require.NoError(t, tx.Insert(iam_model.RoleBindingType, &rb))
+		require.NoError(t, tx.Commit())
+
+		require.Nil(t, mock.CallsToDo())
+	})
+
 	t.Run("delete rolebinding", func(t *testing.T) {
 		mock.expectedCalls = []pkg.UserEffectiveRoles{emptyUser1Role1UER}
 		tx = store.Txn(true)
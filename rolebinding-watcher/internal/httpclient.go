@@ -39,6 +39,15 @@ func (c *HTTPClient) ProceedUserEffectiveRole(userEffectiveRoles pkg.UserEffecti
 	return err
 }
 
+// Flush does nothing, as each item is posted at passing
+func (c *HTTPClient) Flush() error {
+	return nil
+}
+
+func (c *HTTPClient) Close() error {
+	return nil
+}
+
 func (c *HTTPClient) Post(url string, contentType string, body *bytes.Reader) (*http.Response, error) {
 	req, err := http.NewRequest("POST", url, body)
 	if err != nil {
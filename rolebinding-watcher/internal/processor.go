@@ -18,6 +18,11 @@ type UserEffectiveRoleProcessor interface {
 	ProceedUserEffectiveRole(userEffectiveRoles pkg.UserEffectiveRoles) error
 }
 
+// flusher is implemented by processors, which buffer UserEffectiveRoles
+type flusher interface {
+	Flush() error
+}
+
 type ChangesProcessor struct {
 	userEffectiveRoleProcessor UserEffectiveRoleProcessor
 	Logger                     hclog.Logger
@@ -52,6 +57,12 @@ func (c *ChangesProcessor) UpdateUserEffectiveRoles(futureDB *io.MemoryStoreTxn,
 			}
 		}
 	}
+	// all of UserEffectiveRoles should be delivered before acknowledging of the message
+	if f, ok := c.userEffectiveRoleProcessor.(flusher); ok {
+		if err := f.Flush(); err != nil {
+			return fmt.Errorf("userEffectiveRoleProcessor.Flush: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -97,3 +108,12 @@ func (c PrintProceeder) ProceedUserEffectiveRole(newUsersEffectiveRoles pkg.User
 	c.Logger.Info("\n" + string(data) + "\n")
 	return nil
 }
+
+// Flush does nothing, as each item is printed at passing
+func (c PrintProceeder) Flush() error {
+	return nil
+}
+
+func (c PrintProceeder) Close() error {
+	return nil
+}
@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/flant/negentropy/rolebinding-watcher/pkg"
+	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
+)
+
+// Sink is an output of changed UserEffectiveRoles
+type Sink interface {
+	UserEffectiveRoleProcessor
+	// Flush delivers buffered UserEffectiveRoles, it is called before acknowledging of the processed message
+	Flush() error
+	// Close flushes buffered UserEffectiveRoles and releases resources
+	Close() error
+}
+
+// MultiSink passes UserEffectiveRoles to all sinks in order, the first failed sink stops passing.
+// The processing of the message is repeated after the failure, so UserEffectiveRoles, already delivered by a sink,
+// are not passed to it again till the successful Flush of all sinks
+type MultiSink struct {
+	sinks []Sink
+	// pending are passed to the sinks, but not flushed yet
+	pending []map[pkg.UserEffectiveRolesKey]pkg.UserEffectiveRoles
+	// delivered are flushed by the sinks since the last successful Flush of all sinks
+	delivered []map[pkg.UserEffectiveRolesKey]pkg.UserEffectiveRoles
+}
+
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	s := &MultiSink{sinks: sinks}
+	s.pending = newSinkRecords(len(sinks))
+	s.delivered = newSinkRecords(len(sinks))
+	return s
+}
+
+func newSinkRecords(count int) []map[pkg.UserEffectiveRolesKey]pkg.UserEffectiveRoles {
+	records := make([]map[pkg.UserEffectiveRolesKey]pkg.UserEffectiveRoles, count)
+	for i := range records {
+		records[i] = map[pkg.UserEffectiveRolesKey]pkg.UserEffectiveRoles{}
+	}
+	return records
+}
+
+func (s *MultiSink) ProceedUserEffectiveRole(userEffectiveRoles pkg.UserEffectiveRoles) error {
+	key := pkg.UserEffectiveRolesKey{UserUUID: userEffectiveRoles.UserUUID, RoleName: userEffectiveRoles.RoleName}
+	for i, sink := range s.sinks {
+		if delivered, ok := s.delivered[i][key]; ok && delivered.Equal(&userEffectiveRoles) {
+			continue
+		}
+		if err := sink.ProceedUserEffectiveRole(userEffectiveRoles); err != nil {
+			// items, buffered by other sinks, are delivered now to skip them at the repeating
+			s.flushSinks() // nolint: errcheck
+			return err
+		}
+		s.pending[i][key] = userEffectiveRoles
+	}
+	return nil
+}
+
+func (s *MultiSink) Flush() error {
+	if err := s.flushSinks(); err != nil {
+		return err
+	}
+	// the message is acknowledged, next UserEffectiveRoles are new
+	s.delivered = newSinkRecords(len(s.sinks))
+	return nil
+}
+
+// flushSinks flushes all sinks and moves pending UserEffectiveRoles of succeeded sinks into delivered
+func (s *MultiSink) flushSinks() error {
+	var allErrors *multierror.Error
+	for i, sink := range s.sinks {
+		if err := sink.Flush(); err != nil {
+			allErrors = multierror.Append(allErrors, err)
+		} else {
+			for key, userEffectiveRoles := range s.pending[i] {
+				s.delivered[i][key] = userEffectiveRoles
+			}
+		}
+		s.pending[i] = map[pkg.UserEffectiveRolesKey]pkg.UserEffectiveRoles{}
+	}
+	return allErrors.ErrorOrNil()
+}
+
+func (s *MultiSink) Close() error {
+	var allErrors *multierror.Error
+	for _, sink := range s.sinks {
+		if err := sink.Close(); err != nil {
+			allErrors = multierror.Append(allErrors, err)
+		}
+	}
+	return allErrors.ErrorOrNil()
+}
+
+// FileSink appends UserEffectiveRoles into the local file as JSON lines
+type FileSink struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening journal: %w", err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) ProceedUserEffectiveRole(userEffectiveRoles pkg.UserEffectiveRoles) error {
+	data, err := json.Marshal(userEffectiveRoles)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err = s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	return s.file.Sync()
+}
+
+// Flush does nothing, as each item is synced at passing
+func (s *FileSink) Flush() error {
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.file.Close()
+}
+
+const (
+	PrintSinkType   = "print"
+	HTTPSinkType    = "http"
+	FileSinkType    = "file"
+	WebhookSinkType = "webhook"
+	KafkaSinkType   = "kafka"
+)
+
+type SinksConfig struct {
+	// Types of sinks to use, in the order of passing UserEffectiveRoles
+	Types []string
+
+	// for http sink
+	HTTPURL         string
+	HTTPHeaderName  string
+	HTTPHeaderValue string
+
+	FilePath string
+
+	Webhook WebhookSinkConfig
+
+	// for kafka sink, the connection is the same as for reading
+	KafkaTopic string
+}
+
+// NewSink builds sinks by the config, if there are several sinks, they are combined into MultiSink
+func NewSink(cfg SinksConfig, kafkaCFG sharedkafka.BrokerConfig, consumerGroupID string, logger hclog.Logger) (Sink, error) {
+	var sinks []Sink
+	for _, sinkType := range cfg.Types {
+		sink, err := newSink(sinkType, cfg, kafkaCFG, consumerGroupID, logger)
+		if err != nil {
+			NewMultiSink(sinks...).Close() // nolint: errcheck
+			return nil, fmt.Errorf("%s sink: %w", sinkType, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return NewMultiSink(sinks...), nil
+}
+
+func newSink(sinkType string, cfg SinksConfig, kafkaCFG sharedkafka.BrokerConfig, consumerGroupID string,
+	logger hclog.Logger) (Sink, error) {
+	switch sinkType {
+	case PrintSinkType:
+		return PrintProceeder{Logger: logger}, nil
+	case HTTPSinkType:
+		if cfg.HTTPURL == "" {
+			return nil, fmt.Errorf("url is required")
+		}
+		return NewHTTPClient(cfg.HTTPURL, cfg.HTTPHeaderName, cfg.HTTPHeaderValue), nil
+	case FileSinkType:
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("path is required")
+		}
+		return NewFileSink(cfg.FilePath)
+	case WebhookSinkType:
+		if cfg.Webhook.URL == "" {
+			return nil, fmt.Errorf("url is required")
+		}
+		return NewWebhookSink(cfg.Webhook, logger), nil
+	case KafkaSinkType:
+		if cfg.KafkaTopic == "" {
+			return nil, fmt.Errorf("topic is required")
+		}
+		return NewKafkaSink(kafkaCFG, cfg.KafkaTopic, consumerGroupID+".sink", logger), nil
+	}
+	return nil, fmt.Errorf("unknown sink type")
+}
@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"encoding/json"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/flant/negentropy/rolebinding-watcher/pkg"
+	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
+)
+
+// KafkaSink re-publishes UserEffectiveRoles as plain JSON into the topic,
+// the key of message is "<user_uuid>@<role_name>", so the topic can be compacted
+type KafkaSink struct {
+	mb    *sharedkafka.MessageBroker
+	topic string
+}
+
+// NewKafkaSink uses its own producer, transactionalID should differ from the consumer group of the watcher
+func NewKafkaSink(kafkaCFG sharedkafka.BrokerConfig, topic string, transactionalID string,
+	parentLogger hclog.Logger) *KafkaSink {
+	return &KafkaSink{
+		mb:    messageBroker(kafkaCFG, transactionalID, parentLogger.Named("kafkaSink")),
+		topic: topic,
+	}
+}
+
+func (s *KafkaSink) ProceedUserEffectiveRole(userEffectiveRoles pkg.UserEffectiveRoles) error {
+	data, err := json.Marshal(userEffectiveRoles)
+	if err != nil {
+		return err
+	}
+	return s.mb.SendMessages([]sharedkafka.Message{{
+		Topic: s.topic,
+		Key:   userEffectiveRoles.ObjId(),
+		Value: data,
+	}}, nil)
+}
+
+// Flush does nothing, as each item is sent at passing
+func (s *KafkaSink) Flush() error {
+	return nil
+}
+
+func (s *KafkaSink) Close() error {
+	s.mb.Close()
+	return nil
+}
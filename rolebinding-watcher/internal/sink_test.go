@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/rolebinding-watcher/pkg"
+	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
+)
+
+func sinkTestRoles(userUUID string) pkg.UserEffectiveRoles {
+	return pkg.UserEffectiveRoles{UserUUID: userUUID, RoleName: "ssh"}
+}
+
+func Test_FileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	sink, err := NewFileSink(path)
+	require.NoError(t, err)
+
+	require.NoError(t, sink.ProceedUserEffectiveRole(sinkTestRoles("u1")))
+	require.NoError(t, sink.ProceedUserEffectiveRole(sinkTestRoles("u2")))
+	require.NoError(t, sink.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var users []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var roles pkg.UserEffectiveRoles
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &roles))
+		users = append(users, roles.UserUUID)
+	}
+	require.Equal(t, []string{"u1", "u2"}, users)
+}
+
+// webhookServer collects batches with valid signatures
+type webhookServer struct {
+	t       *testing.T
+	secret  string
+	mutex   sync.Mutex
+	batches [][]pkg.UserEffectiveRoles
+}
+
+func (s *webhookServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(s.t, err)
+	if req.Header.Get(SignatureHeader) != Sign([]byte(s.secret), body) {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var batch []pkg.UserEffectiveRoles
+	require.NoError(s.t, json.Unmarshal(body, &batch))
+	s.mutex.Lock()
+	s.batches = append(s.batches, batch)
+	s.mutex.Unlock()
+	rw.WriteHeader(http.StatusOK)
+}
+
+func Test_WebhookSinkBatching(t *testing.T) {
+	handler := &webhookServer{t: t, secret: "s3cr3t"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	sink := NewWebhookSink(WebhookSinkConfig{URL: server.URL, HMACSecret: "s3cr3t", BatchSize: 2}, hclog.NewNullLogger())
+
+	for _, u := range []string{"u1", "u2", "u3"} {
+		require.NoError(t, sink.ProceedUserEffectiveRole(sinkTestRoles(u)))
+	}
+	require.Len(t, handler.batches, 1)
+	require.NoError(t, sink.Flush())
+	require.NoError(t, sink.Close())
+
+	require.Len(t, handler.batches, 2)
+	require.Len(t, handler.batches[0], 2)
+	require.Equal(t, "u3", handler.batches[1][0].UserUUID)
+}
+
+func Test_NewSinkValidatesConfig(t *testing.T) {
+	_, err := NewSink(SinksConfig{Types: []string{WebhookSinkType}}, sharedkafka.BrokerConfig{}, "watcher", hclog.NewNullLogger())
+	require.Error(t, err)
+
+	_, err = NewSink(SinksConfig{Types: []string{"unknown"}}, sharedkafka.BrokerConfig{}, "watcher", hclog.NewNullLogger())
+	require.Error(t, err)
+
+	sink, err := NewSink(SinksConfig{
+		Types:    []string{PrintSinkType, FileSinkType},
+		FilePath: filepath.Join(t.TempDir(), "journal.jsonl"),
+	}, sharedkafka.BrokerConfig{}, "watcher", hclog.NewNullLogger())
+	require.NoError(t, err)
+	require.Len(t, sink.(*MultiSink).sinks, 2)
+	require.NoError(t, sink.Close())
+}
+
+// recordingSink keeps passed users, fails passing while fail is set
+type recordingSink struct {
+	fail  bool
+	users []string
+}
+
+func (s *recordingSink) ProceedUserEffectiveRole(userEffectiveRoles pkg.UserEffectiveRoles) error {
+	if s.fail {
+		return errors.New("failed")
+	}
+	s.users = append(s.users, userEffectiveRoles.UserUUID)
+	return nil
+}
+
+func (s *recordingSink) Flush() error { return nil }
+
+func (s *recordingSink) Close() error { return nil }
+
+func Test_MultiSinkDoesNotRepeatDelivered(t *testing.T) {
+	first := &recordingSink{}
+	second := &recordingSink{fail: true}
+	sink := NewMultiSink(first, second)
+
+	require.Error(t, sink.ProceedUserEffectiveRole(sinkTestRoles("u1")))
+	second.fail = false
+	// repeating of the processing of the message
+	require.NoError(t, sink.ProceedUserEffectiveRole(sinkTestRoles("u1")))
+	require.NoError(t, sink.Flush())
+
+	require.Equal(t, []string{"u1"}, first.users)
+	require.Equal(t, []string{"u1"}, second.users)
+
+	// the next message
+	require.NoError(t, sink.ProceedUserEffectiveRole(sinkTestRoles("u1")))
+	require.NoError(t, sink.Flush())
+	require.Equal(t, []string{"u1", "u1"}, first.users)
+}
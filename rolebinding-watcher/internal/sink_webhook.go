@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/cenkalti/backoff"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/flant/negentropy/rolebinding-watcher/pkg"
+	sharedio "github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+// SignatureHeader keeps HMAC-SHA256 of the request body, in the form "sha256=<hex>"
+const SignatureHeader = "X-Negentropy-Signature"
+
+type WebhookSinkConfig struct {
+	URL string
+	// HMACSecret signs the body of the request, if empty, the request is not signed
+	HMACSecret string
+	// BatchSize is a maximum count of UserEffectiveRoles in one request, 1 means sending without buffering
+	BatchSize   int
+	HeaderName  string
+	HeaderValue string
+}
+
+// WebhookSink posts JSON arrays of UserEffectiveRoles
+// UserEffectiveRoles are buffered till BatchSize or till Flush, which is called before acknowledging
+// of the processed message, so all of passed UserEffectiveRoles are delivered or the error is returned
+type WebhookSink struct {
+	client *http.Client
+	cfg    WebhookSinkConfig
+	logger hclog.Logger
+
+	mutex sync.Mutex
+	batch []pkg.UserEffectiveRoles
+}
+
+func NewWebhookSink(cfg WebhookSinkConfig, parentLogger hclog.Logger) *WebhookSink {
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 1
+	}
+	return &WebhookSink{
+		client: &http.Client{},
+		cfg:    cfg,
+		logger: parentLogger.Named("webhook"),
+	}
+}
+
+func (s *WebhookSink) ProceedUserEffectiveRole(userEffectiveRoles pkg.UserEffectiveRoles) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.batch = append(s.batch, userEffectiveRoles)
+	if len(s.batch) < s.cfg.BatchSize {
+		return nil
+	}
+	return s.sendBatch()
+}
+
+func (s *WebhookSink) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sendBatch()
+}
+
+// sendBatch sends buffered UserEffectiveRoles, the buffer is cleared anyway, as failed items are passed again
+// at repeating of the processing of the message, should be called under the mutex
+func (s *WebhookSink) sendBatch() error {
+	batch := s.batch
+	s.batch = nil
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.send(batch)
+}
+
+func (s *WebhookSink) send(batch []pkg.UserEffectiveRoles) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	operation := func() error {
+		req, err := http.NewRequest("POST", s.cfg.URL, bytes.NewReader(data))
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if s.cfg.HMACSecret != "" {
+			req.Header.Set(SignatureHeader, Sign([]byte(s.cfg.HMACSecret), data))
+		}
+		if s.cfg.HeaderName != "" && s.cfg.HeaderValue != "" {
+			req.Header.Set(s.cfg.HeaderName, s.cfg.HeaderValue)
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() // nolint: errcheck
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("wrong response: %d", resp.StatusCode)
+		}
+		return nil
+	}
+	return backoff.Retry(operation, sharedio.ThirtySecondsBackoff())
+}
+
+func (s *WebhookSink) Close() error {
+	return s.Flush()
+}
+
+// Sign returns the value of SignatureHeader for the body
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body) // nolint: errcheck
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
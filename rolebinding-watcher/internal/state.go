@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/flant/negentropy/rolebinding-watcher/pkg"
+	sharedio "github.com/flant/negentropy/vault-plugins/shared/io"
+	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
+)
+
+var (
+	// objectsBucket keeps data of processed messages by message key, it is a snapshot of the memdb
+	objectsBucket = []byte("objects")
+	// objectVersionsBucket keeps schema versions of data at objectsBucket by message key, data without version
+	// has version 0
+	objectVersionsBucket = []byte("object_versions")
+	// effectiveRolesBucket keeps the last emitted UserEffectiveRoles by ObjId
+	effectiveRolesBucket = []byte("user_effective_roles")
+	// metaBucket keeps the topic name and the last processed offset
+	metaBucket = []byte("meta")
+
+	topicKey  = []byte("topic")
+	offsetKey = []byte("offset")
+)
+
+// State persists the snapshot of processed messages, the last processed offset and the last emitted
+// UserEffectiveRoles, to resume the watcher after restart without re-emitting UserEffectiveRoles
+// All changes of one message are written in one bolt transaction at the processing of the message, before the commit
+// of the memdb transaction, so the failed writing fails the processing. If the commit fails after writing,
+// the message is processed again and its changes are rewritten
+type State struct {
+	db       *bolt.DB
+	topic    string
+	registry *sharedio.SchemaRegistry
+	logger   hclog.Logger
+
+	mutex      sync.Mutex
+	lastOffset int64
+	hasOffset  bool
+}
+
+// OpenState opens or creates the state file, the state of another topic is dropped.
+// Objects are saved with versions of registry and are upgraded by registry at loading
+func OpenState(path string, topic string, registry *sharedio.SchemaRegistry,
+	parentLogger hclog.Logger) (*State, error) {
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening state: %w", err)
+	}
+	s := &State{
+		db:       db,
+		topic:    topic,
+		registry: registry,
+		logger:   parentLogger.Named("state"),
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if meta != nil && string(meta.Get(topicKey)) != topic {
+			s.logger.Warn(fmt.Sprintf("state of topic %q is dropped", string(meta.Get(topicKey))))
+			for _, name := range [][]byte{objectsBucket, objectVersionsBucket, effectiveRolesBucket, metaBucket} {
+				if err := tx.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+					return err
+				}
+			}
+		}
+		for _, name := range [][]byte{objectsBucket, objectVersionsBucket, effectiveRolesBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		meta = tx.Bucket(metaBucket)
+		if rawOffset := meta.Get(offsetKey); rawOffset != nil {
+			s.lastOffset = int64(binary.BigEndian.Uint64(rawOffset))
+			s.hasOffset = true
+		}
+		return meta.Put(topicKey, []byte(topic))
+	})
+	if err != nil {
+		db.Close() // nolint: errcheck
+		return nil, fmt.Errorf("initializing state: %w", err)
+	}
+	return s, nil
+}
+
+// LastProcessedOffset is suitable for KafkaSourceImpl.ProvideLastProcessedOffset
+func (s *State) LastProcessedOffset(_ *sharedkafka.MessageBroker) (int64, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastOffset, s.hasOffset
+}
+
+// Load fills the store by the snapshot, processed by handler, and the last emitted UserEffectiveRoles,
+// objects of previous schema versions are upgraded
+func (s *State) Load(store *sharedio.MemoryStore, handler func(txn sharedio.Txn, msg sharedio.MsgDecoded) error) error {
+	txn := store.MemDB.Txn(true)
+	defer txn.Abort()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		versions := tx.Bucket(objectVersionsBucket)
+		err := tx.Bucket(objectsBucket).ForEach(func(k, v []byte) error {
+			splitted := strings.SplitN(string(k), "/", 2)
+			if len(splitted) != 2 {
+				return fmt.Errorf("wrong key %q", string(k))
+			}
+			version := 0
+			if rawVersion := versions.Get(k); rawVersion != nil {
+				var err error
+				if version, err = strconv.Atoi(string(rawVersion)); err != nil {
+					return fmt.Errorf("version of %q: %w", string(k), err)
+				}
+			}
+			data, err := s.registry.Upgrade(splitted[0], version, v)
+			if err != nil {
+				return err
+			}
+			return handler(txn, sharedio.MsgDecoded{Type: splitted[0], ID: splitted[1], Data: data})
+		})
+		if err != nil {
+			return fmt.Errorf("loading objects: %w", err)
+		}
+		return tx.Bucket(effectiveRolesBucket).ForEach(func(k, v []byte) error {
+			userEffectiveRoles := &pkg.UserEffectiveRoles{}
+			if err := json.Unmarshal(v, userEffectiveRoles); err != nil {
+				return fmt.Errorf("loading %s: %w", string(k), err)
+			}
+			return txn.Insert(pkg.UserEffectiveRolesType, userEffectiveRoles)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	txn.Commit()
+	return nil
+}
+
+// RecordMessage writes the message with changed UserEffectiveRoles of txn
+func (s *State) RecordMessage(txn sharedio.Txn, msg sharedio.MsgDecoded) error {
+	memTxn, ok := txn.(*sharedio.MemoryStoreTxn)
+	if !ok {
+		return fmt.Errorf("expected type *io.MemoryStoreTxn, got: %T", txn)
+	}
+	effectiveRoles := map[string][]byte{} // by ObjId, nil value means deleted
+	for _, change := range memTxn.Changes() {
+		if change.Table != pkg.UserEffectiveRolesType {
+			continue
+		}
+		if change.After == nil {
+			effectiveRoles[change.Before.(*pkg.UserEffectiveRoles).ObjId()] = nil
+			continue
+		}
+		userEffectiveRoles := change.After.(*pkg.UserEffectiveRoles)
+		data, err := json.Marshal(userEffectiveRoles)
+		if err != nil {
+			return err
+		}
+		effectiveRoles[userEffectiveRoles.ObjId()] = data
+	}
+	if err := s.write(msg, effectiveRoles); err != nil {
+		return fmt.Errorf("writing message %s at offset %d: %w", msg.Key(), msg.Offset, err)
+	}
+	return nil
+}
+
+func (s *State) write(msg sharedio.MsgDecoded, effectiveRoles map[string][]byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		objects := tx.Bucket(objectsBucket)
+		versions := tx.Bucket(objectVersionsBucket)
+		key := []byte(msg.Key())
+		var err error
+		if msg.IsDeleted() {
+			if err = objects.Delete(key); err == nil {
+				err = versions.Delete(key)
+			}
+		} else if err = objects.Put(key, msg.Data); err == nil {
+			// data of the message is upgraded to the current version by the source
+			err = versions.Put(key, s.registry.VersionHeader(msg.Type))
+		}
+		if err != nil {
+			return err
+		}
+		bucket := tx.Bucket(effectiveRolesBucket)
+		for id, data := range effectiveRoles {
+			if data == nil {
+				err = bucket.Delete([]byte(id))
+			} else {
+				err = bucket.Put([]byte(id), data)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		rawOffset := make([]byte, 8)
+		binary.BigEndian.PutUint64(rawOffset, uint64(msg.Offset))
+		return tx.Bucket(metaBucket).Put(offsetKey, rawOffset)
+	})
+	if err != nil {
+		return err
+	}
+	s.lastOffset = msg.Offset
+	s.hasOffset = true
+	return nil
+}
+
+func (s *State) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/rolebinding-watcher/pkg"
+	iam_io "github.com/flant/negentropy/vault-plugins/flant_iam/io"
+	iam_model "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	sharedio "github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+const stateTestTenantUUID = "00000000-0000-4000-a000-000000000001"
+
+func stateTestStore(t *testing.T, state *State) *sharedio.MemoryStore {
+	store, err := memStorage(nil, hclog.NewNullLogger())
+	require.NoError(t, err)
+	require.NoError(t, state.Load(store, msgHandler(nil)))
+	return store
+}
+
+func Test_StateResumes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	state, err := OpenState(path, "root_source.watcher", iam_io.SchemaRegistry, hclog.NewNullLogger())
+	require.NoError(t, err)
+	_, ok := state.LastProcessedOffset(nil)
+	require.False(t, ok)
+	store := stateTestStore(t, state)
+	data, err := json.Marshal(&iam_model.Tenant{UUID: stateTestTenantUUID, Version: "1", Identifier: "tenant"})
+	require.NoError(t, err)
+	msg := sharedio.MsgDecoded{Type: iam_model.TenantType, ID: stateTestTenantUUID, Offset: 41, Data: data}
+
+	txn := store.Txn(true)
+	require.NoError(t, msgHandler(nil)(txn, msg))
+	require.NoError(t, txn.Insert(pkg.UserEffectiveRolesType, &pkg.UserEffectiveRoles{UserUUID: "u1", RoleName: "ssh"}))
+	require.NoError(t, state.RecordMessage(txn, msg))
+	require.NoError(t, txn.Commit())
+	require.NoError(t, state.Close())
+
+	state, err = OpenState(path, "root_source.watcher", iam_io.SchemaRegistry, hclog.NewNullLogger())
+	require.NoError(t, err)
+	defer state.Close()
+	store = stateTestStore(t, state)
+
+	offset, ok := state.LastProcessedOffset(nil)
+	require.True(t, ok)
+	require.Equal(t, int64(41), offset)
+	tenant, err := iam_repo.NewTenantRepository(store.Txn(false)).GetByID(stateTestTenantUUID)
+	require.NoError(t, err)
+	require.Equal(t, "tenant", tenant.Identifier)
+	userEffectiveRoles, err := pkg.UserEffectiveRolesService(store.Txn(false)).GetByKey(
+		pkg.UserEffectiveRolesKey{UserUUID: "u1", RoleName: "ssh"})
+	require.NoError(t, err)
+	require.NotNil(t, userEffectiveRoles)
+}
+
+func Test_StateWritingErrorFailsRecording(t *testing.T) {
+	state, err := OpenState(filepath.Join(t.TempDir(), "state.db"), "root_source.watcher", iam_io.SchemaRegistry,
+		hclog.NewNullLogger())
+	require.NoError(t, err)
+	store := stateTestStore(t, state)
+	require.NoError(t, state.Close())
+
+	txn := store.Txn(true)
+	defer txn.Abort()
+	require.Error(t, state.RecordMessage(txn, sharedio.MsgDecoded{Type: iam_model.TenantType, ID: stateTestTenantUUID, Offset: 7}))
+
+	_, ok := state.LastProcessedOffset(nil)
+	require.False(t, ok)
+}
+
+func Test_StateOfAnotherTopicIsDropped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	state, err := OpenState(path, "root_source.watcher", iam_io.SchemaRegistry, hclog.NewNullLogger())
+	require.NoError(t, err)
+	store := stateTestStore(t, state)
+	txn := store.Txn(true)
+	require.NoError(t, state.RecordMessage(txn, sharedio.MsgDecoded{Type: iam_model.TenantType, ID: stateTestTenantUUID, Offset: 7}))
+	require.NoError(t, txn.Commit())
+	require.NoError(t, state.Close())
+
+	state, err = OpenState(path, "root_source.other", iam_io.SchemaRegistry, hclog.NewNullLogger())
+	require.NoError(t, err)
+	defer state.Close()
+
+	_, ok := state.LastProcessedOffset(nil)
+	require.False(t, ok)
+}
+
+func Test_StateUpgradesObjectsOfPreviousVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	state, err := OpenState(path, "root_source.watcher", sharedio.NewSchemaRegistry(), hclog.NewNullLogger())
+	require.NoError(t, err)
+	store := stateTestStore(t, state)
+	data, err := json.Marshal(&iam_model.Tenant{UUID: stateTestTenantUUID, Version: "1", Identifier: "tenant"})
+	require.NoError(t, err)
+	txn := store.Txn(true)
+	msg := sharedio.MsgDecoded{Type: iam_model.TenantType, ID: stateTestTenantUUID, Offset: 7, Data: data}
+	require.NoError(t, state.RecordMessage(txn, msg))
+	require.NoError(t, txn.Commit())
+	require.NoError(t, state.Close())
+
+	registry := sharedio.NewSchemaRegistry().MustRegister(iam_model.TenantType, 0, func(data []byte) ([]byte, error) {
+		tenant := &iam_model.Tenant{}
+		if err := json.Unmarshal(data, tenant); err != nil {
+			return nil, err
+		}
+		tenant.Identifier += "_upgraded"
+		return json.Marshal(tenant)
+	})
+	state, err = OpenState(path, "root_source.watcher", registry, hclog.NewNullLogger())
+	require.NoError(t, err)
+	defer state.Close()
+	store = stateTestStore(t, state)
+
+	tenant, err := iam_repo.NewTenantRepository(store.Txn(false)).GetByID(stateTestTenantUUID)
+	require.NoError(t, err)
+	require.Equal(t, "tenant_upgraded", tenant.Identifier)
+}
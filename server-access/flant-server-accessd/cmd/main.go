@@ -39,7 +39,8 @@ Find more information at https://flant.com`,
 				log.Fatal(err)
 			}
 
-			syncer := sync.NewPeriodic(database, config.AppConfig.AuthdSettings, config.AppConfig.ServerAccessSettings)
+			syncer := sync.NewPeriodic(database, config.AppConfig.AuthdSettings, config.AppConfig.ServerAccessSettings,
+				config.AppConfig.SudoersPath, config.AppConfig.StatusPath)
 			syncer.Start()
 
 			lockCh := make(chan struct{}, 0)
@@ -16,6 +16,10 @@ type Config struct {
 	vault.ServerAccessSettings
 	vault.AuthdSettings
 	DatabasePath string `json:"database"`
+	// SudoersPath is the managed sudoers drop-in, "-" turns off managing of sudoers
+	SudoersPath string `json:"sudoersPath"`
+	// StatusPath is the file with the sync status for monitoring, "-" turns off writing of the status
+	StatusPath string `json:"statusPath"`
 }
 
 var AppConfig Config
@@ -23,6 +27,8 @@ var AppConfig Config
 const (
 	DefaultConfigFile   = "server-accessd.yaml"
 	DefaultDatabasePath = "server-accessd.db"
+	DefaultSudoersPath  = "/etc/sudoers.d/negentropy"
+	DefaultStatusPath   = "server-accessd.status.json"
 )
 
 func LoadConfig(fileName string) (Config, error) {
@@ -41,6 +47,14 @@ func LoadConfig(fileName string) (Config, error) {
 	cfg.ServerAccessSettings = vault.AssembleServerAccessSettings(cfg.ServerAccessSettings)
 	cfg.AuthdSettings = vault.AssembleAuthdSettings(cfg.AuthdSettings)
 	cfg.DatabasePath = util.FirstNonEmptyString(cfg.DatabasePath, os.Getenv("DATABASE"), DefaultDatabasePath)
+	cfg.SudoersPath = util.FirstNonEmptyString(cfg.SudoersPath, os.Getenv("SUDOERS_PATH"), DefaultSudoersPath)
+	if cfg.SudoersPath == "-" {
+		cfg.SudoersPath = ""
+	}
+	cfg.StatusPath = util.FirstNonEmptyString(cfg.StatusPath, os.Getenv("STATUS_PATH"), DefaultStatusPath)
+	if cfg.StatusPath == "-" {
+		cfg.StatusPath = ""
+	}
 
 	return *cfg, nil
 }
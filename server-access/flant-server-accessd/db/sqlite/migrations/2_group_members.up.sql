@@ -0,0 +1,6 @@
+CREATE TABLE group_members
+(
+    group_name TEXT NOT NULL,
+    user_name  TEXT NOT NULL,
+    PRIMARY KEY (group_name, user_name)
+);
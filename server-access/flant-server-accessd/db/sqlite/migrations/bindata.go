@@ -2,6 +2,8 @@
 // sources:
 // 1_initialize_schema.down.sql
 // 1_initialize_schema.up.sql
+// 2_group_members.down.sql
+// 2_group_members.up.sql
 // bindata.go
 package migrations
 
@@ -119,6 +121,46 @@ func _1_initialize_schemaUpSql() (*asset, error) {
 	return a, nil
 }
 
+var __2_group_membersDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x00\x24\x00\xdb\xff\x44\x52\x4f\x50\x20\x54\x41\x42\x4c\x45\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x67\x72\x6f\x75\x70\x5f\x6d\x65\x6d\x62\x65\x72\x73\x3b\x0a\x03\x00\x65\xed\x25\x4d\x24\x00\x00\x00")
+
+func _2_group_membersDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__2_group_membersDownSql,
+		"2_group_members.down.sql",
+	)
+}
+
+func _2_group_membersDownSql() (*asset, error) {
+	bytes, err := _2_group_membersDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "2_group_members.down.sql", size: 36, mode: os.FileMode(420), modTime: time.Unix(1792224000, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __2_group_membersUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x72\x0e\x72\x75\x0c\x71\x55\x08\x71\x74\xf2\x71\x55\x48\x2f\xca\x2f\x2d\x88\xcf\x4d\xcd\x4d\x4a\x2d\x2a\xe6\xd2\xe0\x52\x50\x50\x80\x8a\xe5\x25\xe6\xa6\x2a\x84\xb8\x46\x84\x28\xf8\xf9\x87\x28\xf8\x85\xfa\xf8\xe8\x80\x65\x4b\x8b\x53\x8b\x20\x92\xd8\x64\x03\x82\x3c\x7d\x1d\x83\x22\x15\xbc\x5d\x23\x15\x34\x10\x06\xe9\x20\xb4\x69\x72\x69\x5a\x73\x01\x06\x00\x21\xe5\xad\x0b\x84\x00\x00\x00")
+
+func _2_group_membersUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__2_group_membersUpSql,
+		"2_group_members.up.sql",
+	)
+}
+
+func _2_group_membersUpSql() (*asset, error) {
+	bytes, err := _2_group_membersUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "2_group_members.up.sql", size: 132, mode: os.FileMode(420), modTime: time.Unix(1792224000, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var _bindataGo = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x01\x00\x00\xff\xff\x00\x00\x00\x00\x00\x00\x00\x00")
 
 func bindataGoBytes() ([]byte, error) {
@@ -193,6 +235,8 @@ func AssetNames() []string {
 var _bindata = map[string]func() (*asset, error){
 	"1_initialize_schema.down.sql": _1_initialize_schemaDownSql,
 	"1_initialize_schema.up.sql":   _1_initialize_schemaUpSql,
+	"2_group_members.down.sql":     _2_group_membersDownSql,
+	"2_group_members.up.sql":       _2_group_membersUpSql,
 	"bindata.go":                   bindataGo,
 }
 
@@ -239,6 +283,8 @@ type bintree struct {
 var _bintree = &bintree{nil, map[string]*bintree{
 	"1_initialize_schema.down.sql": &bintree{_1_initialize_schemaDownSql, map[string]*bintree{}},
 	"1_initialize_schema.up.sql":   &bintree{_1_initialize_schemaUpSql, map[string]*bintree{}},
+	"2_group_members.down.sql":     &bintree{_2_group_membersDownSql, map[string]*bintree{}},
+	"2_group_members.up.sql":       &bintree{_2_group_membersUpSql, map[string]*bintree{}},
 	"bindata.go":                   &bintree{bindataGo, map[string]*bintree{}},
 }}
 
@@ -25,7 +25,7 @@ import (
 )
 
 const (
-	currentDatabaseVersion          = 1
+	currentDatabaseVersion          = 2
 	usersTempTableCreateStatementv1 = `
 CREATE TEMPORARY TABLE temp_users
 (
@@ -137,6 +137,11 @@ func (db *UserDatabase) Sync(ctx context.Context, uwg types.UsersWithGroups) err
 		return err
 	}
 
+	_, err = tx.Exec(`DELETE FROM group_members`)
+	if err != nil {
+		return err
+	}
+
 	preparedInsertUsers, err := tx.PrepareNamed(`
 INSERT INTO users (name, uid, gid, gecos, homedir, shell, hashed_pass, principal) 
 VALUES (:name, :uid, :gid, :gecos, :homedir, :shell, :hashed_pass, :principal);`)
@@ -158,11 +163,25 @@ VALUES (:name, :gid);`)
 		return err
 	}
 
+	preparedInsertGroupMembers, err := tx.Prepare(`
+INSERT INTO group_members (group_name, user_name) 
+VALUES (?, ?);`)
+	if err != nil {
+		return err
+	}
+
 	for _, group := range uwg.Groups {
 		_, err := preparedInsertGroups.Exec(group)
 		if err != nil {
 			return err
 		}
+
+		for _, member := range group.Members {
+			_, err := preparedInsertGroupMembers.Exec(group.Name, member)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	err = tx.Commit()
@@ -321,3 +340,13 @@ func (db *UserDatabase) GetGroups(ctx context.Context) ([]types.Group, error) {
 
 	return groups, nil
 }
+
+func (db *UserDatabase) GetGroupMembers(ctx context.Context, name string) ([]string, error) {
+	var members []string
+	err := db.db.SelectContext(ctx, &members, `SELECT user_name FROM group_members WHERE group_name == ? ORDER BY user_name;`, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
@@ -805,3 +805,32 @@ func TestUserDatabase_Sync(t *testing.T) {
 		})
 	}
 }
+
+func TestUserDatabase_SyncGroupMembers(t *testing.T) {
+	ctx, cancel := getDeadlineContext(t)
+	defer cancel()
+
+	db := newTestDB(t)
+	err := db.Sync(ctx, types.UsersWithGroups{
+		Groups: []types.Group{
+			{Name: "srvgroup999", Gid: 999},
+			{Name: "docker", Gid: 998, Members: []string{"vasya", "petya"}},
+		},
+	})
+	require.Nil(t, err)
+
+	members, err := db.GetGroupMembers(ctx, "docker")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"petya", "vasya"}, members)
+
+	err = db.Sync(ctx, types.UsersWithGroups{
+		Groups: []types.Group{
+			{Name: "docker", Gid: 998, Members: []string{"vasya"}},
+		},
+	})
+	require.Nil(t, err)
+
+	members, err = db.GetGroupMembers(ctx, "docker")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"vasya"}, members)
+}
@@ -0,0 +1,67 @@
+package sync
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Status is the state of syncing users, it is written into the status file for monitoring
+type Status struct {
+	// Healthy is false, if the last sync attempt is failed, the last known good users are kept at the database
+	Healthy             bool      `json:"healthy"`
+	LastAttempt         time.Time `json:"last_attempt"`
+	LastSuccess         time.Time `json:"last_success"`
+	LastChange          time.Time `json:"last_change"`
+	LastError           string    `json:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	// ResourceVersion is the version of users, applied at the server
+	ResourceVersion string    `json:"resource_version,omitempty"`
+	Users           int       `json:"users"`
+	NextAttempt     time.Time `json:"next_attempt"`
+}
+
+// WriteStatusFile replaces the status file at once, so readers never see a partially written file
+func WriteStatusFile(path string, status Status) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(append(data, '\n'))
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func ReadStatusFile(path string) (Status, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Status{}, err
+	}
+
+	var status Status
+	err = json.Unmarshal(data, &status)
+
+	return status, err
+}
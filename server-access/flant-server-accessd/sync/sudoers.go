@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/flant/negentropy/server-access/flant-server-accessd/system"
+	"github.com/flant/negentropy/server-access/flant-server-accessd/types"
+)
+
+const sudoersHeader = "# Managed by flant-server-accessd, DO NOT EDIT\n"
+
+// RenderSudoers returns the sudoers drop-in with rules of all users, names are quoted as they can contain '@'
+func RenderSudoers(users []types.User) []byte {
+	sorted := append([]types.User{}, users...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var buf bytes.Buffer
+	buf.WriteString(sudoersHeader)
+	for _, user := range sorted {
+		for _, rule := range user.SudoRules {
+			fmt.Fprintf(&buf, "%q %s\n", user.Name, rule)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// ApplySudoers writes sudo rules of users, empty path turns off managing of sudoers
+func ApplySudoers(sudoersPath string, users []types.User) error {
+	if sudoersPath == "" {
+		return nil
+	}
+
+	var sysOp system.Interface
+	sysOp = system.NewSystemOperator()
+
+	return sysOp.WriteSudoers(sudoersPath, RenderSudoers(users))
+}
@@ -4,59 +4,142 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"github.com/flant/negentropy/server-access/flant-server-accessd/db"
+	"github.com/flant/negentropy/server-access/flant-server-accessd/system"
 	"github.com/flant/negentropy/server-access/flant-server-accessd/types"
 	"github.com/flant/negentropy/server-access/vault"
 )
 
+const (
+	DefaultSyncInterval = 30 * time.Second
+	// maxSyncBackoff limits the delay between failed attempts
+	maxSyncBackoff = 5 * time.Minute
+)
+
+// Periodic syncs users from Vault, users are applied only if their resource version is changed. If syncing fails,
+// the last known good users are kept and the next attempt is delayed exponentially.
 type Periodic struct {
 	DB            db.UserDatabase
 	AuthdSettings vault.AuthdSettings
 	Settings      vault.ServerAccessSettings
+	// SudoersPath is the managed sudoers drop-in, empty path turns off managing of sudoers
+	SudoersPath string
+	// StatusPath is the status file for monitoring, empty path turns off writing of the status
+	StatusPath string
+	Interval   time.Duration
+
+	// fetchPosixUsers returns users, if they are changed since the resource version
+	fetchPosixUsers  func(resourceVersion string) (vault.PosixUsers, error)
+	systemGroupsPath string
+	status           Status
 }
 
 func NewPeriodic(dbInstance db.UserDatabase, authdSettings vault.AuthdSettings,
-	settings vault.ServerAccessSettings) *Periodic {
-	return &Periodic{
-		DB:            dbInstance,
-		AuthdSettings: authdSettings,
-		Settings:      settings,
+	settings vault.ServerAccessSettings, sudoersPath string, statusPath string) *Periodic {
+	p := &Periodic{
+		DB:               dbInstance,
+		AuthdSettings:    authdSettings,
+		Settings:         settings,
+		SudoersPath:      sudoersPath,
+		StatusPath:       statusPath,
+		Interval:         DefaultSyncInterval,
+		systemGroupsPath: system.SystemGroupsFile,
 	}
+	p.fetchPosixUsers = p.fetchFromVault
+
+	return p
 }
 
 func (p *Periodic) Start() {
 	go func() {
 		for {
-			err := p.SyncUsers()
-			if err != nil {
-				os.Exit(1)
-			}
-			time.Sleep(30 * time.Second)
+			time.Sleep(p.attempt())
 		}
 	}()
 }
 
-func (p *Periodic) SyncUsers() error {
+// Status returns the state of syncing, it should not be called concurrently with running Start
+func (p *Periodic) Status() Status {
+	return p.status
+}
+
+// attempt syncs users, writes the status and returns the delay before the next attempt
+func (p *Periodic) attempt() time.Duration {
+	now := time.Now()
+	err := p.SyncUsers()
+
+	p.status.LastAttempt = now
+	if err != nil {
+		log.Printf("Sync users: %v", err)
+		p.status.Healthy = false
+		p.status.LastError = err.Error()
+		p.status.ConsecutiveFailures++
+	} else {
+		p.status.Healthy = true
+		p.status.LastError = ""
+		p.status.ConsecutiveFailures = 0
+		p.status.LastSuccess = now
+	}
+
+	delay := syncBackoff(p.Interval, p.status.ConsecutiveFailures)
+	p.status.NextAttempt = now.Add(delay)
+
+	if p.StatusPath != "" {
+		err = WriteStatusFile(p.StatusPath, p.status)
+		if err != nil {
+			log.Printf("Write status file: %v", err)
+		}
+	}
+
+	return delay
+}
+
+// syncBackoff doubles the interval for every failure in a row, up to maxSyncBackoff
+func syncBackoff(interval time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 0; i < failures && delay < maxSyncBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxSyncBackoff {
+		delay = maxSyncBackoff
+	}
+
+	return delay
+}
+
+func (p *Periodic) fetchFromVault(resourceVersion string) (vault.PosixUsers, error) {
 	vaultClient, err := vault.ClientFromAuthd(p.AuthdSettings, p.Settings)
 	if err != nil {
-		log.Printf("Open Vault session: %v", err)
-		return nil
+		return vault.PosixUsers{}, fmt.Errorf("open Vault session: %v", err)
 	}
 
-	posixUsers, err := vault.NewFlantIAMAuth(vaultClient).PosixUsers(p.Settings)
+	return vault.NewFlantIAMAuth(vaultClient).PosixUsersSince(p.Settings, resourceVersion)
+}
+
+// SyncUsers applies users from Vault, the database is not touched, if users are not changed or
+// any step fails before syncing the database
+func (p *Periodic) SyncUsers() error {
+	posixUsers, err := p.fetchPosixUsers(p.status.ResourceVersion)
 	if err != nil {
-		log.Printf("List POSIX users: %v", err)
+		return fmt.Errorf("list POSIX users: %v", err)
+	}
+
+	if posixUsers.NotModified ||
+		(posixUsers.ResourceVersion != "" && posixUsers.ResourceVersion == p.status.ResourceVersion) {
 		return nil
 	}
 
+	systemGroups, err := system.ReadSystemGroups(p.systemGroupsPath)
+	if err != nil {
+		return fmt.Errorf("read system groups: %v", err)
+	}
+
 	// TODO: Can we use PosixUsers directly without conversion?
-	uwg, err := ConvertPOSIXUsers(posixUsers)
+	uwg, err := ConvertPOSIXUsers(posixUsers.Users, systemGroups)
 	if err != nil {
-		log.Printf("Convert POSIX users: %v", err)
-		return nil
+		return fmt.Errorf("convert POSIX users: %v", err)
 	}
 
 	err = ApplyChanges(context.Background(), p.DB, uwg)
@@ -69,12 +152,24 @@ func (p *Periodic) SyncUsers() error {
 		return fmt.Errorf("sync database after apply user changes: %v", err)
 	}
 
+	err = ApplySudoers(p.SudoersPath, uwg.Users)
+	if err != nil {
+		return fmt.Errorf("apply sudoers: %v", err)
+	}
+
+	p.status.ResourceVersion = posixUsers.ResourceVersion
+	p.status.Users = len(uwg.Users)
+	p.status.LastChange = time.Now()
+
 	return nil
 }
 
-func ConvertPOSIXUsers(posixUsers []vault.PosixUser) (types.UsersWithGroups, error) {
+// ConvertPOSIXUsers makes users and their groups, supplementary groups should exist at systemGroups,
+// as their gids can't be allocated by us, others are skipped
+func ConvertPOSIXUsers(posixUsers []vault.PosixUser, systemGroups map[string]uint) (types.UsersWithGroups, error) {
 	groupMap := make(map[int]types.Group)
 	userMap := make(map[int]types.User)
+	supplementaryGroupMap := make(map[string]types.Group)
 
 	for _, posixUser := range posixUsers {
 		if _, has := userMap[posixUser.UID]; !has {
@@ -87,6 +182,21 @@ func ConvertPOSIXUsers(posixUsers []vault.PosixUser) (types.UsersWithGroups, err
 				Shell:      posixUser.Shell,
 				HashedPass: posixUser.Password,
 				Principal:  posixUser.Principal,
+				SudoRules:  posixUser.SudoRules,
+			}
+
+			for _, groupName := range posixUser.Groups {
+				gid, has := systemGroups[groupName]
+				if !has {
+					log.Printf("Skip group %q of user %q: group is not found at the system", groupName, posixUser.Name)
+					continue
+				}
+				group, has := supplementaryGroupMap[groupName]
+				if !has {
+					group = types.Group{Name: groupName, Gid: gid}
+				}
+				group.Members = append(group.Members, posixUser.Name)
+				supplementaryGroupMap[groupName] = group
 			}
 		}
 
@@ -106,6 +216,9 @@ func ConvertPOSIXUsers(posixUsers []vault.PosixUser) (types.UsersWithGroups, err
 	for _, group := range groupMap {
 		groups = append(groups, group)
 	}
+	for _, group := range supplementaryGroupMap {
+		groups = append(groups, group)
+	}
 
 	return types.UsersWithGroups{Users: users, Groups: groups}, nil
 }
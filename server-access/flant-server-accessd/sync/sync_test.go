@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/server-access/flant-server-accessd/types"
+	"github.com/flant/negentropy/server-access/vault"
+)
+
+func TestConvertPOSIXUsers_SupplementaryGroups(t *testing.T) {
+	posixUsers := []vault.PosixUser{
+		{UID: 1001, Name: "vasya", Gid: 999, Groups: []string{"docker", "unknown"}},
+		{UID: 1002, Name: "petya@tenant2", Gid: 999, Groups: []string{"docker", "adm"}},
+	}
+
+	uwg, err := ConvertPOSIXUsers(posixUsers, map[string]uint{"docker": 998, "adm": 4})
+	require.NoError(t, err)
+
+	assert.Len(t, uwg.Users, 2)
+	assert.ElementsMatch(t, []types.Group{
+		{Name: "srvgroup999", Gid: 999},
+		{Name: "docker", Gid: 998, Members: []string{"vasya", "petya@tenant2"}},
+		{Name: "adm", Gid: 4, Members: []string{"petya@tenant2"}},
+	}, uwg.Groups)
+}
+
+func TestRenderSudoers(t *testing.T) {
+	users := []types.User{
+		{Name: "vasya", SudoRules: []string{"ALL=(ALL:ALL) ALL"}},
+		{Name: "nobody"},
+		{Name: "petya@tenant2", SudoRules: []string{"ALL=(ALL) NOPASSWD: /usr/bin/systemctl", "ALL=(postgres) ALL"}},
+	}
+
+	assert.Equal(t, sudoersHeader+
+		"\"petya@tenant2\" ALL=(ALL) NOPASSWD: /usr/bin/systemctl\n"+
+		"\"petya@tenant2\" ALL=(postgres) ALL\n"+
+		"\"vasya\" ALL=(ALL:ALL) ALL\n", string(RenderSudoers(users)))
+}
+
+type fakeUserDatabase struct {
+	synced []types.UsersWithGroups
+}
+
+func (f *fakeUserDatabase) Migrate() error { return nil }
+
+func (f *fakeUserDatabase) Sync(_ context.Context, uwg types.UsersWithGroups) error {
+	f.synced = append(f.synced, uwg)
+	return nil
+}
+
+func (f *fakeUserDatabase) GetChanges(context.Context, types.UsersWithGroups) ([]types.User, []types.User, error) {
+	return nil, nil, nil
+}
+
+func (f *fakeUserDatabase) Close() {}
+
+func TestPeriodic_SyncOnlyChangedUsers(t *testing.T) {
+	dir := t.TempDir()
+	groupsPath := filepath.Join(dir, "group")
+	require.NoError(t, os.WriteFile(groupsPath, []byte("root:x:0:\ndocker:x:998:\n"), 0o644))
+	statusPath := filepath.Join(dir, "status.json")
+
+	db := &fakeUserDatabase{}
+	p := NewPeriodic(db, vault.AuthdSettings{}, vault.ServerAccessSettings{}, "", statusPath)
+	p.systemGroupsPath = groupsPath
+
+	var (
+		passedVersions []string
+		response       vault.PosixUsers
+		responseErr    error
+	)
+	p.fetchPosixUsers = func(resourceVersion string) (vault.PosixUsers, error) {
+		passedVersions = append(passedVersions, resourceVersion)
+		return response, responseErr
+	}
+
+	response = vault.PosixUsers{
+		Users:           []vault.PosixUser{{UID: 1001, Name: "vasya", Gid: 999, Groups: []string{"docker"}}},
+		ResourceVersion: "v1",
+	}
+	assert.Equal(t, DefaultSyncInterval, p.attempt())
+	require.Len(t, db.synced, 1)
+	assert.True(t, p.Status().Healthy)
+	assert.Equal(t, "v1", p.Status().ResourceVersion)
+	assert.Equal(t, 1, p.Status().Users)
+
+	response = vault.PosixUsers{ResourceVersion: "v1", NotModified: true}
+	p.attempt()
+	assert.Len(t, db.synced, 1, "unchanged users should not be synced")
+
+	responseErr = errors.New("vault is sealed")
+	assert.Equal(t, 2*DefaultSyncInterval, p.attempt())
+	assert.Equal(t, 4*DefaultSyncInterval, p.attempt())
+	assert.Len(t, db.synced, 1, "the last known good users should be kept")
+
+	status, err := ReadStatusFile(statusPath)
+	require.NoError(t, err)
+	assert.False(t, status.Healthy)
+	assert.Equal(t, 2, status.ConsecutiveFailures)
+	assert.Equal(t, "list POSIX users: vault is sealed", status.LastError)
+	assert.Equal(t, "v1", status.ResourceVersion)
+	assert.Equal(t, []string{"", "v1", "v1", "v1"}, passedVersions)
+}
+
+func TestSyncBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, syncBackoff(30*time.Second, 0))
+	assert.Equal(t, 60*time.Second, syncBackoff(30*time.Second, 1))
+	assert.Equal(t, maxSyncBackoff, syncBackoff(30*time.Second, 100))
+}
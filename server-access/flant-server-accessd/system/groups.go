@@ -0,0 +1,41 @@
+package system
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const SystemGroupsFile = "/etc/group"
+
+// ReadSystemGroups returns gids of groups from the group file by names. The file is read directly,
+// as lookups through nss return also groups of our own database.
+func ReadSystemGroups(path string) (map[string]uint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	groups := make(map[string]uint)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s: wrong line %q", path, line)
+		}
+		gid, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: gid of %q: %v", path, fields[0], err)
+		}
+		groups[fields[0]] = uint(gid)
+	}
+
+	return groups, scanner.Err()
+}
@@ -23,6 +23,8 @@ type Interface interface {
 	DeleteHomeDir(dir string) error
 	// Kill all user processes.
 	PurgeUserLegacy(username string) error
+	// Replace the managed sudoers drop-in.
+	WriteSudoers(path string, content []byte) error
 }
 
 type SystemOperator struct {
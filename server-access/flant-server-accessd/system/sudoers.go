@@ -0,0 +1,48 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// WriteSudoers replaces the managed sudoers drop-in. The content is checked by visudo, if it is installed,
+// as sudo refuses to work with a broken drop-in.
+func (s *SystemOperator) WriteSudoers(path string, content []byte) error {
+	if s.dryRun {
+		fmt.Printf("Write sudoers '%s':\n%s", path, content)
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), 0o440)
+	if err != nil {
+		return err
+	}
+
+	if visudo, err := exec.LookPath("visudo"); err == nil {
+		out, err := exec.Command(visudo, "-c", "-q", "-f", tmp.Name()).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("check sudoers: %v: %s", err, out)
+		}
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
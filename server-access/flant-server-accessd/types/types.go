@@ -9,11 +9,15 @@ type User struct {
 	Shell      string `json:"shell,omitempty" db:"shell"`
 	HashedPass string `json:"hashed_pass,omitempty" db:"hashed_pass"`
 	Principal  string `json:"principal"`
+	// SudoRules are rendered into the sudoers drop-in, they are not stored at the database
+	SudoRules []string `json:"sudo_rules,omitempty" db:"-"`
 }
 
 type Group struct {
 	Name string `json:"name,omitempty" db:"name"`
 	Gid  uint   `json:"gid,omitempty" db:"gid"`
+	// Members are names of users, which have the group as supplementary one
+	Members []string `json:"members,omitempty" db:"-"`
 }
 
 type UsersWithGroups struct {
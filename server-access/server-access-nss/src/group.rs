@@ -7,6 +7,11 @@ use rusqlite::{params, Connection, Row, NO_PARAMS};
 use crate::db::from_result;
 use crate::db::DB_PATH;
 
+// members are joined by comma, names of users can't contain it
+const SELECT_GROUPS: &str = "SELECT name, gid, \
+     (SELECT group_concat(user_name, ',') FROM group_members WHERE group_name = groups.name) \
+     FROM groups";
+
 pub struct SqliteGroup;
 libnss_group_hooks!(flantauth, SqliteGroup);
 
@@ -38,30 +43,33 @@ impl GroupHooks for SqliteGroup {
 }
 
 fn get_all_entries(conn: Connection) -> Result<Vec<Group>> {
-    conn.prepare("SELECT name, gid FROM groups")?
+    conn.prepare(SELECT_GROUPS)?
         .query_and_then(NO_PARAMS, from_row)?
         .collect()
 }
 fn get_entry_by_gid(conn: Connection, gid: u32) -> Result<Group> {
     conn.query_row_and_then(
-        "SELECT name, gid FROM groups WHERE gid = ?1",
+        &format!("{} WHERE gid = ?1", SELECT_GROUPS),
         params![gid],
         from_row,
     )
 }
 fn get_entry_by_name(conn: Connection, name: &str) -> Result<Group> {
     conn.query_row_and_then(
-        "SELECT name, gid FROM groups WHERE name = ?1",
+        &format!("{} WHERE name = ?1", SELECT_GROUPS),
         params![name],
         from_row,
     )
 }
 
 fn from_row(row: &Row) -> Result<Group> {
+    let members: Option<String> = row.get(2)?;
     Ok(Group {
         name: row.get(0)?,
         gid: row.get(1)?,
         passwd: "".to_string(),
-        members: Vec::new(),
+        members: members
+            .map(|m| m.split(',').map(String::from).collect())
+            .unwrap_or_default(),
     })
 }
\ No newline at end of file
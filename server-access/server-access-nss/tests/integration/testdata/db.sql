@@ -4,6 +4,13 @@ CREATE TABLE groups
     gid  INTEGER NOT NULL
 );
 
+CREATE TABLE group_members
+(
+    group_name TEXT NOT NULL,
+    user_name  TEXT NOT NULL,
+    PRIMARY KEY (group_name, user_name)
+);
+
 CREATE TABLE users
 (
     name        TEXT PRIMARY KEY NOT NULL,
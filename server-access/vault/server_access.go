@@ -19,6 +19,10 @@ type PosixUsersData struct {
 
 type PosixUsers struct {
 	Users []PosixUser `json:"posix_users"`
+	// ResourceVersion is the hash of users, it should be passed to the next request
+	ResourceVersion string `json:"resource_version"`
+	// NotModified is set, if users are not changed since the passed resource version, users are not passed then
+	NotModified bool `json:"not_modified"`
 }
 
 type PosixUser struct {
@@ -31,6 +35,9 @@ type PosixUser struct {
 	Shell    string `json:"shell"`
 	Gecos    string `json:"gecos"`
 	Gid      int    `json:"gid"`
+	// Groups are names of supplementary groups
+	Groups    []string `json:"groups"`
+	SudoRules []string `json:"sudo_rules"`
 }
 
 type ServerAccessSettings struct {
@@ -60,36 +67,59 @@ func NewFlantIAMAuth(client *api.Client) *FlantIAMAuth {
 }
 
 func (c *FlantIAMAuth) PosixUsers(settings ServerAccessSettings) ([]PosixUser, error) {
+	users, err := c.PosixUsersSince(settings, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return users.Users, nil
+}
+
+// PosixUsersSince returns users, if they are changed since resourceVersion, empty resourceVersion means
+// users are needed anyway
+func (c *FlantIAMAuth) PosixUsersSince(settings ServerAccessSettings, resourceVersion string) (PosixUsers, error) {
 	r := c.c.NewRequest("GET", fmt.Sprintf("/v1/auth/%s/tenant/%s/project/%s/server/%s/posix_users", FlantIAMMountpoint, settings.TenantUUID, settings.ProjectUUID, settings.ServerUUID))
+	if resourceVersion != "" {
+		r.Params.Set("resource_version", resourceVersion)
+	}
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	defer cancelFunc()
 	resp, err := c.c.RawRequestWithContext(ctx, r)
 	if err != nil {
-		return nil, err
+		return PosixUsers{}, err
 	}
 	defer resp.Body.Close()
 
-	return ParsePosixUsers(resp.Body)
+	return ParsePosixUsersResponse(resp.Body)
 }
 
 func ParsePosixUsers(r io.Reader) ([]PosixUser, error) {
+	users, err := ParsePosixUsersResponse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return users.Users, nil
+}
+
+func ParsePosixUsersResponse(r io.Reader) (PosixUsers, error) {
 	// First read the data into a buffer. Not super efficient but we want to
 	// know if we actually have a body or not.
 	var buf bytes.Buffer
 	_, err := buf.ReadFrom(r)
 	if err != nil {
-		return nil, err
+		return PosixUsers{}, err
 	}
 	if buf.Len() == 0 {
-		return nil, nil
+		return PosixUsers{}, nil
 	}
 
 	var users PosixUsersData
 	err = json.Unmarshal(buf.Bytes(), &users)
 	if err != nil {
-		return nil, err
+		return PosixUsers{}, err
 	}
 
-	return users.Data.Users, nil
+	return users.Data, nil
 }
@@ -17,10 +17,13 @@ import (
 	"github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access"
 	ext_sa_io "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/io"
 	ext_sa_repo "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/repo"
+	iam_io "github.com/flant/negentropy/vault-plugins/flant_iam/io"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/change_feed"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_destination"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_source"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
 	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
 	sharedio "github.com/flant/negentropy/vault-plugins/shared/io"
 	sharedjwt "github.com/flant/negentropy/vault-plugins/shared/jwt"
@@ -110,15 +113,15 @@ func newBackend(ctx context.Context, conf *logical.BackendConfig) (logical.Backe
 		return nil, err
 	}
 
+	restoreHandlers := []kafka_source.RestoreFunc{
+		jwtkafka.SelfRestoreMessage,
+		ext_sa_io.HandleServerAccessObjects,
+		ext_ff_io.HandleFlantFlowObjects,
+	}
+
 	if backentutils.IsLoading(conf) {
 		logger.Info("final run Factory, apply kafka operations on MemoryStore")
 
-		restoreHandlers := []kafka_source.RestoreFunc{
-			jwtkafka.SelfRestoreMessage,
-			ext_sa_io.HandleServerAccessObjects,
-			ext_ff_io.HandleFlantFlowObjects,
-		}
-
 		storage.AddKafkaSource(kafka_source.NewSelfKafkaSource(mb, restoreHandlers, conf.Logger))
 
 		storage.AddKafkaSource(jwtkafka.NewJWKSKafkaSource(conf.StorageView, mb, conf.Logger))
@@ -128,6 +131,18 @@ func newBackend(ctx context.Context, conf *logical.BackendConfig) (logical.Backe
 			return nil, err
 		}
 
+		if mb.Configured() {
+			// the history topic can be absent at plugins, configured before it was introduced
+			err = mb.CreateTopic(ctx, iam_io.SelfHistoryTopicName(mb), iam_io.SelfHistoryTopicConfig)
+			if err != nil {
+				return nil, err
+			}
+			err = kafka_source.SeedSelfHistory(mb, conf.Logger)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		// destinations
 		storage.AddKafkaDestination(kafka_destination.NewSelfKafkaDestination(mb))
 
@@ -163,6 +178,13 @@ func newBackend(ctx context.Context, conf *logical.BackendConfig) (logical.Backe
 
 	b.InitializeFunc = initializer(storage)
 
+	snapshotWriter := kafka_source.NewSelfSnapshotWriter(mb, conf.Logger)
+
+	history := kafka_source.NewPointInTimeStorage(mb, schema, restoreHandlers, conf.Logger)
+
+	feed := change_feed.NewFeed(change_feed.DefaultCapacity)
+	feed.RegisterHooks(storage)
+
 	tokenController := sharedjwt.NewJwtController(
 		storage,
 		mb.GetEncryptionPublicKeyStrict,
@@ -206,6 +228,12 @@ func newBackend(ctx context.Context, conf *logical.BackendConfig) (logical.Backe
 			return nil
 		})
 
+		run("expiredObjectsSweeper", func() error {
+			return archiveExpiredObjects(storage, time.Now(), periodicLogger)
+		})
+
+		run("snapshotWriter", snapshotWriter.OnPeriodical)
+
 		return allErrors
 	}
 
@@ -215,24 +243,29 @@ func newBackend(ctx context.Context, conf *logical.BackendConfig) (logical.Backe
 	}
 
 	b.Paths = framework.PathAppend(
-		tenantPaths(b, storage),
+		tenantPaths(b, storage, history),
 
-		userPaths(b, tokenController, storage),
-		serviceAccountPaths(b, tokenController, storage),
+		userPaths(b, tokenController, storage, history),
+		serviceAccountPaths(b, tokenController, storage, history),
 
-		groupPaths(b, storage),
-		projectPaths(b, storage),
+		groupPaths(b, storage, history),
+		projectPaths(b, storage, history),
 		featureFlagPaths(b, storage),
-		roleBindingPaths(b, storage),
+		roleBindingPaths(b, storage, history),
 		roleBindingApprovalPaths(b, storage),
-		rolePaths(b, storage),
+		accessRequestPaths(b, storage),
+		rolePaths(b, storage, history),
 
 		replicasPaths(b, storage),
 		kafkaPaths(b, storage, conf.Logger),
+		sharedio.DeadLetterPaths(storage),
+		sharedio.MetricsPaths(storage),
+		watchPaths(b, feed),
 		identitySharingPaths(b, storage),
 
 		ext_server_access.ServerPaths(b, storage, tokenController),
 		ext_server_access.ServerConfigurePaths(b, storage),
+		ext_server_access.PosixAttributesPaths(b, storage),
 
 		tokenController.ApiPaths(),
 
@@ -255,3 +288,29 @@ func newBackend(ctx context.Context, conf *logical.BackendConfig) (logical.Backe
 const commonHelp = `
 IAM API here
 `
+
+// archiveExpiredObjects archives every expired object by the own transaction,
+// so the failing object is logged and skipped, and it doesn't block archiving of others
+func archiveExpiredObjects(storage *sharedio.MemoryStore, now time.Time, logger log.Logger) error {
+	readTx := storage.Txn(false)
+	expired, err := usecase.ExpiredObjects(readTx).ListExpired(now)
+	readTx.Abort()
+	if err != nil {
+		return err
+	}
+	for _, obj := range expired {
+		if err = archiveExpiredObject(storage, obj, now); err != nil {
+			logger.Error(fmt.Sprintf("Can not archive expired %s %s, skipped: %s", obj.Type, obj.UUID, err), "err", err)
+		}
+	}
+	return nil
+}
+
+func archiveExpiredObject(storage *sharedio.MemoryStore, obj usecase.ExpiredObject, now time.Time) error {
+	tx := storage.Txn(true)
+	defer tx.Abort()
+	if err := usecase.ExpiredObjects(tx).Archive(obj, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,37 @@
+package backend
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_source"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+// asOfFieldSchema is the optional field of read-only endpoints, which allows to query the state at the past moment
+var asOfFieldSchema = &framework.FieldSchema{
+	Type:        framework.TypeString,
+	Description: "Optional moment to read the state at, RFC3339 or unix seconds. The state of a past moment is built in background, until it is built the response has status 503",
+	Required:    false,
+}
+
+// storageAsOf returns the current storage, or the state of the storage at the past moment passed by as_of,
+// the state of the past moment is built in background, consts.ErrNotReady is returned until it is built
+func storageAsOf(storage *io.MemoryStore, history *kafka_source.PointInTimeStorage,
+	data *framework.FieldData) (*io.MemoryStore, error) {
+	rawAsOf, ok := data.GetOk("as_of")
+	if !ok || rawAsOf.(string) == "" {
+		return storage, nil
+	}
+	asOf, err := parseAsOf(rawAsOf.(string))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", consts.ErrInvalidArg, err.Error())
+	}
+	if !asOf.Before(time.Now()) {
+		return storage, nil
+	}
+	return history.StoreAt(asOf)
+}
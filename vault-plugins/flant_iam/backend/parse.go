@@ -1,9 +1,16 @@
 package backend
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/invopop/yaml"
 
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
 )
 
 func parseMembers(rawList interface{}) ([]model.MemberNotation, error) {
@@ -77,3 +84,60 @@ func parseBoundRoles(rawList interface{}) ([]model.BoundRole, error) {
 
 	return boundRoles, nil
 }
+
+// parseRoleBindingCondition returns nil if condition is not passed
+func parseRoleBindingCondition(raw interface{}) (*model.RoleBindingCondition, error) {
+	rawCondition, ok := raw.(map[string]interface{})
+	if !ok || len(rawCondition) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(rawCondition)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse condition: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	condition := &model.RoleBindingCondition{}
+	if err = decoder.Decode(condition); err != nil {
+		return nil, fmt.Errorf("cannot parse condition: %w", err)
+	}
+	return condition, nil
+}
+
+// parseTenantDocument accepts the document as a JSON object, or as a string in YAML or JSON format
+func parseTenantDocument(raw interface{}) (*usecase.TenantDocument, error) {
+	var data []byte
+	var err error
+	switch document := raw.(type) {
+	case nil:
+		return nil, fmt.Errorf("document is required")
+	case string:
+		data, err = yaml.YAMLToJSON([]byte(document))
+	case map[string]interface{}:
+		data, err = json.Marshal(document)
+	default:
+		return nil, fmt.Errorf("document should be an object or a string, got %T", raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse document: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	doc := &usecase.TenantDocument{}
+	if err = decoder.Decode(doc); err != nil {
+		return nil, fmt.Errorf("cannot parse document: %w", err)
+	}
+	return doc, nil
+}
+
+// parseAsOf accepts the moment in RFC3339 format or as unix seconds
+func parseAsOf(raw string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	asOf, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse as_of %q, RFC3339 or unix seconds are expected", raw)
+	}
+	return asOf, nil
+}
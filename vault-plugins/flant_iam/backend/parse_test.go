@@ -4,6 +4,8 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 )
 
@@ -154,3 +156,54 @@ func Test_parseMembers(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseTenantDocument(t *testing.T) {
+	yamlDocument := `
+tenant:
+  uuid: 00000000-0000-4000-a000-000000000001
+  identifier: tenant1
+users:
+- uuid: 00000000-0000-4000-a000-000000000002
+  tenant_uuid: 00000000-0000-4000-a000-000000000001
+  identifier: vasya
+`
+	jsonDocument := map[string]interface{}{
+		"tenant": map[string]interface{}{"uuid": "00000000-0000-4000-a000-000000000001", "identifier": "tenant1"},
+		"users": []interface{}{map[string]interface{}{
+			"uuid":        "00000000-0000-4000-a000-000000000002",
+			"tenant_uuid": "00000000-0000-4000-a000-000000000001",
+			"identifier":  "vasya",
+		}},
+	}
+
+	for name, raw := range map[string]interface{}{"yaml": yamlDocument, "json": jsonDocument} {
+		doc, err := parseTenantDocument(raw)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		if doc.Tenant.Identifier != "tenant1" || len(doc.Users) != 1 || doc.Users[0].Identifier != "vasya" {
+			t.Errorf("%s: wrong document: %#v", name, doc)
+		}
+	}
+
+	if _, err := parseTenantDocument("tenant: {unknown_field: 1}"); err == nil {
+		t.Errorf("unknown field: error expected")
+	}
+}
+
+func Test_parseAsOf(t *testing.T) {
+	for raw, want := range map[string]int64{
+		"1640995200":                1640995200,
+		"2022-01-01T00:00:00Z":      1640995200,
+		"2022-01-01T03:00:00+03:00": 1640995200,
+	} {
+		asOf, err := parseAsOf(raw)
+
+		require.NoError(t, err, raw)
+		require.Equal(t, want, asOf.Unix(), raw)
+	}
+
+	_, err := parseAsOf("yesterday")
+
+	require.Error(t, err)
+}
@@ -0,0 +1,418 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
+	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+	"github.com/flant/negentropy/vault-plugins/shared/uuid"
+)
+
+type accessRequestBackend struct {
+	logical.Backend
+	storage *io.MemoryStore
+}
+
+func accessRequestPaths(b logical.Backend, storage *io.MemoryStore) []*framework.Path {
+	bb := &accessRequestBackend{
+		Backend: b,
+		storage: storage,
+	}
+	return bb.paths()
+}
+
+func (b accessRequestBackend) paths() []*framework.Path {
+	decisionFields := map[string]*framework.FieldSchema{
+		"tenant_uuid": {
+			Type:        framework.TypeNameString,
+			Description: "ID of a tenant",
+			Required:    true,
+		},
+		"uuid": {
+			Type:        framework.TypeNameString,
+			Description: "ID of an access request",
+			Required:    true,
+		},
+	}
+	// Approve, the approval through the mfa path is MFA-verified
+	approve := &framework.Path{
+		Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/access_request/" + uuid.Pattern("uuid") + "/approve$",
+		Fields:  decisionFields,
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.CreateOperation: &framework.PathOperation{
+				Callback: b.handleDecision(true),
+				Summary:  "Vote for the access request by the owner of the token.",
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.handleDecision(true),
+				Summary:  "Vote for the access request by the owner of the token.",
+			},
+		},
+	}
+	return []*framework.Path{
+		// Policy of the tenant
+		{
+			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/access_request_policy$",
+			Fields: map[string]*framework.FieldSchema{
+				"tenant_uuid": {
+					Type:        framework.TypeNameString,
+					Description: "ID of a tenant",
+					Required:    true,
+				},
+				"approvers": {
+					Type:        framework.TypeSlice,
+					Description: "Approvers list",
+					Required:    true,
+				},
+				"required_votes": {
+					Type:        framework.TypeInt,
+					Description: "Count of required approves.",
+					Default:     1,
+				},
+				"require_mfa": {
+					Type:        framework.TypeBool,
+					Description: "Necessity to approve second auth factor.",
+					Default:     false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.handleSetPolicy(),
+					Summary:  "Set the access request policy of the tenant.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleSetPolicy(),
+					Summary:  "Set the access request policy of the tenant.",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleReadPolicy(),
+					Summary:  "Retrieve the access request policy of the tenant.",
+				},
+			},
+		},
+		// Creation
+		{
+			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/access_request",
+			Fields: map[string]*framework.FieldSchema{
+				"tenant_uuid": {
+					Type:        framework.TypeNameString,
+					Description: "ID of a tenant",
+					Required:    true,
+				},
+				"reason": {
+					Type:        framework.TypeString,
+					Description: "Reason of the access request",
+					Required:    true,
+				},
+				"role": {
+					Type:        framework.TypeString,
+					Description: "Requested role name",
+					Required:    true,
+				},
+				"options": {
+					Type:        framework.TypeMap,
+					Description: "Options of the requested role",
+				},
+				"any_project": {
+					Type:        framework.TypeBool,
+					Description: "request role for all projects of tenant",
+				},
+				"projects": {
+					Type:        framework.TypeStringSlice,
+					Description: "project uuids list",
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: fmt.Sprintf("Requested time of access in seconds, max %d", model.AccessRequestMaxTTL),
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.handleCreate(),
+					Summary:  "Create access request.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleCreate(),
+					Summary:  "Create access request.",
+				},
+			},
+		},
+		// List
+		{
+			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/access_request/?",
+			Fields: map[string]*framework.FieldSchema{
+				"tenant_uuid": {
+					Type:        framework.TypeNameString,
+					Description: "ID of a tenant",
+					Required:    true,
+				},
+				"show_archived": {
+					Type:        framework.TypeBool,
+					Description: "Option to list archived access requests",
+					Required:    false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleList(),
+					Summary:  "Lists all access requests of the tenant.",
+				},
+			},
+		},
+		// Read, delete by uuid
+		{
+			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/access_request/" + uuid.Pattern("uuid") + "$",
+			Fields: map[string]*framework.FieldSchema{
+				"tenant_uuid": {
+					Type:        framework.TypeNameString,
+					Description: "ID of a tenant",
+					Required:    true,
+				},
+				"uuid": {
+					Type:        framework.TypeNameString,
+					Description: "ID of an access request",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleRead(),
+					Summary:  "Retrieve the access request by ID.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleDelete(),
+					Summary:  "Deletes the access request by ID, and revokes given access.",
+				},
+			},
+		},
+		// Approve
+		approve,
+		mfaPath(approve),
+		// Reject
+		{
+			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/access_request/" + uuid.Pattern("uuid") + "/reject$",
+			Fields:  decisionFields,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.handleDecision(false),
+					Summary:  "Reject the access request by the owner of the token.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleDecision(false),
+					Summary:  "Reject the access request by the owner of the token.",
+				},
+			},
+		},
+	}
+}
+
+func (b *accessRequestBackend) handleCreate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("create access_request", "path", req.Path)
+		ttl := data.Get("ttl").(int)
+		if ttl <= 0 || ttl > model.AccessRequestMaxTTL {
+			return backentutils.ResponseErrMessage(req, fmt.Sprintf("ttl must be greater then zero and not greater then %d",
+				model.AccessRequestMaxTTL), http.StatusBadRequest)
+		}
+		anyProject := data.Get("any_project").(bool)
+		var projects []string
+		if !anyProject {
+			projects = data.Get("projects").([]string)
+		}
+		options, _ := data.Get("options").(map[string]interface{})
+		if options == nil {
+			options = map[string]interface{}{}
+		}
+
+		tx := b.storage.Txn(true)
+		defer tx.Abort()
+
+		requester, err := requestOwner(b.System(), tx, req)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		accessRequest := &model.AccessRequest{
+			TenantUUID: data.Get(iam_repo.TenantForeignPK).(string),
+			Requester:  requester.MemberNotation,
+			Reason:     data.Get("reason").(string),
+			Role: model.BoundRole{
+				Name:    data.Get("role").(string),
+				Options: options,
+			},
+			AnyProject:   anyProject,
+			Projects:     projects,
+			RequestedTTL: int64(ttl),
+		}
+
+		err = usecase.AccessRequests(tx).Create(accessRequest)
+		if err != nil {
+			err = fmt.Errorf("cannot create access request:%w", err)
+			b.Logger().Error(err.Error())
+			return backentutils.ResponseErr(req, err)
+		}
+		if err = io.CommitWithLog(tx, b.Logger()); err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{"access_request": accessRequest}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusCreated)
+	}
+}
+
+func (b *accessRequestBackend) handleSetPolicy() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("set access_request_policy", "path", req.Path)
+		approvers, err := parseMembers(data.Get("approvers"))
+		if err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusBadRequest)
+		}
+		policy := model.AccessRequestPolicy{
+			Approvers:     approvers,
+			RequiredVotes: data.Get("required_votes").(int),
+			RequireMFA:    data.Get("require_mfa").(bool),
+		}
+
+		tx := b.storage.Txn(true)
+		defer tx.Abort()
+
+		tenant, err := usecase.AccessRequests(tx).SetPolicy(data.Get(iam_repo.TenantForeignPK).(string), policy)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		if err = io.CommitWithLog(tx, b.Logger()); err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{"access_request_policy": tenant.AccessRequestPolicy}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
+
+func (b *accessRequestBackend) handleReadPolicy() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("read access_request_policy", "path", req.Path)
+		tx := b.storage.Txn(false)
+		defer tx.Abort()
+
+		tenant, err := usecase.Tenants(tx, consts.OriginIAM).GetByID(data.Get(iam_repo.TenantForeignPK).(string))
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		if tenant.AccessRequestPolicy == nil {
+			return backentutils.ResponseErrMessage(req, "access request policy is not configured", http.StatusNotFound)
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{"access_request_policy": tenant.AccessRequestPolicy}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
+
+func (b *accessRequestBackend) handleDecision(approve bool) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("decide access_request", "path", req.Path, "approve", approve)
+		id := data.Get("uuid").(string)
+		tenantID := data.Get(iam_repo.TenantForeignPK).(string)
+
+		tx := b.storage.Txn(true)
+		defer tx.Abort()
+
+		decider, err := requestOwner(b.System(), tx, req)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		var accessRequest *model.AccessRequest
+		if approve {
+			accessRequest, err = usecase.AccessRequests(tx).Approve(tenantID, id, *decider)
+		} else {
+			accessRequest, err = usecase.AccessRequests(tx).Reject(tenantID, id, *decider)
+		}
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		if err = io.CommitWithLog(tx, b.Logger()); err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{"access_request": accessRequest}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
+
+func (b *accessRequestBackend) handleDelete() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("delete access_request", "path", req.Path)
+		id := data.Get("uuid").(string)
+
+		tx := b.storage.Txn(true)
+		defer tx.Abort()
+
+		err := usecase.AccessRequests(tx).Delete(data.Get(iam_repo.TenantForeignPK).(string), id)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		if err = io.CommitWithLog(tx, b.Logger()); err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+		}
+
+		return logical.RespondWithStatusCode(nil, req, http.StatusNoContent)
+	}
+}
+
+func (b *accessRequestBackend) handleRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("read access_request", "path", req.Path)
+		id := data.Get("uuid").(string)
+		tenantID := data.Get(iam_repo.TenantForeignPK).(string)
+
+		tx := b.storage.Txn(false)
+		defer tx.Abort()
+
+		accessRequest, err := usecase.AccessRequests(tx).GetByID(id)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		if accessRequest.TenantUUID != tenantID {
+			return backentutils.ResponseErrMessage(req, "not found", http.StatusNotFound)
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{"access_request": accessRequest}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
+
+func (b *accessRequestBackend) handleList() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("list access_requests", "path", req.Path)
+		var showArchived bool
+		rawShowArchived, ok := data.GetOk("show_archived")
+		if ok {
+			showArchived = rawShowArchived.(bool)
+		}
+		tenantID := data.Get(iam_repo.TenantForeignPK).(string)
+
+		tx := b.storage.Txn(false)
+		defer tx.Abort()
+
+		accessRequests, err := usecase.AccessRequests(tx).List(tenantID, showArchived)
+		if err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+		}
+
+		resp := &logical.Response{
+			Data: map[string]interface{}{
+				"access_requests": accessRequests,
+			},
+		}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
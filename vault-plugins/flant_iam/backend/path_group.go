@@ -9,6 +9,7 @@ import (
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_source"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
@@ -21,12 +22,14 @@ import (
 type groupBackend struct {
 	logical.Backend
 	storage *io.MemoryStore
+	history *kafka_source.PointInTimeStorage
 }
 
-func groupPaths(b logical.Backend, storage *io.MemoryStore) []*framework.Path {
+func groupPaths(b logical.Backend, storage *io.MemoryStore, history *kafka_source.PointInTimeStorage) []*framework.Path {
 	bb := &groupBackend{
 		Backend: b,
 		storage: storage,
+		history: history,
 	}
 	return bb.paths()
 }
@@ -104,6 +107,7 @@ func (b groupBackend) paths() []*framework.Path {
 		{
 			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/group/?",
 			Fields: map[string]*framework.FieldSchema{
+				"as_of": asOfFieldSchema,
 				"tenant_uuid": {
 					Type:        framework.TypeNameString,
 					Description: "ID of a tenant",
@@ -336,7 +340,11 @@ func (b *groupBackend) handleList() framework.OperationFunc {
 
 		tenantID := data.Get(iam_repo.TenantForeignPK).(string)
 
-		tx := b.storage.Txn(true) // need writable for fixing members
+		storage, err := storageAsOf(b.storage, b.history, data)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		tx := storage.Txn(true) // need writable for fixing members
 		defer tx.Abort()
 
 		groups, err := usecase.Groups(tx, tenantID, consts.OriginIAM).List(showShared, showArchived)
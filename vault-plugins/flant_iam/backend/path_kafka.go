@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -12,6 +13,11 @@ import (
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	iam_io "github.com/flant/negentropy/vault-plugins/flant_iam/io"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_destination"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_source"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
 	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
 	"github.com/flant/negentropy/vault-plugins/shared/kafka"
@@ -20,18 +26,22 @@ import (
 
 type kafkaBackend struct {
 	logical.Backend
-	storage *io.MemoryStore
-	broker  *kafka.MessageBroker
-	logger  hclog.Logger
+	storage    *io.MemoryStore
+	broker     *kafka.MessageBroker
+	rewriteJob *io.RewriteJob
+	logger     hclog.Logger
 }
 
 func kafkaPaths(b logical.Backend, storage *io.MemoryStore, parentLogger hclog.Logger) []*framework.Path {
 	bb := kafkaBackend{
-		Backend: b,
-		storage: storage,
-		broker:  storage.GetKafkaBroker(),
-		logger:  parentLogger.Named("KafkaPath"),
+		Backend:    b,
+		storage:    storage,
+		broker:     storage.GetKafkaBroker(),
+		rewriteJob: io.NewRewriteJob(storage, parentLogger),
+		logger:     parentLogger.Named("KafkaPath"),
 	}
+	bb.broker.OnEncryptionKeyPromoting = bb.announceRootPublicKey
+	bb.broker.OnEncryptionKeyRetiring = bb.checkHistoryKey
 
 	configurePath := &framework.Path{
 		Pattern: "kafka/configure",
@@ -46,6 +56,11 @@ func kafkaPaths(b logical.Backend, storage *io.MemoryStore, parentLogger hclog.L
 				Type:        framework.TypeCommaStringSlice,
 				Description: "Vault public keys to check signature in JWKS topic",
 			},
+			"dead_letter_max_failures": {
+				Type:        framework.TypeInt,
+				Default:     0,
+				Description: "Count of failures of message processing, after which the message is moved to the dead letter topic, 0 turns it off. If omitted, the configured value is kept",
+			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.CreateOperation: &framework.PathOperation{
@@ -63,7 +78,75 @@ func kafkaPaths(b logical.Backend, storage *io.MemoryStore, parentLogger hclog.L
 		},
 	}
 
-	return append(bb.broker.KafkaPaths(), configurePath)
+	reencryptPath := &framework.Path{
+		Pattern: "kafka/encryption_keys/reencrypt",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Summary:  "Start rewriting of all objects into topics under the primary encryption key",
+				Callback: bb.handleReencrypt,
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Summary:  "Read status of the last rewriting",
+				Callback: bb.handleReencryptStatus,
+			},
+		},
+	}
+
+	return append(bb.broker.KafkaPaths(), configurePath, reencryptPath)
+}
+
+// announceRootPublicKey passes the public part of the key to all replicas, before the key starts signing
+func (kb kafkaBackend) announceRootPublicKey(key kafka.EncryptionKey) error {
+	if !kb.broker.Configured() {
+		return nil
+	}
+	iter, err := kb.storage.Txn(false).Get(model.ReplicaType, iam_repo.PK)
+	if err != nil {
+		return err
+	}
+	var msgs []kafka.Message
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		replica := raw.(*model.Replica)
+		msg, err := kafka_destination.RootPublicKeyMessage(kb.broker, *replica, &key.PrivateKey.PublicKey)
+		if err != nil {
+			return fmt.Errorf("building message for replica %s: %w", replica.Name, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return kb.broker.SendMessages(msgs, nil)
+}
+
+// checkHistoryKey forbids retiring of the key, which encrypts messages of the history topic, used by as_of reads
+func (kb kafkaBackend) checkHistoryKey(key kafka.EncryptionKey) error {
+	if !kb.broker.Configured() {
+		return nil
+	}
+	return kafka_source.CheckHistoryKey(kb.broker, key.ID, kb.logger)
+}
+
+func (kb kafkaBackend) handleReencrypt(_ context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	if !kb.broker.Configured() {
+		rr := logical.ErrorResponse("kafka is not configured")
+		return logical.RespondWithStatusCode(rr, req, http.StatusPreconditionFailed)
+	}
+	err := kb.rewriteJob.Start()
+	if errors.Is(err, io.ErrRewriteIsRunning) {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusConflict)
+	}
+	if err != nil {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+	}
+	resp := &logical.Response{Data: map[string]interface{}{
+		"status": kb.rewriteJob.Status(),
+	}}
+	return logical.RespondWithStatusCode(resp, req, http.StatusAccepted)
+}
+
+func (kb kafkaBackend) handleReencryptStatus(_ context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	resp := &logical.Response{Data: map[string]interface{}{
+		"status": kb.rewriteJob.Status(),
+	}}
+	return logical.RespondWithStatusCode(resp, req, http.StatusOK)
 }
 
 func (kb kafkaBackend) handleKafkaConfiguration(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -84,6 +167,10 @@ func (kb kafkaBackend) handleKafkaConfiguration(ctx context.Context, req *logica
 	if err != nil {
 		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
 	}
+	err = kb.broker.CreateTopic(ctx, iam_io.SelfHistoryTopicName(kb.broker), iam_io.SelfHistoryTopicConfig)
+	if err != nil {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+	}
 
 	var peerKeys []*rsa.PublicKey
 	peerKeysRaw, ok := data.GetOk("peers_public_keys")
@@ -105,6 +192,10 @@ func (kb kafkaBackend) handleKafkaConfiguration(ctx context.Context, req *logica
 	if len(peerKeys) > 0 {
 		kb.broker.PluginConfig.PeersPublicKeys = peerKeys
 	}
+	// the stored value is kept, if the field is omitted
+	if maxFailures, ok := data.GetOk("dead_letter_max_failures"); ok {
+		kb.broker.PluginConfig.DeadLetterMaxFailures = maxFailures.(int)
+	}
 
 	// Create JWKS topic
 	jwksConfig := map[string]string{
@@ -135,17 +226,19 @@ func (kb kafkaBackend) handleKafkaConfiguration(ctx context.Context, req *logica
 func (kb kafkaBackend) handleKafkaReadConfiguration(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	cfg := kb.broker.PluginConfig
 	cfgResp := struct {
-		SelfTopicName     string   `json:"self_topic_name,omitempty"`
-		RootTopicName     string   `json:"root_topic_name,omitempty"`
-		RootPublicKey     string   `json:"root_public_key,omitempty"`
-		PeersPublicKeys   []string `json:"peers_public_keys,omitempty"`
-		PublishQuotaUsage bool     `json:"publish_quota,omitempty"`
+		SelfTopicName         string   `json:"self_topic_name,omitempty"`
+		RootTopicName         string   `json:"root_topic_name,omitempty"`
+		RootPublicKey         string   `json:"root_public_key,omitempty"`
+		PeersPublicKeys       []string `json:"peers_public_keys,omitempty"`
+		PublishQuotaUsage     bool     `json:"publish_quota,omitempty"`
+		DeadLetterMaxFailures int      `json:"dead_letter_max_failures,omitempty"`
 	}{
-		SelfTopicName:     cfg.SelfTopicName,
-		RootTopicName:     cfg.RootTopicName,
-		RootPublicKey:     backentutils.ConvertToPem(cfg.RootPublicKey),
-		PeersPublicKeys:   backentutils.ConvertToPems(cfg.PeersPublicKeys),
-		PublishQuotaUsage: cfg.PublishQuotaUsage,
+		SelfTopicName:         cfg.SelfTopicName,
+		RootTopicName:         cfg.RootTopicName,
+		RootPublicKey:         backentutils.ConvertToPem(cfg.RootPublicKey),
+		PeersPublicKeys:       backentutils.ConvertToPems(cfg.PeersPublicKeys),
+		PublishQuotaUsage:     cfg.PublishQuotaUsage,
+		DeadLetterMaxFailures: cfg.DeadLetterMaxFailures,
 	}
 
 	resp := &logical.Response{Data: map[string]interface{}{
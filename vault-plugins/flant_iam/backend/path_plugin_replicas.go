@@ -19,6 +19,7 @@ import (
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
 	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
+	"github.com/flant/negentropy/vault-plugins/shared/kafka"
 	"github.com/flant/negentropy/vault-plugins/shared/memdb"
 )
 
@@ -60,7 +61,7 @@ func (b replicaBackend) paths() []*framework.Path {
 				},
 				"public_key": {
 					Type:        framework.TypeString,
-					Description: "Public rsa key for encryption",
+					Description: "Public rsa key for encryption, if the key of the existing replica is changed, messages of the replica topic are rewritten by the new key",
 				},
 				"send_current_state_at_start": {
 					Type:        framework.TypeBool,
@@ -70,6 +71,14 @@ func (b replicaBackend) paths() []*framework.Path {
 					Type:        framework.TypeBool,
 					Description: "Send deleted items at start of replication, only valuable if  send_current_state_at_start",
 				},
+				"tenants": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Allow-list of tenants, which objects are sent to the replica",
+				},
+				"tenant_label_selector": {
+					Type:        framework.TypeKVPairs,
+					Description: "Labels of tenants, which objects are sent to the replica",
+				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				// GET
@@ -148,9 +157,25 @@ func (b replicaBackend) handleReplicaCreate(ctx context.Context, req *logical.Re
 		SendCurrentStateAtStart:           sendCurrentStateAtStart,
 		ShowArchivedInCurrentStateAtStart: showArchivedInCurrentStateAtStart && sendCurrentStateAtStart,
 	}
+	tenants := data.Get("tenants").([]string)
+	labelSelector := data.Get("tenant_label_selector").(map[string]string)
+	if len(tenants) > 0 || len(labelSelector) > 0 {
+		r.TenantScope = &model.ReplicaTenantScope{
+			Tenants:       tenants,
+			LabelSelector: labelSelector,
+		}
+	}
 
 	tx := b.storage.Txn(true)
 	defer tx.Abort()
+	var previous *model.Replica
+	raw, err := tx.First(model.ReplicaType, iam_repo.PK, replicaName)
+	if err != nil {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+	}
+	if raw != nil {
+		previous = raw.(*model.Replica)
+	}
 	err = tx.Insert(model.ReplicaType, r)
 	if err != nil {
 		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
@@ -166,7 +191,7 @@ func (b replicaBackend) handleReplicaCreate(ctx context.Context, req *logical.Re
 		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
 	}
 
-	err = b.addReplicaToReplications(*r)
+	err = b.addReplicaToReplications(*r, previous)
 	if err != nil {
 		b.Logger().Error("addReplicaToReplications", "error", err.Error())
 		return backentutils.ResponseErr(req, err)
@@ -204,6 +229,7 @@ func (b replicaBackend) handleReplicaRead(ctx context.Context, req *logical.Requ
 			"public_key":                  strings.ReplaceAll(string(pemdata), "\n", "\\n"),
 			"send_current_state_at_start": replica.SendCurrentStateAtStart,
 			"show_archived_in_current_state_at_start": replica.ShowArchivedInCurrentStateAtStart,
+			"tenant_scope": replica.TenantScope,
 		},
 	}, nil
 }
@@ -244,8 +270,18 @@ func (b replicaBackend) handleReplicaDelete(ctx context.Context, req *logical.Re
 	return &logical.Response{}, err
 }
 
-func (b replicaBackend) addReplicaToReplications(replica model.Replica) error {
-	var kafkaDestination io.KafkaDestination
+// tenantScopedDestination is a replica destination, which sends only objects of tenants in the replica scope
+type tenantScopedDestination interface {
+	io.KafkaDestination
+	TenantTombstones(txn *memdb.Txn, tenantUUID model.TenantUUID) ([]kafka.Message, error)
+	TenantCurrentState(txn *memdb.Txn, tenant *model.Tenant) ([]kafka.Message, error)
+}
+
+// addReplicaToReplications registers destination for the replica, if the replica is updated,
+// previous is the replaced replica, tombstones are sent for tenants, which left the scope,
+// and the current state is sent for tenants, which entered the scope
+func (b replicaBackend) addReplicaToReplications(replica model.Replica, previous *model.Replica) error {
+	var kafkaDestination tenantScopedDestination
 	switch replica.TopicType {
 	case kafka_destination.VaultTopicType:
 		kafkaDestination = kafka_destination.NewVaultKafkaDestination(b.storage.GetKafkaBroker(), replica)
@@ -254,7 +290,19 @@ func (b replicaBackend) addReplicaToReplications(replica model.Replica) error {
 	default:
 		return fmt.Errorf("unknown replica type, replicaName: %s, topicType: %s", replica.Name, replica.TopicType)
 	}
-	if replica.SendCurrentStateAtStart {
+	txn := b.storage.Txn(false)
+	defer txn.Abort()
+	if previous != nil {
+		if err := b.sendTenantScopeChanges(kafkaDestination, txn.Txn, *previous, replica); err != nil {
+			return err
+		}
+	}
+	switch {
+	case previous != nil && !previous.PublicKey.Equal(replica.PublicKey):
+		if err := b.sendPublicKeyRotation(kafkaDestination, replica); err != nil {
+			return err
+		}
+	case replica.SendCurrentStateAtStart:
 		err := b.sendCurrentState(kafkaDestination, replica)
 		if err != nil {
 			return err
@@ -264,6 +312,81 @@ func (b replicaBackend) addReplicaToReplications(replica model.Replica) error {
 	return nil
 }
 
+// sendPublicKeyRotation rewrites messages of the replica topic, encrypted by the previous public key of the replica:
+// the primary public key of the root source and all objects, including archived ones, are sent encrypted by the new key
+func (b replicaBackend) sendPublicKeyRotation(destination io.KafkaDestination, replica model.Replica) error {
+	mb := b.storage.GetKafkaBroker()
+	msg, err := kafka_destination.RootPublicKeyMessage(mb, replica, mb.EncryptionPublicKey())
+	if err != nil {
+		return fmt.Errorf("building root public key message: %w", err)
+	}
+	if err = mb.SendMessages([]kafka.Message{msg}, nil); err != nil {
+		return fmt.Errorf("sending root public key message: %w", err)
+	}
+	replica.ShowArchivedInCurrentStateAtStart = true
+	if err = b.sendCurrentState(destination, replica); err != nil {
+		return err
+	}
+	b.Logger().Info(fmt.Sprintf("public key of %s is rotated, messages are rewritten", replica.Name))
+	return nil
+}
+
+// sendTenantScopeChanges sends tombstones for tenants, which left the scope, and the current state of tenants,
+// which entered the scope, if the whole current state is not sent at start
+func (b replicaBackend) sendTenantScopeChanges(destination tenantScopedDestination, txn *memdb.Txn,
+	previous model.Replica, replica model.Replica) error {
+	previousTenants, err := kafka_destination.TenantsInScope(txn, previous.TenantScope)
+	if err != nil {
+		return err
+	}
+	currentTenants, err := kafka_destination.TenantsInScope(txn, replica.TenantScope)
+	if err != nil {
+		return err
+	}
+	previousSet := map[model.TenantUUID]struct{}{}
+	for _, t := range previousTenants {
+		previousSet[t] = struct{}{}
+	}
+	current := map[model.TenantUUID]struct{}{}
+	for _, t := range currentTenants {
+		current[t] = struct{}{}
+	}
+	for _, t := range previousTenants {
+		if _, stillInScope := current[t]; stillInScope {
+			continue
+		}
+		msgs, err := destination.TenantTombstones(txn, t)
+		if err != nil {
+			return fmt.Errorf("building tombstones for tenant %s: %w", t, err)
+		}
+		if err = b.storage.GetKafkaBroker().SendMessages(msgs, nil); err != nil {
+			return fmt.Errorf("sending tombstones for tenant %s: %w", t, err)
+		}
+		b.Logger().Info(fmt.Sprintf("tenant %s left scope of %s, sent %d tombstones", t, replica.Name, len(msgs)))
+	}
+	if replica.SendCurrentStateAtStart {
+		return nil
+	}
+	for _, t := range currentTenants {
+		if _, wasInScope := previousSet[t]; wasInScope {
+			continue
+		}
+		raw, err := txn.First(model.TenantType, iam_repo.PK, t)
+		if err != nil {
+			return fmt.Errorf("getting tenant %s: %w", t, err)
+		}
+		msgs, err := destination.TenantCurrentState(txn, raw.(*model.Tenant))
+		if err != nil {
+			return fmt.Errorf("building current state of tenant %s: %w", t, err)
+		}
+		if err = b.storage.GetKafkaBroker().SendMessages(msgs, nil); err != nil {
+			return fmt.Errorf("sending current state of tenant %s: %w", t, err)
+		}
+		b.Logger().Info(fmt.Sprintf("tenant %s entered scope of %s, sent %d objects", t, replica.Name, len(msgs)))
+	}
+	return nil
+}
+
 func (b replicaBackend) removeReplicaFromReplications(replica model.Replica) {
 	b.storage.RemoveKafkaDestination(replica.Name)
 }
@@ -169,8 +169,8 @@ func generateBackend(t *testing.T) (logical.Backend, logical.Storage) {
 
 	b.Paths = framework.PathAppend(
 		replicasPaths(b, storage),
-		tenantPaths(b, storage),
-		userPaths(b, nil, storage),
+		tenantPaths(b, storage, nil),
+		userPaths(b, nil, storage, nil),
 	)
 	err = b.Setup(context.TODO(), config)
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_source"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
@@ -20,12 +21,14 @@ import (
 type projectBackend struct {
 	logical.Backend
 	storage *io.MemoryStore
+	history *kafka_source.PointInTimeStorage
 }
 
-func projectPaths(b logical.Backend, storage *io.MemoryStore) []*framework.Path {
+func projectPaths(b logical.Backend, storage *io.MemoryStore, history *kafka_source.PointInTimeStorage) []*framework.Path {
 	bb := &projectBackend{
 		Backend: b,
 		storage: storage,
+		history: history,
 	}
 	paths := append(bb.paths(), bb.featureFlagPath())
 	return paths
@@ -94,6 +97,7 @@ func (b projectBackend) paths() []*framework.Path {
 		{
 			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/project/?",
 			Fields: map[string]*framework.FieldSchema{
+				"as_of": asOfFieldSchema,
 				"tenant_uuid": {
 					Type:        framework.TypeNameString,
 					Description: "ID of a tenant",
@@ -311,7 +315,12 @@ func (b *projectBackend) handleList() framework.OperationFunc {
 		}
 		tenantID := data.Get(iam_repo.TenantForeignPK).(string)
 
-		tx := b.storage.Txn(false)
+		storage, err := storageAsOf(b.storage, b.history, data)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		tx := storage.Txn(false)
+		defer tx.Abort()
 
 		projects, err := usecase.Projects(tx, consts.OriginIAM).List(tenantID, showArchived)
 		if err != nil {
@@ -3,26 +3,31 @@ package backend
 import (
 	"context"
 	"net/http"
+	"sort"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_source"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
 	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
+	"github.com/flant/negentropy/vault-plugins/shared/uuid"
 )
 
 type roleBackend struct {
 	logical.Backend
 	storage *io.MemoryStore
+	history *kafka_source.PointInTimeStorage
 }
 
-func rolePaths(b logical.Backend, storage *io.MemoryStore) []*framework.Path {
+func rolePaths(b logical.Backend, storage *io.MemoryStore, history *kafka_source.PointInTimeStorage) []*framework.Path {
 	bb := &roleBackend{
 		Backend: b,
 		storage: storage,
+		history: history,
 	}
 	return bb.paths()
 }
@@ -164,6 +169,34 @@ func (b roleBackend) paths() []*framework.Path {
 				},
 			},
 		},
+		// Members, having the role in the tenant or the project
+		{
+			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/role/" + framework.GenericNameRegex("name") + "/members$",
+			Fields: map[string]*framework.FieldSchema{
+				"tenant_uuid": {
+					Type:        framework.TypeNameString,
+					Description: "ID of a tenant",
+					Required:    true,
+				},
+				"name": {
+					Type:        framework.TypeNameString,
+					Description: "Role name",
+					Required:    true,
+				},
+				"project_uuid": {
+					Type:        framework.TypeString,
+					Description: "ID of a project, is required for the project scoped role",
+					Required:    false,
+				},
+				"as_of": asOfFieldSchema,
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleMembers(),
+					Summary:  "Lists users and service accounts, having the role",
+				},
+			},
+		},
 		// Include/exclude inherited role
 		{
 
@@ -380,3 +413,39 @@ func (b *roleBackend) handleExclude() framework.OperationFunc {
 		return logical.RespondWithStatusCode(nil, req, http.StatusNoContent)
 	}
 }
+
+func (b *roleBackend) handleMembers() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("list role members", "path", req.Path)
+		roleName := data.Get("name").(string)
+		tenantUUID := data.Get("tenant_uuid").(string)
+		projectUUID := data.Get("project_uuid").(string)
+
+		storage, err := storageAsOf(b.storage, b.history, data)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		tx := storage.Txn(false)
+		defer tx.Abort()
+		resolver := usecase.NewRoleResolver(tx)
+
+		var users []model.UserUUID
+		var serviceAccounts []model.ServiceAccountUUID
+		if projectUUID != "" {
+			users, serviceAccounts, err = resolver.FindMembersWithProjectScopedRole(roleName, tenantUUID, projectUUID)
+		} else {
+			users, serviceAccounts, err = resolver.FindMembersWithTenantScopedRole(roleName, tenantUUID)
+		}
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		sort.Strings(users)
+		sort.Strings(serviceAccounts)
+
+		resp := &logical.Response{Data: map[string]interface{}{
+			"users":            users,
+			"service_accounts": serviceAccounts,
+		}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
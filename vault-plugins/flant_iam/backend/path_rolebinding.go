@@ -9,6 +9,7 @@ import (
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_source"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
@@ -21,12 +22,14 @@ import (
 type roleBindingBackend struct {
 	logical.Backend
 	storage *io.MemoryStore
+	history *kafka_source.PointInTimeStorage
 }
 
-func roleBindingPaths(b logical.Backend, storage *io.MemoryStore) []*framework.Path {
+func roleBindingPaths(b logical.Backend, storage *io.MemoryStore, history *kafka_source.PointInTimeStorage) []*framework.Path {
 	bb := &roleBindingBackend{
 		Backend: b,
 		storage: storage,
+		history: history,
 	}
 	return bb.paths()
 }
@@ -73,6 +76,16 @@ func rbBaseAndExtraFields(extraFields map[string]*framework.FieldSchema) map[str
 			Description: "project uuids list",
 			Required:    true,
 		},
+		"deny": {
+			Type:        framework.TypeBool,
+			Description: "Revoke roles from members instead of granting, false if not passed",
+		},
+		"condition": {
+			Type: framework.TypeMap,
+			Description: "Restrictions of login by the rolebinding, all passed should be satisfied: " +
+				"source_cidrs - list of allowed networks, auth_methods - list of allowed auth methods names, " +
+				"time_window - {weekdays: [\"mon\",...], from: \"09:00\", till: \"18:00\", timezone: \"Europe/Moscow\"}",
+		},
 	}
 	for fieldName, fieldSchema := range extraFields {
 		if _, alreadyDefined := fs[fieldName]; alreadyDefined {
@@ -125,6 +138,7 @@ func (b roleBindingBackend) paths() []*framework.Path {
 		{
 			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/role_binding/?",
 			Fields: map[string]*framework.FieldSchema{
+				"as_of": asOfFieldSchema,
 				"tenant_uuid": {
 					Type:        framework.TypeNameString,
 					Description: "ID of a tenant",
@@ -231,6 +245,11 @@ func (b *roleBindingBackend) handleCreate(expectID bool) framework.OperationFunc
 			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusBadRequest)
 		}
 
+		condition, err := parseRoleBindingCondition(data.Get("condition"))
+		if err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusBadRequest)
+		}
+
 		anyProject := data.Get("any_project").(bool)
 		var projects []string
 		if !anyProject {
@@ -248,6 +267,8 @@ func (b *roleBindingBackend) handleCreate(expectID bool) framework.OperationFunc
 			Description: data.Get("description").(string),
 			AnyProject:  anyProject,
 			Projects:    projects,
+			Deny:        data.Get("deny").(bool),
+			Condition:   condition,
 		}
 
 		tx := b.storage.Txn(true)
@@ -294,6 +315,11 @@ func (b *roleBindingBackend) handleUpdate() framework.OperationFunc {
 		if err != nil {
 			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusBadRequest)
 		}
+		condition, err := parseRoleBindingCondition(data.Get("condition"))
+		if err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusBadRequest)
+		}
+
 		anyProject := data.Get("any_project").(bool)
 		var projects []string
 		if !anyProject {
@@ -311,6 +337,8 @@ func (b *roleBindingBackend) handleUpdate() framework.OperationFunc {
 			Description: data.Get("description").(string),
 			AnyProject:  anyProject,
 			Projects:    projects,
+			Deny:        data.Get("deny").(bool),
+			Condition:   condition,
 		}
 
 		tx := b.storage.Txn(true)
@@ -379,7 +407,11 @@ func (b *roleBindingBackend) handleList() framework.OperationFunc {
 		}
 		tenantID := data.Get(iam_repo.TenantForeignPK).(string)
 
-		tx := b.storage.Txn(true) // need writable for fixing members
+		storage, err := storageAsOf(b.storage, b.history, data)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		tx := storage.Txn(true) // need writable for fixing members
 		defer tx.Abort()
 
 		roleBindings, err := usecase.RoleBindings(tx).List(tenantID, showArchived)
@@ -73,6 +73,37 @@ func rbaBaseAndExtraFields(extraFields map[string]*framework.FieldSchema) map[st
 }
 
 func (b roleBindingApprovalBackend) paths() []*framework.Path {
+	// Vote, the vote through the mfa path is MFA-verified
+	vote := &framework.Path{
+		Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/role_binding/" + uuid.Pattern("role_binding_uuid") + "/approval/" + uuid.Pattern("uuid") + "/vote$",
+		Fields: map[string]*framework.FieldSchema{
+			"role_binding_uuid": {
+				Type:        framework.TypeNameString,
+				Description: "ID of a roleBinding",
+				Required:    true,
+			},
+			"tenant_uuid": {
+				Type:        framework.TypeNameString,
+				Description: "ID of a tenant",
+				Required:    true,
+			},
+			"uuid": {
+				Type:        framework.TypeNameString,
+				Description: "ID of a role binding approval",
+				Required:    true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.CreateOperation: &framework.PathOperation{
+				Callback: b.handleVote(),
+				Summary:  "Vote for the role binding approval by the owner of the token.",
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.handleVote(),
+				Summary:  "Vote for the role binding approval by the owner of the token.",
+			},
+		},
+	}
 	return []*framework.Path{
 		{
 			// Create
@@ -121,6 +152,9 @@ func (b roleBindingApprovalBackend) paths() []*framework.Path {
 				},
 			},
 		},
+		// Vote
+		vote,
+		mfaPath(vote),
 		// List
 		{
 			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/role_binding/" + uuid.Pattern("role_binding_uuid") + "/approval/?",
@@ -278,6 +312,35 @@ func (b *roleBindingApprovalBackend) handleDelete() framework.OperationFunc {
 	}
 }
 
+func (b *roleBindingApprovalBackend) handleVote() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("vote role_binding_approval", "path", req.Path)
+		id := data.Get("uuid").(string)
+
+		tx := b.storage.Txn(true)
+		defer tx.Abort()
+
+		voter, err := requestOwner(b.System(), tx, req)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		roleBindingApproval, err := usecase.RoleBindingApprovals(tx).Vote(data.Get(iam_repo.TenantForeignPK).(string),
+			data.Get(iam_repo.RoleBindingForeignPK).(string), id, *voter)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		if err = io.CommitWithLog(tx, b.Logger()); err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{
+			"approval": roleBindingApproval,
+			"approved": roleBindingApproval.Approved(),
+		}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
+
 func (b *roleBindingApprovalBackend) handleRead() framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 		b.Logger().Debug("read role_binding_approval", "path", req.Path)
@@ -10,6 +10,7 @@ import (
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/sethvargo/go-password/password"
 
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_source"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
@@ -24,13 +25,15 @@ type serviceAccountBackend struct {
 	logical.Backend
 	storage         *io.MemoryStore
 	tokenController *jwt.Controller
+	history         *kafka_source.PointInTimeStorage
 }
 
-func serviceAccountPaths(b logical.Backend, tokenController *jwt.Controller, storage *io.MemoryStore) []*framework.Path {
+func serviceAccountPaths(b logical.Backend, tokenController *jwt.Controller, storage *io.MemoryStore, history *kafka_source.PointInTimeStorage) []*framework.Path {
 	bb := &serviceAccountBackend{
 		Backend:         b,
 		storage:         storage,
 		tokenController: tokenController,
+		history:         history,
 	}
 	return bb.paths()
 }
@@ -128,6 +131,7 @@ func (b serviceAccountBackend) paths() []*framework.Path {
 		{
 			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/service_account/?",
 			Fields: map[string]*framework.FieldSchema{
+				"as_of": asOfFieldSchema,
 				"tenant_uuid": {
 					Type:        framework.TypeNameString,
 					Description: "ID of a tenant",
@@ -589,7 +593,12 @@ func (b *serviceAccountBackend) handleList() framework.OperationFunc {
 
 		tenantUUID := data.Get(iam_repo.TenantForeignPK).(string)
 
-		tx := b.storage.Txn(false)
+		storage, err := storageAsOf(b.storage, b.history, data)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		tx := storage.Txn(false)
+		defer tx.Abort()
 
 		serviceAccounts, err := usecase.ServiceAccounts(tx, consts.OriginIAM, tenantUUID).List(showShared, showArchived)
 		if err != nil {
@@ -7,7 +7,9 @@ import (
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/invopop/yaml"
 
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_source"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
 	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
@@ -19,12 +21,14 @@ import (
 type tenantBackend struct {
 	logical.Backend
 	storage *io.MemoryStore
+	history *kafka_source.PointInTimeStorage
 }
 
-func tenantPaths(b logical.Backend, storage *io.MemoryStore) []*framework.Path {
+func tenantPaths(b logical.Backend, storage *io.MemoryStore, history *kafka_source.PointInTimeStorage) []*framework.Path {
 	bb := &tenantBackend{
 		Backend: b,
 		storage: storage,
+		history: history,
 	}
 	paths := append(bb.paths(), bb.featureFlagPath())
 	return paths
@@ -46,6 +50,10 @@ func (b tenantBackend) paths() []*framework.Path {
 					Description: "preferred language",
 					Required:    true,
 				},
+				"labels": {
+					Type:        framework.TypeKVPairs,
+					Description: "Labels of the tenant, used for selecting tenants",
+				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.CreateOperation: &framework.PathOperation{
@@ -77,6 +85,10 @@ func (b tenantBackend) paths() []*framework.Path {
 					Description: "preferred language",
 					Required:    true,
 				},
+				"labels": {
+					Type:        framework.TypeKVPairs,
+					Description: "Labels of the tenant, used for selecting tenants",
+				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.CreateOperation: &framework.PathOperation{
@@ -93,6 +105,7 @@ func (b tenantBackend) paths() []*framework.Path {
 		{
 			Pattern: "tenant/?",
 			Fields: map[string]*framework.FieldSchema{
+				"as_of": asOfFieldSchema,
 				"show_archived": {
 					Type:        framework.TypeBool,
 					Description: "Option to list archived tenants",
@@ -125,6 +138,10 @@ func (b tenantBackend) paths() []*framework.Path {
 					Description: "preferred language",
 					Required:    true,
 				},
+				"labels": {
+					Type:        framework.TypeKVPairs,
+					Description: "Labels of the tenant, used for selecting tenants",
+				},
 				"resource_version": {
 					Type:        framework.TypeString,
 					Description: "Resource version",
@@ -188,6 +205,54 @@ func (b tenantBackend) paths() []*framework.Path {
 				},
 			},
 		},
+		// Export as a single document
+		{
+			Pattern: "tenant/" + uuid.Pattern("uuid") + "/export" + "$",
+			Fields: map[string]*framework.FieldSchema{
+				"uuid": {
+					Type:        framework.TypeNameString,
+					Description: "ID of a tenant",
+					Required:    true,
+				},
+				"format": {
+					Type:          framework.TypeString,
+					Description:   "Format of the document: json returns an object, yaml returns a string",
+					Default:       tenantDocumentFormatJSON,
+					AllowedValues: []interface{}{tenantDocumentFormatJSON, tenantDocumentFormatYAML},
+				},
+			},
+			ExistenceCheck: b.handleExistence(),
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleExport(),
+					Summary:  "Export the tenant with all its objects as a single document.",
+				},
+			},
+		},
+		// Import a single document
+		{
+			Pattern: "tenant/import" + "$",
+			Fields: map[string]*framework.FieldSchema{
+				"document": {
+					Type:        framework.TypeMap,
+					Description: "Tenant document, as it is returned by the export in json format",
+				},
+				"document_yaml": {
+					Type:        framework.TypeString,
+					Description: "Tenant document, as it is returned by the export in yaml format, used instead of document",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.handleImport(),
+					Summary:  "Bring the tenant to the state of the document atomically.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleImport(),
+					Summary:  "Bring the tenant to the state of the document atomically.",
+				},
+			},
+		},
 
 		// Feature flag for tenant
 		b.featureFlagPath(),
@@ -264,6 +329,7 @@ func (b *tenantBackend) handleCreate(expectID bool) framework.OperationFunc {
 			UUID:       id,
 			Identifier: data.Get("identifier").(string),
 			Language:   data.Get("language").(string),
+			Labels:     data.Get("labels").(map[string]string),
 		}
 
 		tx := b.storage.Txn(true)
@@ -296,6 +362,9 @@ func (b *tenantBackend) handleUpdate() framework.OperationFunc {
 			Version:    data.Get("resource_version").(string),
 			Language:   data.Get("language").(string),
 		}
+		if rawLabels, ok := data.GetOk("labels"); ok {
+			tenant.Labels = rawLabels.(map[string]string)
+		}
 
 		err := usecase.Tenants(tx, consts.OriginIAM).Update(tenant)
 		if err != nil {
@@ -359,7 +428,12 @@ func (b *tenantBackend) handleList() framework.OperationFunc {
 			showArchived = rawShowArchived.(bool)
 		}
 
-		tx := b.storage.Txn(false)
+		storage, err := storageAsOf(b.storage, b.history, data)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		tx := storage.Txn(false)
+		defer tx.Abort()
 		tenants, err := usecase.Tenants(tx, consts.OriginIAM).List(showArchived)
 		if err != nil {
 			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
@@ -402,3 +476,70 @@ func (b *tenantBackend) handleRestore() framework.OperationFunc {
 		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
 	}
 }
+
+const (
+	tenantDocumentFormatJSON = "json"
+	tenantDocumentFormatYAML = "yaml"
+)
+
+func (b *tenantBackend) handleExport() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("export tenant", "path", req.Path)
+		id := data.Get("uuid").(string)
+
+		tx := b.storage.Txn(false)
+		defer tx.Abort()
+
+		doc, err := usecase.TenantDocuments(tx).Export(id)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+
+		var document interface{} = doc
+		if data.Get("format").(string) == tenantDocumentFormatYAML {
+			rawYAML, err := yaml.Marshal(doc)
+			if err != nil {
+				return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+			}
+			document = string(rawYAML)
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{
+			"document": document,
+		}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
+
+func (b *tenantBackend) handleImport() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("import tenant", "path", req.Path)
+		var rawDocument interface{}
+		if documentYAML, ok := data.GetOk("document_yaml"); ok {
+			rawDocument = documentYAML
+		} else if document, ok := data.GetOk("document"); ok {
+			rawDocument = document
+		}
+		doc, err := parseTenantDocument(rawDocument)
+		if err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusBadRequest)
+		}
+
+		tx := b.storage.Txn(true)
+		defer tx.Abort()
+
+		if err = usecase.TenantDocuments(tx).Import(doc); err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		exported, err := usecase.TenantDocuments(tx).Export(doc.Tenant.UUID)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		if err = io.CommitWithLog(tx, b.Logger()); err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{"document": exported}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
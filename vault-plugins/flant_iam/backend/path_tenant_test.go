@@ -0,0 +1,102 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	testTenantUUID = "00000000-0000-4000-a000-000000000001"
+	testUserUUID1  = "00000000-0000-4000-a000-000000000002"
+	testUserUUID2  = "00000000-0000-4000-a000-000000000003"
+	testGroupUUID  = "00000000-0000-4000-a000-000000000004"
+	testRbUUID     = "00000000-0000-4000-a000-000000000005"
+)
+
+func testTenantDocument() map[string]interface{} {
+	return map[string]interface{}{
+		"tenant": map[string]interface{}{"uuid": testTenantUUID, "identifier": "tenant1"},
+		"users": []interface{}{
+			map[string]interface{}{"uuid": testUserUUID1, "tenant_uuid": testTenantUUID, "identifier": "vasya", "email": "vasya@example.com"},
+			map[string]interface{}{"uuid": testUserUUID2, "tenant_uuid": testTenantUUID, "identifier": "petya", "email": "petya@example.com"},
+		},
+		"groups": []interface{}{map[string]interface{}{
+			"uuid": testGroupUUID, "tenant_uuid": testTenantUUID, "identifier": "admins",
+			"members": []interface{}{map[string]interface{}{"type": "user", "uuid": testUserUUID1}},
+		}},
+		"rolebindings": []interface{}{map[string]interface{}{
+			"uuid": testRbUUID, "tenant_uuid": testTenantUUID, "description": "admins",
+			"members":     []interface{}{map[string]interface{}{"type": "group", "uuid": testGroupUUID}},
+			"any_project": true,
+			"roles":       []interface{}{map[string]interface{}{"name": "ssh.open", "options": map[string]interface{}{}}},
+		}},
+		"rolebinding_approvals": []interface{}{map[string]interface{}{
+			"uuid": "00000000-0000-4000-a000-000000000006", "tenant_uuid": testTenantUUID,
+			"role_binding_uuid": testRbUUID, "required_votes": 1,
+			"approvers": []interface{}{map[string]interface{}{"type": "user", "uuid": testUserUUID2}},
+		}},
+	}
+}
+
+func importTenantDocument(t *testing.T, b logical.Backend, data map[string]interface{}) gjson.Result {
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "tenant/import",
+		Data:      data,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.Data["http_status_code"], resp.Data["http_raw_body"])
+	return gjson.Parse(resp.Data["http_raw_body"].(string)).Get("data.document")
+}
+
+func exportTenantDocument(t *testing.T, b logical.Backend, format string) gjson.Result {
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "tenant/" + testTenantUUID + "/export",
+		Data:      map[string]interface{}{"format": format},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.Data["http_status_code"], resp.Data["http_raw_body"])
+	return gjson.Parse(resp.Data["http_raw_body"].(string)).Get("data.document")
+}
+
+func Test_TenantExportImportRoundTrip(t *testing.T) {
+	b := testRoleBackend(t)
+	createRole(t, "ssh.open", b)
+	importTenantDocument(t, b, map[string]interface{}{"document": testTenantDocument()})
+
+	exported := exportTenantDocument(t, b, tenantDocumentFormatJSON)
+	var document map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(exported.Raw), &document))
+	reimported := importTenantDocument(t, b, map[string]interface{}{"document": document})
+
+	require.JSONEq(t, exported.Raw, reimported.Raw)
+	require.Len(t, exported.Get("users").Array(), 2)
+	require.Len(t, exported.Get("rolebinding_approvals").Array(), 1)
+
+	exportedYAML := exportTenantDocument(t, b, tenantDocumentFormatYAML)
+	reimported = importTenantDocument(t, b, map[string]interface{}{"document_yaml": exportedYAML.String()})
+
+	require.JSONEq(t, exported.Raw, reimported.Raw)
+}
+
+func Test_TenantImportDeletesAbsentObjects(t *testing.T) {
+	b := testRoleBackend(t)
+	createRole(t, "ssh.open", b)
+	document := testTenantDocument()
+	importTenantDocument(t, b, map[string]interface{}{"document": document})
+	document["users"] = document["users"].([]interface{})[:1]
+	delete(document, "rolebinding_approvals")
+
+	imported := importTenantDocument(t, b, map[string]interface{}{"document": document})
+
+	require.Len(t, imported.Get("users").Array(), 1)
+	require.Equal(t, testUserUUID1, imported.Get("users.0.uuid").String())
+	require.Len(t, imported.Get("rolebinding_approvals").Array(), 0)
+	require.Len(t, imported.Get("rolebindings").Array(), 1)
+}
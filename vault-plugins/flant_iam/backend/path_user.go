@@ -9,6 +9,7 @@ import (
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/kafka_source"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
@@ -23,13 +24,15 @@ type userBackend struct {
 	logical.Backend
 	storage         *io.MemoryStore
 	tokenController *jwt.Controller
+	history         *kafka_source.PointInTimeStorage
 }
 
-func userPaths(b logical.Backend, tokenController *jwt.Controller, storage *io.MemoryStore) []*framework.Path {
+func userPaths(b logical.Backend, tokenController *jwt.Controller, storage *io.MemoryStore, history *kafka_source.PointInTimeStorage) []*framework.Path {
 	bb := &userBackend{
 		Backend:         b,
 		storage:         storage,
 		tokenController: tokenController,
+		history:         history,
 	}
 	return bb.paths()
 }
@@ -138,6 +141,7 @@ func (b userBackend) paths() []*framework.Path {
 		{
 			Pattern: "tenant/" + uuid.Pattern("tenant_uuid") + "/user/?",
 			Fields: map[string]*framework.FieldSchema{
+				"as_of": asOfFieldSchema,
 				"tenant_uuid": {
 					Type:        framework.TypeNameString,
 					Description: "ID of a tenant",
@@ -482,7 +486,12 @@ func (b *userBackend) handleList() framework.OperationFunc {
 
 		tenantID := data.Get(iam_repo.TenantForeignPK).(string)
 
-		tx := b.storage.Txn(false)
+		storage, err := storageAsOf(b.storage, b.history, data)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		tx := storage.Txn(false)
+		defer tx.Abort()
 
 		users, err := usecase.Users(tx, tenantID, consts.OriginIAM).List(showShared, showArchived)
 		if err != nil {
@@ -0,0 +1,122 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/io/change_feed"
+	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
+)
+
+const (
+	defaultWatchTimeout = 30 * time.Second
+	maxWatchTimeout     = 5 * time.Minute
+	defaultWatchLimit   = 100
+)
+
+type watchBackend struct {
+	logical.Backend
+	feed *change_feed.Feed
+}
+
+func watchPaths(b logical.Backend, feed *change_feed.Feed) []*framework.Path {
+	bb := &watchBackend{
+		Backend: b,
+		feed:    feed,
+	}
+	return bb.paths()
+}
+
+func (b watchBackend) paths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "watch/?",
+			Fields: map[string]*framework.FieldSchema{
+				"cursor": {
+					Type:        framework.TypeString,
+					Description: "Cursor of the last received event, empty cursor starts watching from the current moment",
+					Required:    false,
+				},
+				"object_types": {
+					Type:          framework.TypeCommaStringSlice,
+					Description:   "Types of objects to watch, all types are watched by default",
+					Required:      false,
+					AllowedValues: stringsToInterfaces(change_feed.WatchedTypes),
+				},
+				"tenant_uuid": {
+					Type:        framework.TypeString,
+					Description: "Watch only objects of the tenant",
+					Required:    false,
+				},
+				"timeout": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Time to wait for events, in seconds",
+					Default:     int(defaultWatchTimeout.Seconds()),
+					Required:    false,
+				},
+				"limit": {
+					Type:        framework.TypeInt,
+					Description: "Maximum count of events in the response",
+					Default:     defaultWatchLimit,
+					Required:    false,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleWatch(),
+					Summary:  "Long polling of changes of IAM objects. Expired cursor is responded with 410, the watcher should resync.",
+				},
+			},
+		},
+	}
+}
+
+func stringsToInterfaces(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		result = append(result, v)
+	}
+	return result
+}
+
+func (b *watchBackend) handleWatch() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		cursor := data.Get("cursor").(string)
+		filter := change_feed.Filter{
+			ObjectTypes: data.Get("object_types").([]string),
+			TenantUUID:  data.Get("tenant_uuid").(string),
+		}
+		timeout := time.Duration(data.Get("timeout").(int)) * time.Second
+		if timeout > maxWatchTimeout {
+			timeout = maxWatchTimeout
+		}
+		limit := data.Get("limit").(int)
+		b.Logger().Debug("watch", "path", req.Path, "cursor", cursor, "timeout", timeout)
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		events, next, err := b.feed.Wait(ctx, cursor, filter, limit)
+		if errors.Is(err, change_feed.ErrCursorExpired) {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusGone)
+		}
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		if events == nil {
+			events = []change_feed.Event{}
+		}
+
+		resp := &logical.Response{
+			Data: map[string]interface{}{
+				"events": events,
+				"cursor": next,
+			},
+		}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
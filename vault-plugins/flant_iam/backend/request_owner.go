@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+// mfaPathPrefix prefixes paths, which are allowed only for tokens of logins passed MFA: flant_iam_auth adds them
+// into the own dynamic policy of such token, so the request through such path is MFA-verified
+const mfaPathPrefix = "mfa/"
+
+// requestOwner reveals the user or the service account, who sent the request, by the vault entity of its token
+func requestOwner(sys logical.SystemView, tx *io.MemoryStoreTxn, req *logical.Request) (*usecase.RequestOwner, error) {
+	if req.EntityID == "" {
+		return nil, fmt.Errorf("%w: token is not bound to a vault entity", consts.ErrAccessForbidden)
+	}
+	entity, err := sys.EntityInfo(req.EntityID)
+	if err != nil {
+		return nil, fmt.Errorf("getting vault entity: %w", err)
+	}
+	if entity == nil {
+		return nil, fmt.Errorf("%w: vault entity %s is not found", consts.ErrAccessForbidden, req.EntityID)
+	}
+	owner, err := usecase.RevealRequestOwner(tx, entity.Name)
+	if errors.Is(err, consts.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", consts.ErrAccessForbidden, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &usecase.RequestOwner{MemberNotation: owner, MFA: strings.HasPrefix(req.Path, mfaPathPrefix)}, nil
+}
+
+// mfaPath returns the copy of the path under mfaPathPrefix
+func mfaPath(p *framework.Path) *framework.Path {
+	mfa := *p
+	mfa.Pattern = mfaPathPrefix + p.Pattern
+	return &mfa
+}
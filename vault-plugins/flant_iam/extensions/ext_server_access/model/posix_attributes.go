@@ -0,0 +1,156 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Keys of POSIX attributes, they are the same at the server_access extension of the user
+// and at options of rolebindings of the ssh role
+const (
+	PosixShellKey     = "shell"
+	PosixGecosKey     = "gecos"
+	PosixGroupsKey    = "groups"
+	PosixSudoRulesKey = "sudo_rules"
+)
+
+// SudoAllRule gives all privileges, as the sudo group at most distributives, it is used if sudo_rules is true
+const SudoAllRule = "ALL=(ALL:ALL) ALL"
+
+var posixGroupNameRe = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// PosixAttributes are settings of the POSIX user at servers, empty values mean defaults of the server access
+type PosixAttributes struct {
+	Shell  string   `json:"shell,omitempty"`
+	Gecos  string   `json:"gecos,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// SudoRules are sudoers rules without the user part, e.g. "ALL=(ALL) NOPASSWD: /usr/bin/systemctl"
+	SudoRules []string `json:"sudo_rules,omitempty"`
+}
+
+// ParsePosixAttributes collects POSIX attributes from extension attributes or rolebinding options,
+// other keys are ignored
+func ParsePosixAttributes(attributes map[string]interface{}) (PosixAttributes, error) {
+	var (
+		result PosixAttributes
+		err    error
+	)
+	if result.Shell, err = stringAttribute(attributes, PosixShellKey); err != nil {
+		return PosixAttributes{}, err
+	}
+	if result.Gecos, err = stringAttribute(attributes, PosixGecosKey); err != nil {
+		return PosixAttributes{}, err
+	}
+	if result.Groups, err = stringSliceAttribute(attributes, PosixGroupsKey); err != nil {
+		return PosixAttributes{}, err
+	}
+	if all, ok := attributes[PosixSudoRulesKey].(bool); ok {
+		if all {
+			result.SudoRules = []string{SudoAllRule}
+		}
+	} else if result.SudoRules, err = stringSliceAttribute(attributes, PosixSudoRulesKey); err != nil {
+		return PosixAttributes{}, err
+	}
+	return result, result.Validate()
+}
+
+// Validate checks values can be written into passwd, group and sudoers files
+func (a PosixAttributes) Validate() error {
+	if a.Shell != "" && (!strings.HasPrefix(a.Shell, "/") || strings.ContainsAny(a.Shell, ":\n ")) {
+		return fmt.Errorf("%s: should be an absolute path, got %q", PosixShellKey, a.Shell)
+	}
+	if strings.ContainsAny(a.Gecos, ":\n") {
+		return fmt.Errorf("%s: should not contain ':' or new lines", PosixGecosKey)
+	}
+	for _, group := range a.Groups {
+		if !posixGroupNameRe.MatchString(group) {
+			return fmt.Errorf("%s: wrong group name %q", PosixGroupsKey, group)
+		}
+	}
+	for _, rule := range a.SudoRules {
+		if strings.TrimSpace(rule) == "" || strings.ContainsAny(rule, "\n\\") {
+			return fmt.Errorf("%s: wrong rule %q", PosixSudoRulesKey, rule)
+		}
+	}
+	return nil
+}
+
+// Merge returns attributes, overridden by other: shell and gecos are replaced if they are set at other,
+// groups and sudo rules are joined
+func (a PosixAttributes) Merge(other PosixAttributes) PosixAttributes {
+	result := a
+	if other.Shell != "" {
+		result.Shell = other.Shell
+	}
+	if other.Gecos != "" {
+		result.Gecos = other.Gecos
+	}
+	result.Groups = union(a.Groups, other.Groups)
+	result.SudoRules = union(a.SudoRules, other.SudoRules)
+	return result
+}
+
+// Attributes returns values to be stored at the extension attributes
+func (a PosixAttributes) Attributes() map[string]interface{} {
+	return map[string]interface{}{
+		PosixShellKey:     a.Shell,
+		PosixGecosKey:     a.Gecos,
+		PosixGroupsKey:    a.Groups,
+		PosixSudoRulesKey: a.SudoRules,
+	}
+}
+
+func stringAttribute(attributes map[string]interface{}, key string) (string, error) {
+	raw, ok := attributes[key]
+	if !ok || raw == nil {
+		return "", nil
+	}
+	value, ok := raw.(string)
+	if !ok {
+		return "", fmt.Errorf("%s: expected string, got %T", key, raw)
+	}
+	return value, nil
+}
+
+// stringSliceAttribute accepts both []string of the flant_iam and []interface{} after passing through kafka
+func stringSliceAttribute(attributes map[string]interface{}, key string) ([]string, error) {
+	raw, ok := attributes[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	switch value := raw.(type) {
+	case []string:
+		return value, nil
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: expected strings, got %T", key, item)
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("%s: expected list of strings, got %T", key, raw)
+}
+
+func union(a, b []string) []string {
+	set := map[string]struct{}{}
+	for _, s := range append(append([]string{}, a...), b...) {
+		set[s] = struct{}{}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	result := make([]string, 0, len(set))
+	for s := range set {
+		result = append(result, s)
+	}
+	sort.Strings(result)
+	return result
+}
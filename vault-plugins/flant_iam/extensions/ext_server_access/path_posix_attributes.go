@@ -0,0 +1,173 @@
+package ext_server_access
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/model"
+	iam_model "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+	"github.com/flant/negentropy/vault-plugins/shared/uuid"
+)
+
+type posixAttributesBackend struct {
+	logical.Backend
+	storage *io.MemoryStore
+}
+
+// PosixAttributesPaths serves POSIX attributes of the server_access extension of the user,
+// they can be overridden by options of rolebindings of the ssh role
+func PosixAttributesPaths(b logical.Backend, storage *io.MemoryStore) []*framework.Path {
+	bb := &posixAttributesBackend{
+		Backend: b,
+		storage: storage,
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: path.Join("tenant", uuid.Pattern("tenant_uuid"), "user", uuid.Pattern("user_uuid"), "posix_attributes"),
+			Fields: map[string]*framework.FieldSchema{
+				"tenant_uuid": {
+					Type:        framework.TypeNameString,
+					Description: "ID of a tenant",
+					Required:    true,
+				},
+				"user_uuid": {
+					Type:        framework.TypeNameString,
+					Description: "ID of a user",
+					Required:    true,
+				},
+				"resource_version": {
+					Type:        framework.TypeString,
+					Description: "Resource version of the user",
+				},
+				model.PosixShellKey: {
+					Type:        framework.TypeString,
+					Description: "Login shell, the server default is used if empty",
+				},
+				model.PosixGecosKey: {
+					Type:        framework.TypeString,
+					Description: "GECOS field",
+				},
+				model.PosixGroupsKey: {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Supplementary groups, they should exist at servers",
+				},
+				model.PosixSudoRulesKey: {
+					Type:        framework.TypeStringSlice,
+					Description: "Sudoers rules without the user part, e.g. 'ALL=(ALL) NOPASSWD: /usr/bin/systemctl'",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: bb.handleRead,
+					Summary:  "Read POSIX attributes of the user",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: bb.handleUpdate,
+					Summary:  "Update POSIX attributes of the user",
+				},
+			},
+		},
+	}
+}
+
+func (b *posixAttributesBackend) handleRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Debug("handleRead started")
+	defer b.Logger().Debug("handleRead exit")
+	tx := b.storage.Txn(false)
+	defer tx.Abort()
+
+	user, ext, err := serverAccessExtension(tx, data.Get("tenant_uuid").(string), data.Get("user_uuid").(string))
+	if err != nil {
+		return backentutils.ResponseErr(req, err)
+	}
+	attributes, err := model.ParsePosixAttributes(ext.Attributes)
+	if err != nil {
+		return backentutils.ResponseErr(req, err)
+	}
+
+	resp := &logical.Response{Data: map[string]interface{}{
+		"posix_attributes": attributes,
+		"resource_version": user.Version,
+	}}
+	return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+}
+
+func (b *posixAttributesBackend) handleUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Debug("handleUpdate started")
+	defer b.Logger().Debug("handleUpdate exit")
+	tx := b.storage.Txn(true)
+	defer tx.Abort()
+
+	stored, ext, err := serverAccessExtension(tx, data.Get("tenant_uuid").(string), data.Get("user_uuid").(string))
+	if err != nil {
+		return backentutils.ResponseErr(req, err)
+	}
+	if version := data.Get("resource_version").(string); version != "" && version != stored.Version {
+		return backentutils.ResponseErr(req, consts.ErrBadVersion)
+	}
+
+	attributes := model.PosixAttributes{
+		Shell:     data.Get(model.PosixShellKey).(string),
+		Gecos:     data.Get(model.PosixGecosKey).(string),
+		Groups:    data.Get(model.PosixGroupsKey).([]string),
+		SudoRules: data.Get(model.PosixSudoRulesKey).([]string),
+	}
+	if err = attributes.Validate(); err != nil {
+		return backentutils.ResponseErr(req, fmt.Errorf("%w: %s", consts.ErrInvalidArg, err.Error()))
+	}
+
+	// objects of the memdb should not be changed in place
+	user := *stored
+	user.Extensions = make(map[consts.ObjectOrigin]*iam_model.Extension, len(stored.Extensions))
+	for origin, extension := range stored.Extensions {
+		user.Extensions[origin] = extension
+	}
+	updatedExt := *ext
+	updatedExt.Attributes = make(map[string]interface{}, len(ext.Attributes)+4)
+	for k, v := range ext.Attributes {
+		updatedExt.Attributes[k] = v
+	}
+	for k, v := range attributes.Attributes() {
+		updatedExt.Attributes[k] = v
+	}
+	user.Extensions[consts.OriginServerAccess] = &updatedExt
+	user.Version = iam_repo.NewResourceVersion()
+
+	if err = iam_repo.NewUserRepository(tx).Update(&user); err != nil {
+		return backentutils.ResponseErr(req, err)
+	}
+	if err = io.CommitWithLog(tx, b.Logger()); err != nil {
+		return backentutils.ResponseErr(req, err)
+	}
+
+	resp := &logical.Response{Data: map[string]interface{}{
+		"posix_attributes": attributes,
+		"resource_version": user.Version,
+	}}
+	return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+}
+
+func serverAccessExtension(tx *io.MemoryStoreTxn, tenantUUID, userUUID string) (*iam_model.User, *iam_model.Extension, error) {
+	user, err := iam_repo.NewUserRepository(tx).GetByID(userUUID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.TenantUUID != tenantUUID || user.Archived() {
+		return nil, nil, consts.ErrNotFound
+	}
+	ext, ok := user.Extensions[consts.OriginServerAccess]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: server_access extension of the user", consts.ErrNotFound)
+	}
+	return user, ext, nil
+}
@@ -0,0 +1,228 @@
+package change_feed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+// DefaultCapacity is the count of the last events, available for watchers
+const DefaultCapacity = 10000
+
+// WatchedTypes are object types, which changes are passed into the feed
+var WatchedTypes = []string{
+	model.TenantType,
+	model.UserType,
+	model.ServiceAccountType,
+	model.GroupType,
+	model.ProjectType,
+	model.RoleType,
+	model.RoleBindingType,
+}
+
+// ErrCursorExpired means the events after the cursor are not available anymore, and the watcher should resync
+var ErrCursorExpired = errors.New("cursor is expired")
+
+type Event struct {
+	Cursor     string           `json:"cursor"`
+	ObjectType string           `json:"object_type"`
+	ObjectID   string           `json:"object_id"`
+	TenantUUID model.TenantUUID `json:"tenant_uuid,omitempty"`
+	Deleted    bool             `json:"deleted"`
+	// Object is the json of the object at the moment of the change, it is empty for deleted objects
+	Object    json.RawMessage `json:"object,omitempty"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+// Filter selects events, empty fields match any events
+type Filter struct {
+	ObjectTypes []string
+	TenantUUID  model.TenantUUID
+}
+
+func (f Filter) match(e *Event) bool {
+	if f.TenantUUID != "" && e.TenantUUID != f.TenantUUID {
+		return false
+	}
+	if len(f.ObjectTypes) == 0 {
+		return true
+	}
+	for _, t := range f.ObjectTypes {
+		if t == e.ObjectType {
+			return true
+		}
+	}
+	return false
+}
+
+// Feed keeps the last committed changes of the store in memory, each event has a cursor,
+// which is used to continue watching. Cursors are valid only for the feed instance, so the restart of the plugin
+// expires all given cursors
+type Feed struct {
+	// epoch distinguishes cursors of different feed instances
+	epoch    string
+	capacity int
+
+	mutex      sync.Mutex
+	events     []Event
+	nextOffset uint64
+	// changed is closed and replaced at each new event
+	changed chan struct{}
+}
+
+func NewFeed(capacity int) *Feed {
+	return &Feed{
+		epoch:    strconv.FormatInt(time.Now().UnixNano(), 36),
+		capacity: capacity,
+		changed:  make(chan struct{}),
+	}
+}
+
+// RegisterHooks subscribes the feed on changes of all WatchedTypes
+func (f *Feed) RegisterHooks(store *io.MemoryStore) {
+	for _, objType := range WatchedTypes {
+		store.RegisterHook(io.ObjectHook{
+			Events:     []io.HookEvent{io.HookEventInsert, io.HookEventDelete},
+			ObjType:    objType,
+			CallbackFn: f.hook,
+		})
+	}
+}
+
+func (f *Feed) hook(txn *io.MemoryStoreTxn, event io.HookEvent, obj interface{}) error {
+	storable, ok := obj.(io.MemoryStorableObject)
+	if !ok {
+		return nil
+	}
+	e := Event{
+		ObjectType: storable.ObjType(),
+		ObjectID:   storable.ObjId(),
+		TenantUUID: objectTenant(obj),
+		Deleted:    event == io.HookEventDelete,
+	}
+	if !e.Deleted {
+		// stored objects can be changed in place later, so the state is fixed here
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("marshaling %s for change feed: %w", e.ObjectType, err)
+		}
+		e.Object = data
+	}
+	txn.AfterCommit(func() { f.append(e) })
+	return nil
+}
+
+func objectTenant(obj interface{}) model.TenantUUID {
+	switch o := obj.(type) {
+	case *model.Tenant:
+		return o.UUID
+	case *model.User:
+		return o.TenantUUID
+	case *model.ServiceAccount:
+		return o.TenantUUID
+	case *model.Group:
+		return o.TenantUUID
+	case *model.Project:
+		return o.TenantUUID
+	case *model.RoleBinding:
+		return o.TenantUUID
+	}
+	return ""
+}
+
+func (f *Feed) append(e Event) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	e.Cursor = f.cursor(f.nextOffset + 1)
+	e.Timestamp = time.Now().Unix()
+	f.nextOffset++
+	f.events = append(f.events, e)
+	if len(f.events) > f.capacity {
+		f.events = f.events[len(f.events)-f.capacity:]
+	}
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *Feed) cursor(offset uint64) string {
+	return f.epoch + "-" + strconv.FormatUint(offset, 10)
+}
+
+func (f *Feed) parseCursor(cursor string) (uint64, error) {
+	parts := strings.Split(cursor, "-")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("%w: wrong cursor format: %q", consts.ErrInvalidArg, cursor)
+	}
+	if parts[0] != f.epoch {
+		return 0, ErrCursorExpired
+	}
+	offset, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: wrong cursor format: %q", consts.ErrInvalidArg, cursor)
+	}
+	return offset, nil
+}
+
+// Since returns up to limit events, matched by the filter and happened after the cursor,
+// and the cursor to continue, empty cursor means the current moment
+func (f *Feed) Since(cursor string, filter Filter, limit int) ([]Event, string, error) {
+	events, next, _, err := f.since(cursor, filter, limit)
+	return events, next, err
+}
+
+func (f *Feed) since(cursor string, filter Filter, limit int) ([]Event, string, <-chan struct{}, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if cursor == "" {
+		return nil, f.cursor(f.nextOffset), f.changed, nil
+	}
+	offset, err := f.parseCursor(cursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if offset > f.nextOffset {
+		return nil, "", nil, ErrCursorExpired
+	}
+	firstOffset := f.nextOffset - uint64(len(f.events)) + 1
+	if offset+1 < firstOffset {
+		return nil, "", nil, ErrCursorExpired
+	}
+	var result []Event
+	lastOffset := offset
+	for i := int(offset + 1 - firstOffset); i < len(f.events); i++ {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		lastOffset = firstOffset + uint64(i)
+		if filter.match(&f.events[i]) {
+			result = append(result, f.events[i])
+		}
+	}
+	return result, f.cursor(lastOffset), f.changed, nil
+}
+
+// Wait is a long polling version of Since, it waits for matched events till ctx is done
+// and returns empty events and the cursor to continue if nothing happens
+func (f *Feed) Wait(ctx context.Context, cursor string, filter Filter, limit int) ([]Event, string, error) {
+	for {
+		events, next, changed, err := f.since(cursor, filter, limit)
+		if err != nil || len(events) > 0 {
+			return events, next, err
+		}
+		cursor = next
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, cursor, nil
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package change_feed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+const (
+	tenantUUID = "00000000-0000-4000-a000-000000000001"
+	userUUID   = "00000000-0000-4000-a000-000000000011"
+)
+
+func feedTestStore(t *testing.T, feed *Feed) *io.MemoryStore {
+	schema, err := iam_repo.GetSchema()
+	require.NoError(t, err)
+	store, err := io.NewMemoryStore(schema, nil, hclog.NewNullLogger())
+	require.NoError(t, err)
+	feed.RegisterHooks(store)
+	return store
+}
+
+func insert(t *testing.T, store *io.MemoryStore, commit bool, objs ...io.MemoryStorableObject) {
+	txn := store.Txn(true)
+	defer txn.Abort()
+	for _, obj := range objs {
+		require.NoError(t, txn.Insert(obj.ObjType(), obj))
+	}
+	if commit {
+		require.NoError(t, txn.Commit())
+	}
+}
+
+func Test_FeedPassesCommittedChanges(t *testing.T) {
+	feed := NewFeed(DefaultCapacity)
+	store := feedTestStore(t, feed)
+	_, cursor, err := feed.Since("", Filter{}, 0)
+	require.NoError(t, err)
+
+	insert(t, store, true,
+		&model.Tenant{UUID: tenantUUID, Version: "1", Identifier: "tenant"},
+		&model.User{UUID: userUUID, TenantUUID: tenantUUID, Version: "1", Identifier: "vasya", Email: "v@tenant"})
+	insert(t, store, false,
+		&model.User{UUID: userUUID, TenantUUID: tenantUUID, Version: "2", Identifier: "aborted", Email: "v@tenant"})
+
+	events, next, err := feed.Since(cursor, Filter{}, 0)
+
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, model.TenantType, events[0].ObjectType)
+	require.Equal(t, model.UserType, events[1].ObjectType)
+	require.Equal(t, events[1].Cursor, next)
+	require.Contains(t, string(events[1].Object), "vasya")
+	events, _, err = feed.Since(next, Filter{}, 0)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func Test_FeedFilterAndLimit(t *testing.T) {
+	feed := NewFeed(DefaultCapacity)
+	store := feedTestStore(t, feed)
+	_, cursor, err := feed.Since("", Filter{}, 0)
+	require.NoError(t, err)
+	insert(t, store, true,
+		&model.Tenant{UUID: tenantUUID, Version: "1", Identifier: "tenant"},
+		&model.User{UUID: userUUID, TenantUUID: tenantUUID, Version: "1", Identifier: "vasya", Email: "v@tenant"})
+
+	users, _, err := feed.Since(cursor, Filter{ObjectTypes: []string{model.UserType}}, 0)
+	require.NoError(t, err)
+	otherTenant, _, err := feed.Since(cursor, Filter{TenantUUID: "other"}, 0)
+	require.NoError(t, err)
+	first, next, err := feed.Since(cursor, Filter{}, 1)
+	require.NoError(t, err)
+
+	require.Len(t, users, 1)
+	require.Empty(t, otherTenant)
+	require.Len(t, first, 1)
+	require.Equal(t, first[0].Cursor, next)
+}
+
+func Test_FeedCursorExpired(t *testing.T) {
+	feed := NewFeed(1)
+	store := feedTestStore(t, feed)
+	_, cursor, err := feed.Since("", Filter{}, 0)
+	require.NoError(t, err)
+	insert(t, store, true,
+		&model.Tenant{UUID: tenantUUID, Version: "1", Identifier: "tenant"},
+		&model.User{UUID: userUUID, TenantUUID: tenantUUID, Version: "1", Identifier: "vasya", Email: "v@tenant"})
+
+	_, _, err = feed.Since(cursor, Filter{}, 0)
+	require.ErrorIs(t, err, ErrCursorExpired)
+	_, _, err = NewFeed(1).Since(cursor, Filter{}, 0)
+	require.ErrorIs(t, err, ErrCursorExpired)
+}
+
+func Test_FeedWait(t *testing.T) {
+	feed := NewFeed(DefaultCapacity)
+	store := feedTestStore(t, feed)
+	_, cursor, err := feed.Since("", Filter{}, 0)
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		insert(t, store, true, &model.Tenant{UUID: tenantUUID, Version: "1", Identifier: "tenant"})
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, _, err := feed.Wait(ctx, cursor, Filter{}, 0)
+
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+}
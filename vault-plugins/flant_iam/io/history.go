@@ -0,0 +1,18 @@
+package io
+
+import (
+	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
+)
+
+// SelfHistoryTopicName returns the name of not compacted topic, which keeps all changes written into the self topic,
+// it is used for point-in-time reads, as the self topic keeps only the latest version of each object
+func SelfHistoryTopicName(kf *sharedkafka.MessageBroker) string {
+	return kf.PluginConfig.SelfTopicName + ".history"
+}
+
+// SelfHistoryTopicConfig keeps messages of the history topic forever
+var SelfHistoryTopicConfig = map[string]string{
+	"cleanup.policy":  "delete",
+	"retention.ms":    "-1",
+	"retention.bytes": "-1",
+}
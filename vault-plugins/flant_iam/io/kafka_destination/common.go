@@ -8,11 +8,24 @@ import (
 	"encoding/json"
 	"fmt"
 
+	iam_io "github.com/flant/negentropy/vault-plugins/flant_iam/io"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
 	"github.com/flant/negentropy/vault-plugins/shared/kafka"
 )
 
+// replicaTopic returns the name of the topic of the replica
+func replicaTopic(mb *kafka.MessageBroker, replicaName string) string {
+	return fmt.Sprintf("%s.%s", mb.PluginConfig.SelfTopicName, replicaName)
+}
+
+// RootPublicKeyMessage returns the message, which passes the public key of the root source to the replica,
+// it should be sent before the key starts signing
+func RootPublicKeyMessage(mb *kafka.MessageBroker, replica model.Replica, key *rsa.PublicKey) (kafka.Message, error) {
+	return mb.RootPublicKeyMessage(replicaTopic(mb, replica.Name), key, replica.PublicKey)
+}
+
 type commonDest struct {
 	encrypter *kafka.Encrypter
 }
@@ -54,10 +67,14 @@ func (cd *commonDest) simpleObjectKafker(topic string, obj io.MemoryStorableObje
 	}
 
 	msg := kafka.Message{
-		Topic:   topic,
-		Key:     key,
-		Value:   data,
-		Headers: map[string][]byte{"signature": sign},
+		Topic: topic,
+		Key:   key,
+		Value: data,
+		Headers: map[string][]byte{
+			"signature":            sign,
+			kafka.KeyIDHeader:      []byte(kafka.KeyID(pub)),
+			io.SchemaVersionHeader: iam_io.SchemaRegistry.VersionHeader(obj.ObjType()),
+		},
 	}
 	if chunked {
 		msg.Headers["chunked"] = []byte("true")
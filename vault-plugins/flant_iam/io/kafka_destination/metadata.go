@@ -2,7 +2,6 @@ package kafka_destination
 
 import (
 	"crypto/rsa"
-	"fmt"
 
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
@@ -20,6 +19,7 @@ type MetadataKafkaDestination struct {
 
 	pubKey      *rsa.PublicKey
 	replicaName string
+	scope       *tenantScope
 }
 
 func NewMetadataKafkaDestination(mb *kafka.MessageBroker, replica model.Replica) *MetadataKafkaDestination {
@@ -28,6 +28,7 @@ func NewMetadataKafkaDestination(mb *kafka.MessageBroker, replica model.Replica)
 		mb:          mb,
 		pubKey:      replica.PublicKey,
 		replicaName: replica.Name,
+		scope:       newTenantScope(replica.TenantScope),
 	}
 }
 
@@ -36,13 +37,41 @@ func (mkd *MetadataKafkaDestination) ReplicaName() string {
 }
 
 func (mkd *MetadataKafkaDestination) topic() string {
-	return fmt.Sprintf("%s.%s", mkd.mb.PluginConfig.SelfTopicName, mkd.replicaName)
+	return replicaTopic(mkd.mb, mkd.replicaName)
 }
 
-func (mkd *MetadataKafkaDestination) ProcessObject(_ *io.MemoryStore, _ *memdb.Txn, obj io.MemoryStorableObject) ([]kafka.Message, error) {
+// TenantTombstones returns delete messages for the tenant and all its objects
+func (mkd *MetadataKafkaDestination) TenantTombstones(txn *memdb.Txn, tenantUUID model.TenantUUID) ([]kafka.Message, error) {
+	return mkd.tenantTombstones(txn, mkd.scope, tenantUUID, mkd.isValidObjectType, mkd.topic(), mkd.mb.EncryptionPrivateKey())
+}
+
+// TenantCurrentState returns messages for the tenant and all its objects
+func (mkd *MetadataKafkaDestination) TenantCurrentState(txn *memdb.Txn, tenant *model.Tenant) ([]kafka.Message, error) {
+	return mkd.tenantCurrentState(txn, mkd.scope, tenant, mkd.isValidObjectType, mkd.topic(), mkd.mb.EncryptionPrivateKey(), mkd.pubKey)
+}
+
+func (mkd *MetadataKafkaDestination) ProcessObject(_ *io.MemoryStore, txn *memdb.Txn, obj io.MemoryStorableObject) ([]kafka.Message, error) {
 	if !mkd.isValidObjectType(obj.ObjType()) {
 		return nil, nil
 	}
+	if tenant, isTenant := obj.(*model.Tenant); isTenant {
+		inScope, entered, left := mkd.scope.processTenant(txn, tenant)
+		if left {
+			return mkd.TenantTombstones(txn, tenant.UUID)
+		}
+		if entered {
+			return mkd.TenantCurrentState(txn, tenant)
+		}
+		if !inScope {
+			return nil, nil
+		}
+	} else if inScope, err := mkd.scope.objectInScope(txn, obj); err != nil || !inScope {
+		return nil, err
+	}
+	obj, err := mkd.scope.stripOutOfScopeMembers(txn, obj)
+	if err != nil {
+		return nil, err
+	}
 	msg, err := mkd.simpleObjectKafker(mkd.topic(), obj, mkd.mb.EncryptionPrivateKey(), mkd.pubKey, true)
 	if err != nil {
 		return nil, err
@@ -51,10 +80,13 @@ func (mkd *MetadataKafkaDestination) ProcessObject(_ *io.MemoryStore, _ *memdb.T
 	return []kafka.Message{msg}, nil
 }
 
-func (mkd *MetadataKafkaDestination) ProcessObjectDelete(_ *io.MemoryStore, _ *memdb.Txn, obj io.MemoryStorableObject) ([]kafka.Message, error) {
+func (mkd *MetadataKafkaDestination) ProcessObjectDelete(_ *io.MemoryStore, txn *memdb.Txn, obj io.MemoryStorableObject) ([]kafka.Message, error) {
 	if !mkd.isValidObjectType(obj.ObjType()) {
 		return nil, nil
 	}
+	if inScope, err := mkd.scope.objectInScope(txn, obj); err != nil || !inScope {
+		return nil, err
+	}
 	msg, err := mkd.simpleObjectDeleteKafker(mkd.topic(), obj, mkd.mb.EncryptionPrivateKey())
 	if err != nil {
 		return nil, err
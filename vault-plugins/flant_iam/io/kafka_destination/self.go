@@ -1,6 +1,7 @@
 package kafka_destination
 
 import (
+	iam_io "github.com/flant/negentropy/vault-plugins/flant_iam/io"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
 	"github.com/flant/negentropy/vault-plugins/shared/kafka"
 	"github.com/flant/negentropy/vault-plugins/shared/memdb"
@@ -22,6 +23,7 @@ func (mkd *SelfKafkaDestination) ReplicaName() string {
 	return mkd.mb.PluginConfig.SelfTopicName
 }
 
+// ProcessObject writes the object into the self topic and the same message into the history topic
 func (mkd *SelfKafkaDestination) ProcessObject(_ *io.MemoryStore, _ *memdb.Txn, obj io.MemoryStorableObject) ([]kafka.Message, error) {
 	msg, err := mkd.simpleObjectKafker(
 		mkd.mb.PluginConfig.SelfTopicName,
@@ -33,7 +35,7 @@ func (mkd *SelfKafkaDestination) ProcessObject(_ *io.MemoryStore, _ *memdb.Txn,
 		return nil, err
 	}
 
-	return []kafka.Message{msg}, nil
+	return mkd.withHistory(msg), nil
 }
 
 func (mkd *SelfKafkaDestination) ProcessObjectDelete(ms *io.MemoryStore, _ *memdb.Txn, obj io.MemoryStorableObject) ([]kafka.Message, error) {
@@ -41,5 +43,12 @@ func (mkd *SelfKafkaDestination) ProcessObjectDelete(ms *io.MemoryStore, _ *memd
 	if err != nil {
 		return nil, err
 	}
-	return []kafka.Message{msg}, nil
+	return mkd.withHistory(msg), nil
+}
+
+// withHistory returns msg and its copy for the history topic, which is not compacted
+func (mkd *SelfKafkaDestination) withHistory(msg kafka.Message) []kafka.Message {
+	historyMsg := msg
+	historyMsg.Topic = iam_io.SelfHistoryTopicName(mkd.mb)
+	return []kafka.Message{msg, historyMsg}
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/require"
 
+	iam_io "github.com/flant/negentropy/vault-plugins/flant_iam/io"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	"github.com/flant/negentropy/vault-plugins/shared/kafka"
 )
@@ -51,6 +52,8 @@ func TestSendItem(t *testing.T) {
 
 	err = mb.CreateTopic(context.TODO(), topic, nil)
 	require.NoError(t, err)
+	err = mb.CreateTopic(context.TODO(), iam_io.SelfHistoryTopicName(mb), iam_io.SelfHistoryTopicConfig)
+	require.NoError(t, err)
 
 	ss := NewSelfKafkaDestination(mb)
 	u := &model.User{
@@ -0,0 +1,398 @@
+package kafka_destination
+
+import (
+	"crypto/rsa"
+	"fmt"
+
+	ext_sa_model "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/model"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+	"github.com/flant/negentropy/vault-plugins/shared/kafka"
+	"github.com/flant/negentropy/vault-plugins/shared/memdb"
+)
+
+// tenantScope filters objects by tenants of the replica scope, and detects tenants entering and leaving the scope
+// by the previous state of the tenant in the committed transaction
+type tenantScope struct {
+	scope *model.ReplicaTenantScope
+}
+
+func newTenantScope(scope *model.ReplicaTenantScope) *tenantScope {
+	return &tenantScope{scope: scope}
+}
+
+// TenantsInScope returns uuids of all stored tenants, matched by the scope
+func TenantsInScope(txn *memdb.Txn, scope *model.ReplicaTenantScope) ([]model.TenantUUID, error) {
+	iter, err := txn.Get(model.TenantType, iam_repo.PK)
+	if err != nil {
+		return nil, err
+	}
+	var result []model.TenantUUID
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		tenant := raw.(*model.Tenant)
+		if scope.Contains(tenant) {
+			result = append(result, tenant.UUID)
+		}
+	}
+	return result, nil
+}
+
+// processTenant checks the tenant against the scope, entered and left are filled only if the tenant is changed
+// in the transaction, and its state before the transaction had the other scope matching
+func (s *tenantScope) processTenant(txn *memdb.Txn, tenant *model.Tenant) (inScope bool, entered bool, left bool) {
+	inScope = s.scope.Contains(tenant)
+	if s.scope == nil {
+		return inScope, false, false
+	}
+	for _, change := range txn.Changes() {
+		if change.Table != model.TenantType {
+			continue
+		}
+		if change.After != nil && change.After.(*model.Tenant).UUID != tenant.UUID {
+			continue
+		}
+		if change.Before == nil {
+			// the tenant is created by the transaction
+			return inScope, inScope && change.After != nil, false
+		}
+		before := change.Before.(*model.Tenant)
+		if before.UUID != tenant.UUID {
+			continue
+		}
+		wasInScope := s.scope.Contains(before)
+		return inScope, inScope && !wasInScope, wasInScope && !inScope
+	}
+	return inScope, false, false
+}
+
+func (s *tenantScope) isTenantInScope(txn *memdb.Txn, tenantUUID model.TenantUUID) (bool, error) {
+	if s.scope == nil {
+		return true, nil
+	}
+	for _, t := range s.scope.Tenants {
+		if t == tenantUUID {
+			return true, nil
+		}
+	}
+	if len(s.scope.LabelSelector) == 0 {
+		return false, nil
+	}
+	raw, err := txn.First(model.TenantType, iam_repo.PK, tenantUUID)
+	if err != nil {
+		return false, err
+	}
+	if raw == nil {
+		return false, nil
+	}
+	return s.scope.Contains(raw.(*model.Tenant)), nil
+}
+
+// objectInScope checks tenant of the object, objects without tenant (roles, feature_flags, etc.) are always in scope
+func (s *tenantScope) objectInScope(txn *memdb.Txn, obj io.MemoryStorableObject) (bool, error) {
+	if s.scope == nil {
+		return true, nil
+	}
+	var tenantUUIDs []model.TenantUUID
+	switch o := obj.(type) {
+	case *model.Tenant:
+		return s.scope.Contains(o), nil
+	case *model.Project:
+		tenantUUIDs = []model.TenantUUID{o.TenantUUID}
+	case *model.User:
+		tenantUUIDs = []model.TenantUUID{o.TenantUUID}
+	case *model.ServiceAccount:
+		tenantUUIDs = []model.TenantUUID{o.TenantUUID}
+	case *model.Group:
+		tenantUUIDs = []model.TenantUUID{o.TenantUUID}
+	case *model.RoleBinding:
+		tenantUUIDs = []model.TenantUUID{o.TenantUUID}
+	case *model.RoleBindingApproval:
+		tenantUUIDs = []model.TenantUUID{o.TenantUUID}
+	case *model.Multipass:
+		tenantUUIDs = []model.TenantUUID{o.TenantUUID}
+	case *model.ServiceAccountPassword:
+		tenantUUIDs = []model.TenantUUID{o.TenantUUID}
+	case *ext_sa_model.Server:
+		tenantUUIDs = []model.TenantUUID{o.TenantUUID}
+	case *model.IdentitySharing:
+		// sharing is needed by both sides
+		tenantUUIDs = []model.TenantUUID{o.SourceTenantUUID, o.DestinationTenantUUID}
+	default:
+		return true, nil
+	}
+	for _, t := range tenantUUIDs {
+		in, err := s.isTenantInScope(txn, t)
+		if err != nil || in {
+			return in, err
+		}
+	}
+	return false, nil
+}
+
+// stripOutOfScopeMembers returns the copy of the group, the rolebinding, the approval or the identity sharing without
+// members of tenants out of the scope, such members can be shared from other tenants by identity sharings,
+// they are not sent to the replica, so references to them would be dangling
+func (s *tenantScope) stripOutOfScopeMembers(txn *memdb.Txn, obj io.MemoryStorableObject) (io.MemoryStorableObject, error) {
+	if s.scope == nil {
+		return obj, nil
+	}
+	var err error
+	switch o := obj.(type) {
+	case *model.Group:
+		stripped := *o
+		if stripped.Users, stripped.Groups, stripped.ServiceAccounts, stripped.Members, err = s.membersInScope(txn,
+			o.Users, o.Groups, o.ServiceAccounts, o.Members); err != nil {
+			return nil, err
+		}
+		return &stripped, nil
+	case *model.RoleBinding:
+		stripped := *o
+		if stripped.Users, stripped.Groups, stripped.ServiceAccounts, stripped.Members, err = s.membersInScope(txn,
+			o.Users, o.Groups, o.ServiceAccounts, o.Members); err != nil {
+			return nil, err
+		}
+		return &stripped, nil
+	case *model.RoleBindingApproval:
+		stripped := *o
+		if stripped.Users, stripped.Groups, stripped.ServiceAccounts, stripped.Approvers, err = s.membersInScope(txn,
+			o.Users, o.Groups, o.ServiceAccounts, o.Approvers); err != nil {
+			return nil, err
+		}
+		return &stripped, nil
+	case *model.IdentitySharing:
+		stripped := *o
+		if _, stripped.Groups, _, _, err = s.membersInScope(txn, nil, o.Groups, nil, nil); err != nil {
+			return nil, err
+		}
+		return &stripped, nil
+	}
+	return obj, nil
+}
+
+func (s *tenantScope) membersInScope(txn *memdb.Txn, users []model.UserUUID, groups []model.GroupUUID,
+	serviceAccounts []model.ServiceAccountUUID, members []model.MemberNotation) ([]model.UserUUID, []model.GroupUUID,
+	[]model.ServiceAccountUUID, []model.MemberNotation, error) {
+	filter := func(memberType string, uuids []string) ([]string, error) {
+		if uuids == nil {
+			return nil, nil
+		}
+		result := make([]string, 0, len(uuids))
+		for _, uuid := range uuids {
+			in, err := s.memberInScope(txn, memberType, uuid)
+			if err != nil {
+				return nil, err
+			}
+			if in {
+				result = append(result, uuid)
+			}
+		}
+		return result, nil
+	}
+	users, err := filter(model.UserType, users)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if groups, err = filter(model.GroupType, groups); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if serviceAccounts, err = filter(model.ServiceAccountType, serviceAccounts); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	var strippedMembers []model.MemberNotation
+	if members != nil {
+		strippedMembers = make([]model.MemberNotation, 0, len(members))
+	}
+	for _, m := range members {
+		in, err := s.memberInScope(txn, m.Type, m.UUID)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		if in {
+			strippedMembers = append(strippedMembers, m)
+		}
+	}
+	return users, groups, serviceAccounts, strippedMembers, nil
+}
+
+// memberInScope checks the tenant of the user, the service account or the group, unknown members are out of the scope
+func (s *tenantScope) memberInScope(txn *memdb.Txn, memberType string, uuid string) (bool, error) {
+	raw, err := txn.First(memberType, iam_repo.PK, uuid)
+	if err != nil || raw == nil {
+		return false, err
+	}
+	var tenantUUID model.TenantUUID
+	switch m := raw.(type) {
+	case *model.User:
+		tenantUUID = m.TenantUUID
+	case *model.ServiceAccount:
+		tenantUUID = m.TenantUUID
+	case *model.Group:
+		tenantUUID = m.TenantUUID
+	default:
+		return false, nil
+	}
+	return s.isTenantInScope(txn, tenantUUID)
+}
+
+// tenantChildTypes are ordered to delete dependent objects first
+var tenantChildTypes = []string{
+	model.RoleBindingApprovalType,
+	model.RoleBindingType,
+	model.MultipassType,
+	model.ServiceAccountPasswordType,
+	ext_sa_model.ServerType,
+	model.IdentitySharingType,
+	model.GroupType,
+	model.ServiceAccountType,
+	model.UserType,
+	model.ProjectType,
+}
+
+// tenantTombstones builds delete messages for all stored objects of the tenant and the tenant itself,
+// identity sharings are kept if the other side is still in the scope
+func (cd *commonDest) tenantTombstones(txn *memdb.Txn, scope *tenantScope, tenantUUID model.TenantUUID,
+	isValidObjectType func(string) bool, topic string, pk *rsa.PrivateKey) ([]kafka.Message, error) {
+	var msgs []kafka.Message
+	addTombstone := func(obj io.MemoryStorableObject) error {
+		if !isValidObjectType(obj.ObjType()) {
+			return nil
+		}
+		msg, err := cd.simpleObjectDeleteKafker(topic, obj, pk)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+		return nil
+	}
+	for _, objType := range tenantChildTypes {
+		objs, err := tenantChildren(txn, objType, tenantUUID)
+		if err != nil {
+			return nil, fmt.Errorf("collecting %s of tenant %s: %w", objType, tenantUUID, err)
+		}
+		for _, obj := range objs {
+			if is, ok := obj.(*model.IdentitySharing); ok {
+				otherTenant := is.DestinationTenantUUID
+				if otherTenant == tenantUUID {
+					otherTenant = is.SourceTenantUUID
+				}
+				otherInScope, err := scope.isTenantInScope(txn, otherTenant)
+				if err != nil {
+					return nil, err
+				}
+				if otherInScope {
+					continue
+				}
+			}
+			if err = addTombstone(obj); err != nil {
+				return nil, err
+			}
+		}
+	}
+	raw, err := txn.First(model.TenantType, iam_repo.PK, tenantUUID)
+	if err != nil {
+		return nil, err
+	}
+	if raw != nil {
+		if err = addTombstone(raw.(*model.Tenant)); err != nil {
+			return nil, err
+		}
+	}
+	return msgs, nil
+}
+
+// tenantCurrentState builds messages for the tenant and all its stored objects, dependencies go first
+func (cd *commonDest) tenantCurrentState(txn *memdb.Txn, scope *tenantScope, tenant *model.Tenant,
+	isValidObjectType func(string) bool, topic string, pk *rsa.PrivateKey, pubKey *rsa.PublicKey) ([]kafka.Message, error) {
+	var msgs []kafka.Message
+	add := func(obj io.MemoryStorableObject) error {
+		if !isValidObjectType(obj.ObjType()) {
+			return nil
+		}
+		obj, err := scope.stripOutOfScopeMembers(txn, obj)
+		if err != nil {
+			return err
+		}
+		msg, err := cd.simpleObjectKafker(topic, obj, pk, pubKey, true)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+		return nil
+	}
+	if err := add(tenant); err != nil {
+		return nil, err
+	}
+	for i := len(tenantChildTypes) - 1; i >= 0; i-- {
+		objType := tenantChildTypes[i]
+		objs, err := tenantChildren(txn, objType, tenant.UUID)
+		if err != nil {
+			return nil, fmt.Errorf("collecting %s of tenant %s: %w", objType, tenant.UUID, err)
+		}
+		if objType == model.GroupType {
+			// members go before parents
+			for l, r := 0, len(objs)-1; l < r; l, r = l+1, r-1 {
+				objs[l], objs[r] = objs[r], objs[l]
+			}
+		}
+		for _, obj := range objs {
+			if err = add(obj); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return msgs, nil
+}
+
+func tenantChildren(txn *memdb.Txn, objType string, tenantUUID model.TenantUUID) ([]io.MemoryStorableObject, error) {
+	indexes := []string{iam_repo.TenantForeignPK}
+	if objType == model.IdentitySharingType {
+		indexes = []string{iam_repo.SourceTenantUUIDIndex, iam_repo.DestinationTenantUUIDIndex}
+	}
+	var result []io.MemoryStorableObject
+	for _, index := range indexes {
+		iter, err := txn.Get(objType, index, tenantUUID)
+		if err != nil {
+			return nil, err
+		}
+		for raw := iter.Next(); raw != nil; raw = iter.Next() {
+			result = append(result, raw.(io.MemoryStorableObject))
+		}
+	}
+	if objType == model.GroupType {
+		sortGroupsParentsFirst(result)
+	}
+	return result, nil
+}
+
+// sortGroupsParentsFirst orders groups to delete parent groups before their members
+func sortGroupsParentsFirst(groups []io.MemoryStorableObject) {
+	parents := map[model.GroupUUID][]model.GroupUUID{}
+	byUUID := map[model.GroupUUID]io.MemoryStorableObject{}
+	for _, obj := range groups {
+		g := obj.(*model.Group)
+		byUUID[g.UUID] = obj
+		for _, child := range g.Groups {
+			parents[child] = append(parents[child], g.UUID)
+		}
+	}
+	visited := map[model.GroupUUID]struct{}{}
+	sorted := make([]io.MemoryStorableObject, 0, len(groups))
+	var visit func(uuid model.GroupUUID)
+	visit = func(uuid model.GroupUUID) {
+		if _, ok := visited[uuid]; ok {
+			return
+		}
+		visited[uuid] = struct{}{}
+		for _, p := range parents[uuid] {
+			visit(p)
+		}
+		if obj, ok := byUUID[uuid]; ok {
+			sorted = append(sorted, obj)
+		}
+	}
+	for _, obj := range groups {
+		visit(obj.ObjId())
+	}
+	copy(groups, sorted)
+}
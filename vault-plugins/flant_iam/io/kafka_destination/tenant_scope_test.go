@@ -0,0 +1,180 @@
+package kafka_destination
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ext_sa_repo "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/repo"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+	"github.com/flant/negentropy/vault-plugins/shared/kafka"
+	"github.com/flant/negentropy/vault-plugins/shared/memdb"
+)
+
+const (
+	euTenantUUID = "00000000-0000-4000-a000-000000000001"
+	usTenantUUID = "00000000-0000-4000-a000-000000000002"
+	euUserUUID   = "00000000-0000-4000-a000-000000000011"
+	usUserUUID   = "00000000-0000-4000-a000-000000000012"
+	euGroupUUID  = "00000000-0000-4000-a000-000000000021"
+)
+
+func scopeTestTxn(t *testing.T) *memdb.Txn {
+	iamSchema, err := iam_repo.GetSchema()
+	require.NoError(t, err)
+	schema, err := memdb.MergeDBSchemasAndValidate(iamSchema, ext_sa_repo.ServerSchema())
+	require.NoError(t, err)
+	db, err := memdb.NewMemDB(schema)
+	require.NoError(t, err)
+	txn := db.Txn(true)
+	for _, obj := range []struct {
+		table string
+		obj   interface{}
+	}{
+		{model.TenantType, &model.Tenant{UUID: euTenantUUID, Version: "1", Identifier: "eu", Labels: map[string]string{"region": "eu"}}},
+		{model.TenantType, &model.Tenant{UUID: usTenantUUID, Version: "1", Identifier: "us", Labels: map[string]string{"region": "us"}}},
+		{model.UserType, &model.User{UUID: euUserUUID, TenantUUID: euTenantUUID, Version: "1", Identifier: "vasya", Email: "v@eu"}},
+		{model.UserType, &model.User{UUID: usUserUUID, TenantUUID: usTenantUUID, Version: "1", Identifier: "john", Email: "j@us"}},
+		{model.GroupType, &model.Group{UUID: euGroupUUID, TenantUUID: euTenantUUID, Version: "1", Identifier: "g", Users: []model.UserUUID{euUserUUID}}},
+	} {
+		require.NoError(t, txn.Insert(obj.table, obj.obj))
+	}
+	txn.Commit()
+	return db.Txn(true)
+}
+
+func scopeTestDestination(t *testing.T, scope *model.ReplicaTenantScope) *VaultKafkaDestination {
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	mb := &kafka.MessageBroker{
+		KafkaConfig:  kafka.BrokerConfig{EncryptionPrivateKey: pk},
+		PluginConfig: kafka.PluginConfig{SelfTopicName: "root_source"},
+	}
+	return NewVaultKafkaDestination(mb, model.Replica{Name: "eu", PublicKey: &pk.PublicKey, TenantScope: scope})
+}
+
+func messageKeys(msgs []kafka.Message) []string {
+	keys := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		keys = append(keys, m.Key)
+	}
+	return keys
+}
+
+func Test_TenantScopeFiltersObjects(t *testing.T) {
+	txn := scopeTestTxn(t)
+	dest := scopeTestDestination(t, &model.ReplicaTenantScope{LabelSelector: map[string]string{"region": "eu"}})
+
+	for _, tc := range []struct {
+		obj     io.MemoryStorableObject
+		inScope bool
+	}{
+		{&model.User{UUID: euUserUUID, TenantUUID: euTenantUUID}, true},
+		{&model.User{UUID: usUserUUID, TenantUUID: usTenantUUID}, false},
+		{&model.Tenant{UUID: usTenantUUID, Labels: map[string]string{"region": "us"}}, false},
+		{&model.Role{Name: "ssh"}, true},
+		{&model.IdentitySharing{UUID: "is", SourceTenantUUID: usTenantUUID, DestinationTenantUUID: euTenantUUID}, true},
+	} {
+		msgs, err := dest.ProcessObject(nil, txn, tc.obj)
+
+		require.NoError(t, err)
+		if tc.inScope {
+			require.Len(t, msgs, 1, tc.obj.ObjType())
+		} else {
+			require.Empty(t, msgs, tc.obj.ObjType())
+		}
+	}
+}
+
+func Test_TenantScopeTombstonesWhenTenantLeaves(t *testing.T) {
+	txn := scopeTestTxn(t)
+	dest := scopeTestDestination(t, &model.ReplicaTenantScope{LabelSelector: map[string]string{"region": "eu"}})
+	moved := &model.Tenant{UUID: euTenantUUID, Version: "2", Identifier: "eu", Labels: map[string]string{"region": "us"}}
+	require.NoError(t, txn.Insert(model.TenantType, moved))
+
+	msgs, err := dest.ProcessObject(nil, txn, moved)
+
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		model.GroupType + "/" + euGroupUUID,
+		model.UserType + "/" + euUserUUID,
+		model.TenantType + "/" + euTenantUUID,
+	}, messageKeys(msgs))
+	for _, m := range msgs {
+		require.Nil(t, m.Value)
+	}
+}
+
+func Test_TenantScopeCurrentStateWhenTenantEnters(t *testing.T) {
+	txn := scopeTestTxn(t)
+	dest := scopeTestDestination(t, &model.ReplicaTenantScope{LabelSelector: map[string]string{"region": "us"}})
+	moved := &model.Tenant{UUID: euTenantUUID, Version: "2", Identifier: "eu", Labels: map[string]string{"region": "us"}}
+	require.NoError(t, txn.Insert(model.TenantType, moved))
+
+	msgs, err := dest.ProcessObject(nil, txn, moved)
+
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		model.TenantType + "/" + euTenantUUID,
+		model.UserType + "/" + euUserUUID,
+		model.GroupType + "/" + euGroupUUID,
+	}, messageKeys(msgs))
+	for _, m := range msgs {
+		require.NotNil(t, m.Value)
+	}
+}
+
+func Test_TenantScopeNotChangedTenant(t *testing.T) {
+	txn := scopeTestTxn(t)
+	dest := scopeTestDestination(t, &model.ReplicaTenantScope{LabelSelector: map[string]string{"region": "eu"}})
+	same := &model.Tenant{UUID: euTenantUUID, Version: "2", Identifier: "eu2", Labels: map[string]string{"region": "eu"}}
+	require.NoError(t, txn.Insert(model.TenantType, same))
+
+	msgs, err := dest.ProcessObject(nil, txn, same)
+
+	require.NoError(t, err)
+	require.Equal(t, []string{model.TenantType + "/" + euTenantUUID}, messageKeys(msgs))
+}
+
+func Test_TenantsInScope(t *testing.T) {
+	txn := scopeTestTxn(t)
+
+	all, err := TenantsInScope(txn, nil)
+	require.NoError(t, err)
+	byList, err := TenantsInScope(txn, &model.ReplicaTenantScope{Tenants: []model.TenantUUID{usTenantUUID}})
+	require.NoError(t, err)
+
+	require.ElementsMatch(t, []model.TenantUUID{euTenantUUID, usTenantUUID}, all)
+	require.Equal(t, []model.TenantUUID{usTenantUUID}, byList)
+}
+
+func Test_TenantScopeStripsSharedMembersOfOutOfScopeTenants(t *testing.T) {
+	txn := scopeTestTxn(t)
+	require.NoError(t, txn.Insert(model.IdentitySharingType, &model.IdentitySharing{
+		UUID: "00000000-0000-4000-a000-000000000031", SourceTenantUUID: usTenantUUID,
+		DestinationTenantUUID: euTenantUUID, Version: "1", Groups: []model.GroupUUID{euGroupUUID},
+	}))
+	dest := scopeTestDestination(t, &model.ReplicaTenantScope{LabelSelector: map[string]string{"region": "eu"}})
+	group := &model.Group{
+		UUID: euGroupUUID, TenantUUID: euTenantUUID, Version: "2", Identifier: "g",
+		Users: []model.UserUUID{euUserUUID, usUserUUID},
+		Members: []model.MemberNotation{
+			{Type: model.UserType, UUID: euUserUUID},
+			{Type: model.UserType, UUID: usUserUUID},
+		},
+	}
+
+	stripped, err := dest.scope.stripOutOfScopeMembers(txn, group)
+
+	require.NoError(t, err)
+	require.Equal(t, []model.UserUUID{euUserUUID}, stripped.(*model.Group).Users)
+	require.Equal(t, []model.MemberNotation{{Type: model.UserType, UUID: euUserUUID}}, stripped.(*model.Group).Members)
+	require.Len(t, group.Users, 2, "stored object should not be changed")
+	msgs, err := dest.ProcessObject(nil, txn, group)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+}
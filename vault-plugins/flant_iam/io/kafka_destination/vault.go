@@ -2,7 +2,6 @@ package kafka_destination
 
 import (
 	"crypto/rsa"
-	"fmt"
 
 	ext_ff_model "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_flant_flow/model"
 	ext_sa_model "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/model"
@@ -22,6 +21,7 @@ type VaultKafkaDestination struct {
 
 	pubKey      *rsa.PublicKey
 	replicaName string
+	scope       *tenantScope
 }
 
 func NewVaultKafkaDestination(mb *kafka.MessageBroker, replica model.Replica) *VaultKafkaDestination {
@@ -30,21 +30,50 @@ func NewVaultKafkaDestination(mb *kafka.MessageBroker, replica model.Replica) *V
 		mb:          mb,
 		pubKey:      replica.PublicKey,
 		replicaName: replica.Name,
+		scope:       newTenantScope(replica.TenantScope),
 	}
 }
 
 func (vkd *VaultKafkaDestination) topic() string {
-	return fmt.Sprintf("%s.%s", vkd.mb.PluginConfig.SelfTopicName, vkd.replicaName)
+	return replicaTopic(vkd.mb, vkd.replicaName)
 }
 
 func (vkd *VaultKafkaDestination) ReplicaName() string {
 	return vkd.replicaName
 }
 
-func (vkd *VaultKafkaDestination) ProcessObject(_ *io.MemoryStore, _ *memdb.Txn, obj io.MemoryStorableObject) ([]kafka.Message, error) {
+// TenantTombstones returns delete messages for the tenant and all its objects
+func (vkd *VaultKafkaDestination) TenantTombstones(txn *memdb.Txn, tenantUUID model.TenantUUID) ([]kafka.Message, error) {
+	return vkd.tenantTombstones(txn, vkd.scope, tenantUUID, vkd.isValidObjectType, vkd.topic(), vkd.mb.EncryptionPrivateKey())
+}
+
+// TenantCurrentState returns messages for the tenant and all its objects
+func (vkd *VaultKafkaDestination) TenantCurrentState(txn *memdb.Txn, tenant *model.Tenant) ([]kafka.Message, error) {
+	return vkd.tenantCurrentState(txn, vkd.scope, tenant, vkd.isValidObjectType, vkd.topic(), vkd.mb.EncryptionPrivateKey(), vkd.pubKey)
+}
+
+func (vkd *VaultKafkaDestination) ProcessObject(_ *io.MemoryStore, txn *memdb.Txn, obj io.MemoryStorableObject) ([]kafka.Message, error) {
 	if !vkd.isValidObjectType(obj.ObjType()) {
 		return nil, nil
 	}
+	if tenant, isTenant := obj.(*model.Tenant); isTenant {
+		inScope, entered, left := vkd.scope.processTenant(txn, tenant)
+		if left {
+			return vkd.TenantTombstones(txn, tenant.UUID)
+		}
+		if entered {
+			return vkd.TenantCurrentState(txn, tenant)
+		}
+		if !inScope {
+			return nil, nil
+		}
+	} else if inScope, err := vkd.scope.objectInScope(txn, obj); err != nil || !inScope {
+		return nil, err
+	}
+	obj, err := vkd.scope.stripOutOfScopeMembers(txn, obj)
+	if err != nil {
+		return nil, err
+	}
 	msg, err := vkd.simpleObjectKafker(vkd.topic(), obj, vkd.mb.EncryptionPrivateKey(), vkd.pubKey, true)
 	if err != nil {
 		return nil, err
@@ -53,10 +82,13 @@ func (vkd *VaultKafkaDestination) ProcessObject(_ *io.MemoryStore, _ *memdb.Txn,
 	return []kafka.Message{msg}, nil
 }
 
-func (vkd *VaultKafkaDestination) ProcessObjectDelete(_ *io.MemoryStore, _ *memdb.Txn, obj io.MemoryStorableObject) ([]kafka.Message, error) {
+func (vkd *VaultKafkaDestination) ProcessObjectDelete(_ *io.MemoryStore, txn *memdb.Txn, obj io.MemoryStorableObject) ([]kafka.Message, error) {
 	if !vkd.isValidObjectType(obj.ObjType()) {
 		return nil, nil
 	}
+	if inScope, err := vkd.scope.objectInScope(txn, obj); err != nil || !inScope {
+		return nil, err
+	}
 	msg, err := vkd.simpleObjectDeleteKafker(vkd.topic(), obj, vkd.mb.EncryptionPrivateKey())
 	if err != nil {
 		return nil, err
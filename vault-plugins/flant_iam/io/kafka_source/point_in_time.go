@@ -0,0 +1,251 @@
+package kafka_source
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	log "github.com/hashicorp/go-hclog"
+
+	iam_io "github.com/flant/negentropy/vault-plugins/flant_iam/io"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
+	"github.com/flant/negentropy/vault-plugins/shared/memdb"
+)
+
+// pointInTimeCacheSize is the count of replayed stores of past moments, kept for repeated queries,
+// it also limits the count of moments waiting for replaying
+const pointInTimeCacheSize = 4
+
+// historySeedBatchSize is the count of messages of the self topic, copied into the history topic by one transaction
+const historySeedBatchSize = 1000
+
+// pointInTimeState is the state of the replaying of the history topic till the moment
+type pointInTimeState struct {
+	done  bool
+	store *io.MemoryStore
+	err   error
+}
+
+// PointInTimeStorage builds read-only states of the IAM at the passed moments,
+// by replaying the history topic into a temporary MemoryStore. Replaying runs in background,
+// StoreAt returns consts.ErrNotReady until the state is built
+type PointInTimeStorage struct {
+	kf              *sharedkafka.MessageBroker
+	schema          *memdb.DBSchema
+	restoreHandlers []RestoreFunc
+	logger          log.Logger
+
+	mutex  sync.Mutex
+	states map[int64]*pointInTimeState // by unix seconds
+	order  []int64                     // built states, the oldest first
+	queue  chan int64
+	once   sync.Once
+}
+
+func NewPointInTimeStorage(kf *sharedkafka.MessageBroker, schema *memdb.DBSchema, restoreHandlers []RestoreFunc,
+	parentLogger log.Logger) *PointInTimeStorage {
+	return &PointInTimeStorage{
+		kf:              kf,
+		schema:          schema,
+		restoreHandlers: restoreHandlers,
+		logger:          parentLogger.Named("PointInTimeStorage"),
+		states:          map[int64]*pointInTimeState{},
+		queue:           make(chan int64, pointInTimeCacheSize),
+	}
+}
+
+// StoreAt returns the store, filled by the messages of the history topic, written not later than asOf,
+// or consts.ErrNotReady, if the store is not built yet. asOf is truncated to seconds and should be in the past,
+// the returned store should be used only for reading
+func (s *PointInTimeStorage) StoreAt(asOf time.Time) (*io.MemoryStore, error) {
+	if s.kf == nil || !s.kf.Configured() {
+		return nil, fmt.Errorf("%w: kafka, point-in-time reads are unavailable", consts.ErrNotConfigured)
+	}
+	key := asOf.Unix()
+	moment := time.Unix(key, 0).UTC().Format(time.RFC3339)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if state, ok := s.states[key]; ok {
+		if !state.done {
+			return nil, fmt.Errorf("%w: the state at %s is being built, retry later", consts.ErrNotReady, moment)
+		}
+		if state.err != nil {
+			// the failed state is reported once, the next query builds it again
+			s.forget(key)
+		}
+		return state.store, state.err
+	}
+
+	s.once.Do(func() { go s.buildLoop() })
+	select {
+	case s.queue <- key:
+		s.states[key] = &pointInTimeState{}
+		return nil, fmt.Errorf("%w: the state at %s is scheduled to be built, retry later", consts.ErrNotReady, moment)
+	default:
+		return nil, fmt.Errorf("%w: too many states are being built, retry later", consts.ErrNotReady)
+	}
+}
+
+// buildLoop builds queued states one by one
+func (s *PointInTimeStorage) buildLoop() {
+	for key := range s.queue {
+		store, err := s.build(key)
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("building state at %d: %s", key, err.Error()))
+		}
+
+		s.mutex.Lock()
+		s.states[key] = &pointInTimeState{done: true, store: store, err: err}
+		s.order = append(s.order, key)
+		if len(s.order) > pointInTimeCacheSize {
+			s.forget(s.order[0])
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// forget removes built state, should be called under the mutex
+func (s *PointInTimeStorage) forget(key int64) {
+	delete(s.states, key)
+	for i, k := range s.order {
+		if k == key {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// build replays the history topic till the end of the second key
+func (s *PointInTimeStorage) build(key int64) (*io.MemoryStore, error) {
+	store, err := io.NewMemoryStore(s.schema, nil, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	moment := time.Unix(key, 0)
+	// turn off checking due to restoring items with archived relations
+	txn := store.MemDB.Txn(true).WithSkippingInsertForeignKeysCheck()
+	source := newSelfHistoryKafkaSource(s.kf, s.restoreHandlers, s.logger)
+	first, err := source.RestoreTill(txn, moment.Add(time.Second-time.Nanosecond))
+	if err != nil {
+		txn.Abort()
+		return nil, fmt.Errorf("replaying till %s: %w", moment.UTC().Format(time.RFC3339), err)
+	}
+	if first.IsZero() {
+		txn.Abort()
+		return nil, fmt.Errorf("%w: history is empty", consts.ErrInvalidArg)
+	}
+	if first.After(moment) {
+		txn.Abort()
+		return nil, fmt.Errorf("%w: history is kept since %s", consts.ErrInvalidArg, first.UTC().Format(time.RFC3339))
+	}
+	txn.Commit()
+	return store, nil
+}
+
+// newSelfHistoryKafkaSource returns not runnable source of the history topic, snapshots are not used,
+// as they keep only the current state
+func newSelfHistoryKafkaSource(kf *sharedkafka.MessageBroker, restoreHandlers []RestoreFunc,
+	parentLogger log.Logger) *io.KafkaSourceImpl {
+	source := NewSelfKafkaSource(kf, restoreHandlers, parentLogger)
+	source.NameOfSource = "iamSelfHistoryKafkaSource"
+	source.Logger = parentLogger.Named("iamSelfHistoryKafkaSource")
+	source.ProvideTopicName = iam_io.SelfHistoryTopicName
+	source.ProvideSnapshotTopicName = nil
+	return source
+}
+
+// SeedSelfHistory copies messages of the self topic into the empty history topic, it is needed for plugins,
+// configured before the history topic was introduced. The history of such plugins starts at the seeding.
+// Should be called before adding the self destination
+func SeedSelfHistory(kf *sharedkafka.MessageBroker, parentLogger log.Logger) error {
+	logger := parentLogger.Named("SeedSelfHistory")
+	historyTopic := iam_io.SelfHistoryTopicName(kf)
+	consumer, err := kf.GetRestorationReader()
+	if err != nil {
+		return err
+	}
+	lastOffset, _, err := io.LastOffsetByNewConsumer(consumer, historyTopic)
+	sharedkafka.DeferredСlose(consumer, logger)
+	if err != nil {
+		return fmt.Errorf("getting offset of %s: %w", historyTopic, err)
+	}
+	if lastOffset > 0 {
+		return nil
+	}
+
+	var batch []sharedkafka.Message
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := kf.SendMessages(batch, nil)
+		batch = nil
+		return err
+	}
+	source := NewSelfKafkaSource(kf, nil, parentLogger)
+	err = source.RunRestorationLoop(nil, func(_ io.Txn, msg *kafka.Message, _ log.Logger) error {
+		headers := map[string][]byte{}
+		for _, h := range msg.Headers {
+			headers[h.Key] = h.Value
+		}
+		batch = append(batch, sharedkafka.Message{
+			Topic:   historyTopic,
+			Key:     string(msg.Key),
+			Value:   msg.Value,
+			Headers: headers,
+		})
+		if len(batch) < historySeedBatchSize {
+			return nil
+		}
+		return send()
+	}, logger)
+	if err != nil {
+		return fmt.Errorf("seeding %s: %w", historyTopic, err)
+	}
+	return send()
+}
+
+// errHistoryKeyFound stops reading of the history topic at the first message, encrypted by the key
+var errHistoryKeyFound = errors.New("history message is encrypted by the key")
+
+// CheckHistoryKey returns sharedkafka.ErrEncryptionKeyInUse if any message of the history topic is encrypted by the key,
+// the history topic is not compacted and is not rewritten by re-encryption, so such key can't be retired,
+// messages without the key id header are checked by decryption
+func CheckHistoryKey(kf *sharedkafka.MessageBroker, keyID string, parentLogger log.Logger) error {
+	logger := parentLogger.Named("CheckHistoryKey")
+	source := newSelfHistoryKafkaSource(kf, nil, parentLogger)
+	err := source.RunRestorationLoop(nil, func(_ io.Txn, msg *kafka.Message, _ log.Logger) error {
+		if len(msg.Value) == 0 {
+			return nil
+		}
+		var chunked bool
+		msgKeyID := ""
+		for _, h := range msg.Headers {
+			switch h.Key {
+			case "chunked":
+				chunked = true
+			case sharedkafka.KeyIDHeader:
+				msgKeyID = string(h.Value)
+			}
+		}
+		if msgKeyID == keyID {
+			return errHistoryKeyFound
+		}
+		if msgKeyID == "" {
+			if _, err := kf.Decrypt(msg.Value, chunked, keyID); err == nil {
+				return errHistoryKeyFound
+			}
+		}
+		return nil
+	}, logger)
+	if errors.Is(err, errHistoryKeyFound) {
+		return fmt.Errorf("%w: messages of %s are encrypted by the key", sharedkafka.ErrEncryptionKeyInUse,
+			iam_io.SelfHistoryTopicName(kf))
+	}
+	return err
+}
@@ -7,6 +7,7 @@ import (
 
 	log "github.com/hashicorp/go-hclog"
 
+	iam_io "github.com/flant/negentropy/vault-plugins/flant_iam/io"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	"github.com/flant/negentropy/vault-plugins/shared/consts"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
@@ -25,10 +26,10 @@ func NewSelfKafkaSource(kf *sharedkafka.MessageBroker, restoreHandlers []Restore
 	}
 	verifySign := func(signature []byte, messageValue []byte) error {
 		hashed := sha256.Sum256(messageValue)
-		return sharedkafka.VerifySignature(signature, kf.EncryptionPublicKey(), hashed)
+		return kf.VerifyOwnSignature(signature, hashed)
 	}
-	decrypt := func(encryptedMessageValue []byte, chunked bool) ([]byte, error) {
-		return sharedkafka.NewEncrypter().Decrypt(encryptedMessageValue, kf.EncryptionPrivateKey(), chunked)
+	decrypt := func(encryptedMessageValue []byte, chunked bool, keyID string) ([]byte, error) {
+		return kf.Decrypt(encryptedMessageValue, chunked, keyID)
 	}
 	proccessRestoreMessage := func(txn io.Txn, m io.MsgDecoded) error {
 		for _, r := range restoreHandlers {
@@ -50,6 +51,8 @@ func NewSelfKafkaSource(kf *sharedkafka.MessageBroker, restoreHandlers []Restore
 		Logger:                    parentLogger.Named("iamSelfKafkaSource"),
 		ProvideRunConsumerGroupID: runConsumerGroupIDProvider,
 		ProvideTopicName:          topicNameProvider,
+		ProvideSnapshotTopicName:  SelfSnapshotTopicName,
+		SchemaRegistry:            iam_io.SchemaRegistry,
 		VerifySign:                verifySign,
 		Decrypt:                   decrypt,
 		ProcessRunMessage:         nil, // don't need as not runnable
@@ -106,6 +109,9 @@ func IamObjectsRestoreHandler(txn io.Txn, m io.MsgDecoded) (bool, error) {
 
 	case model.RoleBindingApprovalType:
 		inputObject = &model.RoleBindingApproval{}
+
+	case model.AccessRequestType:
+		inputObject = &model.AccessRequest{}
 	}
 	err = json.Unmarshal(m.Data, inputObject)
 	if err != nil {
@@ -0,0 +1,33 @@
+package kafka_source
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+
+	log "github.com/hashicorp/go-hclog"
+
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
+)
+
+// SelfSnapshotTopicName returns the name of compacted topic with snapshots of the self topic
+func SelfSnapshotTopicName(kf *sharedkafka.MessageBroker) string {
+	return "snapshot." + kf.PluginConfig.SelfTopicName
+}
+
+// NewSelfSnapshotWriter returns writer of snapshots of the self topic, signed and encrypted by the plugin keys
+func NewSelfSnapshotWriter(kf *sharedkafka.MessageBroker, parentLogger log.Logger) *io.SnapshotWriter {
+	sign := func(data []byte) ([]byte, error) {
+		hashed := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, kf.EncryptionPrivateKey(), crypto.SHA256, hashed[:])
+	}
+	encrypter := sharedkafka.NewEncrypter()
+	encrypt := func(data []byte) ([]byte, bool, string, error) {
+		pub := kf.EncryptionPublicKey()
+		encrypted, chunked, err := encrypter.Encrypt(data, pub)
+		return encrypted, chunked, sharedkafka.KeyID(pub), err
+	}
+	return io.NewSnapshotWriter(NewSelfKafkaSource(kf, nil, parentLogger), sign, encrypt, parentLogger)
+}
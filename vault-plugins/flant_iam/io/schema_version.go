@@ -0,0 +1,14 @@
+package io
+
+import (
+	sharedio "github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+// SchemaRegistry keeps upgrades of data of flant_iam objects, written into kafka topics by previous versions
+// of the plugin. Register an upgrade here at any incompatible change of the object json, e.g.:
+//
+//	SchemaRegistry = sharedio.NewSchemaRegistry().
+//		MustRegister(model.RoleType, 0, upgradeRoleForbiddenDirectUse)
+//
+// Destinations mark messages by the current versions, sources upgrade data on restore and run
+var SchemaRegistry = sharedio.NewSchemaRegistry()
@@ -0,0 +1,66 @@
+package model
+
+import "github.com/flant/negentropy/vault-plugins/shared/memdb"
+
+const AccessRequestType = "access_request" // also, memdb schema name
+
+type AccessRequestStatus = string
+
+const (
+	AccessRequestPending  AccessRequestStatus = "pending"
+	AccessRequestApproved AccessRequestStatus = "approved"
+	AccessRequestRejected AccessRequestStatus = "rejected"
+	AccessRequestExpired  AccessRequestStatus = "expired"
+)
+
+// AccessRequestMaxTTL limits the requested time of access, in seconds
+const AccessRequestMaxTTL = 24 * 60 * 60
+
+// AccessRequest is a request of just-in-time access: the requester asks for the role at the scope for the limited time.
+// The temporary RoleBinding is created with the request, it is ineffective until its RoleBindingApproval is approved
+type AccessRequest struct {
+	memdb.ArchiveMark
+
+	UUID       AccessRequestUUID `json:"uuid"` // PK
+	TenantUUID TenantUUID        `json:"tenant_uuid"`
+	Version    string            `json:"resource_version"`
+
+	Requester MemberNotation `json:"requester"`
+	Reason    string         `json:"reason"`
+
+	Role       BoundRole     `json:"role"`
+	AnyProject bool          `json:"any_project"`
+	Projects   []ProjectUUID `json:"projects"`
+
+	RequestedTTL int64 `json:"requested_ttl"` // in seconds
+
+	// Approvers, RequiredVotes and RequireMFA configure the approval of the temporary rolebinding,
+	// they are taken from the AccessRequestPolicy of the tenant at creating
+	Approvers     []MemberNotation `json:"approvers"`
+	RequiredVotes int              `json:"required_votes"`
+	RequireMFA    bool             `json:"require_mfa"`
+
+	Status    AccessRequestStatus `json:"status"`
+	DecidedBy *MemberNotation     `json:"decided_by,omitempty"`
+	DecidedAt UnixTime            `json:"decided_at,omitempty"`
+
+	// RoleBindingUUID and ApprovalUUID are filled by creating
+	RoleBindingUUID RoleBindingUUID         `json:"role_binding_uuid,omitempty"`
+	ApprovalUUID    RoleBindingApprovalUUID `json:"approval_uuid,omitempty"`
+}
+
+// AccessRequestPolicy is the setting of the tenant, which configures approvals of all access requests at the tenant,
+// so the requester can't choose approvers of own request
+type AccessRequestPolicy struct {
+	Approvers     []MemberNotation `json:"approvers"`
+	RequiredVotes int              `json:"required_votes"`
+	RequireMFA    bool             `json:"require_mfa"`
+}
+
+func (r *AccessRequest) ObjType() string {
+	return AccessRequestType
+}
+
+func (r *AccessRequest) ObjId() string {
+	return r.UUID
+}
@@ -12,6 +12,7 @@ type (
 	MultipassUUID              = string
 	RoleBindingUUID            = string
 	RoleBindingApprovalUUID    = string
+	AccessRequestUUID          = string
 	IdentitySharingUUID        = string
 	ReplicaName                = string
 	OwnerUUID                  = string
@@ -12,6 +12,36 @@ type Replica struct {
 	PublicKey                         *rsa.PublicKey `json:"replica_key"`
 	SendCurrentStateAtStart           bool           `json:"send_current_state_at_start"`
 	ShowArchivedInCurrentStateAtStart bool           `json:"show_archived_in_current_state_at_start"`
+	// TenantScope restricts tenants, which objects are sent to the replica, nil means all tenants
+	TenantScope *ReplicaTenantScope `json:"tenant_scope,omitempty"`
+}
+
+// ReplicaTenantScope selects tenants by uuid or by labels, tenant is in the scope if it matches any of the ways
+type ReplicaTenantScope struct {
+	Tenants []TenantUUID `json:"tenants,omitempty"`
+	// LabelSelector matches tenants, which have all the labels
+	LabelSelector map[string]string `json:"label_selector,omitempty"`
+}
+
+// Contains returns true if the tenant is in the scope
+func (s *ReplicaTenantScope) Contains(tenant *Tenant) bool {
+	if s == nil {
+		return true
+	}
+	for _, t := range s.Tenants {
+		if t == tenant.UUID {
+			return true
+		}
+	}
+	if len(s.LabelSelector) == 0 {
+		return false
+	}
+	for k, v := range s.LabelSelector {
+		if label, ok := tenant.Labels[k]; !ok || label != v {
+			return false
+		}
+	}
+	return true
 }
 
 func (r Replica) ObjType() string {
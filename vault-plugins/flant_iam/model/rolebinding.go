@@ -29,6 +29,13 @@ type RoleBinding struct {
 
 	Roles []BoundRole `json:"roles"`
 
+	// Deny marks the rolebinding as revoking: bound roles and all roles included into them are taken away from members
+	// at projects of the rolebinding, or at the whole tenant if any_project is true or projects are not specified
+	Deny bool `json:"deny"`
+
+	// Condition restricts using of the rolebinding at login, if nil => no restrictions
+	Condition *RoleBindingCondition `json:"condition,omitempty"`
+
 	Origin consts.ObjectOrigin `json:"origin"`
 
 	Extensions map[consts.ObjectOrigin]*Extension `json:"-"`
@@ -39,6 +46,28 @@ type BoundRole struct {
 	Options map[string]interface{} `json:"options"`
 }
 
+// RoleBindingCondition is a set of restrictions, all filled restrictions should be satisfied
+type RoleBindingCondition struct {
+	// SourceCIDRs are allowed networks of the login request source address
+	SourceCIDRs []string `json:"source_cidrs,omitempty"`
+	// TimeWindow is allowed time of login
+	TimeWindow *TimeWindow `json:"time_window,omitempty"`
+	// AuthMethods are names of allowed auth methods
+	AuthMethods []string `json:"auth_methods,omitempty"`
+}
+
+// TimeWindow represents daily period, for example: weekdays: ["mon","tue","wed","thu","fri"], from: "09:00", till: "18:00"
+type TimeWindow struct {
+	// Weekdays are allowed days: "mon", "tue", "wed", "thu", "fri", "sat", "sun", if empty => any day
+	Weekdays []string `json:"weekdays,omitempty"`
+	// From is a start of the period in "15:04" format
+	From string `json:"from"`
+	// Till is an end of the period in "15:04" format, if Till is less than From, the period passes midnight
+	Till string `json:"till"`
+	// Timezone is IANA name of a timezone, for example "Europe/Moscow", if empty => UTC
+	Timezone string `json:"timezone,omitempty"`
+}
+
 func (r *RoleBinding) ObjType() string {
 	return RoleBindingType
 }
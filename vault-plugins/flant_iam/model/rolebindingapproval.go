@@ -21,6 +21,14 @@ type RoleBindingApproval struct {
 	RequireMFA    bool `json:"require_mfa"`
 
 	RequireUniqueApprover bool `json:"require_unique_approver"`
+
+	Votes []ApprovalVote `json:"votes"`
+}
+
+// ApprovalVote is a stored vote of one approver, kept for audit of the decision
+type ApprovalVote struct {
+	Author  MemberNotation `json:"author"`
+	VotedAt UnixTime       `json:"voted_at"`
 }
 
 func (r *RoleBindingApproval) ObjType() string {
@@ -35,3 +43,18 @@ func (r *RoleBindingApproval) ObjId() string {
 func (r *RoleBindingApproval) FixApprovers() bool {
 	return FixMembers(&r.Approvers, r.Users, r.Groups, r.ServiceAccounts)
 }
+
+// Approved returns true if approval collected enough votes
+func (r *RoleBindingApproval) Approved() bool {
+	return len(r.Votes) >= r.RequiredVotes
+}
+
+// HasVoteOf returns true if author already voted for the approval
+func (r *RoleBindingApproval) HasVoteOf(author MemberNotation) bool {
+	for _, v := range r.Votes {
+		if v.Author == author {
+			return true
+		}
+	}
+	return false
+}
@@ -18,6 +18,12 @@ type Tenant struct {
 	Origin consts.ObjectOrigin `json:"origin,omitempty"`
 
 	FeatureFlags []TenantFeatureFlag `json:"feature_flags"`
+
+	// AccessRequestPolicy configures approvals of access requests at the tenant, access requests are not allowed without it
+	AccessRequestPolicy *AccessRequestPolicy `json:"access_request_policy,omitempty"`
+
+	// Labels are used for selecting tenants, e.g. by plugin replicas
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 func (t *Tenant) ObjType() string {
@@ -0,0 +1,182 @@
+package repo
+
+import (
+	"encoding/json"
+
+	hcmemdb "github.com/hashicorp/go-memdb"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+	"github.com/flant/negentropy/vault-plugins/shared/memdb"
+)
+
+const AccessRequestStatusIndex = "access_request_status"
+
+func AccessRequestSchema() *memdb.DBSchema {
+	return &memdb.DBSchema{
+		Tables: map[string]*hcmemdb.TableSchema{
+			model.AccessRequestType: {
+				Name: model.AccessRequestType,
+				Indexes: map[string]*hcmemdb.IndexSchema{
+					PK: {
+						Name:   PK,
+						Unique: true,
+						Indexer: &hcmemdb.UUIDFieldIndex{
+							Field: "UUID",
+						},
+					},
+					TenantForeignPK: {
+						Name: TenantForeignPK,
+						Indexer: &hcmemdb.StringFieldIndex{
+							Field:     "TenantUUID",
+							Lowercase: true,
+						},
+					},
+					AccessRequestStatusIndex: {
+						Name: AccessRequestStatusIndex,
+						Indexer: &hcmemdb.StringFieldIndex{
+							Field: "Status",
+						},
+					},
+				},
+			},
+		},
+		MandatoryForeignKeys: map[string][]memdb.Relation{
+			model.AccessRequestType: {
+				{OriginalDataTypeFieldName: "TenantUUID", RelatedDataType: model.TenantType, RelatedDataTypeFieldIndexName: PK},
+				{OriginalDataTypeFieldName: "Projects", RelatedDataType: model.ProjectType, RelatedDataTypeFieldIndexName: PK},
+			},
+		},
+	}
+}
+
+type AccessRequestRepository struct {
+	db *io.MemoryStoreTxn // called "db" not to provoke transaction semantics
+}
+
+func NewAccessRequestRepository(tx *io.MemoryStoreTxn) *AccessRequestRepository {
+	return &AccessRequestRepository{db: tx}
+}
+
+func (r *AccessRequestRepository) save(ar *model.AccessRequest) error {
+	return r.db.Insert(model.AccessRequestType, ar)
+}
+
+func (r *AccessRequestRepository) Create(ar *model.AccessRequest) error {
+	return r.save(ar)
+}
+
+func (r *AccessRequestRepository) GetRawByID(id model.AccessRequestUUID) (interface{}, error) {
+	raw, err := r.db.First(model.AccessRequestType, PK, id)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, consts.ErrNotFound
+	}
+	return raw, nil
+}
+
+func (r *AccessRequestRepository) GetByID(id model.AccessRequestUUID) (*model.AccessRequest, error) {
+	raw, err := r.GetRawByID(id)
+	if raw == nil {
+		return nil, err
+	}
+	return raw.(*model.AccessRequest), err
+}
+
+func (r *AccessRequestRepository) Update(ar *model.AccessRequest) error {
+	_, err := r.GetByID(ar.UUID)
+	if err != nil {
+		return err
+	}
+	return r.save(ar)
+}
+
+func (r *AccessRequestRepository) Delete(id model.AccessRequestUUID, archiveMark memdb.ArchiveMark) error {
+	ar, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if ar.Archived() {
+		return consts.ErrIsArchived
+	}
+	return r.db.Archive(model.AccessRequestType, ar, archiveMark)
+}
+
+func (r *AccessRequestRepository) List(tenantUUID model.TenantUUID, showArchived bool) ([]*model.AccessRequest, error) {
+	iter, err := r.db.Get(model.AccessRequestType, TenantForeignPK, tenantUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*model.AccessRequest{}
+	for {
+		raw := iter.Next()
+		if raw == nil {
+			break
+		}
+		obj := raw.(*model.AccessRequest)
+		if showArchived || obj.NotArchived() {
+			list = append(list, obj)
+		}
+	}
+	return list, nil
+}
+
+// ListByStatus returns active access requests with specified status
+func (r *AccessRequestRepository) ListByStatus(status model.AccessRequestStatus) ([]*model.AccessRequest, error) {
+	iter, err := r.db.Get(model.AccessRequestType, AccessRequestStatusIndex, status)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*model.AccessRequest{}
+	for {
+		raw := iter.Next()
+		if raw == nil {
+			break
+		}
+		obj := raw.(*model.AccessRequest)
+		if obj.NotArchived() {
+			list = append(list, obj)
+		}
+	}
+	return list, nil
+}
+
+func (r *AccessRequestRepository) Iter(action func(*model.AccessRequest) (bool, error)) error {
+	iter, err := r.db.Get(model.AccessRequestType, PK)
+	if err != nil {
+		return err
+	}
+
+	for {
+		raw := iter.Next()
+		if raw == nil {
+			break
+		}
+		obj := raw.(*model.AccessRequest)
+		next, err := action(obj)
+		if err != nil {
+			return err
+		}
+
+		if !next {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (r *AccessRequestRepository) Sync(_ string, data []byte) error {
+	ar := &model.AccessRequest{}
+	err := json.Unmarshal(data, ar)
+	if err != nil {
+		return err
+	}
+
+	return r.save(ar)
+}
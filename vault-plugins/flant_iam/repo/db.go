@@ -27,6 +27,7 @@ func GetSchema() (*memdb.DBSchema, error) {
 		RoleSchema(),
 		RoleBindingSchema(),
 		RoleBindingApprovalSchema(),
+		AccessRequestSchema(),
 		MultipassSchema(),
 		ServiceAccountPasswordSchema(),
 		IdentitySharingSchema(),
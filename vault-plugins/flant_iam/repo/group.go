@@ -318,6 +318,7 @@ func (r *GroupRepository) FindAllParentGroupsForGroupUUID(
 	return r.FindAllParentGroupsForGroupUUIDs(map[model.GroupUUID]struct{}{groupUUID: {}})
 }
 
+// FindAllMembersFor collects users and service accounts, including members of nested groups at any depth
 func (r *GroupRepository) FindAllMembersFor(users []model.UserUUID,
 	serviceAccounts []model.ServiceAccountUUID, groups []model.GroupUUID) (map[model.UserUUID]struct{},
 	map[model.ServiceAccountUUID]struct{}, error) {
@@ -330,16 +331,28 @@ func (r *GroupRepository) FindAllMembersFor(users []model.UserUUID,
 	for _, sa := range serviceAccounts {
 		resultSAs[sa] = struct{}{}
 	}
+	if len(groups) == 0 {
+		return resultUsers, resultSAs, nil
+	}
 
+	groupsSet := map[model.GroupUUID]struct{}{}
 	for _, groupUUID := range groups {
-		groupsUsers, groupsSAs, err := r.FindAllMembersForGroupUUID(groupUUID)
+		groupsSet[groupUUID] = struct{}{}
+	}
+	// each group is visited once, so cycles don't break traversing
+	allGroups, err := r.findAllChildGroups(groupsSet, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	for groupUUID := range allGroups {
+		group, err := r.GetByID(groupUUID)
 		if err != nil {
 			return nil, nil, err
 		}
-		for user := range groupsUsers {
+		for _, user := range group.Users {
 			resultUsers[user] = struct{}{}
 		}
-		for sa := range groupsSAs {
+		for _, sa := range group.ServiceAccounts {
 			resultSAs[sa] = struct{}{}
 		}
 	}
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"encoding/json"
+	"fmt"
 
 	hcmemdb "github.com/hashicorp/go-memdb"
 
@@ -95,6 +96,22 @@ func (r *RoleBindingApprovalRepository) RoleBindingApprovalCount(rolebindingUUID
 	return int64(len(rbs))
 }
 
+// PendingRoleBindingApprovalCount returns count of active approvals of the rolebinding, which didn't collect enough votes,
+// the error should deny the access
+func (r *RoleBindingApprovalRepository) PendingRoleBindingApprovalCount(rolebindingUUID model.RoleBindingUUID) (int64, error) {
+	rbs, err := r.List(rolebindingUUID, false)
+	if err != nil {
+		return 0, fmt.Errorf("listing approvals of rolebinding %s: %w", rolebindingUUID, err)
+	}
+	var count int64
+	for _, rba := range rbs {
+		if !rba.Approved() {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func NewRoleBindingApprovalRepository(tx *io.MemoryStoreTxn) *RoleBindingApprovalRepository {
 	return &RoleBindingApprovalRepository{db: tx}
 }
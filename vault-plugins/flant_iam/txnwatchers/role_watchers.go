@@ -151,7 +151,8 @@ func arrayFromMap(m map[string]struct{}) []string {
 }
 
 func CheckRoleBindingGrantsRole(txn *io.MemoryStoreTxn, roleBinding *model.RoleBinding, role string) (bool, error) {
-	if roleBinding == nil {
+	// deny rolebinding never grants roles
+	if roleBinding == nil || roleBinding.Deny {
 		return false, nil
 	}
 
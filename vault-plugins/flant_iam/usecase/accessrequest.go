@@ -0,0 +1,309 @@
+package usecase
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+	"github.com/flant/negentropy/vault-plugins/shared/memdb"
+	"github.com/flant/negentropy/vault-plugins/shared/uuid"
+)
+
+type AccessRequestService struct {
+	db              *io.MemoryStoreTxn // called "db" not to provoke transaction semantics
+	repo            *iam_repo.AccessRequestRepository
+	roleBindingRepo *iam_repo.RoleBindingRepository
+	tenantRepo      *iam_repo.TenantRepository
+
+	memberFetcher *MembersFetcher
+}
+
+func AccessRequests(db *io.MemoryStoreTxn) *AccessRequestService {
+	return &AccessRequestService{
+		db:              db,
+		repo:            iam_repo.NewAccessRequestRepository(db),
+		roleBindingRepo: iam_repo.NewRoleBindingRepository(db),
+		tenantRepo:      iam_repo.NewTenantRepository(db),
+		memberFetcher:   NewMembersFetcher(db),
+	}
+}
+
+// SetPolicy stores the access request policy of the tenant, it is applied to access requests created after
+func (s *AccessRequestService) SetPolicy(tid model.TenantUUID, policy model.AccessRequestPolicy) (*model.Tenant, error) {
+	if len(policy.Approvers) == 0 {
+		return nil, fmt.Errorf("%w: approvers should be passed", consts.ErrInvalidArg)
+	}
+	if policy.RequiredVotes <= 0 {
+		return nil, fmt.Errorf("%w: required votes should be positive", consts.ErrInvalidArg)
+	}
+	if _, err := s.memberFetcher.Fetch(policy.Approvers); err != nil {
+		return nil, fmt.Errorf("AccessRequestService.SetPolicy:%w", err)
+	}
+	tenant, err := s.tenantRepo.GetByID(tid)
+	if err != nil {
+		return nil, err
+	}
+	if tenant.Archived() {
+		return nil, consts.ErrIsArchived
+	}
+	updated := *tenant
+	updated.AccessRequestPolicy = &policy
+	updated.Version = iam_repo.NewResourceVersion()
+	if err = s.tenantRepo.Update(&updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Create stores the pending access request with the temporary rolebinding and its approval,
+// the rolebinding is valid for the requested ttl since creating, and it is prolonged by approving.
+// The approval is configured by the access request policy of the tenant
+func (s *AccessRequestService) Create(ar *model.AccessRequest) error {
+	if ar.Version != "" {
+		return consts.ErrBadVersion
+	}
+	if ar.Requester.Type != model.UserType && ar.Requester.Type != model.ServiceAccountType {
+		return fmt.Errorf("%w: wrong type of requester: %q", consts.ErrInvalidArg, ar.Requester.Type)
+	}
+	if ar.RequestedTTL <= 0 || ar.RequestedTTL > model.AccessRequestMaxTTL {
+		return fmt.Errorf("%w: requested ttl should be positive and not greater than %d seconds",
+			consts.ErrInvalidArg, model.AccessRequestMaxTTL)
+	}
+	tenant, err := s.tenantRepo.GetByID(ar.TenantUUID)
+	if err != nil {
+		return fmt.Errorf("AccessRequestService.Create:%w", err)
+	}
+	if tenant.AccessRequestPolicy == nil {
+		return fmt.Errorf("%w: access request policy of the tenant", consts.ErrNotConfigured)
+	}
+	ar.Approvers = tenant.AccessRequestPolicy.Approvers
+	ar.RequiredVotes = tenant.AccessRequestPolicy.RequiredVotes
+	ar.RequireMFA = tenant.AccessRequestPolicy.RequireMFA
+	if ar.UUID == "" {
+		ar.UUID = uuid.New()
+	}
+	rb := &model.RoleBinding{
+		TenantUUID:  ar.TenantUUID,
+		Description: fmt.Sprintf("access request %s: %s", ar.UUID, ar.Reason),
+		ValidTill:   time.Now().Add(time.Duration(ar.RequestedTTL) * time.Second).Unix(),
+		Members:     []model.MemberNotation{ar.Requester},
+		AnyProject:  ar.AnyProject,
+		Projects:    ar.Projects,
+		Roles:       []model.BoundRole{ar.Role},
+		Origin:      consts.OriginIAM,
+	}
+	if _, err := RoleBindings(s.db).Create(rb); err != nil {
+		return fmt.Errorf("AccessRequestService.Create:%w", err)
+	}
+	rba := &model.RoleBindingApproval{
+		TenantUUID:            ar.TenantUUID,
+		RoleBindingUUID:       rb.UUID,
+		Approvers:             ar.Approvers,
+		RequiredVotes:         ar.RequiredVotes,
+		RequireMFA:            ar.RequireMFA,
+		RequireUniqueApprover: true,
+	}
+	if err := RoleBindingApprovals(s.db).Create(rba); err != nil {
+		return fmt.Errorf("AccessRequestService.Create:%w", err)
+	}
+	ar.Status = model.AccessRequestPending
+	ar.DecidedBy = nil
+	ar.DecidedAt = 0
+	ar.RoleBindingUUID = rb.UUID
+	ar.ApprovalUUID = rba.UUID
+	ar.Version = iam_repo.NewResourceVersion()
+	return s.repo.Create(ar)
+}
+
+func (s *AccessRequestService) GetByID(id model.AccessRequestUUID) (*model.AccessRequest, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *AccessRequestService) List(tid model.TenantUUID, showArchived bool) ([]*model.AccessRequest, error) {
+	return s.repo.List(tid, showArchived)
+}
+
+// Approve votes for the approval of the temporary rolebinding by the decider, the requester can't approve own request.
+// The request becomes approved, when the approval collects required votes, then the rolebinding is valid for the
+// requested ttl since approving
+func (s *AccessRequestService) Approve(tid model.TenantUUID, id model.AccessRequestUUID,
+	decider RequestOwner) (*model.AccessRequest, error) {
+	stored, err := s.getPending(tid, id)
+	if err != nil {
+		return nil, err
+	}
+	if decider.MemberNotation == stored.Requester {
+		return nil, fmt.Errorf("%w: the requester can't decide own access request", consts.ErrAccessForbidden)
+	}
+	approval, err := RoleBindingApprovals(s.db).Vote(tid, stored.RoleBindingUUID, stored.ApprovalUUID, decider)
+	if err != nil {
+		return nil, fmt.Errorf("AccessRequestService.Approve:%w", err)
+	}
+	if !approval.Approved() {
+		return stored, nil
+	}
+
+	now := time.Now()
+	rb, err := s.roleBindingRepo.GetByID(stored.RoleBindingUUID)
+	if err != nil {
+		return nil, fmt.Errorf("AccessRequestService.Approve:%w", err)
+	}
+	prolonged := *rb
+	prolonged.ValidTill = now.Add(time.Duration(stored.RequestedTTL) * time.Second).Unix()
+	prolonged.Version = iam_repo.NewResourceVersion()
+	if err = s.roleBindingRepo.Update(&prolonged); err != nil {
+		return nil, fmt.Errorf("AccessRequestService.Approve:%w", err)
+	}
+
+	updated := *stored
+	updated.Status = model.AccessRequestApproved
+	updated.DecidedBy = &decider.MemberNotation
+	updated.DecidedAt = now.Unix()
+	return s.update(&updated)
+}
+
+// Reject closes the pending access request and revokes its temporary rolebinding,
+// only approvers of the request can reject it, the requester can't reject own request
+func (s *AccessRequestService) Reject(tid model.TenantUUID, id model.AccessRequestUUID,
+	decider RequestOwner) (*model.AccessRequest, error) {
+	stored, err := s.getPending(tid, id)
+	if err != nil {
+		return nil, err
+	}
+	if decider.MemberNotation == stored.Requester {
+		return nil, fmt.Errorf("%w: the requester can't decide own access request", consts.ErrAccessForbidden)
+	}
+	approvals := RoleBindingApprovals(s.db)
+	approval, err := approvals.GetByID(stored.ApprovalUUID)
+	if err != nil {
+		return nil, fmt.Errorf("AccessRequestService.Reject:%w", err)
+	}
+	if err = approvals.checkApprover(approval, decider.MemberNotation); err != nil {
+		return nil, err
+	}
+	if err = s.revokeRoleBinding(stored.RoleBindingUUID, memdb.NewArchiveMark()); err != nil {
+		return nil, err
+	}
+	updated := *stored
+	updated.Status = model.AccessRequestRejected
+	updated.DecidedBy = &decider.MemberNotation
+	updated.DecidedAt = time.Now().Unix()
+	return s.update(&updated)
+}
+
+// Delete archives the access request of the tenant, and revokes given or pending access
+func (s *AccessRequestService) Delete(tid model.TenantUUID, id model.AccessRequestUUID) error {
+	stored, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if stored.TenantUUID != tid {
+		return consts.ErrNotFound
+	}
+	if stored.Archived() {
+		return consts.ErrIsArchived
+	}
+	archiveMark := memdb.NewArchiveMark()
+	if stored.Status == model.AccessRequestApproved || stored.Status == model.AccessRequestPending {
+		if err = s.revokeRoleBinding(stored.RoleBindingUUID, archiveMark); err != nil {
+			return err
+		}
+	}
+	return s.repo.Delete(id, archiveMark)
+}
+
+// ListExpired returns approved and pending access requests, which are expired at the moment,
+// pending requests expire, if they are not approved during the requested ttl
+func (s *AccessRequestService) ListExpired(now time.Time) ([]model.AccessRequestUUID, error) {
+	approved, err := s.repo.ListByStatus(model.AccessRequestApproved)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.repo.ListByStatus(model.AccessRequestPending)
+	if err != nil {
+		return nil, err
+	}
+	var expired []model.AccessRequestUUID
+	for _, ar := range append(approved, pending...) {
+		isExpired, err := s.isExpired(ar, now)
+		if err != nil {
+			return nil, err
+		}
+		if isExpired {
+			expired = append(expired, ar.UUID)
+		}
+	}
+	return expired, nil
+}
+
+// Expire archives the rolebinding of the expired access request and marks the request as expired
+func (s *AccessRequestService) Expire(id model.AccessRequestUUID, now time.Time) error {
+	ar, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if ar.Archived() {
+		return consts.ErrIsArchived
+	}
+	if ar.Status != model.AccessRequestApproved && ar.Status != model.AccessRequestPending {
+		return fmt.Errorf("%w: access request is %s", consts.ErrInvalidArg, ar.Status)
+	}
+	isExpired, err := s.isExpired(ar, now)
+	if err != nil {
+		return err
+	}
+	if !isExpired {
+		return fmt.Errorf("%w: access request is not expired", consts.ErrInvalidArg)
+	}
+	if err = s.revokeRoleBinding(ar.RoleBindingUUID, memdb.NewArchiveMark()); err != nil {
+		return err
+	}
+	updated := *ar
+	updated.Status = model.AccessRequestExpired
+	_, err = s.update(&updated)
+	return err
+}
+
+func (s *AccessRequestService) isExpired(ar *model.AccessRequest, now time.Time) (bool, error) {
+	rb, err := s.roleBindingRepo.GetByID(ar.RoleBindingUUID)
+	if err != nil && err != consts.ErrNotFound {
+		return false, err
+	}
+	return rb == nil || rb.Archived() || rb.ValidTill <= now.Unix(), nil
+}
+
+func (s *AccessRequestService) revokeRoleBinding(rbUUID model.RoleBindingUUID, archiveMark memdb.ArchiveMark) error {
+	err := s.roleBindingRepo.CascadeDelete(rbUUID, archiveMark)
+	if err != nil && err != consts.ErrNotFound && err != consts.ErrIsArchived {
+		return fmt.Errorf("revoking rolebinding %s:%w", rbUUID, err)
+	}
+	return nil
+}
+
+func (s *AccessRequestService) getPending(tid model.TenantUUID, id model.AccessRequestUUID) (*model.AccessRequest, error) {
+	stored, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if stored.TenantUUID != tid {
+		return nil, consts.ErrNotFound
+	}
+	if stored.Archived() {
+		return nil, consts.ErrIsArchived
+	}
+	if stored.Status != model.AccessRequestPending {
+		return nil, fmt.Errorf("%w: access request is %s", consts.ErrInvalidArg, stored.Status)
+	}
+	return stored, nil
+}
+
+func (s *AccessRequestService) update(ar *model.AccessRequest) (*model.AccessRequest, error) {
+	ar.Version = iam_repo.NewResourceVersion()
+	if err := s.repo.Update(ar); err != nil {
+		return nil, err
+	}
+	return ar, nil
+}
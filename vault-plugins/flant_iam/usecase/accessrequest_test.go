@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+func createAccessRequest(t *testing.T, tx *io.MemoryStoreTxn) *model.AccessRequest {
+	_, err := AccessRequests(tx).SetPolicy(fixtures.TenantUUID1, model.AccessRequestPolicy{
+		// UserUUID1 is the member of GroupUUID2 too
+		Approvers: []model.MemberNotation{
+			{Type: model.UserType, UUID: fixtures.UserUUID2},
+			{Type: model.GroupType, UUID: fixtures.GroupUUID2},
+		},
+		RequiredVotes: 1,
+	})
+	require.NoError(t, err)
+	ar := &model.AccessRequest{
+		TenantUUID:   fixtures.TenantUUID1,
+		Requester:    model.MemberNotation{Type: model.UserType, UUID: fixtures.UserUUID1},
+		Reason:       "on-call",
+		Role:         model.BoundRole{Name: fixtures.RoleName1, Options: map[string]interface{}{}},
+		Projects:     []model.ProjectUUID{fixtures.ProjectUUID1},
+		RequestedTTL: 7200,
+		// the requester can't choose approvers
+		Approvers:     []model.MemberNotation{{Type: model.UserType, UUID: fixtures.UserUUID4}},
+		RequiredVotes: 1,
+	}
+	err = AccessRequests(tx).Create(ar)
+	require.NoError(t, err)
+	return ar
+}
+
+func Test_AccessRequestApproveAndExpire(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture).Txn(true)
+	ar := createAccessRequest(t, tx)
+	service := AccessRequests(tx)
+	require.Equal(t, model.AccessRequestPending, ar.Status)
+	require.Equal(t, int64(1), pendingApprovals(t, tx, ar.RoleBindingUUID))
+
+	_, err := service.Approve(fixtures.TenantUUID1, ar.UUID, voter(model.UserType, fixtures.UserUUID1))
+	require.ErrorIs(t, err, consts.ErrAccessForbidden)
+	_, err = RoleBindingApprovals(tx).Vote(fixtures.TenantUUID1, ar.RoleBindingUUID, ar.ApprovalUUID,
+		voter(model.UserType, fixtures.UserUUID1))
+	require.ErrorIs(t, err, consts.ErrAccessForbidden)
+	_, err = service.Approve(fixtures.TenantUUID1, ar.UUID, voter(model.UserType, fixtures.UserUUID4))
+	require.ErrorIs(t, err, consts.ErrAccessForbidden)
+
+	approved, err := service.Approve(fixtures.TenantUUID1, ar.UUID, voter(model.UserType, fixtures.UserUUID2))
+
+	require.NoError(t, err)
+	require.Equal(t, model.AccessRequestApproved, approved.Status)
+	require.Equal(t, &model.MemberNotation{Type: model.UserType, UUID: fixtures.UserUUID2}, approved.DecidedBy)
+	require.Equal(t, int64(0), pendingApprovals(t, tx, ar.RoleBindingUUID))
+	rb, err := iam_repo.NewRoleBindingRepository(tx).GetByID(approved.RoleBindingUUID)
+	require.NoError(t, err)
+	require.InDelta(t, time.Now().Unix()+7200, rb.ValidTill, 5)
+	require.Equal(t, []model.UserUUID{fixtures.UserUUID1}, rb.Users)
+
+	_, err = service.Approve(fixtures.TenantUUID1, ar.UUID, voter(model.UserType, fixtures.UserUUID3))
+	require.ErrorIs(t, err, consts.ErrInvalidArg)
+
+	archiveExpired(t, tx, time.Now())
+	rb, err = iam_repo.NewRoleBindingRepository(tx).GetByID(approved.RoleBindingUUID)
+	require.NoError(t, err)
+	require.False(t, rb.Archived())
+
+	archiveExpired(t, tx, time.Now().Add(3*time.Hour))
+
+	rb, err = iam_repo.NewRoleBindingRepository(tx).GetByID(approved.RoleBindingUUID)
+	require.NoError(t, err)
+	require.True(t, rb.Archived())
+	expired, err := service.GetByID(ar.UUID)
+	require.NoError(t, err)
+	require.Equal(t, model.AccessRequestExpired, expired.Status)
+}
+
+func Test_AccessRequestReject(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture).Txn(true)
+	ar := createAccessRequest(t, tx)
+	decider := voter(model.UserType, fixtures.UserUUID2)
+
+	_, err := AccessRequests(tx).Reject(fixtures.TenantUUID1, ar.UUID, voter(model.UserType, fixtures.UserUUID4))
+	require.ErrorIs(t, err, consts.ErrAccessForbidden)
+
+	rejected, err := AccessRequests(tx).Reject(fixtures.TenantUUID1, ar.UUID, decider)
+
+	require.NoError(t, err)
+	require.Equal(t, model.AccessRequestRejected, rejected.Status)
+	require.Equal(t, &decider.MemberNotation, rejected.DecidedBy)
+	rb, err := iam_repo.NewRoleBindingRepository(tx).GetByID(ar.RoleBindingUUID)
+	require.NoError(t, err)
+	require.True(t, rb.Archived())
+	_, err = AccessRequests(tx).Approve(fixtures.TenantUUID1, ar.UUID, decider)
+	require.ErrorIs(t, err, consts.ErrInvalidArg)
+}
+
+func Test_AccessRequestDeleteAtOtherTenant(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture).Txn(true)
+	ar := createAccessRequest(t, tx)
+
+	err := AccessRequests(tx).Delete(fixtures.TenantUUID2, ar.UUID)
+
+	require.ErrorIs(t, err, consts.ErrNotFound)
+	require.NoError(t, AccessRequests(tx).Delete(fixtures.TenantUUID1, ar.UUID))
+}
+
+func Test_AccessRequestCreateTooLongTTL(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture).Txn(true)
+
+	err := AccessRequests(tx).Create(&model.AccessRequest{
+		TenantUUID:   fixtures.TenantUUID1,
+		Requester:    model.MemberNotation{Type: model.UserType, UUID: fixtures.UserUUID1},
+		Role:         model.BoundRole{Name: fixtures.RoleName1},
+		RequestedTTL: model.AccessRequestMaxTTL + 1,
+	})
+
+	require.ErrorIs(t, err, consts.ErrInvalidArg)
+}
+
+func Test_AccessRequestCreateWrongRequester(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture).Txn(true)
+
+	err := AccessRequests(tx).Create(&model.AccessRequest{
+		TenantUUID:   fixtures.TenantUUID1,
+		Requester:    model.MemberNotation{Type: model.GroupType, UUID: fixtures.GroupUUID1},
+		Role:         model.BoundRole{Name: fixtures.RoleName1},
+		RequestedTTL: 60,
+	})
+
+	require.ErrorIs(t, err, consts.ErrInvalidArg)
+}
+
+func Test_AccessRequestCreateWithoutPolicy(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture).Txn(true)
+
+	err := AccessRequests(tx).Create(&model.AccessRequest{
+		TenantUUID:    fixtures.TenantUUID1,
+		Requester:     model.MemberNotation{Type: model.UserType, UUID: fixtures.UserUUID1},
+		Role:          model.BoundRole{Name: fixtures.RoleName1},
+		RequestedTTL:  60,
+		Approvers:     []model.MemberNotation{{Type: model.UserType, UUID: fixtures.UserUUID2}},
+		RequiredVotes: 1,
+	})
+
+	require.ErrorIs(t, err, consts.ErrNotConfigured)
+}
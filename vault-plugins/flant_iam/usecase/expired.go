@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+	"github.com/flant/negentropy/vault-plugins/shared/memdb"
+)
+
+// ExpiredObject is the object with passed valid_till
+type ExpiredObject struct {
+	Type string
+	UUID string
+}
+
+// ExpiredObjectsSweeper archives objects with passed valid_till, archiving goes through the transaction,
+// so all kafka destinations receive archived objects at commit
+type ExpiredObjectsSweeper struct {
+	db              *io.MemoryStoreTxn // called "db" not to provoke transaction semantics
+	roleBindingRepo *iam_repo.RoleBindingRepository
+	multipassRepo   *iam_repo.MultipassRepository
+}
+
+func ExpiredObjects(db *io.MemoryStoreTxn) *ExpiredObjectsSweeper {
+	return &ExpiredObjectsSweeper{
+		db:              db,
+		roleBindingRepo: iam_repo.NewRoleBindingRepository(db),
+		multipassRepo:   iam_repo.NewMultipassRepository(db),
+	}
+}
+
+// ListExpired returns all expired at the moment access requests, rolebindings and multipasses,
+// access requests go first, to mark them expired before archiving their rolebindings
+func (s *ExpiredObjectsSweeper) ListExpired(now time.Time) ([]ExpiredObject, error) {
+	accessRequests, err := AccessRequests(s.db).ListExpired(now)
+	if err != nil {
+		return nil, fmt.Errorf("listing expired access requests:%w", err)
+	}
+	var expired []ExpiredObject
+	for _, arUUID := range accessRequests {
+		expired = append(expired, ExpiredObject{Type: model.AccessRequestType, UUID: arUUID})
+	}
+	err = s.roleBindingRepo.Iter(func(rb *model.RoleBinding) (bool, error) {
+		if rb.NotArchived() && isExpired(rb.ValidTill, now.Unix()) {
+			expired = append(expired, ExpiredObject{Type: model.RoleBindingType, UUID: rb.UUID})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing expired rolebindings:%w", err)
+	}
+	err = s.multipassRepo.Iter(func(mp *model.Multipass) (bool, error) {
+		if mp.NotArchived() && isExpired(mp.ValidTill, now.Unix()) {
+			expired = append(expired, ExpiredObject{Type: model.MultipassType, UUID: mp.UUID})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing expired multipasses:%w", err)
+	}
+	return expired, nil
+}
+
+// Archive archives the expired object, the object archived already, e.g. by its access request, is skipped
+func (s *ExpiredObjectsSweeper) Archive(obj ExpiredObject, now time.Time) error {
+	var err error
+	switch obj.Type {
+	case model.AccessRequestType:
+		err = AccessRequests(s.db).Expire(obj.UUID, now)
+	case model.RoleBindingType:
+		err = s.roleBindingRepo.CascadeDelete(obj.UUID, memdb.NewArchiveMark())
+	case model.MultipassType:
+		err = s.multipassRepo.Delete(obj.UUID, memdb.NewArchiveMark())
+	default:
+		return fmt.Errorf("%w: wrong type of expired object: %q", consts.ErrInvalidArg, obj.Type)
+	}
+	if err != nil && !errors.Is(err, consts.ErrIsArchived) {
+		return fmt.Errorf("%s %s:%w", obj.Type, obj.UUID, err)
+	}
+	return nil
+}
+
+func isExpired(validTill int64, now int64) bool {
+	return validTill != 0 && validTill <= now
+}
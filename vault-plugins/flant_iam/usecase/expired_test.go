@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+func archiveExpired(t *testing.T, tx *io.MemoryStoreTxn, now time.Time) {
+	sweeper := ExpiredObjects(tx)
+	expired, err := sweeper.ListExpired(now)
+	require.NoError(t, err)
+	for _, obj := range expired {
+		require.NoError(t, sweeper.Archive(obj, now))
+	}
+}
+
+func Test_ArchiveExpired(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture,
+		RoleBindingFixture).Txn(true)
+	now := time.Now()
+	rbRepo := iam_repo.NewRoleBindingRepository(tx)
+	mpRepo := iam_repo.NewMultipassRepository(tx)
+	expiredRB := fixtures.RoleBindings()[0]
+	expiredRB.UUID = "00000000-0000-4000-a000-000000000001"
+	expiredRB.ValidTill = now.Add(-time.Minute).Unix()
+	require.NoError(t, rbRepo.Create(&expiredRB))
+	validMP := &model.Multipass{
+		UUID: "00000000-0000-4000-a000-000000000002", TenantUUID: fixtures.TenantUUID1,
+		OwnerUUID: fixtures.UserUUID1, OwnerType: model.MultipassOwnerUser, ValidTill: now.Add(time.Hour).Unix(),
+	}
+	require.NoError(t, mpRepo.Create(validMP))
+	expiredMP := &model.Multipass{
+		UUID: "00000000-0000-4000-a000-000000000003", TenantUUID: fixtures.TenantUUID1,
+		OwnerUUID: fixtures.UserUUID1, OwnerType: model.MultipassOwnerUser, ValidTill: now.Add(-time.Minute).Unix(),
+	}
+	require.NoError(t, mpRepo.Create(expiredMP))
+
+	archiveExpired(t, tx, now)
+
+	rb, err := rbRepo.GetByID(expiredRB.UUID)
+	require.NoError(t, err)
+	require.True(t, rb.Archived())
+	rb, err = rbRepo.GetByID(fixtures.RbUUID1)
+	require.NoError(t, err)
+	require.False(t, rb.Archived(), "rolebinding without valid_till should be kept")
+	mp, err := mpRepo.GetByID(expiredMP.UUID)
+	require.NoError(t, err)
+	require.True(t, mp.Archived())
+	mp, err = mpRepo.GetByID(validMP.UUID)
+	require.NoError(t, err)
+	require.False(t, mp.Archived())
+}
@@ -56,6 +56,9 @@ func (s *GroupService) Create(group *model.Group) error {
 	if err != nil {
 		return err
 	}
+	if err = s.checkCycles(group.UUID, subj.Groups); err != nil {
+		return err
+	}
 	group.Groups = subj.Groups
 	group.ServiceAccounts = subj.ServiceAccounts
 	group.Users = subj.Users
@@ -96,6 +99,9 @@ func (s *GroupService) Update(group *model.Group) error {
 	if err != nil {
 		return err
 	}
+	if err = s.checkCycles(group.UUID, subj.Groups); err != nil {
+		return err
+	}
 	group.Groups = subj.Groups
 	group.ServiceAccounts = subj.ServiceAccounts
 	group.Users = subj.Users
@@ -239,3 +245,21 @@ func (s *GroupService) AddUsersToGroup(groupUUID model.GroupUUID, userUUIDs ...m
 	}
 	return s.Update(group)
 }
+
+// checkCycles checks the group is not a member of any of passed groups, including nested ones
+func (s *GroupService) checkCycles(groupUUID model.GroupUUID, memberGroups []model.GroupUUID) error {
+	for _, memberGroupUUID := range memberGroups {
+		if memberGroupUUID == groupUUID {
+			return fmt.Errorf("%w: group %s can't be a member of itself", consts.ErrInvalidArg, groupUUID)
+		}
+		children, err := s.repo.FindAllChildGroups(memberGroupUUID, true)
+		if err != nil {
+			return err
+		}
+		if _, found := children[groupUUID]; found {
+			return fmt.Errorf("%w: cycle detected: group %s is a member of group %s", consts.ErrInvalidArg,
+				groupUUID, memberGroupUUID)
+		}
+	}
+	return nil
+}
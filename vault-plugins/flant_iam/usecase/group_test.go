@@ -8,6 +8,7 @@ import (
 	"github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
 )
 
 func Test_ListGroups(t *testing.T) {
@@ -95,3 +96,38 @@ func Test_FindAllParentGroupsForServiceAccountUUID(t *testing.T) {
 		fixtures.GroupUUID4, fixtures.GroupUUID5,
 	}, stringSlice(ids))
 }
+
+func Test_GroupCycleIsRejected(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture).Txn(true)
+	service := Groups(tx, fixtures.TenantUUID1, consts.OriginIAM)
+	// GroupUUID1 is a member of GroupUUID5
+	group, err := service.GetByID(fixtures.GroupUUID1)
+	require.NoError(t, err)
+
+	for _, memberGroup := range []model.GroupUUID{fixtures.GroupUUID5, fixtures.GroupUUID1} {
+		updated := *group
+		updated.Members = append(append([]model.MemberNotation{}, group.Members...),
+			model.MemberNotation{Type: model.GroupType, UUID: memberGroup})
+
+		err = service.Update(&updated)
+
+		require.ErrorIs(t, err, consts.ErrInvalidArg)
+	}
+}
+
+func Test_FindAllMembersForNestedGroupsWithCycle(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture).Txn(true)
+	repository := iam_repo.NewGroupRepository(tx)
+	// make cycle directly through repository
+	group, err := repository.GetByID(fixtures.GroupUUID1)
+	require.NoError(t, err)
+	updated := *group
+	updated.Groups = append(append([]model.GroupUUID{}, group.Groups...), fixtures.GroupUUID5)
+	require.NoError(t, repository.Update(&updated))
+
+	users, _, err := repository.FindAllMembersFor(nil, nil, []model.GroupUUID{fixtures.GroupUUID5})
+
+	require.NoError(t, err)
+	require.Contains(t, users, fixtures.UserUUID2)
+	require.Contains(t, users, fixtures.UserUUID3)
+}
@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+const serviceAccountDomainPrefix = "serviceaccount."
+
+// RequestOwner is the user or the service account, who sent the request
+type RequestOwner struct {
+	model.MemberNotation
+	// MFA is true, if the request is sent by the token of the login, passed MFA
+	MFA bool
+}
+
+// RevealRequestOwner finds the user or the service account by the name of the vault entity,
+// flant_iam_auth names vault entities by full identifiers of their owners
+func RevealRequestOwner(db *io.MemoryStoreTxn, entityName string) (model.MemberNotation, error) {
+	at := strings.LastIndex(entityName, "@")
+	if at < 0 {
+		return model.MemberNotation{}, fmt.Errorf("%w: entity %q is not an owner of flant_iam", consts.ErrNotFound, entityName)
+	}
+	identifier, domain := entityName[:at], entityName[at+1:]
+	ownerType := model.UserType
+	if strings.HasPrefix(domain, serviceAccountDomainPrefix) {
+		ownerType = model.ServiceAccountType
+		domain = strings.TrimPrefix(domain, serviceAccountDomainPrefix)
+	}
+	tenant, err := iam_repo.NewTenantRepository(db).GetByIdentifier(domain)
+	if err != nil {
+		return model.MemberNotation{}, fmt.Errorf("RevealRequestOwner:%w", err)
+	}
+	if ownerType == model.ServiceAccountType {
+		sa, err := iam_repo.NewServiceAccountRepository(db).GetByIdentifierAtTenant(tenant.UUID, identifier)
+		if err != nil {
+			return model.MemberNotation{}, fmt.Errorf("RevealRequestOwner:%w", err)
+		}
+		return model.MemberNotation{Type: model.ServiceAccountType, UUID: sa.UUID}, nil
+	}
+	user, err := iam_repo.NewUserRepository(db).GetByIdentifierAtTenant(tenant.UUID, identifier)
+	if err != nil {
+		return model.MemberNotation{}, fmt.Errorf("RevealRequestOwner:%w", err)
+	}
+	return model.MemberNotation{Type: model.UserType, UUID: user.UUID}, nil
+}
@@ -0,0 +1,33 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+)
+
+func Test_RevealRequestOwner(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture).Txn(false)
+	tenant, err := iam_repo.NewTenantRepository(tx).GetByID(fixtures.TenantUUID1)
+	require.NoError(t, err)
+	user, err := iam_repo.NewUserRepository(tx).GetByID(fixtures.UserUUID1)
+	require.NoError(t, err)
+	sa, err := iam_repo.NewServiceAccountRepository(tx).GetByID(fixtures.ServiceAccountUUID1)
+	require.NoError(t, err)
+
+	owner, err := RevealRequestOwner(tx, user.Identifier+"@"+tenant.Identifier)
+	require.NoError(t, err)
+	require.Equal(t, model.MemberNotation{Type: model.UserType, UUID: fixtures.UserUUID1}, owner)
+
+	owner, err = RevealRequestOwner(tx, iam_repo.CalcServiceAccountFullIdentifier(sa.Identifier, tenant.Identifier))
+	require.NoError(t, err)
+	require.Equal(t, model.MemberNotation{Type: model.ServiceAccountType, UUID: fixtures.ServiceAccountUUID1}, owner)
+
+	_, err = RevealRequestOwner(tx, "root")
+	require.ErrorIs(t, err, consts.ErrNotFound)
+}
@@ -53,6 +53,12 @@ func (s *RoleBindingService) Create(rb *model.RoleBinding) (*DenormalizedRoleBin
 	if err := s.checkRoles(rb.Roles); err != nil {
 		return nil, err
 	}
+	if err := ValidateRoleBindingCondition(rb.Condition); err != nil {
+		return nil, err
+	}
+	if rb.Deny && rb.Condition != nil {
+		return nil, fmt.Errorf("%w: condition is not supported for deny rolebinding", consts.ErrInvalidArg)
+	}
 	// TODO check - owned or shared
 	rb.Groups = subj.Groups
 	rb.ServiceAccounts = subj.ServiceAccounts
@@ -94,6 +100,12 @@ func (s *RoleBindingService) Update(rb *model.RoleBinding) (*DenormalizedRoleBin
 	if err := s.checkRoles(rb.Roles); err != nil {
 		return nil, err
 	}
+	if err := ValidateRoleBindingCondition(rb.Condition); err != nil {
+		return nil, err
+	}
+	if rb.Deny && rb.Condition != nil {
+		return nil, fmt.Errorf("%w: condition is not supported for deny rolebinding", consts.ErrInvalidArg)
+	}
 	// TODO check - owned or shared
 	rb.Groups = subj.Groups
 	rb.ServiceAccounts = subj.ServiceAccounts
@@ -196,6 +208,10 @@ type DenormalizedRoleBinding struct {
 
 	Roles []model.BoundRole `json:"roles"`
 
+	Deny bool `json:"deny"`
+
+	Condition *model.RoleBindingCondition `json:"condition,omitempty"`
+
 	Origin consts.ObjectOrigin `json:"origin"`
 }
 
@@ -232,6 +248,8 @@ func (s *RoleBindingService) denormalizeRoleBinding(rb *model.RoleBinding) (*Den
 		AnyProject:  rb.AnyProject,
 		Projects:    denormilizedProjects,
 		Roles:       rb.Roles,
+		Deny:        rb.Deny,
+		Condition:   rb.Condition,
 		Origin:      rb.Origin,
 	}, nil
 }
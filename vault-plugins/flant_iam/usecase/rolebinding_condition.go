@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+)
+
+const timeWindowLayout = "15:04"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// LoginContext represents attributes of the login request, used for checking rolebinding conditions
+type LoginContext struct {
+	// RemoteAddr is an IP address of the request source
+	RemoteAddr string
+	// AuthMethod is a name of the used auth method
+	AuthMethod string
+	Time       time.Time
+}
+
+// ValidateRoleBindingCondition checks all fields of the condition are parsable
+func ValidateRoleBindingCondition(condition *model.RoleBindingCondition) error {
+	if condition == nil {
+		return nil
+	}
+	for _, cidr := range condition.SourceCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("%w: condition: source_cidrs: %s", consts.ErrInvalidArg, err.Error())
+		}
+	}
+	if tw := condition.TimeWindow; tw != nil {
+		if _, _, _, err := parseTimeWindow(*tw); err != nil {
+			return fmt.Errorf("%w: condition: time_window: %s", consts.ErrInvalidArg, err.Error())
+		}
+	}
+	for _, m := range condition.AuthMethods {
+		if m == "" {
+			return fmt.Errorf("%w: condition: auth_methods: empty auth method name", consts.ErrInvalidArg)
+		}
+	}
+	return nil
+}
+
+// CheckRoleBindingCondition returns error, describing the first unsatisfied restriction of the condition
+func CheckRoleBindingCondition(condition *model.RoleBindingCondition, loginCtx LoginContext) error {
+	if condition == nil {
+		return nil
+	}
+	if len(condition.SourceCIDRs) > 0 {
+		if err := checkSourceCIDRs(condition.SourceCIDRs, loginCtx.RemoteAddr); err != nil {
+			return err
+		}
+	}
+	if condition.TimeWindow != nil {
+		if err := checkTimeWindow(*condition.TimeWindow, loginCtx.Time); err != nil {
+			return err
+		}
+	}
+	if len(condition.AuthMethods) > 0 {
+		for _, m := range condition.AuthMethods {
+			if m == loginCtx.AuthMethod {
+				return nil
+			}
+		}
+		return fmt.Errorf("auth method %q is not allowed, allowed: %s", loginCtx.AuthMethod,
+			strings.Join(condition.AuthMethods, ", "))
+	}
+	return nil
+}
+
+func checkSourceCIDRs(cidrs []string, remoteAddr string) error {
+	if remoteAddr == "" {
+		return fmt.Errorf("source address is unknown, allowed networks: %s", strings.Join(cidrs, ", "))
+	}
+	ip := net.ParseIP(remoteAddr)
+	if ip == nil {
+		return fmt.Errorf("source address %q is not valid IP", remoteAddr)
+	}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("source address %s is not in allowed networks: %s", remoteAddr, strings.Join(cidrs, ", "))
+}
+
+func checkTimeWindow(tw model.TimeWindow, now time.Time) error {
+	from, till, location, err := parseTimeWindow(tw)
+	if err != nil {
+		return err
+	}
+	now = now.In(location)
+	minutes := now.Hour()*60 + now.Minute()
+	day := now.Weekday()
+	inPeriod := false
+	if from <= till {
+		inPeriod = minutes >= from && minutes < till
+	} else {
+		// the period passes midnight, the part after midnight belongs to the previous day
+		if minutes < till {
+			inPeriod = true
+			day = (day + 6) % 7
+		} else {
+			inPeriod = minutes >= from
+		}
+	}
+	if inPeriod && len(tw.Weekdays) > 0 {
+		inPeriod = false
+		for _, d := range tw.Weekdays {
+			if weekdays[strings.ToLower(d)] == day {
+				inPeriod = true
+				break
+			}
+		}
+	}
+	if !inPeriod {
+		return fmt.Errorf("time %s is out of allowed time window: %s %s-%s %s", now.Format(time.RFC3339),
+			strings.Join(tw.Weekdays, ","), tw.From, tw.Till, location.String())
+	}
+	return nil
+}
+
+// parseTimeWindow returns bounds of the period in minutes from midnight and location of the time window
+func parseTimeWindow(tw model.TimeWindow) (from int, till int, location *time.Location, err error) {
+	fromTime, err := time.Parse(timeWindowLayout, tw.From)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("wrong from: %w", err)
+	}
+	tillTime, err := time.Parse(timeWindowLayout, tw.Till)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("wrong till: %w", err)
+	}
+	from = fromTime.Hour()*60 + fromTime.Minute()
+	till = tillTime.Hour()*60 + tillTime.Minute()
+	if from == till {
+		return 0, 0, nil, fmt.Errorf("empty period: %s-%s", tw.From, tw.Till)
+	}
+	for _, d := range tw.Weekdays {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return 0, 0, nil, fmt.Errorf("wrong weekday: %q", d)
+		}
+	}
+	location, err = time.LoadLocation(tw.Timezone) // "" means UTC
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("wrong timezone: %w", err)
+	}
+	return from, till, location, nil
+}
@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+)
+
+func Test_ValidateRoleBindingCondition(t *testing.T) {
+	for name, condition := range map[string]*model.RoleBindingCondition{
+		"wrong cidr":     {SourceCIDRs: []string{"10.0.0.0/33"}},
+		"wrong from":     {TimeWindow: &model.TimeWindow{From: "9am", Till: "18:00"}},
+		"wrong weekday":  {TimeWindow: &model.TimeWindow{Weekdays: []string{"monday"}, From: "09:00", Till: "18:00"}},
+		"wrong timezone": {TimeWindow: &model.TimeWindow{From: "09:00", Till: "18:00", Timezone: "Mars/Olympus"}},
+		"empty period":   {TimeWindow: &model.TimeWindow{From: "09:00", Till: "09:00"}},
+		"empty method":   {AuthMethods: []string{""}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateRoleBindingCondition(condition)
+
+			require.ErrorIs(t, err, consts.ErrInvalidArg)
+		})
+	}
+}
+
+func Test_CheckRoleBindingCondition(t *testing.T) {
+	officeHours := &model.RoleBindingCondition{
+		SourceCIDRs: []string{"10.0.0.0/8", "192.168.1.0/24"},
+		TimeWindow: &model.TimeWindow{
+			Weekdays: []string{"mon", "tue", "wed", "thu", "fri"},
+			From:     "09:00",
+			Till:     "18:00",
+			Timezone: "Europe/Moscow",
+		},
+		AuthMethods: []string{"okta"},
+	}
+	nightShift := &model.RoleBindingCondition{
+		TimeWindow: &model.TimeWindow{Weekdays: []string{"fri"}, From: "22:00", Till: "06:00"},
+	}
+	// Friday, 12:00 at Moscow
+	friday := time.Date(2021, 10, 15, 9, 0, 0, 0, time.UTC)
+	// Saturday, 12:00 at Moscow
+	saturday := time.Date(2021, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	for name, tc := range map[string]struct {
+		condition *model.RoleBindingCondition
+		loginCtx  LoginContext
+		satisfied bool
+	}{
+		"nil condition": {
+			condition: nil,
+			loginCtx:  LoginContext{},
+			satisfied: true,
+		},
+		"office hours": {
+			condition: officeHours,
+			loginCtx:  LoginContext{RemoteAddr: "192.168.1.15", AuthMethod: "okta", Time: friday},
+			satisfied: true,
+		},
+		"wrong source": {
+			condition: officeHours,
+			loginCtx:  LoginContext{RemoteAddr: "8.8.8.8", AuthMethod: "okta", Time: friday},
+		},
+		"unknown source": {
+			condition: officeHours,
+			loginCtx:  LoginContext{AuthMethod: "okta", Time: friday},
+		},
+		"wrong weekday": {
+			condition: officeHours,
+			loginCtx:  LoginContext{RemoteAddr: "10.1.1.1", AuthMethod: "okta", Time: saturday},
+		},
+		"wrong time": {
+			condition: officeHours,
+			loginCtx:  LoginContext{RemoteAddr: "10.1.1.1", AuthMethod: "okta", Time: friday.Add(8 * time.Hour)},
+		},
+		"wrong auth method": {
+			condition: officeHours,
+			loginCtx:  LoginContext{RemoteAddr: "10.1.1.1", AuthMethod: "multipass", Time: friday},
+		},
+		"night shift after midnight": {
+			condition: nightShift,
+			loginCtx:  LoginContext{Time: time.Date(2021, 10, 16, 3, 0, 0, 0, time.UTC)},
+			satisfied: true,
+		},
+		"night shift of other day": {
+			condition: nightShift,
+			loginCtx:  LoginContext{Time: time.Date(2021, 10, 15, 3, 0, 0, 0, time.UTC)},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := CheckRoleBindingCondition(tc.condition, tc.loginCtx)
+
+			if tc.satisfied {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
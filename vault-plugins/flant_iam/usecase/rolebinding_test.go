@@ -8,6 +8,7 @@ import (
 	"github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
 )
 
 func roleBindingsUUIDSFromSlice(rbs []*DenormalizedRoleBinding) []string {
@@ -130,3 +131,34 @@ func Test_RoleBindingListAfterDeleteUserFail(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, "cannot insert in read-only transaction", err.Error())
 }
+
+func Test_RoleBindingCreateWithCondition(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture).Txn(true)
+	rb := &model.RoleBinding{
+		TenantUUID: fixtures.TenantUUID1,
+		Members:    []model.MemberNotation{{Type: model.UserType, UUID: fixtures.UserUUID1}},
+		Roles:      []model.BoundRole{{Name: fixtures.RoleName1}},
+		AnyProject: true,
+		Origin:     consts.OriginIAM,
+		Condition:  &model.RoleBindingCondition{SourceCIDRs: []string{"wrong"}},
+	}
+
+	_, err := RoleBindings(tx).Create(rb)
+	require.ErrorIs(t, err, consts.ErrInvalidArg)
+
+	rb.Version = ""
+	rb.Condition = &model.RoleBindingCondition{SourceCIDRs: []string{"10.0.0.0/8"}, AuthMethods: []string{"okta"}}
+	created, err := RoleBindings(tx).Create(rb)
+	require.NoError(t, err)
+	require.Equal(t, rb.Condition, created.Condition)
+
+	roles, err := NewRoleResolver(tx).CollectUserEffectiveRoles(fixtures.UserUUID1, []model.RoleName{fixtures.RoleName1})
+	require.NoError(t, err)
+	var condition *model.RoleBindingCondition
+	for _, er := range roles[fixtures.RoleName1] {
+		if er.RoleBindingUUID == created.UUID {
+			condition = er.Condition
+		}
+	}
+	require.Equal(t, rb.Condition, condition)
+}
@@ -1,7 +1,9 @@
 package usecase
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
@@ -12,15 +14,19 @@ import (
 )
 
 type RoleBindingApprovalService struct {
-	repo *iam_repo.RoleBindingApprovalRepository
+	repo              *iam_repo.RoleBindingApprovalRepository
+	groupRepo         *iam_repo.GroupRepository
+	accessRequestRepo *iam_repo.AccessRequestRepository
 
 	approverFetcher *MembersFetcher
 }
 
 func RoleBindingApprovals(db *io.MemoryStoreTxn) *RoleBindingApprovalService {
 	return &RoleBindingApprovalService{
-		repo:            iam_repo.NewRoleBindingApprovalRepository(db),
-		approverFetcher: NewMembersFetcher(db),
+		repo:              iam_repo.NewRoleBindingApprovalRepository(db),
+		groupRepo:         iam_repo.NewGroupRepository(db),
+		accessRequestRepo: iam_repo.NewAccessRequestRepository(db),
+		approverFetcher:   NewMembersFetcher(db),
 	}
 }
 
@@ -44,6 +50,7 @@ func (s RoleBindingApprovalService) Create(rba *model.RoleBindingApproval) error
 	rba.Groups = subj.Groups
 	rba.ServiceAccounts = subj.ServiceAccounts
 	rba.Users = subj.Users
+	rba.Votes = nil
 	if rba.UUID == "" {
 		rba.UUID = uuid.New()
 	}
@@ -68,9 +75,122 @@ func (s RoleBindingApprovalService) Update(rba *model.RoleBindingApproval) error
 	rba.Groups = subj.Groups
 	rba.ServiceAccounts = subj.ServiceAccounts
 	rba.Users = subj.Users
+	// votes are collected only through Vote, votes of removed approvers are dropped
+	rba.Votes = nil
+	for _, vote := range stored.Votes {
+		if err = s.checkApprover(rba, vote.Author); err == nil {
+			rba.Votes = append(rba.Votes, vote)
+		} else if !errors.Is(err, consts.ErrAccessForbidden) {
+			return fmt.Errorf("RoleBindingApprovalService.Update:%w", err)
+		}
+	}
 	return s.repo.UpdateOrCreate(rba)
 }
 
 func (s RoleBindingApprovalService) Delete(id model.RoleBindingApprovalUUID) error {
 	return s.repo.Delete(id, memdb.NewArchiveMark())
 }
+
+// Vote registers the vote of the request owner for the approval, each approver can vote only once
+func (s RoleBindingApprovalService) Vote(tenantUUID model.TenantUUID, rbUUID model.RoleBindingUUID,
+	id model.RoleBindingApprovalUUID, voter RequestOwner) (*model.RoleBindingApproval, error) {
+	author := voter.MemberNotation
+	stored, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if stored.TenantUUID != tenantUUID || stored.RoleBindingUUID != rbUUID {
+		return nil, consts.ErrNotFound
+	}
+	if stored.Archived() {
+		return nil, consts.ErrIsArchived
+	}
+	if err = s.checkApprover(stored, author); err != nil {
+		return nil, err
+	}
+	if err = s.checkNotRequester(stored, author); err != nil {
+		return nil, err
+	}
+	if stored.RequireMFA && !voter.MFA {
+		return nil, fmt.Errorf("%w: approval requires MFA, login with MFA to vote", consts.ErrAccessForbidden)
+	}
+	if stored.HasVoteOf(author) {
+		return nil, fmt.Errorf("%w: %s %s already voted", consts.ErrAlreadyExists, author.Type, author.UUID)
+	}
+	if stored.RequireUniqueApprover {
+		if err = s.checkUniqueApprover(stored, author); err != nil {
+			return nil, err
+		}
+	}
+
+	updated := *stored
+	updated.Votes = append(append([]model.ApprovalVote{}, stored.Votes...), model.ApprovalVote{
+		Author:  author,
+		VotedAt: time.Now().Unix(),
+	})
+	updated.Version = iam_repo.NewResourceVersion()
+	if err = s.repo.Update(&updated); err != nil {
+		return nil, fmt.Errorf("RoleBindingApprovalService.Vote:%w", err)
+	}
+	return &updated, nil
+}
+
+// checkApprover checks the author is listed at approvers directly or through groups
+func (s RoleBindingApprovalService) checkApprover(rba *model.RoleBindingApproval, author model.MemberNotation) error {
+	var directApprovers []string
+	var groups map[model.GroupUUID]struct{}
+	var err error
+	switch author.Type {
+	case model.UserType:
+		directApprovers = rba.Users
+		groups, err = s.groupRepo.FindAllParentGroupsForUserUUID(author.UUID)
+	case model.ServiceAccountType:
+		directApprovers = rba.ServiceAccounts
+		groups, err = s.groupRepo.FindAllParentGroupsForServiceAccountUUID(author.UUID)
+	default:
+		return fmt.Errorf("%w: wrong type of approver: %q", consts.ErrInvalidArg, author.Type)
+	}
+	if err != nil {
+		return err
+	}
+	for _, approverUUID := range directApprovers {
+		if approverUUID == author.UUID {
+			return nil
+		}
+	}
+	for _, groupUUID := range rba.Groups {
+		if _, isMember := groups[groupUUID]; isMember {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s %s is not an approver", consts.ErrAccessForbidden, author.Type, author.UUID)
+}
+
+// checkNotRequester checks the author doesn't approve own access request
+func (s RoleBindingApprovalService) checkNotRequester(rba *model.RoleBindingApproval, author model.MemberNotation) error {
+	accessRequests, err := s.accessRequestRepo.List(rba.TenantUUID, false)
+	if err != nil {
+		return err
+	}
+	for _, ar := range accessRequests {
+		if ar.ApprovalUUID == rba.UUID && ar.Requester == author {
+			return fmt.Errorf("%w: the requester can't approve own access request %s", consts.ErrAccessForbidden, ar.UUID)
+		}
+	}
+	return nil
+}
+
+// checkUniqueApprover checks the author didn't vote for other approvals of the same rolebinding
+func (s RoleBindingApprovalService) checkUniqueApprover(rba *model.RoleBindingApproval, author model.MemberNotation) error {
+	approvals, err := s.repo.List(rba.RoleBindingUUID, false)
+	if err != nil {
+		return err
+	}
+	for _, other := range approvals {
+		if other.UUID != rba.UUID && other.HasVoteOf(author) {
+			return fmt.Errorf("%w: %s %s already voted for approval %s of the same rolebinding",
+				consts.ErrInvalidArg, author.Type, author.UUID, other.UUID)
+		}
+	}
+	return nil
+}
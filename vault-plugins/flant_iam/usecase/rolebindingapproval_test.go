@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+func createApproval(t *testing.T, tx *io.MemoryStoreTxn, requiredVotes int, uniqueApprover bool) *model.RoleBindingApproval {
+	rba := &model.RoleBindingApproval{
+		TenantUUID:      fixtures.TenantUUID1,
+		RoleBindingUUID: fixtures.RbUUID1,
+		Approvers: []model.MemberNotation{
+			{Type: model.UserType, UUID: fixtures.UserUUID1},
+			{Type: model.GroupType, UUID: fixtures.GroupUUID2},
+		},
+		RequiredVotes:         requiredVotes,
+		RequireUniqueApprover: uniqueApprover,
+	}
+	err := RoleBindingApprovals(tx).Create(rba)
+	require.NoError(t, err)
+	return rba
+}
+
+func voter(ownerType string, ownerUUID string) RequestOwner {
+	return RequestOwner{MemberNotation: model.MemberNotation{Type: ownerType, UUID: ownerUUID}}
+}
+
+func Test_RoleBindingApprovalVote(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture,
+		RoleBindingFixture).Txn(true)
+	rba := createApproval(t, tx, 2, false)
+	service := RoleBindingApprovals(tx)
+	require.Equal(t, int64(1), pendingApprovals(t, tx, fixtures.RbUUID1))
+
+	voted, err := service.Vote(fixtures.TenantUUID1, fixtures.RbUUID1, rba.UUID,
+		voter(model.UserType, fixtures.UserUUID1))
+
+	require.NoError(t, err)
+	require.False(t, voted.Approved())
+	require.Len(t, voted.Votes, 1)
+	require.NotZero(t, voted.Votes[0].VotedAt)
+	require.NotEqual(t, rba.Version, voted.Version)
+
+	_, err = service.Vote(fixtures.TenantUUID1, fixtures.RbUUID1, rba.UUID,
+		voter(model.UserType, fixtures.UserUUID1))
+	require.ErrorIs(t, err, consts.ErrAlreadyExists)
+
+	_, err = service.Vote(fixtures.TenantUUID1, fixtures.RbUUID1, rba.UUID,
+		voter(model.UserType, fixtures.UserUUID4))
+	require.ErrorIs(t, err, consts.ErrAccessForbidden)
+
+	// UserUUID3 is approver as member of GroupUUID2
+	voted, err = service.Vote(fixtures.TenantUUID1, fixtures.RbUUID1, rba.UUID,
+		voter(model.UserType, fixtures.UserUUID3))
+
+	require.NoError(t, err)
+	require.True(t, voted.Approved())
+	require.Equal(t, int64(0), pendingApprovals(t, tx, fixtures.RbUUID1))
+}
+
+func Test_RoleBindingApprovalVoteUniqueApprover(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture,
+		RoleBindingFixture).Txn(true)
+	rba1 := createApproval(t, tx, 1, true)
+	rba2 := createApproval(t, tx, 1, true)
+	service := RoleBindingApprovals(tx)
+	author := voter(model.UserType, fixtures.UserUUID1)
+
+	_, err := service.Vote(fixtures.TenantUUID1, fixtures.RbUUID1, rba1.UUID, author)
+	require.NoError(t, err)
+
+	_, err = service.Vote(fixtures.TenantUUID1, fixtures.RbUUID1, rba2.UUID, author)
+	require.ErrorIs(t, err, consts.ErrInvalidArg)
+}
+
+func Test_RoleBindingApprovalUpdateKeepsVotes(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture,
+		RoleBindingFixture).Txn(true)
+	rba := createApproval(t, tx, 2, false)
+	service := RoleBindingApprovals(tx)
+	voted, err := service.Vote(fixtures.TenantUUID1, fixtures.RbUUID1, rba.UUID,
+		voter(model.UserType, fixtures.UserUUID1))
+	require.NoError(t, err)
+
+	updated := *voted
+	updated.Votes = nil
+	updated.RequiredVotes = 1
+	err = service.Update(&updated)
+
+	require.NoError(t, err)
+	require.Len(t, updated.Votes, 1)
+	require.True(t, updated.Approved())
+}
+
+func Test_RoleBindingApprovalUpdateDropsVotesOfRemovedApprovers(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture,
+		RoleBindingFixture).Txn(true)
+	rba := createApproval(t, tx, 2, false)
+	service := RoleBindingApprovals(tx)
+	_, err := service.Vote(fixtures.TenantUUID1, fixtures.RbUUID1, rba.UUID, voter(model.UserType, fixtures.UserUUID1))
+	require.NoError(t, err)
+	voted, err := service.Vote(fixtures.TenantUUID1, fixtures.RbUUID1, rba.UUID, voter(model.UserType, fixtures.UserUUID3))
+	require.NoError(t, err)
+
+	updated := *voted
+	updated.Approvers = []model.MemberNotation{{Type: model.UserType, UUID: fixtures.UserUUID3}}
+	err = service.Update(&updated)
+
+	require.NoError(t, err)
+	require.Len(t, updated.Votes, 1)
+	require.Equal(t, fixtures.UserUUID3, updated.Votes[0].Author.UUID)
+}
+
+func Test_RoleBindingApprovalVoteRequireMFA(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, ProjectFixture, RoleFixture,
+		RoleBindingFixture).Txn(true)
+	rba := createApproval(t, tx, 1, false)
+	rba.RequireMFA = true
+	service := RoleBindingApprovals(tx)
+	require.NoError(t, service.Update(rba))
+	author := voter(model.UserType, fixtures.UserUUID1)
+
+	_, err := service.Vote(fixtures.TenantUUID1, fixtures.RbUUID1, rba.UUID, author)
+	require.ErrorIs(t, err, consts.ErrAccessForbidden)
+
+	author.MFA = true
+	voted, err := service.Vote(fixtures.TenantUUID1, fixtures.RbUUID1, rba.UUID, author)
+	require.NoError(t, err)
+	require.True(t, voted.Approved())
+}
+
+func pendingApprovals(t *testing.T, tx *io.MemoryStoreTxn, rolebindingUUID model.RoleBindingUUID) int64 {
+	count, err := iam_repo.NewRoleBindingApprovalRepository(tx).PendingRoleBindingApprovalCount(rolebindingUUID)
+	require.NoError(t, err)
+	return count
+}
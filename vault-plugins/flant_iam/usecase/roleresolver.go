@@ -1,6 +1,7 @@
 package usecase
 
 import (
+	"sort"
 	"time"
 
 	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
@@ -39,6 +40,10 @@ type EffectiveRole struct {
 	Projects        []model.ProjectUUID    `json:"projects"`
 	NeedApprovals   int64                  `json:"need_approvals"`
 	Options         map[string]interface{} `json:"options"`
+	// Condition is a condition of the rolebinding, should be checked at login
+	Condition *model.RoleBindingCondition `json:"condition,omitempty"`
+	// ExcludedProjects are projects where the role is revoked by deny rolebindings, filled only if AnyProject is true
+	ExcludedProjects []model.ProjectUUID `json:"excluded_projects,omitempty"`
 }
 
 type RoleInformer interface {
@@ -68,7 +73,7 @@ type SharingInformer interface {
 }
 
 type ApprovalInformer interface {
-	RoleBindingApprovalCount(rolebindingUUID model.RoleBindingApprovalUUID) int64
+	PendingRoleBindingApprovalCount(rolebindingUUID model.RoleBindingUUID) (int64, error)
 }
 
 type roleResolver struct {
@@ -93,12 +98,17 @@ func (r *roleResolver) CollectUserEffectiveRoles(userUUID model.UserUUID, roles
 		if err != nil {
 			return nil, err
 		}
-		for _, roleBinding := range roleBindings {
+		grants, denied := splitDenyRoleBindings(roleBindings, roleBindingsForRoles)
+		for _, roleBinding := range grants {
 			_, rbHasRole := roleBindingsForRoles[roleBinding.UUID]
 			if rbHasRole {
 				for _, boundRole := range roleBinding.Roles {
 					if roleOptionsTemplatesChain, target := targetRoles[boundRole.Name]; target {
-						effectiveRoles = append(effectiveRoles, EffectiveRole{
+						needApprovals, err := r.approvalInformer.PendingRoleBindingApprovalCount(roleBinding.UUID)
+						if err != nil {
+							return nil, err
+						}
+						effectiveRole, granted := applyDeniedScope(EffectiveRole{
 							RoleName:        roleName,
 							RoleBindingUUID: roleBinding.UUID,
 							TenantUUID:      roleBinding.TenantUUID,
@@ -106,9 +116,13 @@ func (r *roleResolver) CollectUserEffectiveRoles(userUUID model.UserUUID, roles
 							RequireMFA:      roleBinding.RequireMFA,
 							AnyProject:      roleBinding.AnyProject,
 							Projects:        roleBinding.Projects,
-							NeedApprovals:   r.approvalInformer.RoleBindingApprovalCount(roleBinding.UUID),
+							NeedApprovals:   needApprovals,
 							Options:         applyRoleOptionsTemplatesChain(boundRole.Options, roleOptionsTemplatesChain),
-						})
+							Condition:       roleBinding.Condition,
+						}, denied[roleBinding.TenantUUID])
+						if granted {
+							effectiveRoles = append(effectiveRoles, effectiveRole)
+						}
 						break
 					}
 				}
@@ -257,14 +271,18 @@ func (r *roleResolver) CheckUserForRolebindingsAtProject(userUUID model.UserUUID
 	}
 	effectiveRoles := []EffectiveRole{}
 	roleExists := false
-	for _, roleBinding := range roleBindings {
+	grants, denied := splitDenyRoleBindings(roleBindings, roleBindingsForRoles)
+	for _, roleBinding := range grants {
 		_, rbHasRole := roleBindingsForRoles[roleBinding.UUID]
 		_, rbHasProject := roleBindingsForProject[roleBinding.UUID]
 		if roleBinding.AnyProject {
 			rbHasProject = true
 		}
-		if rbHasProject && rbHasRole {
-			effectiveRoles = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+		if rbHasProject && rbHasRole && !denied[roleBinding.TenantUUID].deniesProject(projectUUID) {
+			effectiveRoles, err = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+			if err != nil {
+				return false, nil, err
+			}
 			roleExists = true
 		}
 	}
@@ -272,10 +290,14 @@ func (r *roleResolver) CheckUserForRolebindingsAtProject(userUUID model.UserUUID
 }
 
 func (r *roleResolver) mergeEffectiveRoles(originEffectiveRoles []EffectiveRole, roleBinding *model.RoleBinding,
-	targetRoles map[model.RoleName]repo.RoleChain, masterRole model.RoleName) []EffectiveRole {
+	targetRoles map[model.RoleName]repo.RoleChain, masterRole model.RoleName) ([]EffectiveRole, error) {
 	result := originEffectiveRoles
 	for _, boundRole := range roleBinding.Roles {
 		if roleOptionsTemplatesChain, target := targetRoles[boundRole.Name]; target {
+			needApprovals, err := r.approvalInformer.PendingRoleBindingApprovalCount(roleBinding.UUID)
+			if err != nil {
+				return nil, err
+			}
 			newEffectiveRole := EffectiveRole{
 				RoleName:        masterRole,
 				RoleBindingUUID: roleBinding.UUID,
@@ -284,14 +306,15 @@ func (r *roleResolver) mergeEffectiveRoles(originEffectiveRoles []EffectiveRole,
 				RequireMFA:      roleBinding.RequireMFA,
 				AnyProject:      roleBinding.AnyProject,
 				Projects:        roleBinding.Projects,
-				NeedApprovals:   r.approvalInformer.RoleBindingApprovalCount(roleBinding.UUID),
+				NeedApprovals:   needApprovals,
 				Options:         applyRoleOptionsTemplatesChain(boundRole.Options, roleOptionsTemplatesChain),
+				Condition:       roleBinding.Condition,
 			}
 			result = append(result, newEffectiveRole)
 			break
 		}
 	}
-	return result
+	return result, nil
 }
 
 func applyRoleOptionsTemplatesChain(options map[string]interface{}, chain repo.RoleChain) map[string]interface{} {
@@ -327,14 +350,21 @@ func (r *roleResolver) CheckUserForRolebindingsAtTenant(userUUID model.UserUUID,
 	if len(roleBindings) == 0 || len(roleBindingsForRoles) == 0 {
 		return false, nil, nil
 	}
+	grants, denied := splitDenyRoleBindings(roleBindings, roleBindingsForRoles)
+	if denied[tenantUUID].deniesTenant() {
+		return false, nil, nil
+	}
 	effectiveRoles := []EffectiveRole{}
 	roleExists := false
-	for _, roleBinding := range roleBindings {
+	for _, roleBinding := range grants {
 		if roleBinding.TenantUUID != tenantUUID {
 			continue
 		}
 		if _, rbHasRole := roleBindingsForRoles[roleBinding.UUID]; rbHasRole {
-			effectiveRoles = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+			effectiveRoles, err = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+			if err != nil {
+				return false, nil, err
+			}
 			roleExists = true
 		}
 	}
@@ -364,9 +394,16 @@ func (r *roleResolver) CheckUserForRolebindings(userUUID model.UserUUID, roleNam
 	}
 	effectiveRoles := []EffectiveRole{}
 	roleExists := false
-	for _, roleBinding := range roleBindings {
+	grants, denied := splitDenyRoleBindings(roleBindings, roleBindingsForRoles)
+	for _, roleBinding := range grants {
+		if denied[roleBinding.TenantUUID].deniesTenant() {
+			continue
+		}
 		if _, rbHasRole := roleBindingsForRoles[roleBinding.UUID]; rbHasRole {
-			effectiveRoles = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+			effectiveRoles, err = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+			if err != nil {
+				return false, nil, err
+			}
 			roleExists = true
 		}
 	}
@@ -399,14 +436,18 @@ func (r *roleResolver) CheckServiceAccountForRolebindingsAtProject(serviceAccoun
 	}
 	effectiveRoles := []EffectiveRole{}
 	roleExists := false
-	for _, roleBinding := range roleBindings {
+	grants, denied := splitDenyRoleBindings(roleBindings, roleBindingsForRoles)
+	for _, roleBinding := range grants {
 		_, rbHasRole := roleBindingsForRoles[roleBinding.UUID]
 		_, rbHasProject := roleBindingsForProject[roleBinding.UUID]
 		if roleBinding.AnyProject {
 			rbHasProject = true
 		}
-		if rbHasProject && rbHasRole {
-			effectiveRoles = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+		if rbHasProject && rbHasRole && !denied[roleBinding.TenantUUID].deniesProject(projectUUID) {
+			effectiveRoles, err = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+			if err != nil {
+				return false, nil, err
+			}
 			roleExists = true
 		}
 	}
@@ -435,14 +476,21 @@ func (r *roleResolver) CheckServiceAccountForRolebindingsAtTenant(serviceAccount
 	if len(roleBindings) == 0 || len(roleBindingsForRoles) == 0 {
 		return false, nil, nil
 	}
+	grants, denied := splitDenyRoleBindings(roleBindings, roleBindingsForRoles)
+	if denied[tenantUUID].deniesTenant() {
+		return false, nil, nil
+	}
 	effectiveRoles := []EffectiveRole{}
 	roleExists := false
-	for _, roleBinding := range roleBindings {
+	for _, roleBinding := range grants {
 		if roleBinding.TenantUUID != tenantUUID {
 			continue
 		}
 		if _, rbHasRole := roleBindingsForRoles[roleBinding.UUID]; rbHasRole {
-			effectiveRoles = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+			effectiveRoles, err = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+			if err != nil {
+				return false, nil, err
+			}
 			roleExists = true
 		}
 	}
@@ -472,9 +520,16 @@ func (r *roleResolver) CheckServiceAccountForRolebindings(serviceAccountUUID mod
 	}
 	effectiveRoles := []EffectiveRole{}
 	roleExists := false
-	for _, roleBinding := range roleBindings {
+	grants, denied := splitDenyRoleBindings(roleBindings, roleBindingsForRoles)
+	for _, roleBinding := range grants {
+		if denied[roleBinding.TenantUUID].deniesTenant() {
+			continue
+		}
 		if _, rbHasRole := roleBindingsForRoles[roleBinding.UUID]; rbHasRole {
-			effectiveRoles = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+			effectiveRoles, err = r.mergeEffectiveRoles(effectiveRoles, roleBinding, roles, roleName)
+			if err != nil {
+				return false, nil, err
+			}
 			roleExists = true
 		}
 	}
@@ -494,24 +549,58 @@ func (r *roleResolver) FindMembersWithProjectScopedRole(roleName model.RoleName,
 	if err != nil {
 		return nil, nil, err
 	}
-	users := map[model.UserUUID]struct{}{}
-	serviceAccounts := map[model.ServiceAccountUUID]struct{}{}
-	groups := map[model.GroupUUID]struct{}{}
+	grants := map[model.RoleBindingUUID]*model.RoleBinding{}
+	denies := map[model.RoleBindingUUID]*model.RoleBinding{}
 	for _, rb := range roleBindings {
-		if _, hasProject := roleBindingsForProject[rb.UUID]; hasProject || (rb.AnyProject && rb.TenantUUID == tenantUUID) {
-			users = mergeUUIDs(users, rb.Users)
-			serviceAccounts = mergeUUIDs(serviceAccounts, rb.ServiceAccounts)
-			groups = mergeUUIDs(groups, rb.Groups)
+		_, hasProject := roleBindingsForProject[rb.UUID]
+		switch {
+		case rb.Deny && (hasProject || (rb.TenantUUID == tenantUUID && (rb.AnyProject || len(rb.Projects) == 0))):
+			denies[rb.UUID] = rb
+		case !rb.Deny && (hasProject || (rb.AnyProject && rb.TenantUUID == tenantUUID)):
+			grants[rb.UUID] = rb
 		}
 	}
-	users, serviceAccounts, err = r.groupInformer.FindAllMembersFor(stringSlice(users), stringSlice(serviceAccounts),
-		stringSlice(groups))
+	return r.findMembersWithoutDenied(grants, denies)
+}
+
+// findMembersWithoutDenied returns all members of grants rolebindings, except members of denies rolebindings
+func (r *roleResolver) findMembersWithoutDenied(grants map[model.RoleBindingUUID]*model.RoleBinding,
+	denies map[model.RoleBindingUUID]*model.RoleBinding) ([]model.UserUUID, []model.ServiceAccountUUID, error) {
+	users, serviceAccounts, err := r.findAllMembersOfRoleBindings(grants)
 	if err != nil {
 		return nil, nil, err
 	}
+	if len(denies) > 0 {
+		deniedUsers, deniedServiceAccounts, err := r.findAllMembersOfRoleBindings(denies)
+		if err != nil {
+			return nil, nil, err
+		}
+		users = excludeUUIDs(users, deniedUsers)
+		serviceAccounts = excludeUUIDs(serviceAccounts, deniedServiceAccounts)
+	}
 	return stringSlice(users), stringSlice(serviceAccounts), nil
 }
 
+func (r *roleResolver) findAllMembersOfRoleBindings(roleBindings map[model.RoleBindingUUID]*model.RoleBinding) (
+	map[model.UserUUID]struct{}, map[model.ServiceAccountUUID]struct{}, error) {
+	users := map[model.UserUUID]struct{}{}
+	serviceAccounts := map[model.ServiceAccountUUID]struct{}{}
+	groups := map[model.GroupUUID]struct{}{}
+	for _, rb := range roleBindings {
+		users = mergeUUIDs(users, rb.Users)
+		serviceAccounts = mergeUUIDs(serviceAccounts, rb.ServiceAccounts)
+		groups = mergeUUIDs(groups, rb.Groups)
+	}
+	return r.groupInformer.FindAllMembersFor(stringSlice(users), stringSlice(serviceAccounts), stringSlice(groups))
+}
+
+func excludeUUIDs(originUUIDs map[string]struct{}, excludedUUIDs map[string]struct{}) map[string]struct{} {
+	for uuid := range excludedUUIDs {
+		delete(originUUIDs, uuid)
+	}
+	return originUUIDs
+}
+
 func mergeUUIDs(originUUIDs map[string]struct{}, extraUUIDs []string) map[string]struct{} {
 	for i := range extraUUIDs {
 		originUUIDs[extraUUIDs[i]] = struct{}{}
@@ -534,23 +623,19 @@ func (r *roleResolver) FindMembersWithTenantScopedRole(roleName model.RoleName,
 	if len(roleBindings) == 0 {
 		return nil, nil, nil
 	}
-	users := map[model.UserUUID]struct{}{}
-	serviceAccounts := map[model.ServiceAccountUUID]struct{}{}
-	groups := map[model.GroupUUID]struct{}{}
+	grants := map[model.RoleBindingUUID]*model.RoleBinding{}
+	denies := map[model.RoleBindingUUID]*model.RoleBinding{}
 	for _, rb := range roleBindings {
-		if rb.TenantUUID != tenantUUID {
+		switch {
+		case rb.TenantUUID != tenantUUID:
 			continue
+		case !rb.Deny:
+			grants[rb.UUID] = rb
+		case rb.AnyProject || len(rb.Projects) == 0:
+			denies[rb.UUID] = rb
 		}
-		users = mergeUUIDs(users, rb.Users)
-		serviceAccounts = mergeUUIDs(serviceAccounts, rb.ServiceAccounts)
-		groups = mergeUUIDs(groups, rb.Groups)
 	}
-	users, serviceAccounts, err = r.groupInformer.FindAllMembersFor(stringSlice(users),
-		stringSlice(serviceAccounts), stringSlice(groups))
-	if err != nil {
-		return nil, nil, err
-	}
-	return stringSlice(users), stringSlice(serviceAccounts), nil
+	return r.findMembersWithoutDenied(grants, denies)
 }
 
 func (r *roleResolver) CheckGroupForRole(groupUUID model.GroupUUID, roleName model.RoleName) (bool, error) {
@@ -566,12 +651,90 @@ func (r *roleResolver) CheckGroupForRole(groupUUID model.GroupUUID, roleName mod
 	if err != nil {
 		return false, err
 	}
-	for rbUUID := range roleBindingsForRole {
+	hasRole := false
+	for rbUUID, rb := range roleBindingsForRole {
 		if _, found := roleBindingsForGroup[rbUUID]; found {
-			return true, nil
+			if rb.Deny {
+				return false, nil
+			}
+			hasRole = true
+		}
+	}
+	return hasRole, nil
+}
+
+// deniedScope is a part of a tenant, where a role is revoked by deny rolebindings
+type deniedScope struct {
+	wholeTenant bool
+	projects    map[model.ProjectUUID]struct{}
+}
+
+func (d *deniedScope) deniesTenant() bool {
+	return d != nil && d.wholeTenant
+}
+
+func (d *deniedScope) deniesProject(projectUUID model.ProjectUUID) bool {
+	if d == nil {
+		return false
+	}
+	if d.wholeTenant {
+		return true
+	}
+	_, denied := d.projects[projectUUID]
+	return denied
+}
+
+// splitDenyRoleBindings separates grant rolebindings from deny rolebindings, and collects scopes denied by rolebindings
+// with target roles, by tenants
+func splitDenyRoleBindings(roleBindings map[model.RoleBindingUUID]*model.RoleBinding,
+	roleBindingsForRoles map[model.RoleBindingUUID]*model.RoleBinding) (map[model.RoleBindingUUID]*model.RoleBinding,
+	map[model.TenantUUID]*deniedScope) {
+	grants := map[model.RoleBindingUUID]*model.RoleBinding{}
+	denied := map[model.TenantUUID]*deniedScope{}
+	for uuid, rb := range roleBindings {
+		if !rb.Deny {
+			grants[uuid] = rb
+			continue
+		}
+		if _, rbHasRole := roleBindingsForRoles[uuid]; !rbHasRole {
+			continue
+		}
+		scope, exists := denied[rb.TenantUUID]
+		if !exists {
+			scope = &deniedScope{projects: map[model.ProjectUUID]struct{}{}}
+			denied[rb.TenantUUID] = scope
+		}
+		if rb.AnyProject || len(rb.Projects) == 0 {
+			scope.wholeTenant = true
+		}
+		scope.projects = mergeUUIDs(scope.projects, rb.Projects)
+	}
+	return grants, denied
+}
+
+// applyDeniedScope cuts denied projects from the effective role, returns false if the effective role is revoked totally
+func applyDeniedScope(er EffectiveRole, scope *deniedScope) (EffectiveRole, bool) {
+	switch {
+	case scope == nil:
+		return er, true
+	case scope.wholeTenant:
+		return er, false
+	case er.AnyProject:
+		er.ExcludedProjects = stringSlice(scope.projects)
+		sort.Strings(er.ExcludedProjects)
+		return er, true
+	case len(er.Projects) == 0:
+		// rolebinding without projects gives role at the tenant level, it is not affected by denying at projects
+		return er, true
+	}
+	projects := make([]model.ProjectUUID, 0, len(er.Projects))
+	for _, projectUUID := range er.Projects {
+		if !scope.deniesProject(projectUUID) {
+			projects = append(projects, projectUUID)
 		}
 	}
-	return false, err
+	er.Projects = projects
+	return er, len(projects) > 0
 }
 
 func NewRoleResolver(tx *io.MemoryStoreTxn) RoleResolver {
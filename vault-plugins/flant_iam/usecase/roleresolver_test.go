@@ -6,7 +6,10 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	"github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
 )
 
 func Test_collectAllRolesAndRoleBindings(t *testing.T) {
@@ -178,3 +181,69 @@ func Test_FindMembersWithTenantScopedRole(t *testing.T) {
 		fixtures.ServiceAccountUUID1, fixtures.ServiceAccountUUID2, fixtures.ServiceAccountUUID3,
 	}, serviceAccounts)
 }
+
+func createDenyRoleBinding(t *testing.T, tx *io.MemoryStoreTxn, rb model.RoleBinding) {
+	rb.Deny = true
+	rb.TenantUUID = fixtures.TenantUUID1
+	rb.Origin = consts.OriginIAM
+	_, err := RoleBindings(tx).Create(&rb)
+	require.NoError(t, err)
+}
+
+func Test_DenyRoleBindingAtProject(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, RoleFixture, ProjectFixture,
+		RoleBindingFixture).Txn(true)
+	createDenyRoleBinding(t, tx, model.RoleBinding{
+		Members:  []model.MemberNotation{{Type: model.UserType, UUID: fixtures.UserUUID1}},
+		Projects: []model.ProjectUUID{fixtures.ProjectUUID1},
+		Roles:    []model.BoundRole{{Name: fixtures.RoleName1}},
+	})
+	rr := NewRoleResolver(tx)
+
+	_, deniedRoles, err := rr.CheckUserForRolebindingsAtProject(fixtures.UserUUID1, fixtures.RoleName1, fixtures.ProjectUUID1)
+	require.NoError(t, err)
+	_, allowedRoles, err := rr.CheckUserForRolebindingsAtProject(fixtures.UserUUID1, fixtures.RoleName1, fixtures.ProjectUUID3)
+	require.NoError(t, err)
+	effectiveRoles, err := rr.CollectUserEffectiveRoles(fixtures.UserUUID1, []model.RoleName{fixtures.RoleName1})
+	require.NoError(t, err)
+	deniedUsers, _, err := rr.FindMembersWithProjectScopedRole(fixtures.RoleName1, fixtures.TenantUUID1, fixtures.ProjectUUID1)
+	require.NoError(t, err)
+	allowedUsers, _, err := rr.FindMembersWithProjectScopedRole(fixtures.RoleName1, fixtures.TenantUUID1, fixtures.ProjectUUID3)
+	require.NoError(t, err)
+
+	// only rolebinding of other tenant is left
+	require.ElementsMatch(t, []string{fixtures.RbUUID2}, roleBindingsUUIDsFromEffectiveRoles(deniedRoles))
+	require.ElementsMatch(t, []string{fixtures.RbUUID1, fixtures.RbUUID2, fixtures.RbUUID3},
+		roleBindingsUUIDsFromEffectiveRoles(allowedRoles))
+	for _, er := range effectiveRoles[fixtures.RoleName1] {
+		switch er.RoleBindingUUID {
+		case fixtures.RbUUID1:
+			require.Equal(t, []model.ProjectUUID{fixtures.ProjectUUID3}, er.Projects)
+		case fixtures.RbUUID3:
+			require.Equal(t, []model.ProjectUUID{fixtures.ProjectUUID1}, er.ExcludedProjects)
+		}
+	}
+	require.NotContains(t, deniedUsers, fixtures.UserUUID1)
+	require.Contains(t, deniedUsers, fixtures.UserUUID2)
+	require.Contains(t, allowedUsers, fixtures.UserUUID1)
+}
+
+func Test_DenyRoleBindingOfIncludingRoleAtTenant(t *testing.T) {
+	tx := RunFixtures(t, TenantFixture, UserFixture, ServiceAccountFixture, GroupFixture, RoleFixture, ProjectFixture,
+		RoleBindingFixture).Txn(true)
+	// RoleName5 includes RoleName1
+	createDenyRoleBinding(t, tx, model.RoleBinding{
+		Members:    []model.MemberNotation{{Type: model.UserType, UUID: fixtures.UserUUID1}},
+		AnyProject: true,
+		Roles:      []model.BoundRole{{Name: fixtures.RoleName5}},
+	})
+	rr := NewRoleResolver(tx)
+
+	_, effectiveRoles, err := rr.CheckUserForRolebindingsAtProject(fixtures.UserUUID1, fixtures.RoleName1, fixtures.ProjectUUID3)
+	require.NoError(t, err)
+	users, _, err := rr.FindMembersWithProjectScopedRole(fixtures.RoleName1, fixtures.TenantUUID1, fixtures.ProjectUUID3)
+	require.NoError(t, err)
+
+	require.ElementsMatch(t, []string{fixtures.RbUUID2}, roleBindingsUUIDsFromEffectiveRoles(effectiveRoles))
+	require.NotContains(t, users, fixtures.UserUUID1)
+}
@@ -45,6 +45,13 @@ func (s *TenantService) Update(updated *model.Tenant) error {
 	}
 	updated.Version = iam_repo.NewResourceVersion()
 	updated.Origin = s.Origin
+	// Preserve fields, that are not always accessible from the outside, e.g. from HTTP API
+	if updated.Labels == nil {
+		updated.Labels = stored.Labels
+	}
+	if updated.AccessRequestPolicy == nil {
+		updated.AccessRequestPolicy = stored.AccessRequestPolicy
+	}
 	// Update
 
 	return s.repo.Create(updated)
@@ -0,0 +1,679 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+// TenantDocument is a self-consistent declarative representation of a tenant with all its objects
+// Extensions and resource versions are not the part of the document, secrets of credentials are not exported
+type TenantDocument struct {
+	Tenant                  model.Tenant                   `json:"tenant"`
+	Users                   []model.User                   `json:"users"`
+	ServiceAccounts         []model.ServiceAccount         `json:"service_accounts"`
+	Projects                []model.Project                `json:"projects"`
+	Groups                  []model.Group                  `json:"groups"`
+	RoleBindings            []model.RoleBinding            `json:"rolebindings"`
+	RoleBindingApprovals    []model.RoleBindingApproval    `json:"rolebinding_approvals"`
+	IdentitySharings        []model.IdentitySharing        `json:"identity_sharings"`
+	AccessRequests          []model.AccessRequest          `json:"access_requests"`
+	Multipasses             []model.Multipass              `json:"multipasses"`
+	ServiceAccountPasswords []model.ServiceAccountPassword `json:"service_account_passwords"`
+}
+
+type TenantDocumentService struct {
+	db *io.MemoryStoreTxn // called "db" not to provoke transaction semantics
+
+	tenantRepo         *iam_repo.TenantRepository
+	userRepo           *iam_repo.UserRepository
+	serviceAccountRepo *iam_repo.ServiceAccountRepository
+	projectRepo        *iam_repo.ProjectRepository
+	groupRepo          *iam_repo.GroupRepository
+	roleBindingRepo    *iam_repo.RoleBindingRepository
+	approvalRepo       *iam_repo.RoleBindingApprovalRepository
+	sharingRepo        *iam_repo.IdentitySharingRepository
+	accessRequestRepo  *iam_repo.AccessRequestRepository
+	multipassRepo      *iam_repo.MultipassRepository
+	saPasswordRepo     *iam_repo.ServiceAccountPasswordRepository
+}
+
+func TenantDocuments(db *io.MemoryStoreTxn) *TenantDocumentService {
+	return &TenantDocumentService{
+		db:                 db,
+		tenantRepo:         iam_repo.NewTenantRepository(db),
+		userRepo:           iam_repo.NewUserRepository(db),
+		serviceAccountRepo: iam_repo.NewServiceAccountRepository(db),
+		projectRepo:        iam_repo.NewProjectRepository(db),
+		groupRepo:          iam_repo.NewGroupRepository(db),
+		roleBindingRepo:    iam_repo.NewRoleBindingRepository(db),
+		approvalRepo:       iam_repo.NewRoleBindingApprovalRepository(db),
+		sharingRepo:        iam_repo.NewIdentitySharingRepository(db),
+		accessRequestRepo:  iam_repo.NewAccessRequestRepository(db),
+		multipassRepo:      iam_repo.NewMultipassRepository(db),
+		saPasswordRepo:     iam_repo.NewServiceAccountPasswordRepository(db),
+	}
+}
+
+// Export collects all not archived objects of the tenant, objects of each type are sorted by uuid
+func (s *TenantDocumentService) Export(tenantUUID model.TenantUUID) (*TenantDocument, error) {
+	tenant, err := s.tenantRepo.GetByID(tenantUUID)
+	if err != nil {
+		return nil, err
+	}
+	if tenant.Archived() {
+		return nil, consts.ErrIsArchived
+	}
+	doc := &TenantDocument{Tenant: *tenant}
+	doc.Tenant.Version = ""
+
+	users, err := s.userRepo.List(tenantUUID, false)
+	if err != nil {
+		return nil, fmt.Errorf("collecting users:%w", err)
+	}
+	for _, u := range users {
+		obj := *u
+		obj.Version = ""
+		obj.Extensions = nil
+		doc.Users = append(doc.Users, obj)
+	}
+	sort.Slice(doc.Users, func(i, j int) bool { return doc.Users[i].UUID < doc.Users[j].UUID })
+
+	sas, err := s.serviceAccountRepo.List(tenantUUID, false)
+	if err != nil {
+		return nil, fmt.Errorf("collecting service_accounts:%w", err)
+	}
+	for _, sa := range sas {
+		obj := *sa
+		obj.Version = ""
+		obj.Extensions = nil
+		doc.ServiceAccounts = append(doc.ServiceAccounts, obj)
+	}
+	sort.Slice(doc.ServiceAccounts, func(i, j int) bool {
+		return doc.ServiceAccounts[i].UUID < doc.ServiceAccounts[j].UUID
+	})
+
+	projects, err := s.projectRepo.List(tenantUUID, false)
+	if err != nil {
+		return nil, fmt.Errorf("collecting projects:%w", err)
+	}
+	for _, p := range projects {
+		obj := *p
+		obj.Version = ""
+		obj.Extensions = nil
+		doc.Projects = append(doc.Projects, obj)
+	}
+	sort.Slice(doc.Projects, func(i, j int) bool { return doc.Projects[i].UUID < doc.Projects[j].UUID })
+
+	groups, err := s.groupRepo.List(tenantUUID, false)
+	if err != nil {
+		return nil, fmt.Errorf("collecting groups:%w", err)
+	}
+	for _, g := range groups {
+		obj := *g
+		obj.Version = ""
+		obj.Extensions = nil
+		doc.Groups = append(doc.Groups, obj)
+	}
+	sort.Slice(doc.Groups, func(i, j int) bool { return doc.Groups[i].UUID < doc.Groups[j].UUID })
+
+	rbs, err := s.roleBindingRepo.List(tenantUUID, false)
+	if err != nil {
+		return nil, fmt.Errorf("collecting rolebindings:%w", err)
+	}
+	for _, rb := range rbs {
+		obj := *rb
+		obj.Version = ""
+		obj.Extensions = nil
+		doc.RoleBindings = append(doc.RoleBindings, obj)
+	}
+	sort.Slice(doc.RoleBindings, func(i, j int) bool { return doc.RoleBindings[i].UUID < doc.RoleBindings[j].UUID })
+
+	for _, rb := range rbs {
+		approvals, err := s.approvalRepo.List(rb.UUID, false)
+		if err != nil {
+			return nil, fmt.Errorf("collecting rolebinding_approvals:%w", err)
+		}
+		for _, a := range approvals {
+			obj := *a
+			obj.Version = ""
+			doc.RoleBindingApprovals = append(doc.RoleBindingApprovals, obj)
+		}
+	}
+	sort.Slice(doc.RoleBindingApprovals, func(i, j int) bool {
+		return doc.RoleBindingApprovals[i].UUID < doc.RoleBindingApprovals[j].UUID
+	})
+
+	sharings, err := s.sharingRepo.List(tenantUUID, false)
+	if err != nil {
+		return nil, fmt.Errorf("collecting identity_sharings:%w", err)
+	}
+	for _, is := range sharings {
+		obj := *is
+		obj.Version = ""
+		doc.IdentitySharings = append(doc.IdentitySharings, obj)
+	}
+	sort.Slice(doc.IdentitySharings, func(i, j int) bool {
+		return doc.IdentitySharings[i].UUID < doc.IdentitySharings[j].UUID
+	})
+
+	ars, err := s.accessRequestRepo.List(tenantUUID, false)
+	if err != nil {
+		return nil, fmt.Errorf("collecting access_requests:%w", err)
+	}
+	for _, ar := range ars {
+		obj := *ar
+		obj.Version = ""
+		doc.AccessRequests = append(doc.AccessRequests, obj)
+	}
+	sort.Slice(doc.AccessRequests, func(i, j int) bool { return doc.AccessRequests[i].UUID < doc.AccessRequests[j].UUID })
+
+	owners := make([]model.OwnerUUID, 0, len(users)+len(sas))
+	for _, u := range users {
+		owners = append(owners, u.UUID)
+	}
+	for _, sa := range sas {
+		owners = append(owners, sa.UUID)
+	}
+	for _, owner := range owners {
+		mps, err := s.multipassRepo.List(owner, false)
+		if err != nil {
+			return nil, fmt.Errorf("collecting multipasses:%w", err)
+		}
+		for _, mp := range mps {
+			obj := iam_repo.OmitSensitive(mp).(model.Multipass)
+			obj.Extensions = nil
+			doc.Multipasses = append(doc.Multipasses, obj)
+		}
+	}
+	sort.Slice(doc.Multipasses, func(i, j int) bool { return doc.Multipasses[i].UUID < doc.Multipasses[j].UUID })
+
+	for _, sa := range sas {
+		passwords, err := s.saPasswordRepo.List(sa.UUID, false)
+		if err != nil {
+			return nil, fmt.Errorf("collecting service_account_passwords:%w", err)
+		}
+		for _, p := range passwords {
+			doc.ServiceAccountPasswords = append(doc.ServiceAccountPasswords,
+				iam_repo.OmitSensitive(p).(model.ServiceAccountPassword))
+		}
+	}
+	sort.Slice(doc.ServiceAccountPasswords, func(i, j int) bool {
+		return doc.ServiceAccountPasswords[i].UUID < doc.ServiceAccountPasswords[j].UUID
+	})
+
+	return doc, nil
+}
+
+// Import brings the tenant to the state of the document: objects of the document are created or updated,
+// objects of the tenant absent in the document are deleted. Usecases of each type are used, so all validations
+// and cascades of the usual API are applied. Votes of approvals and decisions of access requests are not imported,
+// they are collected only through the API. Credentials are not imported: stored ones are kept, or deleted if they are
+// absent in the document, new ones are skipped, as secrets are never taken from the document, they are issued
+// only through the API.
+// The caller is responsible for committing or aborting the transaction, an error means the document is not applied
+func (s *TenantDocumentService) Import(doc *TenantDocument) error {
+	tenantUUID := doc.Tenant.UUID
+	if tenantUUID == "" {
+		return fmt.Errorf("%w: tenant.uuid is required", consts.ErrInvalidArg)
+	}
+	if err := checkDocumentTenantUUIDs(doc); err != nil {
+		return err
+	}
+	groups, err := sortGroupsByMembers(doc.Groups)
+	if err != nil {
+		return err
+	}
+	if err := s.deleteAbsent(doc); err != nil {
+		return err
+	}
+	if err := s.importTenant(doc.Tenant); err != nil {
+		return fmt.Errorf("tenant %s:%w", tenantUUID, err)
+	}
+	for _, u := range doc.Users {
+		if err := s.importUser(u); err != nil {
+			return fmt.Errorf("user %s:%w", u.UUID, err)
+		}
+	}
+	for _, sa := range doc.ServiceAccounts {
+		if err := s.importServiceAccount(sa); err != nil {
+			return fmt.Errorf("service_account %s:%w", sa.UUID, err)
+		}
+	}
+	for _, p := range doc.Projects {
+		if err := s.importProject(p); err != nil {
+			return fmt.Errorf("project %s:%w", p.UUID, err)
+		}
+	}
+	for _, g := range groups {
+		if err := s.importGroup(g); err != nil {
+			return fmt.Errorf("group %s:%w", g.UUID, err)
+		}
+	}
+	for _, rb := range doc.RoleBindings {
+		if err := s.importRoleBinding(rb); err != nil {
+			return fmt.Errorf("rolebinding %s:%w", rb.UUID, err)
+		}
+	}
+	for _, a := range doc.RoleBindingApprovals {
+		if err := s.importRoleBindingApproval(a); err != nil {
+			return fmt.Errorf("rolebinding_approval %s:%w", a.UUID, err)
+		}
+	}
+	for _, is := range doc.IdentitySharings {
+		if err := s.importIdentitySharing(is); err != nil {
+			return fmt.Errorf("identity_sharing %s:%w", is.UUID, err)
+		}
+	}
+	for _, ar := range doc.AccessRequests {
+		if err := s.importAccessRequest(ar); err != nil {
+			return fmt.Errorf("access_request %s:%w", ar.UUID, err)
+		}
+	}
+	return nil
+}
+
+// deleteAbsent deletes objects of the stored tenant, which are absent in the document, dependent objects go first.
+// Objects archived by cascades of previous deletions are skipped
+func (s *TenantDocumentService) deleteAbsent(doc *TenantDocument) error {
+	tenantUUID := doc.Tenant.UUID
+	tenant, err := s.tenantRepo.GetByID(tenantUUID)
+	if errors.Is(err, consts.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if tenant.Archived() {
+		return nil
+	}
+	stored, err := s.Export(tenantUUID)
+	if err != nil {
+		return err
+	}
+	present := map[string]struct{}{}
+	for _, o := range doc.Users {
+		present[o.UUID] = struct{}{}
+	}
+	for _, o := range doc.ServiceAccounts {
+		present[o.UUID] = struct{}{}
+	}
+	for _, o := range doc.Projects {
+		present[o.UUID] = struct{}{}
+	}
+	for _, o := range doc.Groups {
+		present[o.UUID] = struct{}{}
+	}
+	for _, o := range doc.RoleBindings {
+		present[o.UUID] = struct{}{}
+	}
+	for _, o := range doc.RoleBindingApprovals {
+		present[o.UUID] = struct{}{}
+	}
+	for _, o := range doc.IdentitySharings {
+		present[o.UUID] = struct{}{}
+	}
+	for _, o := range doc.AccessRequests {
+		present[o.UUID] = struct{}{}
+	}
+	for _, o := range doc.Multipasses {
+		present[o.UUID] = struct{}{}
+	}
+	for _, o := range doc.ServiceAccountPasswords {
+		present[o.UUID] = struct{}{}
+	}
+	// deleteIfAbsent calls del for the object absent in the document and not archived yet
+	deleteIfAbsent := func(objType string, uuid string, getRaw func(string) (interface{}, error), del func() error) error {
+		if _, ok := present[uuid]; ok {
+			return nil
+		}
+		raw, err := getRaw(uuid)
+		if err != nil {
+			return fmt.Errorf("%s %s:%w", objType, uuid, err)
+		}
+		if obj, ok := raw.(interface{ Archived() bool }); ok && obj.Archived() {
+			return nil
+		}
+		if err = del(); err != nil {
+			return fmt.Errorf("deleting %s %s:%w", objType, uuid, err)
+		}
+		return nil
+	}
+	for _, o := range stored.AccessRequests {
+		o := o
+		if err = deleteIfAbsent(model.AccessRequestType, o.UUID, s.accessRequestRepo.GetRawByID, func() error {
+			return AccessRequests(s.db).Delete(tenantUUID, o.UUID)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, o := range stored.RoleBindingApprovals {
+		o := o
+		if err = deleteIfAbsent(model.RoleBindingApprovalType, o.UUID, s.approvalRepo.GetRawByID, func() error {
+			return RoleBindingApprovals(s.db).Delete(o.UUID)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, o := range stored.IdentitySharings {
+		o := o
+		if err = deleteIfAbsent(model.IdentitySharingType, o.UUID, s.sharingRepo.GetRawByID, func() error {
+			return IdentityShares(s.db, o.Origin).Delete(o.UUID)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, o := range stored.RoleBindings {
+		o := o
+		if err = deleteIfAbsent(model.RoleBindingType, o.UUID, s.roleBindingRepo.GetRawByID, func() error {
+			return RoleBindings(s.db).Delete(o.Origin, o.UUID)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, o := range stored.Groups {
+		o := o
+		if err = deleteIfAbsent(model.GroupType, o.UUID, s.groupRepo.GetRawByID, func() error {
+			return Groups(s.db, tenantUUID, o.Origin).Delete(o.UUID)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, o := range stored.Projects {
+		o := o
+		if err = deleteIfAbsent(model.ProjectType, o.UUID, s.projectRepo.GetRawByID, func() error {
+			return Projects(s.db, o.Origin).Delete(o.UUID)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, o := range stored.Multipasses {
+		o := o
+		if err = deleteIfAbsent(model.MultipassType, o.UUID, s.multipassRepo.GetRawByID, func() error {
+			return Multipasses(s.db, o.Origin, o.OwnerType, tenantUUID, o.OwnerUUID).Delete(o.UUID)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, o := range stored.ServiceAccountPasswords {
+		o := o
+		if err = deleteIfAbsent(model.ServiceAccountPasswordType, o.UUID, s.saPasswordRepo.GetRawByID, func() error {
+			return ServiceAccountPasswords(s.db, tenantUUID, o.OwnerUUID).Delete(o.UUID)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, o := range stored.ServiceAccounts {
+		o := o
+		if err = deleteIfAbsent(model.ServiceAccountType, o.UUID, s.serviceAccountRepo.GetRawByID, func() error {
+			return ServiceAccounts(s.db, o.Origin, tenantUUID).Delete(o.UUID)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, o := range stored.Users {
+		o := o
+		if err = deleteIfAbsent(model.UserType, o.UUID, s.userRepo.GetRawByID, func() error {
+			return Users(s.db, tenantUUID, o.Origin).Delete(o.UUID)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkDocumentTenantUUIDs(doc *TenantDocument) error {
+	tenantUUID := doc.Tenant.UUID
+	check := func(objType string, uuid string, objTenantUUID model.TenantUUID) error {
+		if uuid == "" {
+			return fmt.Errorf("%w: %s without uuid", consts.ErrInvalidArg, objType)
+		}
+		if objTenantUUID != tenantUUID {
+			return fmt.Errorf("%w: %s %s belongs to tenant %q, expected %q", consts.ErrInvalidArg,
+				objType, uuid, objTenantUUID, tenantUUID)
+		}
+		return nil
+	}
+	for _, o := range doc.Users {
+		if err := check(model.UserType, o.UUID, o.TenantUUID); err != nil {
+			return err
+		}
+	}
+	for _, o := range doc.ServiceAccounts {
+		if err := check(model.ServiceAccountType, o.UUID, o.TenantUUID); err != nil {
+			return err
+		}
+	}
+	for _, o := range doc.Projects {
+		if err := check(model.ProjectType, o.UUID, o.TenantUUID); err != nil {
+			return err
+		}
+	}
+	for _, o := range doc.Groups {
+		if err := check(model.GroupType, o.UUID, o.TenantUUID); err != nil {
+			return err
+		}
+	}
+	for _, o := range doc.RoleBindings {
+		if err := check(model.RoleBindingType, o.UUID, o.TenantUUID); err != nil {
+			return err
+		}
+	}
+	for _, o := range doc.RoleBindingApprovals {
+		if err := check(model.RoleBindingApprovalType, o.UUID, o.TenantUUID); err != nil {
+			return err
+		}
+	}
+	for _, o := range doc.IdentitySharings {
+		if err := check(model.IdentitySharingType, o.UUID, o.SourceTenantUUID); err != nil {
+			return err
+		}
+	}
+	for _, o := range doc.AccessRequests {
+		if err := check(model.AccessRequestType, o.UUID, o.TenantUUID); err != nil {
+			return err
+		}
+	}
+	for _, o := range doc.Multipasses {
+		if err := check(model.MultipassType, o.UUID, o.TenantUUID); err != nil {
+			return err
+		}
+	}
+	for _, o := range doc.ServiceAccountPasswords {
+		if err := check(model.ServiceAccountPasswordType, o.UUID, o.TenantUUID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortGroupsByMembers returns groups ordered in the way, that member groups from the document precede their parents
+func sortGroupsByMembers(groups []model.Group) ([]model.Group, error) {
+	byUUID := map[model.GroupUUID]model.Group{}
+	for _, g := range groups {
+		byUUID[g.UUID] = g
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[model.GroupUUID]int{}
+	result := make([]model.Group, 0, len(groups))
+	var visit func(g model.Group) error
+	visit = func(g model.Group) error {
+		switch state[g.UUID] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: groups cycle at group %s", consts.ErrInvalidArg, g.UUID)
+		}
+		state[g.UUID] = visiting
+		for _, m := range g.Members {
+			if m.Type != model.GroupType {
+				continue
+			}
+			if child, ok := byUUID[m.UUID]; ok {
+				if err := visit(child); err != nil {
+					return err
+				}
+			}
+		}
+		state[g.UUID] = visited
+		result = append(result, g)
+		return nil
+	}
+	for _, g := range groups {
+		if err := visit(g); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func originOrIAM(origin consts.ObjectOrigin) consts.ObjectOrigin {
+	if origin == "" {
+		return consts.OriginIAM
+	}
+	return origin
+}
+
+func (s *TenantDocumentService) importTenant(tenant model.Tenant) error {
+	service := Tenants(s.db, originOrIAM(tenant.Origin))
+	stored, err := s.tenantRepo.GetByID(tenant.UUID)
+	if errors.Is(err, consts.ErrNotFound) {
+		return service.Create(&tenant)
+	}
+	if err != nil {
+		return err
+	}
+	tenant.Version = stored.Version
+	return service.Update(&tenant)
+}
+
+func (s *TenantDocumentService) importUser(user model.User) error {
+	service := Users(s.db, user.TenantUUID, originOrIAM(user.Origin))
+	stored, err := s.userRepo.GetByID(user.UUID)
+	user.Extensions = nil
+	if errors.Is(err, consts.ErrNotFound) {
+		return service.Create(&user)
+	}
+	if err != nil {
+		return err
+	}
+	user.Version = stored.Version
+	return service.Update(&user)
+}
+
+func (s *TenantDocumentService) importServiceAccount(sa model.ServiceAccount) error {
+	sa.Origin = originOrIAM(sa.Origin)
+	sa.Extensions = nil
+	service := ServiceAccounts(s.db, sa.Origin, sa.TenantUUID)
+	stored, err := s.serviceAccountRepo.GetByID(sa.UUID)
+	if errors.Is(err, consts.ErrNotFound) {
+		sa.Version = ""
+		return service.Create(&sa)
+	}
+	if err != nil {
+		return err
+	}
+	sa.Version = stored.Version
+	return service.Update(&sa)
+}
+
+func (s *TenantDocumentService) importProject(project model.Project) error {
+	service := Projects(s.db, originOrIAM(project.Origin))
+	project.Extensions = nil
+	stored, err := s.projectRepo.GetByID(project.UUID)
+	if errors.Is(err, consts.ErrNotFound) {
+		return service.Create(&project)
+	}
+	if err != nil {
+		return err
+	}
+	project.Version = stored.Version
+	return service.Update(&project)
+}
+
+func (s *TenantDocumentService) importGroup(group model.Group) error {
+	service := Groups(s.db, group.TenantUUID, originOrIAM(group.Origin))
+	group.Extensions = nil
+	stored, err := s.groupRepo.GetByID(group.UUID)
+	if errors.Is(err, consts.ErrNotFound) {
+		group.Version = ""
+		return service.Create(&group)
+	}
+	if err != nil {
+		return err
+	}
+	group.Version = stored.Version
+	return service.Update(&group)
+}
+
+func (s *TenantDocumentService) importRoleBinding(rb model.RoleBinding) error {
+	rb.Origin = originOrIAM(rb.Origin)
+	rb.Extensions = nil
+	service := RoleBindings(s.db)
+	stored, err := s.roleBindingRepo.GetByID(rb.UUID)
+	if errors.Is(err, consts.ErrNotFound) {
+		rb.Version = ""
+		_, err = service.Create(&rb)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	rb.Version = stored.Version
+	_, err = service.Update(&rb)
+	return err
+}
+
+func (s *TenantDocumentService) importRoleBindingApproval(approval model.RoleBindingApproval) error {
+	service := RoleBindingApprovals(s.db)
+	stored, err := s.approvalRepo.GetByID(approval.UUID)
+	if errors.Is(err, consts.ErrNotFound) {
+		approval.Version = ""
+		return service.Create(&approval)
+	}
+	if err != nil {
+		return err
+	}
+	approval.Version = stored.Version
+	return service.Update(&approval)
+}
+
+func (s *TenantDocumentService) importIdentitySharing(is model.IdentitySharing) error {
+	service := IdentityShares(s.db, originOrIAM(is.Origin))
+	stored, err := s.sharingRepo.GetByID(is.UUID)
+	if errors.Is(err, consts.ErrNotFound) {
+		_, err = service.Create(&is)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	is.Version = stored.Version
+	_, err = service.Update(&is)
+	return err
+}
+
+// importAccessRequest keeps the stored access request, its state is changed only by deciding through the API,
+// the new one is stored as is, its rolebinding and approval should be the part of the document
+func (s *TenantDocumentService) importAccessRequest(ar model.AccessRequest) error {
+	_, err := s.accessRequestRepo.GetByID(ar.UUID)
+	if err == nil || !errors.Is(err, consts.ErrNotFound) {
+		return err
+	}
+	if _, err = s.roleBindingRepo.GetByID(ar.RoleBindingUUID); err != nil {
+		return fmt.Errorf("rolebinding %q:%w", ar.RoleBindingUUID, err)
+	}
+	if _, err = s.approvalRepo.GetByID(ar.ApprovalUUID); err != nil {
+		return fmt.Errorf("rolebinding_approval %q:%w", ar.ApprovalUUID, err)
+	}
+	ar.Version = iam_repo.NewResourceVersion()
+	return s.accessRequestRepo.Create(&ar)
+}
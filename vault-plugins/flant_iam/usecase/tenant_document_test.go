@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
+	"github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+)
+
+func tenantDocument() *TenantDocument {
+	return &TenantDocument{
+		Tenant: model.Tenant{UUID: fixtures.TenantUUID1, Identifier: "tenant1"},
+		Users: []model.User{
+			{UUID: fixtures.UserUUID1, TenantUUID: fixtures.TenantUUID1, Identifier: "vasya", Email: "vasya@example.com"},
+			{UUID: fixtures.UserUUID2, TenantUUID: fixtures.TenantUUID1, Identifier: "petya", Email: "petya@example.com"},
+		},
+		ServiceAccounts: []model.ServiceAccount{
+			{UUID: fixtures.ServiceAccountUUID1, TenantUUID: fixtures.TenantUUID1, Identifier: "ci"},
+		},
+		Projects: []model.Project{
+			{UUID: fixtures.ProjectUUID1, TenantUUID: fixtures.TenantUUID1, Identifier: "main"},
+		},
+		// parent group goes first, to check ordering at import
+		Groups: []model.Group{
+			{
+				UUID: fixtures.GroupUUID2, TenantUUID: fixtures.TenantUUID1, Identifier: "parent",
+				Members: []model.MemberNotation{
+					{Type: model.GroupType, UUID: fixtures.GroupUUID1},
+					{Type: model.ServiceAccountType, UUID: fixtures.ServiceAccountUUID1},
+				},
+			},
+			{
+				UUID: fixtures.GroupUUID1, TenantUUID: fixtures.TenantUUID1, Identifier: "child",
+				Members: []model.MemberNotation{{Type: model.UserType, UUID: fixtures.UserUUID1}},
+			},
+		},
+		RoleBindings: []model.RoleBinding{
+			{
+				UUID: fixtures.RbUUID1, TenantUUID: fixtures.TenantUUID1, Description: "rb1",
+				Members:  []model.MemberNotation{{Type: model.GroupType, UUID: fixtures.GroupUUID2}},
+				Projects: []model.ProjectUUID{fixtures.ProjectUUID1},
+				Roles:    []model.BoundRole{{Name: fixtures.RoleName1, Options: map[string]interface{}{}}},
+			},
+		},
+	}
+}
+
+// exportImported imports the document into the new store and returns its export passed through serialization
+func exportImported(t *testing.T, doc *TenantDocument) *TenantDocument {
+	tx := RunFixtures(t, RoleFixture).Txn(true)
+	require.NoError(t, TenantDocuments(tx).Import(doc))
+	exported, err := TenantDocuments(tx).Export(doc.Tenant.UUID)
+	require.NoError(t, err)
+	data, err := json.Marshal(exported)
+	require.NoError(t, err)
+	result := &TenantDocument{}
+	require.NoError(t, json.Unmarshal(data, result))
+	return result
+}
+
+func Test_TenantDocumentExportImport(t *testing.T) {
+	doc := exportImported(t, tenantDocument())
+	require.Len(t, doc.Users, 2)
+	require.Len(t, doc.Groups, 2)
+	require.Len(t, doc.RoleBindings, 1)
+	require.Equal(t, []model.GroupUUID{fixtures.GroupUUID2}, doc.RoleBindings[0].Groups)
+
+	imported := exportImported(t, doc)
+
+	require.Equal(t, doc.Tenant.Identifier, imported.Tenant.Identifier)
+	require.Len(t, imported.Users, len(doc.Users))
+	require.Len(t, imported.ServiceAccounts, len(doc.ServiceAccounts))
+	require.Len(t, imported.Projects, len(doc.Projects))
+	require.Len(t, imported.Groups, len(doc.Groups))
+	require.Len(t, imported.RoleBindings, len(doc.RoleBindings))
+	for i := range doc.Groups {
+		require.Equal(t, doc.Groups[i].FullIdentifier, imported.Groups[i].FullIdentifier)
+		require.ElementsMatch(t, doc.Groups[i].Members, imported.Groups[i].Members)
+	}
+}
+
+func Test_TenantDocumentReimportUpdates(t *testing.T) {
+	doc := tenantDocument()
+	tx := RunFixtures(t, RoleFixture).Txn(true)
+	require.NoError(t, TenantDocuments(tx).Import(doc))
+	doc = tenantDocument()
+	doc.Users[0].FirstName = "Updated"
+
+	err := TenantDocuments(tx).Import(doc)
+
+	require.NoError(t, err)
+	user, err := Users(tx, fixtures.TenantUUID1, consts.OriginIAM).GetByID(fixtures.UserUUID1)
+	require.NoError(t, err)
+	require.Equal(t, "Updated", user.FirstName)
+}
+
+func Test_TenantDocumentImportWrongTenant(t *testing.T) {
+	doc := tenantDocument()
+	doc.Users[0].TenantUUID = fixtures.TenantUUID2
+	tx := RunFixtures(t, RoleFixture).Txn(true)
+
+	err := TenantDocuments(tx).Import(doc)
+
+	require.ErrorIs(t, err, consts.ErrInvalidArg)
+}
+
+func Test_TenantDocumentImportGroupsCycle(t *testing.T) {
+	doc := tenantDocument()
+	doc.Groups[1].Members = append(doc.Groups[1].Members,
+		model.MemberNotation{Type: model.GroupType, UUID: fixtures.GroupUUID2})
+	tx := RunFixtures(t, RoleFixture).Txn(true)
+
+	err := TenantDocuments(tx).Import(doc)
+
+	require.ErrorIs(t, err, consts.ErrInvalidArg)
+}
+
+func Test_TenantDocumentImportSkipsNewCredentials(t *testing.T) {
+	doc := tenantDocument()
+	doc.Multipasses = []model.Multipass{{
+		UUID: "00000000-0000-4000-a000-000000000001", TenantUUID: fixtures.TenantUUID1, OwnerUUID: fixtures.UserUUID1,
+		OwnerType: model.MultipassOwnerUser, Salt: "planted",
+	}}
+	doc.ServiceAccountPasswords = []model.ServiceAccountPassword{{
+		UUID: "00000000-0000-4000-a000-000000000002", TenantUUID: fixtures.TenantUUID1,
+		OwnerUUID: fixtures.ServiceAccountUUID1, Secret: "planted",
+	}}
+	tx := RunFixtures(t, RoleFixture).Txn(true)
+
+	err := TenantDocuments(tx).Import(doc)
+
+	require.NoError(t, err)
+	exported, err := TenantDocuments(tx).Export(fixtures.TenantUUID1)
+	require.NoError(t, err)
+	require.Empty(t, exported.Multipasses)
+	require.Empty(t, exported.ServiceAccountPasswords)
+}
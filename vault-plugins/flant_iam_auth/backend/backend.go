@@ -31,6 +31,7 @@ import (
 	njwt "github.com/flant/negentropy/vault-plugins/shared/jwt"
 	jwtkafka "github.com/flant/negentropy/vault-plugins/shared/jwt/kafka"
 	"github.com/flant/negentropy/vault-plugins/shared/kafka"
+	"github.com/flant/negentropy/vault-plugins/shared/metrics"
 	"github.com/flant/negentropy/vault-plugins/shared/openapi"
 )
 
@@ -130,7 +131,7 @@ func backend(conf *logical.BackendConfig, jwksIDGetter func() (string, error)) (
 
 	if backentutils.IsLoading(conf) {
 		logger.Info("final run Factory, apply kafka operations on MemoryStore")
-		storage.AddKafkaSource(kafka_source.NewRootKafkaSource(mb, root.NewObjectHandler(conf.Logger), conf.Logger))
+		storage.AddKafkaSource(kafka_source.NewRootKafkaSource(conf.StorageView, mb, root.NewObjectHandler(conf.Logger), conf.Logger))
 		storage.AddKafkaSource(kafka_source.NewSelfKafkaSource(mb, self.NewObjectHandler(entityApi, conf.Logger), conf.Logger))
 		storage.AddKafkaSource(jwtkafka.NewJWKSKafkaSource(conf.StorageView, mb, conf.Logger))
 		storage.AddKafkaSource(kafka_source.NewMultipassGenerationSource(conf.StorageView, mb, conf.Logger))
@@ -208,6 +209,14 @@ func backend(conf *logical.BackendConfig, jwksIDGetter func() (string, error)) (
 		return allErrors
 	}
 
+	metrics.SetCollector("jwks", func() {
+		txn := b.storage.Txn(false)
+		defer txn.Abort()
+		if err := b.jwtController.CollectMetrics(txn); err != nil {
+			logger.Warn("collecting jwks metrics", "error", err.Error())
+		}
+	})
+
 	b.authnFactory = factory2.NewAuthenticatorFactory(b.jwtController, conf.Logger)
 
 	b.serverAccessBackend = extension_server_access.NewServerAccessBackend(b, storage)
@@ -240,6 +249,7 @@ func backend(conf *logical.BackendConfig, jwksIDGetter func() (string, error)) (
 				pathLogin(b),
 				pathCheckPermissions(b),
 				pathCheckEffectiveRoles(b),
+				pathExplainAccess(b),
 				pathVSTOwner(b),
 				pathJwtType(b),
 				pathJwtTypeList(b),
@@ -259,6 +269,8 @@ func backend(conf *logical.BackendConfig, jwksIDGetter func() (string, error)) (
 			},
 			pathOIDC(b),
 			kafkaPaths(b, storage, conf.Logger),
+			sharedio.DeadLetterPaths(storage),
+			sharedio.MetricsPaths(storage),
 
 			// server_access_extension
 			b.serverAccessBackend.Paths(),
@@ -0,0 +1,33 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_LoginAttempts(t *testing.T) {
+	b, storage := getBackend(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login",
+		Storage:   storage,
+		Data:      map[string]interface{}{"method": "not-existed"},
+	})
+	require.NoError(t, err)
+	require.True(t, resp.IsError())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "metrics",
+		Storage:   storage,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "text/plain; version=0.0.4", resp.Data[logical.HTTPContentType])
+	body := string(resp.Data[logical.HTTPRawBody].([]byte))
+	require.Contains(t, body, `negentropy_login_attempts_total{method="",method_type="",outcome="unknown_method"}`)
+	require.Contains(t, body, `negentropy_memdb_rows{table="auth_method"} 0`)
+}
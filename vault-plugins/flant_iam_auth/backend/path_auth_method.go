@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/cidrutil"
 	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/helper/tokenutil"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -125,6 +126,12 @@ in OIDC responses.`,
 				Description: `Specifies the allowable elapsed time in seconds since the last time the 
 user was actively authenticated.`,
 			},
+			"trusted_proxy_cidrs": {
+				Type: framework.TypeCommaStringSlice,
+				Description: `Comma-separated list of networks of proxies, which pass the client address 
+in the X-Forwarded-For header, the address is used for checking conditions of rolebindings. 
+The header should be passed through by the passthrough_request_headers option of the mount.`,
+			},
 		},
 		ExistenceCheck: b.pathAuthMethodExistenceCheck,
 		Operations: map[logical.Operation]framework.OperationHandler{
@@ -223,6 +230,7 @@ func (b *flantIamAuthBackend) pathAuthMethodRead(ctx context.Context, req *logic
 		"expiration_leeway":     int64(method.ExpirationLeeway.Seconds()),
 		"not_before_leeway":     int64(method.NotBeforeLeeway.Seconds()),
 		"clock_skew_leeway":     int64(method.ClockSkewLeeway.Seconds()),
+		"trusted_proxy_cidrs":   method.TrustedProxyCIDRs,
 	}
 
 	method.PopulateTokenData(d)
@@ -367,6 +375,13 @@ func (b *flantIamAuthBackend) pathAuthMethodCreateUpdate(ctx context.Context, re
 		return errResponse, err
 	}
 
+	if trustedProxyCIDRs, ok := data.GetOk("trusted_proxy_cidrs"); ok {
+		if _, err := cidrutil.ValidateCIDRListSlice(trustedProxyCIDRs.([]string)); err != nil {
+			return logical.ErrorResponse("invalid 'trusted_proxy_cidrs': %s", err.Error()), nil
+		}
+		method.TrustedProxyCIDRs = trustedProxyCIDRs.([]string)
+	}
+
 	resp := &logical.Response{}
 	if method.TokenMaxTTL > b.System().MaxLeaseTTL() {
 		resp.AddWarning("token max ttl is greater than the system or backend mount's maximum TTL value; issued tokens' max TTL value will be truncated")
@@ -30,6 +30,10 @@ func pathCheckPermissions(b *flantIamAuthBackend) *framework.Path {
 				Type:        framework.TypeSlice,
 				Description: "Requested roles",
 			},
+			"source_addr": {
+				Type:        framework.TypeString,
+				Description: "IP address of the subject login, it is checked by source_cidrs conditions of rolebindings, if not passed, the address of the request is used",
+			},
 		},
 
 		Operations: map[logical.Operation]framework.OperationHandler{
@@ -80,6 +84,7 @@ func (b *flantIamAuthBackend) сheckPermissionsHandler(ctx context.Context, req
 	}
 
 	authorizator := authz2.NewAutorizator(txn, b.accessVaultClientProvider, b.accessorGetter, logger)
+	authorizator.RemoteAddr = subjectAddr(req, d, txn, methodName, true)
 	return logical.RespondWithStatusCode(&logical.Response{
 		Data: map[string]interface{}{
 			"permissions": authorizator.CheckPermissions(methodName, *subject, roleClaims),
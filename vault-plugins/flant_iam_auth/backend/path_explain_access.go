@@ -0,0 +1,183 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	iam_usecase "github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
+	model2 "github.com/flant/negentropy/vault-plugins/flant_iam_auth/model"
+	repo2 "github.com/flant/negentropy/vault-plugins/flant_iam_auth/repo"
+	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/usecase/authn"
+	authz2 "github.com/flant/negentropy/vault-plugins/flant_iam_auth/usecase/authz"
+	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+// tenantManageRole allows to explain access of other subjects of the tenant
+const tenantManageRole = "tenant.manage"
+
+func pathExplainAccess(b *flantIamAuthBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: `explain_access$`,
+		Fields: map[string]*framework.FieldSchema{
+			"method": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "The auth method.",
+				Required:    true,
+			},
+			"roles": {
+				Type:        framework.TypeSlice,
+				Description: "Requested roles",
+			},
+			"subject_type": {
+				Type:          framework.TypeString,
+				Description:   "Type of the explained subject, if not passed, owner of the request token is used",
+				AllowedValues: []interface{}{iam.UserType, iam.ServiceAccountType},
+			},
+			"subject_uuid": {
+				Type:        framework.TypeNameString,
+				Description: "ID of the explained subject, if not passed, owner of the request token is used, other subjects are explained only for owners of tenant.manage at the tenant of the subject",
+			},
+			"source_addr": {
+				Type:        framework.TypeString,
+				Description: "IP address of the subject login, it is checked by source_cidrs conditions of rolebindings, if not passed and the subject is the owner of the request token, the address of the request is used",
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			// needs Create or Update for passing data, otherwise vault cuts any request body
+			logical.CreateOperation: &framework.PathOperation{
+				Callback: b.explainAccessHandler,
+				Summary:  "Explain authorization of requested roles without issuing token",
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.explainAccessHandler,
+				Summary:  "Explain authorization of requested roles without issuing token",
+			},
+		},
+	}
+}
+
+func (b *flantIamAuthBackend) explainAccessHandler(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	logger := b.NamedLogger("ExplainAccess")
+
+	// auth_method
+	methodName := d.Get("method").(string)
+	if methodName == "" {
+		return backentutils.ResponseErr(req, fmt.Errorf("%w:missing method", consts.ErrInvalidArg))
+	}
+
+	// collect role_claims
+	roleClaims, err := getRoleClaims(d)
+	if err != nil {
+		return backentutils.ResponseErr(req, fmt.Errorf("%w:parsing roles:%s", consts.ErrInvalidArg, err.Error()))
+	}
+
+	txn := b.storage.Txn(false)
+	defer txn.Abort()
+
+	subject, err := b.explainedSubject(req, d, txn)
+	if err != nil {
+		logger.Error(err.Error())
+		return backentutils.ResponseErr(req, err)
+	}
+
+	authorizator := authz2.NewAutorizator(txn, b.accessVaultClientProvider, b.accessorGetter, logger)
+	authorizator.RemoteAddr = subjectAddr(req, d, txn, methodName, d.Get("subject_uuid").(string) == "")
+	return logical.RespondWithStatusCode(&logical.Response{
+		Data: map[string]interface{}{
+			"subject":      subject,
+			"explanations": authorizator.ExplainAccess(txn, methodName, *subject, roleClaims),
+		},
+	}, req, http.StatusOK)
+}
+
+// explainedSubject returns subject passed in the request, or the owner of the request token,
+// access of other subjects is explained only for owners of the tenantManageRole at the tenant of the subject
+func (b *flantIamAuthBackend) explainedSubject(req *logical.Request, d *framework.FieldData,
+	txn *io.MemoryStoreTxn) (*model2.Subject, error) {
+	entityIDOwner, err := b.entityIDResolver.RevealEntityIDOwner(req.EntityID, txn, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	requestOwner, err := buildSubject(*entityIDOwner)
+	if err != nil {
+		return nil, err
+	}
+	subjectUUID := d.Get("subject_uuid").(string)
+	if subjectUUID == "" || subjectUUID == requestOwner.UUID {
+		return requestOwner, nil
+	}
+	subject, err := passedSubject(d.Get("subject_type").(string), subjectUUID, txn)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkTenantManager(*requestOwner, subject.TenantUUID, txn); err != nil {
+		return nil, err
+	}
+	return subject, nil
+}
+
+func passedSubject(subjectType string, subjectUUID string, txn *io.MemoryStoreTxn) (*model2.Subject, error) {
+	var owner interface{}
+	var err error
+	switch subjectType {
+	case iam.UserType:
+		owner, err = iam_repo.NewUserRepository(txn).GetByID(subjectUUID)
+	case iam.ServiceAccountType:
+		owner, err = iam_repo.NewServiceAccountRepository(txn).GetByID(subjectUUID)
+	default:
+		return nil, fmt.Errorf("%w:wrong subject_type:%q", consts.ErrInvalidArg, subjectType)
+	}
+	if err != nil {
+		if errors.Is(err, consts.ErrNotFound) {
+			return nil, fmt.Errorf("%w:subject %s", consts.ErrNotFound, subjectUUID)
+		}
+		return nil, err
+	}
+	return buildSubject(authn.EntityIDOwner{OwnerType: subjectType, Owner: owner})
+}
+
+// checkTenantManager checks the subject has the tenantManageRole at the tenant, rolebindings with pending approvals
+// or with conditions are not counted, as conditions are checked only at login
+func checkTenantManager(subject model2.Subject, tenantUUID iam.TenantUUID, txn *io.MemoryStoreTxn) error {
+	var effectiveRoles []iam_usecase.EffectiveRole
+	var err error
+	rolesResolver := iam_usecase.NewRoleResolver(txn)
+	switch subject.Type {
+	case iam.UserType:
+		_, effectiveRoles, err = rolesResolver.CheckUserForRolebindingsAtTenant(subject.UUID, tenantManageRole, tenantUUID)
+	case iam.ServiceAccountType:
+		_, effectiveRoles, err = rolesResolver.CheckServiceAccountForRolebindingsAtTenant(subject.UUID, tenantManageRole, tenantUUID)
+	default:
+		return fmt.Errorf("%w:wrong subject type:%q", consts.ErrInvalidArg, subject.Type)
+	}
+	if err != nil {
+		return err
+	}
+	for _, er := range effectiveRoles {
+		if er.NeedApprovals == 0 && er.Condition == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w:explaining access of other subjects requires %s at the tenant %s", consts.ErrAccessForbidden,
+		tenantManageRole, tenantUUID)
+}
+
+// subjectAddr returns passed source_addr, or the client address of the request, if the subject sent the request itself
+func subjectAddr(req *logical.Request, d *framework.FieldData, txn *io.MemoryStoreTxn, methodName string,
+	subjectIsRequestOwner bool) string {
+	if addr := d.Get("source_addr").(string); addr != "" || !subjectIsRequestOwner {
+		return addr
+	}
+	method, _ := repo2.NewAuthMethodRepo(txn).Get(methodName)
+	return clientAddr(req, method)
+}
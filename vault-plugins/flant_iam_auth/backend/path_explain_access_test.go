@@ -0,0 +1,34 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	iam_usecase "github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
+	model2 "github.com/flant/negentropy/vault-plugins/flant_iam_auth/model"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+)
+
+func Test_checkTenantManager(t *testing.T) {
+	tx := iam_usecase.RunFixtures(t, iam_usecase.TenantFixture, iam_usecase.UserFixture, iam_usecase.ServiceAccountFixture,
+		iam_usecase.GroupFixture, iam_usecase.ProjectFixture, iam_usecase.RoleFixture).Txn(true)
+	require.NoError(t, iam_repo.NewRoleRepository(tx).Create(&iam.Role{Name: tenantManageRole, Scope: iam.RoleScopeTenant}))
+	require.NoError(t, iam_repo.NewRoleBindingRepository(tx).Create(&iam.RoleBinding{
+		UUID:       "00000000-0000-4000-a000-000000000001",
+		TenantUUID: fixtures.TenantUUID1,
+		Users:      []iam.UserUUID{fixtures.UserUUID2},
+		Members:    []iam.MemberNotation{{Type: iam.UserType, UUID: fixtures.UserUUID2}},
+		Roles:      []iam.BoundRole{{Name: tenantManageRole}},
+		Origin:     consts.OriginIAM,
+	}))
+	manager := model2.Subject{Type: iam.UserType, UUID: fixtures.UserUUID2, TenantUUID: fixtures.TenantUUID1}
+	other := model2.Subject{Type: iam.UserType, UUID: fixtures.UserUUID3, TenantUUID: fixtures.TenantUUID1}
+
+	require.NoError(t, checkTenantManager(manager, fixtures.TenantUUID1, tx))
+	require.ErrorIs(t, checkTenantManager(manager, fixtures.TenantUUID2, tx), consts.ErrAccessForbidden)
+	require.ErrorIs(t, checkTenantManager(other, fixtures.TenantUUID1, tx), consts.ErrAccessForbidden)
+}
@@ -4,17 +4,25 @@ import (
 	"context"
 	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"math"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
 	"gopkg.in/square/go-jose.v2"
 
 	ext_model "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/model"
 	iam_model "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	auth_ext_model "github.com/flant/negentropy/vault-plugins/flant_iam_auth/extensions/extension_server_access/model"
 	"github.com/flant/negentropy/vault-plugins/shared/consts"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
 	"github.com/flant/negentropy/vault-plugins/shared/uuid"
@@ -53,7 +61,9 @@ func Test_ExtensionServer_PosixUsers(t *testing.T) {
 
 	type response struct {
 		Data struct {
-			PosixUsers []interface{} `json:"posix_users"`
+			PosixUsers      []interface{} `json:"posix_users"`
+			ResourceVersion string        `json:"resource_version"`
+			NotModified     bool          `json:"not_modified"`
 		} `json:"data"`
 	}
 
@@ -61,6 +71,262 @@ func Test_ExtensionServer_PosixUsers(t *testing.T) {
 	err = json.Unmarshal([]byte(resp.Data["http_raw_body"].(string)), &respData)
 	require.NoError(t, err)
 	assert.Len(t, respData.Data.PosixUsers, 2)
+	require.NotEmpty(t, respData.Data.ResourceVersion)
+
+	req.Data = map[string]interface{}{"resource_version": respData.Data.ResourceVersion}
+	resp, err = b.HandleRequest(context.Background(), req)
+	require.NoError(t, err)
+
+	var notModified response
+	err = json.Unmarshal([]byte(resp.Data["http_raw_body"].(string)), &notModified)
+	require.NoError(t, err)
+	assert.True(t, notModified.Data.NotModified)
+	assert.Empty(t, notModified.Data.PosixUsers)
+	assert.Equal(t, respData.Data.ResourceVersion, notModified.Data.ResourceVersion)
+}
+
+func Test_ExtensionServer_SSHSession(t *testing.T) {
+	b, storage := getBackend(t)
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ca, err := ssh.NewSignerFromKey(caKey)
+	require.NoError(t, err)
+	err = storage.Put(context.TODO(), &logical.StorageEntry{
+		Key:      "iam_auth.extensions.server_access.ssh_role",
+		Value:    []byte("ssh.open"),
+		SealWrap: true,
+	})
+	require.NoError(t, err)
+
+	tx := b.storage.Txn(true)
+	defer tx.Abort()
+
+	tenant, project, err := createTenantProject(tx)
+	require.NoError(t, err)
+	user, sa, err := createUserAndSa(tx, tenant)
+	require.NoError(t, err)
+	serverUUIDs, err := createServers(tx, tenant, project)
+	require.NoError(t, err)
+	err = createRoleAndRoleBinding(tx, tenant, user, sa)
+	require.NoError(t, err)
+	_ = tx.Commit()
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "configure_extension/server_access/ssh_session_recordings",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"store":             "vault",
+			"ssh_ca_public_key": string(ssh.MarshalAuthorizedKey(ca.PublicKey())),
+		},
+	})
+	require.NoError(t, err)
+
+	type response struct {
+		Warnings []string `json:"warnings"`
+		Data     struct {
+			SSHSession auth_ext_model.SSHSession `json:"ssh_session"`
+			Recording  string                    `json:"recording"`
+		} `json:"data"`
+	}
+	sessionUUID := uuid.New()
+	certificate := map[string]interface{}{
+		"serial":       "18446744073709551615",
+		"tenant_uuid":  tenant,
+		"project_uuid": project,
+		"server_uuids": []interface{}{serverUUIDs[0]},
+		"certificate":  signSSHCertificate(t, ca, math.MaxUint64, serverUUIDs[0], user),
+	}
+	// the recording is stored by several chunks
+	recording := "{\"version\": 2, \"width\": 80, \"height\": 24}\n" + strings.Repeat("[0.5, \"o\", \"ls\\r\\n\"]\n", 30000)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path.Join("ssh_session", sessionUUID),
+		Storage:   storage,
+		EntityID:  user,
+		Data: map[string]interface{}{
+			"started_at":   "2022-10-01T10:00:00Z",
+			"finished_at":  "2022-10-01T10:05:00Z",
+			"certificates": []interface{}{certificate},
+			"recording":    base64.StdEncoding.EncodeToString([]byte(recording)),
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.Data["http_status_code"])
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      path.Join("ssh_session", sessionUUID),
+		Storage:   storage,
+		Data:      map[string]interface{}{"with_recording": true},
+	})
+	require.NoError(t, err)
+
+	var respData response
+	err = json.Unmarshal([]byte(resp.Data["http_raw_body"].(string)), &respData)
+	require.NoError(t, err)
+	session := respData.Data.SSHSession
+	assert.Equal(t, user, session.OwnerUUID)
+	assert.Equal(t, "vasya@tenant1", session.OwnerFullIdentifier)
+	require.Len(t, session.Certificates, 1)
+	assert.Equal(t, "18446744073709551615", session.Certificates[0].Serial)
+	assert.Equal(t, []string{serverUUIDs[0]}, session.Certificates[0].ServerUUIDs)
+	assert.Equal(t, "2030-01-01T00:00:00Z", session.Certificates[0].ValidBefore.Format(time.RFC3339))
+	assert.Equal(t, "ssh.open", session.Certificates[0].Role)
+	assert.Len(t, session.Certificates[0].RoleBindingUUIDs, 1)
+	require.NotNil(t, session.Recording)
+	assert.Equal(t, len(recording), session.Recording.Size)
+	assert.Equal(t, 2, session.Recording.Chunks)
+	decoded, err := base64.StdEncoding.DecodeString(respData.Data.Recording)
+	require.NoError(t, err)
+	assert.Equal(t, recording, string(decoded))
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path.Join("ssh_session", sessionUUID),
+		Storage:   storage,
+		EntityID:  sa,
+		Data:      map[string]interface{}{"certificates": []interface{}{certificate}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 403, resp.Data["http_status_code"], "only the owner can report the session")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path.Join("ssh_session", sessionUUID),
+		Storage:   storage,
+		EntityID:  user,
+		Data: map[string]interface{}{
+			"finished_at": "2022-10-01T11:00:00Z",
+			"recording":   base64.StdEncoding.EncodeToString([]byte(recording)),
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 409, resp.Data["http_status_code"], "the finished session can't be rewritten")
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path.Join("ssh_session", uuid.New()),
+		Storage:   storage,
+		EntityID:  user,
+		Data:      map[string]interface{}{"recording": base64.StdEncoding.EncodeToString([]byte(recording))},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.Data["http_status_code"], "the recording is passed only with the finish")
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "configure_extension/server_access/ssh_session_recordings",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"store":             "vault",
+			"ssh_ca_public_key": string(ssh.MarshalAuthorizedKey(ca.PublicKey())),
+			"max_size":          1024,
+		},
+	})
+	require.NoError(t, err)
+	oversizedSessionUUID := uuid.New()
+	certificate["serial"] = "2"
+	certificate["certificate"] = signSSHCertificate(t, ca, 2, serverUUIDs[0], user)
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path.Join("ssh_session", oversizedSessionUUID),
+		Storage:   storage,
+		EntityID:  user,
+		Data: map[string]interface{}{
+			"finished_at":  "2022-10-01T10:05:00Z",
+			"certificates": []interface{}{certificate},
+			"recording":    base64.StdEncoding.EncodeToString([]byte(recording)),
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.Data["http_status_code"], "the finish is stored without the oversized recording")
+	var oversizedRespData response
+	err = json.Unmarshal([]byte(resp.Data["http_raw_body"].(string)), &oversizedRespData)
+	require.NoError(t, err)
+	assert.Len(t, oversizedRespData.Warnings, 1)
+	assert.Equal(t, "2022-10-01T10:05:00Z", oversizedRespData.Data.SSHSession.FinishedAt.Format(time.RFC3339))
+	assert.Len(t, oversizedRespData.Data.SSHSession.Certificates, 1)
+	assert.Nil(t, oversizedRespData.Data.SSHSession.Recording)
+	certificate["serial"] = "18446744073709551615"
+	certificate["certificate"] = signSSHCertificate(t, ca, math.MaxUint64, serverUUIDs[0], user)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path.Join("ssh_session", uuid.New()),
+		Storage:   storage,
+		EntityID:  user,
+		Data:      map[string]interface{}{"certificates": []interface{}{certificate}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.Data["http_status_code"], "the certificate is reported by another session")
+
+	certificate["serial"] = "1"
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path.Join("ssh_session", uuid.New()),
+		Storage:   storage,
+		EntityID:  user,
+		Data:      map[string]interface{}{"certificates": []interface{}{certificate}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.Data["http_status_code"], "the serial should match the certificate")
+
+	_, otherCA, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherSigner, err := ssh.NewSignerFromKey(otherCA)
+	require.NoError(t, err)
+	certificate["certificate"] = signSSHCertificate(t, otherSigner, 1, serverUUIDs[0], user)
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path.Join("ssh_session", uuid.New()),
+		Storage:   storage,
+		EntityID:  user,
+		Data:      map[string]interface{}{"certificates": []interface{}{certificate}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.Data["http_status_code"], "the certificate should be signed by the ssh ca")
+
+	certificate["certificate"] = signSSHCertificate(t, ca, 1, serverUUIDs[0], sa)
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path.Join("ssh_session", uuid.New()),
+		Storage:   storage,
+		EntityID:  user,
+		Data:      map[string]interface{}{"certificates": []interface{}{certificate}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.Data["http_status_code"], "the certificate should have the principal of the owner")
+
+	certificate["server_uuids"] = []interface{}{uuid.New()}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path.Join("ssh_session", uuid.New()),
+		Storage:   storage,
+		EntityID:  user,
+		Data:      map[string]interface{}{"certificates": []interface{}{certificate}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.Data["http_status_code"], "servers should exist")
+}
+
+func signSSHCertificate(t *testing.T, ca ssh.Signer, serial uint64, serverUUID string, ownerUUID string) string {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	publicKey, err := ssh.NewPublicKey(privateKey.Public())
+	require.NoError(t, err)
+	principal := sha256.Sum256([]byte(serverUUID + ownerUUID))
+	cert := &ssh.Certificate{
+		Key:             publicKey,
+		Serial:          serial,
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{hex.EncodeToString(principal[:])},
+		ValidAfter:      uint64(time.Date(2022, 10, 1, 10, 0, 0, 0, time.UTC).Unix()),
+		ValidBefore:     uint64(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Unix()),
+	}
+	require.NoError(t, cert.SignCert(rand.Reader, ca))
+	return string(ssh.MarshalAuthorizedKey(cert))
 }
 
 func createRoleAndRoleBinding(tx *io.MemoryStoreTxn, tenant iam_model.TenantUUID, user iam_model.UserUUID, sa iam_model.ServiceAccountUUID) error {
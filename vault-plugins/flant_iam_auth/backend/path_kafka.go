@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -21,17 +22,19 @@ import (
 
 type kafkaBackend struct {
 	logical.Backend
-	storage *sharedio.MemoryStore
-	broker  *kafka.MessageBroker
-	logger  hclog.Logger
+	storage    *sharedio.MemoryStore
+	broker     *kafka.MessageBroker
+	rewriteJob *sharedio.RewriteJob
+	logger     hclog.Logger
 }
 
 func kafkaPaths(b logical.Backend, storage *sharedio.MemoryStore, parentLogger hclog.Logger) []*framework.Path {
 	bb := kafkaBackend{
-		Backend: b,
-		storage: storage,
-		broker:  storage.GetKafkaBroker(),
-		logger:  parentLogger.Named("KafkaBackend"),
+		Backend:    b,
+		storage:    storage,
+		broker:     storage.GetKafkaBroker(),
+		rewriteJob: sharedio.NewRewriteJob(storage, parentLogger),
+		logger:     parentLogger.Named("KafkaBackend"),
 	}
 
 	configurePath := &framework.Path{
@@ -60,6 +63,11 @@ func kafkaPaths(b logical.Backend, storage *sharedio.MemoryStore, parentLogger h
 				Type:    framework.TypeBool,
 				Default: false,
 			},
+			"dead_letter_max_failures": {
+				Type:        framework.TypeInt,
+				Default:     0,
+				Description: "Count of failures of message processing, after which the message is moved to the dead letter topic, 0 turns it off. If omitted, the configured value is kept",
+			},
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.CreateOperation: &framework.PathOperation{
@@ -77,7 +85,46 @@ func kafkaPaths(b logical.Backend, storage *sharedio.MemoryStore, parentLogger h
 		},
 	}
 
-	return append(bb.broker.KafkaPaths(), configurePath)
+	reencryptPath := &framework.Path{
+		Pattern: "kafka/encryption_keys/reencrypt",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Summary:  "Start rewriting of all objects into topics under the primary encryption key",
+				Callback: bb.handleReencrypt,
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Summary:  "Read status of the last rewriting",
+				Callback: bb.handleReencryptStatus,
+			},
+		},
+	}
+
+	return append(bb.broker.KafkaPaths(), configurePath, reencryptPath)
+}
+
+func (kb kafkaBackend) handleReencrypt(_ context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	if !kb.broker.Configured() {
+		rr := logical.ErrorResponse("kafka is not configured")
+		return logical.RespondWithStatusCode(rr, req, http.StatusPreconditionFailed)
+	}
+	err := kb.rewriteJob.Start()
+	if errors.Is(err, sharedio.ErrRewriteIsRunning) {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusConflict)
+	}
+	if err != nil {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+	}
+	resp := &logical.Response{Data: map[string]interface{}{
+		"status": kb.rewriteJob.Status(),
+	}}
+	return logical.RespondWithStatusCode(resp, req, http.StatusAccepted)
+}
+
+func (kb kafkaBackend) handleReencryptStatus(_ context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	resp := &logical.Response{Data: map[string]interface{}{
+		"status": kb.rewriteJob.Status(),
+	}}
+	return logical.RespondWithStatusCode(resp, req, http.StatusOK)
 }
 
 func (kb kafkaBackend) handleKafkaConfiguration(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -153,6 +200,10 @@ func (kb kafkaBackend) handleKafkaConfiguration(ctx context.Context, req *logica
 		kb.broker.PluginConfig.PeersPublicKeys = peerKeys
 	}
 	kb.broker.PluginConfig.PublishQuotaUsage = data.Get("publish_quota_usage").(bool)
+	// the stored value is kept, if the field is omitted
+	if maxFailures, ok := data.GetOk("dead_letter_max_failures"); ok {
+		kb.broker.PluginConfig.DeadLetterMaxFailures = maxFailures.(int)
+	}
 
 	err = kb.broker.CreateTopic(ctx, kb.broker.PluginConfig.SelfTopicName, nil)
 	if err != nil {
@@ -188,17 +239,21 @@ func (kb kafkaBackend) handleKafkaConfiguration(ctx context.Context, req *logica
 func (kb kafkaBackend) handleKafkaReadConfiguration(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	cfg := kb.broker.PluginConfig
 	cfgResp := struct {
-		SelfTopicName     string   `json:"self_topic_name,omitempty"`
-		RootTopicName     string   `json:"root_topic_name,omitempty"`
-		RootPublicKey     string   `json:"root_public_key,omitempty"`
-		PeersPublicKeys   []string `json:"peers_public_keys,omitempty"`
-		PublishQuotaUsage bool     `json:"publish_quota,omitempty"`
+		SelfTopicName         string   `json:"self_topic_name,omitempty"`
+		RootTopicName         string   `json:"root_topic_name,omitempty"`
+		RootPublicKey         string   `json:"root_public_key,omitempty"`
+		RootPublicKeys        []string `json:"root_public_keys,omitempty"`
+		PeersPublicKeys       []string `json:"peers_public_keys,omitempty"`
+		PublishQuotaUsage     bool     `json:"publish_quota,omitempty"`
+		DeadLetterMaxFailures int      `json:"dead_letter_max_failures,omitempty"`
 	}{
-		SelfTopicName:     cfg.SelfTopicName,
-		RootTopicName:     cfg.RootTopicName,
-		RootPublicKey:     backentutils.ConvertToPem(cfg.RootPublicKey),
-		PeersPublicKeys:   backentutils.ConvertToPems(cfg.PeersPublicKeys),
-		PublishQuotaUsage: cfg.PublishQuotaUsage,
+		SelfTopicName:         cfg.SelfTopicName,
+		RootTopicName:         cfg.RootTopicName,
+		RootPublicKey:         backentutils.ConvertToPem(cfg.RootPublicKey),
+		RootPublicKeys:        backentutils.ConvertToPems(cfg.RootPublicKeys),
+		PeersPublicKeys:       backentutils.ConvertToPems(cfg.PeersPublicKeys),
+		PublishQuotaUsage:     cfg.PublishQuotaUsage,
+		DeadLetterMaxFailures: cfg.DeadLetterMaxFailures,
 	}
 
 	resp := &logical.Response{Data: map[string]interface{}{
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/sdk/framework"
@@ -16,6 +17,7 @@ import (
 	authz2 "github.com/flant/negentropy/vault-plugins/flant_iam_auth/usecase/authz"
 	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
 	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/metrics"
 )
 
 func pathLogin(b *flantIamAuthBackend) *framework.Path {
@@ -64,17 +66,37 @@ func pathLogin(b *flantIamAuthBackend) *framework.Path {
 	}
 }
 
+const (
+	loginOutcomeSuccess        = "success"
+	loginOutcomeInvalidRequest = "invalid_request"
+	loginOutcomeUnknownMethod  = "unknown_method"
+	loginOutcomeDeniedCIDR     = "denied_cidr"
+	loginOutcomeAuthnFailed    = "authn_failed"
+	loginOutcomeAuthzFailed    = "authz_failed"
+	loginOutcomeError          = "error"
+)
+
 func (b *flantIamAuthBackend) pathLogin(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	logger := b.NamedLogger("Login")
 
+	// method labels are filled only for existing methods, to not produce series for any passed name
+	var methodLabel, methodTypeLabel string
+	outcome := loginOutcomeError
+	defer func() {
+		metrics.IncrCounter("login_attempts_total", metrics.Label{Name: "method", Value: methodLabel},
+			metrics.Label{Name: "method_type", Value: methodTypeLabel}, metrics.Label{Name: "outcome", Value: outcome})
+	}()
+
 	methodName := d.Get("method").(string)
 
 	if methodName == "" {
+		outcome = loginOutcomeInvalidRequest
 		return backentutils.ResponseErr(req, fmt.Errorf("%w:missing method", consts.ErrInvalidArg))
 	}
 
 	roleClaims, err := getRoleClaims(d)
 	if err != nil {
+		outcome = loginOutcomeInvalidRequest
 		return nil, fmt.Errorf("%w:parsing roles:%s", consts.ErrInvalidArg, err.Error())
 	}
 
@@ -85,16 +107,20 @@ func (b *flantIamAuthBackend) pathLogin(ctx context.Context, req *logical.Reques
 		return nil, err
 	}
 	if method == nil {
+		outcome = loginOutcomeUnknownMethod
 		return logical.ErrorResponse("method %q could not be found", methodName), nil
 	}
+	methodLabel, methodTypeLabel = method.Name, method.MethodType
 
 	logger.Debug("Checking bound CIDR")
 	if len(method.TokenBoundCIDRs) > 0 {
 		if req.Connection == nil {
 			logger.Warn("token bound CIDRs found but no connection information available for validation")
+			outcome = loginOutcomeDeniedCIDR
 			return nil, logical.ErrPermissionDenied
 		}
 		if !cidrutil.RemoteAddrIsOk(req.Connection.RemoteAddr, method.TokenBoundCIDRs) {
+			outcome = loginOutcomeDeniedCIDR
 			return nil, logical.ErrPermissionDenied
 		}
 	}
@@ -109,15 +135,18 @@ func (b *flantIamAuthBackend) pathLogin(ctx context.Context, req *logical.Reques
 	authnRes, err := authenticator.Authenticate(ctx, d)
 	if err != nil {
 		logger.Error(fmt.Sprintf("Not authn, err: %v", err))
+		outcome = loginOutcomeAuthnFailed
 		return logical.ErrorResponse(err.Error()), logical.ErrPermissionDenied
 	}
 
 	authorizator := authz2.NewAutorizator(txn, b.accessVaultClientProvider, b.accessorGetter, logger)
+	authorizator.RemoteAddr = clientAddr(req, method)
 
 	logger.Debug("Start Authorize")
 	authzRes, err := authorizator.Authorize(authnRes, method, authSource, roleClaims)
 	if err != nil {
 		logger.Error(fmt.Sprintf("Not authz, err: %v", err))
+		outcome = loginOutcomeAuthzFailed
 		return logical.ErrorResponse(err.Error()), logical.ErrPermissionDenied
 	}
 
@@ -126,6 +155,7 @@ func (b *flantIamAuthBackend) pathLogin(ctx context.Context, req *logical.Reques
 	logger.Debug(fmt.Sprintf("Authorize successful! %s - %s/%s", authzRes.DisplayName, authzRes.EntityID, authzRes.Alias.ID))
 
 	authzRes.Renewable = true
+	outcome = loginOutcomeSuccess
 	return &logical.Response{
 		Auth: authzRes,
 	}, nil
@@ -184,6 +214,7 @@ func (b *flantIamAuthBackend) pathLoginRenew(ctx context.Context, req *logical.R
 	}
 
 	authorizator := authz2.NewAutorizator(txn, b.accessVaultClientProvider, b.accessorGetter, logger)
+	authorizator.RemoteAddr = clientAddr(req, method)
 
 	logger.Debug("Start renew")
 	rawSubject := req.Auth.InternalData["subject"]
@@ -208,3 +239,33 @@ const (
 Authenticates to Vault using a JWT (or OIDC) token, or service_account password
 `
 )
+
+// xForwardedForHeader passes addresses of the client and proxies, vault passes it to the plugin,
+// if it is listed at passthrough_request_headers of the mount
+const xForwardedForHeader = "X-Forwarded-For"
+
+// clientAddr returns the address of the client, who sent the request, or empty string if connection information
+// is not available. If the request is sent through trusted proxies of the method, the address is taken
+// from the X-Forwarded-For header: the rightmost address, which is not a trusted proxy
+func clientAddr(req *logical.Request, method *model.AuthMethod) string {
+	if req.Connection == nil {
+		return ""
+	}
+	addr := req.Connection.RemoteAddr
+	if method == nil || len(method.TrustedProxyCIDRs) == 0 {
+		return addr
+	}
+	var forwarded []string
+	for _, h := range req.Headers[xForwardedForHeader] {
+		for _, a := range strings.Split(h, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(a))
+		}
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if trusted, _ := cidrutil.IPBelongsToCIDRBlocksSlice(addr, method.TrustedProxyCIDRs); !trusted {
+			break
+		}
+		addr = forwarded[i]
+	}
+	return addr
+}
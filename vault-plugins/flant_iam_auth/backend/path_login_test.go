@@ -1481,3 +1481,34 @@ func getTestJWKS(t *testing.T, pubKey string) []byte {
 
 	return data
 }
+
+func Test_clientAddr(t *testing.T) {
+	method := &model.AuthMethod{TrustedProxyCIDRs: []string{"10.0.0.0/8"}}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		method     *model.AuthMethod
+		expected   string
+	}{
+		{"no trusted proxies", "10.0.0.1", []string{"1.1.1.1"}, &model.AuthMethod{}, "10.0.0.1"},
+		{"untrusted proxy", "2.2.2.2", []string{"1.1.1.1"}, method, "2.2.2.2"},
+		{"trusted proxy", "10.0.0.1", []string{"1.1.1.1"}, method, "1.1.1.1"},
+		{"chain of trusted proxies", "10.0.0.1", []string{"3.3.3.3, 1.1.1.1, 10.0.0.2"}, method, "1.1.1.1"},
+		{"trusted proxy without header", "10.0.0.1", nil, method, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &logical.Request{
+				Connection: &logical.Connection{RemoteAddr: tt.remoteAddr},
+				Headers:    map[string][]string{xForwardedForHeader: tt.forwarded},
+			}
+
+			addr := clientAddr(req, tt.method)
+
+			if addr != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, addr)
+			}
+		})
+	}
+}
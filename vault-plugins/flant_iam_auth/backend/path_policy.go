@@ -2,14 +2,20 @@ package backend
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	iam_usecase "github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/model"
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/usecase"
+	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/usecase/authz"
 	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
 )
 
@@ -149,9 +155,109 @@ func (b policyBackend) paths() []*framework.Path {
 				},
 			},
 		},
+		// Test
+		{
+			Pattern: "login_policy/" + framework.GenericNameRegex("name") + "/test$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeNameString,
+					Description: "Negentropy policy name",
+					Required:    true,
+				},
+				"rego": {
+					Type:        framework.TypeString,
+					Description: "Rego policy to test instead of the stored one, optional",
+				},
+				"claims": {
+					Type:        framework.TypeMap,
+					Description: "Sample input claims",
+				},
+				"subject": {
+					Type:        framework.TypeMap,
+					Description: "Sample subject, fields: type, uuid, tenant_uuid",
+				},
+				"effective_roles": {
+					Type:        framework.TypeSlice,
+					Description: "Sample effective roles",
+				},
+				"extensions_data": {
+					Type:        framework.TypeMap,
+					Description: "Sample data, collected by enriching extensions",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				// needs Create or Update for passing data, otherwise vault cuts any request body
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.handleTest(),
+					Summary:  "Run the policy with sample data.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleTest(),
+					Summary:  "Run the policy with sample data.",
+				},
+			},
+		},
+		// List versions
+		{
+			Pattern: "login_policy/" + framework.GenericNameRegex("name") + "/version/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeNameString,
+					Description: "Negentropy policy name",
+					Required:    true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleListVersions(),
+					Summary:  "Lists the current and last overwritten versions of the policy.",
+				},
+			},
+		},
+		// Read version
+		{
+			Pattern: "login_policy/" + framework.GenericNameRegex("name") + "/version/" + versionPattern + "$",
+			Fields:  policyVersionFields,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleReadVersion(),
+					Summary:  "Retrieve the version of the policy.",
+				},
+			},
+		},
+		// Restore version
+		{
+			Pattern: "login_policy/" + framework.GenericNameRegex("name") + "/version/" + versionPattern + "/restore$",
+			Fields:  policyVersionFields,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.handleRestoreVersion(),
+					Summary:  "Write the version of the policy as a new version.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleRestoreVersion(),
+					Summary:  "Write the version of the policy as a new version.",
+				},
+			},
+		},
 	}
 }
 
+const versionPattern = `(?P<version>\d+)`
+
+var policyVersionFields = map[string]*framework.FieldSchema{
+	"name": {
+		Type:        framework.TypeNameString,
+		Description: "Negentropy policy name",
+		Required:    true,
+	},
+	"version": {
+		Type:        framework.TypeInt,
+		Description: "Number of the policy version",
+		Required:    true,
+	},
+}
+
 func (b *policyBackend) handleExistence() framework.ExistenceFunc {
 	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
 		name := data.Get("name").(string)
@@ -185,7 +291,7 @@ func (b *policyBackend) handleCreate() framework.OperationFunc {
 		if err := usecase.Policies(tx).Create(policy); err != nil {
 			msg := "cannot create policy"
 			b.Logger().Error(msg, "err", err.Error())
-			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+			return backentutils.ResponseErr(req, err)
 		}
 		if err := io.CommitWithLog(tx, b.Logger()); err != nil {
 			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
@@ -305,3 +411,129 @@ func (b *policyBackend) handleErase() framework.OperationFunc {
 		return logical.RespondWithStatusCode(nil, req, http.StatusNoContent)
 	}
 }
+
+func (b *policyBackend) handleTest() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("test policy", "path", req.Path)
+		name := data.Get("name").(string)
+		policy := &model.Policy{Name: name}
+
+		tx := b.storage.Txn(false)
+		defer tx.Abort()
+
+		stored, err := usecase.Policies(tx).GetByID(name)
+		if err != nil && !errors.Is(err, consts.ErrNotFound) {
+			return backentutils.ResponseErr(req, err)
+		}
+		if stored != nil {
+			policy = stored
+		}
+		if rego := data.Get("rego").(string); rego != "" {
+			tmp := *policy
+			tmp.Rego = rego
+			policy = &tmp
+		}
+		if policy.Rego == "" {
+			return backentutils.ResponseErr(req, fmt.Errorf("%w:rego is not passed and policy %q is not found",
+				consts.ErrInvalidArg, name))
+		}
+
+		claims, _ := data.Get("claims").(map[string]interface{})
+		rawSubject, _ := data.Get("subject").(map[string]interface{})
+		extensionsData, _ := data.Get("extensions_data").(map[string]interface{})
+		effectiveRoles, err := parseEffectiveRoles(data.Get("effective_roles"))
+		if err != nil {
+			return backentutils.ResponseErr(req, fmt.Errorf("%w:parsing effective_roles:%s", consts.ErrInvalidArg, err.Error()))
+		}
+
+		result, err := authz.DryRunRegoPolicy(ctx, *policy, authz.MakeSubject(rawSubject), extensionsData,
+			effectiveRoles, claims)
+		if err != nil {
+			return backentutils.ResponseErr(req, fmt.Errorf("%w:running policy:%s", consts.ErrInvalidArg, err.Error()))
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{
+			"result": result,
+		}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
+
+func parseEffectiveRoles(raw interface{}) ([]iam_usecase.EffectiveRole, error) {
+	result := []iam_usecase.EffectiveRole{}
+	if raw == nil {
+		return result, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (b *policyBackend) handleListVersions() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("listing policy versions", "path", req.Path)
+		name := data.Get("name").(string)
+
+		tx := b.storage.Txn(false)
+		defer tx.Abort()
+
+		versions, err := usecase.Policies(tx).ListVersions(name)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{
+			"versions": versions,
+		}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
+
+func (b *policyBackend) handleReadVersion() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("read policy version", "path", req.Path)
+		name := data.Get("name").(string)
+		version := data.Get("version").(int)
+
+		tx := b.storage.Txn(false)
+		defer tx.Abort()
+
+		policyVersion, err := usecase.Policies(tx).GetVersion(name, version)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{
+			"version": policyVersion,
+		}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
+
+func (b *policyBackend) handleRestoreVersion() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		b.Logger().Debug("restore policy version", "path", req.Path)
+		name := data.Get("name").(string)
+		version := data.Get("version").(int)
+
+		tx := b.storage.Txn(true)
+		defer tx.Abort()
+
+		policy, err := usecase.Policies(tx).RestoreVersion(name, version)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		if err = io.CommitWithLog(tx, b.Logger()); err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+		}
+
+		resp := &logical.Response{Data: map[string]interface{}{"policy": policy}}
+		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+	}
+}
@@ -11,7 +11,6 @@ import (
 	iam_fixtures "github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/fixtures"
 	"github.com/flant/negentropy/vault-plugins/shared/tests"
-	"github.com/flant/negentropy/vault-plugins/shared/uuid"
 )
 
 var (
@@ -42,7 +41,7 @@ var _ = Describe("Policy", func() {
 
 	Context("global uniqueness of policy Name", func() {
 		It("Can not be the same Name", func() {
-			name := uuid.New()
+			name := "policy_" + fixtures.RandomStr()
 			tryCreateRandomPolicyWithName(name, "%d == 201")
 			tryCreateRandomPolicyWithName(name, "%d >= 400")
 		})
@@ -161,9 +160,10 @@ var _ = Describe("Policy", func() {
 	})
 })
 
-func tryCreateRandomPolicyWithName(name interface{}, statusCodeCondition string) {
+func tryCreateRandomPolicyWithName(name string, statusCodeCondition string) {
 	payload := fixtures.RandomPolicyCreatePayload()
 	payload["name"] = name
+	payload["rego"] = fixtures.PolicyRego(name)
 
 	params := tests.Params{
 		"expectStatus": tests.ExpectStatus(statusCodeCondition),
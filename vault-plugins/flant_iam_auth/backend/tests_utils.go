@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"math/rand"
 	"net/url"
@@ -216,8 +215,23 @@ func assertResponseCode(t *testing.T, r *api.Response, code int) {
 
 type mockEntityIDResolver struct{}
 
-func (m mockEntityIDResolver) RevealEntityIDOwner(_ authn2.EntityID, _ *io.MemoryStoreTxn, _ logical.Storage) (*authn2.EntityIDOwner, error) {
-	return nil, errors.New("if now need RevealEntityIDOwner, implement it")
+// RevealEntityIDOwner treats entityID as UUID of the user or the service_account
+func (m mockEntityIDResolver) RevealEntityIDOwner(entityID authn2.EntityID, txn *io.MemoryStoreTxn, _ logical.Storage) (*authn2.EntityIDOwner, error) {
+	user, err := repo.NewUserRepository(txn).GetByID(entityID)
+	if err == nil {
+		return &authn2.EntityIDOwner{
+			OwnerType: model.UserType,
+			Owner:     user,
+		}, nil
+	}
+	sa, err := repo.NewServiceAccountRepository(txn).GetByID(entityID)
+	if err != nil {
+		return nil, err
+	}
+	return &authn2.EntityIDOwner{
+		OwnerType: model.ServiceAccountType,
+		Owner:     sa,
+	}, nil
 }
 
 func (m mockEntityIDResolver) AvailableTenantsByEntityID(_ authn2.EntityID, txn *io.MemoryStoreTxn, _ logical.Storage) (map[model.TenantUUID]struct{}, error) {
@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// Stores of ssh session recordings, audit records of sessions are kept at the vault storage in any case
+const (
+	// RecordingStoreNone means recordings are not accepted, only audit records are kept
+	RecordingStoreNone = "none"
+	// RecordingStoreVault keeps recordings at the vault storage
+	RecordingStoreVault = "vault"
+	// RecordingStoreDirectory keeps recordings as files at the directory of the vault host
+	RecordingStoreDirectory = "directory"
+)
+
+// SSHSession is an audit record of the `cli ssh` session, it links signed certificates to the owner of the token
+type SSHSession struct {
+	UUID string `json:"uuid"` // PK
+	// OwnerType is user or service_account, OwnerUUID and OwnerFullIdentifier are taken from the vault token
+	OwnerType           string    `json:"owner_type"`
+	OwnerUUID           string    `json:"owner_uuid"`
+	OwnerFullIdentifier string    `json:"owner_full_identifier"`
+	StartedAt           time.Time `json:"started_at"`
+	FinishedAt          time.Time `json:"finished_at"`
+	// Certificates are all certificates, signed during the session, including refreshed ones
+	Certificates []SSHSessionCertificate `json:"certificates"`
+	// Recording is the stored asciicast recording, empty if the session is not recorded
+	Recording *SSHSessionRecording `json:"recording,omitempty"`
+}
+
+// SSHSessionCertificate links a signed ssh certificate to servers and rolebindings, which allowed the access
+type SSHSessionCertificate struct {
+	// Serial is decimal, as uint64 does not fit into json numbers
+	Serial      string    `json:"serial"`
+	TenantUUID  string    `json:"tenant_uuid"`
+	ProjectUUID string    `json:"project_uuid"`
+	ServerUUIDs []string  `json:"server_uuids"`
+	ValidBefore time.Time `json:"valid_before"`
+	// Certificate is the signed certificate in the authorized_keys format, the serial, principals at servers
+	// and valid_before are checked by it
+	Certificate string `json:"certificate"`
+	// Role and RoleBindingUUIDs are resolved by the flant_iam_auth at the moment of the first report of the certificate
+	Role             string   `json:"role,omitempty"`
+	RoleBindingUUIDs []string `json:"rolebinding_uuids,omitempty"`
+}
+
+// SSHSessionRecording describes the stored recording
+type SSHSessionRecording struct {
+	Store string `json:"store"`
+	// Location is the key prefix of chunks at the vault storage or the path of the file
+	Location string `json:"location"`
+	// Chunks is the count of entries at the vault storage, stored by keys <location>/<number>
+	Chunks int    `json:"chunks,omitempty"`
+	Format string `json:"format"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
@@ -50,7 +50,7 @@ func (b *ServerAccessBackend) SetEntityIDResolver(entityIDResolver authn.EntityI
 }
 
 func (b *ServerAccessBackend) Paths() []*framework.Path {
-	return []*framework.Path{
+	return append([]*framework.Path{
 		{
 			Pattern: path.Join("configure_extension", "server_access"),
 			Fields: map[string]*framework.FieldSchema{
@@ -87,6 +87,11 @@ func (b *ServerAccessBackend) Paths() []*framework.Path {
 					Description: "UUID of a server",
 					Required:    true,
 				},
+				"resource_version": {
+					Type:        framework.TypeString,
+					Description: "Resource version of the last received posix users, users are not passed if it is not changed",
+					Query:       true,
+				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ReadOperation: &framework.PathOperation{
@@ -165,7 +170,7 @@ func (b *ServerAccessBackend) Paths() []*framework.Path {
 				},
 			},
 		},
-	}
+	}, b.sshSessionPaths()...)
 }
 
 func (b *ServerAccessBackend) handleConfig() framework.OperationFunc {
@@ -324,12 +329,28 @@ func (b *ServerAccessBackend) handleReadPosixUsers() framework.OperationFunc {
 			return logical.ErrorResponse(err.Error()), nil
 		}
 
+		role, err := iam_repo.NewRoleRepository(txn).GetByID(sshRole)
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		roleResolver := iam_usecase.NewRoleResolver(txn)
+
 		var posixUsers []posixUser
 		var warnings []string
 		posixBuilder := newPosixUserBuilder(txn, serverID, tenantID)
 
 		for _, user := range users {
-			posix, err := posixBuilder.userToPosix(user)
+			var roles []iam_usecase.EffectiveRole
+			if role.Scope == iam_model.RoleScopeProject {
+				_, roles, err = roleResolver.CheckUserForRolebindingsAtProject(user.UUID, sshRole, projectID)
+			} else {
+				_, roles, err = roleResolver.CheckUserForRolebindingsAtTenant(user.UUID, sshRole, tenantID)
+			}
+			if err != nil {
+				warnings = append(warnings, err.Error())
+				continue
+			}
+			posix, err := posixBuilder.userToPosix(user, roles)
 			if err != nil {
 				warnings = append(warnings, err.Error())
 				continue
@@ -338,7 +359,17 @@ func (b *ServerAccessBackend) handleReadPosixUsers() framework.OperationFunc {
 		}
 
 		for _, sa := range serviceAccounts {
-			posix, err := posixBuilder.serviceAccountToPosix(sa)
+			var roles []iam_usecase.EffectiveRole
+			if role.Scope == iam_model.RoleScopeProject {
+				_, roles, err = roleResolver.CheckServiceAccountForRolebindingsAtProject(sa.UUID, sshRole, projectID)
+			} else {
+				_, roles, err = roleResolver.CheckServiceAccountForRolebindingsAtTenant(sa.UUID, sshRole, tenantID)
+			}
+			if err != nil {
+				warnings = append(warnings, err.Error())
+				continue
+			}
+			posix, err := posixBuilder.serviceAccountToPosix(sa, roles)
 			if err != nil {
 				warnings = append(warnings, err.Error())
 				continue
@@ -350,9 +381,21 @@ func (b *ServerAccessBackend) handleReadPosixUsers() framework.OperationFunc {
 			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
 		}
 
+		resourceVersion, err := posixUsersVersion(posixUsers)
+		if err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+		}
+		if resourceVersion == data.Get("resource_version").(string) {
+			resp := &logical.Response{
+				Warnings: warnings,
+				Data:     map[string]interface{}{"resource_version": resourceVersion, "not_modified": true},
+			}
+			return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+		}
+
 		resp := &logical.Response{
 			Warnings: warnings,
-			Data:     map[string]interface{}{"posix_users": posixUsers},
+			Data:     map[string]interface{}{"posix_users": posixUsers, "resource_version": resourceVersion},
 		}
 		return logical.RespondWithStatusCode(resp, req, http.StatusOK)
 	}
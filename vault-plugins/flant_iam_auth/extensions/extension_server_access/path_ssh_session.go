@@ -0,0 +1,599 @@
+package extension_server_access
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ssh"
+
+	ext_repo "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/repo"
+	iam_model "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	iam_usecase "github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
+	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/extensions/extension_server_access/model"
+	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/usecase/authn"
+	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+	"github.com/flant/negentropy/vault-plugins/shared/uuid"
+)
+
+const (
+	sshSessionRecordingsConfigKey = "iam_auth.extensions.server_access.ssh_session_recordings"
+	sshSessionKeyPrefix           = "iam_auth.extensions.server_access.ssh_session/"
+	sshSessionRecordingKeyPrefix  = "iam_auth.extensions.server_access.ssh_session_recording/"
+	// sshSessionSerialKeyPrefix keeps the session uuid by the serial of the reported certificate
+	sshSessionSerialKeyPrefix = "iam_auth.extensions.server_access.ssh_session_serial/"
+
+	recordingFormat         = "asciicast-v2"
+	defaultRecordingMaxSize = 8 * 1024 * 1024
+	// recordingChunkSize limits entries of the vault store, as the raft storage limits an entry by 1MiB
+	recordingChunkSize = 512 * 1024
+)
+
+type recordingsConfig struct {
+	Store     string `json:"store"`
+	Directory string `json:"directory,omitempty"`
+	MaxSize   int    `json:"max_size"`
+	// SSHCAPublicKey is the ca of the ssh secrets engine, which signs certificates for the cli
+	SSHCAPublicKey string `json:"ssh_ca_public_key,omitempty"`
+}
+
+func (b *ServerAccessBackend) sshSessionPaths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: path.Join("configure_extension", "server_access", "ssh_session_recordings"),
+			Fields: map[string]*framework.FieldSchema{
+				"store": {
+					Type:          framework.TypeString,
+					Description:   "Store of recordings of ssh sessions: none, vault or directory. Audit records are kept in any case",
+					Default:       model.RecordingStoreNone,
+					AllowedValues: []interface{}{model.RecordingStoreNone, model.RecordingStoreVault, model.RecordingStoreDirectory},
+				},
+				"directory": {
+					Type:        framework.TypeString,
+					Description: "Directory at the vault host for the directory store",
+				},
+				"max_size": {
+					Type:        framework.TypeInt,
+					Description: "Max size of a recording in bytes, bigger recordings are not stored",
+					Default:     defaultRecordingMaxSize,
+				},
+				"ssh_ca_public_key": {
+					Type:        framework.TypeString,
+					Description: "Public key of the ssh ca in the authorized_keys format, reported certificates should be signed by it",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleConfigRecordings,
+					Summary:  "Configure the store of ssh session recordings and the ssh ca",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleReadConfigRecordings,
+					Summary:  "Read the store of ssh session recordings and the ssh ca",
+				},
+			},
+		},
+		{
+			Pattern: "ssh_session/?",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.handleListSSHSessions,
+					Summary:  "List UUIDs of ssh sessions",
+				},
+			},
+		},
+		{
+			Pattern: path.Join("ssh_session", uuid.Pattern("session_uuid")),
+			Fields: map[string]*framework.FieldSchema{
+				"session_uuid": {
+					Type:        framework.TypeNameString,
+					Description: "UUID of the ssh session, generated by the cli",
+					Required:    true,
+				},
+				"started_at": {
+					Type:        framework.TypeTime,
+					Description: "Start of the session",
+				},
+				"finished_at": {
+					Type:        framework.TypeTime,
+					Description: "End of the session, is passed with the last report, the finished session can't be reported again",
+				},
+				"certificates": {
+					Type:        framework.TypeSlice,
+					Description: "Signed certificates: serial, tenant_uuid, project_uuid, server_uuids and certificate in the authorized_keys format. New certificates are added to the reported ones",
+				},
+				"recording": {
+					Type:        framework.TypeString,
+					Description: "Base64 encoded asciicast v2 recording of the session, is passed only with finished_at",
+				},
+				"with_recording": {
+					Type:        framework.TypeBool,
+					Description: "Return also the base64 encoded recording",
+					Query:       true,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleReportSSHSession,
+					Summary:  "Report the ssh session of the token owner and upload its recording",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleReadSSHSession,
+					Summary:  "Read the audit record of the ssh session",
+				},
+			},
+		},
+	}
+}
+
+func (b *ServerAccessBackend) handleConfigRecordings(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Info("handleConfigRecordings started")
+	defer b.Logger().Info("handleConfigRecordings exit")
+
+	cfg := recordingsConfig{
+		Store:          data.Get("store").(string),
+		Directory:      data.Get("directory").(string),
+		MaxSize:        data.Get("max_size").(int),
+		SSHCAPublicKey: data.Get("ssh_ca_public_key").(string),
+	}
+	if err := cfg.validate(); err != nil {
+		return backentutils.ResponseErr(req, fmt.Errorf("%w: %s", consts.ErrInvalidArg, err.Error()))
+	}
+
+	entry, err := logical.StorageEntryJSON(sshSessionRecordingsConfigKey, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err = req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return logical.RespondWithStatusCode(nil, req, http.StatusOK)
+}
+
+func (b *ServerAccessBackend) handleReadConfigRecordings(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	cfg, err := readRecordingsConfig(ctx, req.Storage)
+	if err != nil {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+	}
+	resp := &logical.Response{Data: map[string]interface{}{
+		"store":             cfg.Store,
+		"directory":         cfg.Directory,
+		"max_size":          cfg.MaxSize,
+		"ssh_ca_public_key": cfg.SSHCAPublicKey,
+	}}
+	return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+}
+
+func (b *ServerAccessBackend) handleListSSHSessions(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	sessions, err := req.Storage.List(ctx, sshSessionKeyPrefix)
+	if err != nil {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+	}
+	resp := &logical.Response{Data: map[string]interface{}{"ssh_sessions": sessions}}
+	return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+}
+
+func (b *ServerAccessBackend) handleReadSSHSession(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	session, err := getSSHSession(ctx, req.Storage, data.Get("session_uuid").(string))
+	if err != nil {
+		return backentutils.ResponseErr(req, err)
+	}
+
+	resp := &logical.Response{Data: map[string]interface{}{"ssh_session": session}}
+	if data.Get("with_recording").(bool) && session.Recording != nil {
+		recording, err := getRecording(ctx, req.Storage, *session.Recording)
+		if err != nil {
+			return backentutils.ResponseErrMessage(req, fmt.Sprintf("read recording: %s", err.Error()),
+				http.StatusInternalServerError)
+		}
+		resp.Data["recording"] = base64.StdEncoding.EncodeToString(recording)
+	}
+	return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+}
+
+// handleReportSSHSession is called by the cli at the start of the session, after refreshing certificates
+// and at the end of the session with the recording. The owner of the session is the owner of the token
+func (b *ServerAccessBackend) handleReportSSHSession(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Info("handleReportSSHSession started")
+	defer b.Logger().Info("handleReportSSHSession exit")
+	sessionUUID := data.Get("session_uuid").(string)
+
+	certificates, err := parseSSHSessionCertificates(data.Get("certificates"))
+	if err != nil {
+		return backentutils.ResponseErr(req, fmt.Errorf("%w: %s", consts.ErrInvalidArg, err.Error()))
+	}
+	recording, err := base64.StdEncoding.DecodeString(data.Get("recording").(string))
+	if err != nil {
+		return backentutils.ResponseErr(req, fmt.Errorf("%w: recording: %s", consts.ErrInvalidArg, err.Error()))
+	}
+	cfg, err := readRecordingsConfig(ctx, req.Storage)
+	if err != nil {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+	}
+	finishedAt := data.Get("finished_at").(time.Time)
+	if len(recording) > 0 && finishedAt.IsZero() {
+		return backentutils.ResponseErr(req, fmt.Errorf("%w: recording is passed only with finished_at", consts.ErrInvalidArg))
+	}
+
+	txn := b.storage.Txn(false)
+	defer txn.Abort()
+
+	owner, err := b.entityIDResolver.RevealEntityIDOwner(req.EntityID, txn, req.Storage)
+	if err != nil {
+		return backentutils.ResponseErrMessage(req, fmt.Sprintf("reveal token owner: %s", err.Error()), http.StatusForbidden)
+	}
+	ownerUUID, ownerFullIdentifier, err := sshSessionOwner(owner)
+	if err != nil {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusForbidden)
+	}
+
+	session, err := getSSHSession(ctx, req.Storage, sessionUUID)
+	switch {
+	case errors.Is(err, consts.ErrNotFound):
+		session = &model.SSHSession{
+			UUID:                sessionUUID,
+			OwnerType:           owner.OwnerType,
+			OwnerUUID:           ownerUUID,
+			OwnerFullIdentifier: ownerFullIdentifier,
+			StartedAt:           time.Now().UTC(),
+		}
+		if startedAt := data.Get("started_at").(time.Time); !startedAt.IsZero() {
+			session.StartedAt = startedAt.UTC()
+		}
+	case err != nil:
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+	case session.OwnerUUID != ownerUUID:
+		return backentutils.ResponseErrMessage(req, "ssh session belongs to another owner", http.StatusForbidden)
+	case !session.FinishedAt.IsZero():
+		// the finish and the recording are written once, by the last report
+		return backentutils.ResponseErrMessage(req, "ssh session is already finished", http.StatusConflict)
+	}
+
+	if !finishedAt.IsZero() {
+		session.FinishedAt = finishedAt.UTC()
+	}
+
+	caKey, err := cfg.sshCAKey()
+	if err != nil {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+	}
+
+	var warnings []string
+	if caKey == nil && len(certificates) > 0 {
+		warnings = append(warnings, "signers of certificates are not checked, ssh_ca_public_key is not configured")
+	}
+	reported := map[string]struct{}{}
+	for _, cert := range session.Certificates {
+		reported[cert.Serial] = struct{}{}
+	}
+	for _, cert := range certificates {
+		if _, ok := reported[cert.Serial]; ok {
+			continue
+		}
+		if err = checkSessionServers(txn, cert); err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		if err = verifySessionCertificate(&cert, ownerUUID, caKey); err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		if err = claimCertificateSerial(ctx, req.Storage, cert.Serial, sessionUUID); err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		cert.Role, cert.RoleBindingUUIDs, err = sessionRoleBindings(ctx, txn, req.Storage, owner.OwnerType, ownerUUID, cert)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("certificate %s: %s", cert.Serial, err.Error()))
+		}
+		session.Certificates = append(session.Certificates, cert)
+		reported[cert.Serial] = struct{}{}
+	}
+
+	if len(recording) > 0 {
+		switch {
+		case cfg.Store == model.RecordingStoreNone:
+			warnings = append(warnings, "recording is not stored, the store of recordings is not configured")
+		case len(recording) > cfg.MaxSize:
+			// the finish and certificates are stored anyway, as the session can't be reported again
+			warnings = append(warnings, fmt.Sprintf("recording is not stored, it exceeds %d bytes", cfg.MaxSize))
+			b.Logger().Warn("recording exceeds max_size and is dropped", "session_uuid", sessionUUID,
+				"size", len(recording), "max_size", cfg.MaxSize)
+		default:
+			stored, err := putRecording(ctx, req.Storage, cfg, sessionUUID, recording)
+			if err != nil {
+				return backentutils.ResponseErrMessage(req, fmt.Sprintf("store recording: %s", err.Error()),
+					http.StatusInternalServerError)
+			}
+			session.Recording = stored
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(sshSessionKeyPrefix+sessionUUID, session)
+	if err != nil {
+		return nil, err
+	}
+	if err = req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{
+		Warnings: warnings,
+		Data:     map[string]interface{}{"ssh_session": session},
+	}
+	return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+}
+
+func (c recordingsConfig) validate() error {
+	switch c.Store {
+	case model.RecordingStoreNone, model.RecordingStoreVault:
+	case model.RecordingStoreDirectory:
+		if !filepath.IsAbs(c.Directory) {
+			return fmt.Errorf("directory should be an absolute path for the %s store", model.RecordingStoreDirectory)
+		}
+	default:
+		return fmt.Errorf("unknown store %q", c.Store)
+	}
+	if c.MaxSize <= 0 {
+		return fmt.Errorf("max_size should be positive")
+	}
+	_, err := c.sshCAKey()
+	return err
+}
+
+// sshCAKey returns nil, if the ssh ca is not configured
+func (c recordingsConfig) sshCAKey() (ssh.PublicKey, error) {
+	if c.SSHCAPublicKey == "" {
+		return nil, nil
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(c.SSHCAPublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("ssh_ca_public_key: %w", err)
+	}
+	return key, nil
+}
+
+func readRecordingsConfig(ctx context.Context, storage logical.Storage) (recordingsConfig, error) {
+	cfg := recordingsConfig{
+		Store:   model.RecordingStoreNone,
+		MaxSize: defaultRecordingMaxSize,
+	}
+	entry, err := storage.Get(ctx, sshSessionRecordingsConfigKey)
+	if err != nil || entry == nil {
+		return cfg, err
+	}
+	err = entry.DecodeJSON(&cfg)
+	return cfg, err
+}
+
+func getSSHSession(ctx context.Context, storage logical.Storage, sessionUUID string) (*model.SSHSession, error) {
+	entry, err := storage.Get(ctx, sshSessionKeyPrefix+sessionUUID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, consts.ErrNotFound
+	}
+	var session model.SSHSession
+	if err = entry.DecodeJSON(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func putRecording(ctx context.Context, storage logical.Storage, cfg recordingsConfig, sessionUUID string,
+	recording []byte) (*model.SSHSessionRecording, error) {
+	sum := sha256.Sum256(recording)
+	stored := &model.SSHSessionRecording{
+		Store:  cfg.Store,
+		Format: recordingFormat,
+		Size:   len(recording),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+
+	switch cfg.Store {
+	case model.RecordingStoreVault:
+		stored.Location = sshSessionRecordingKeyPrefix + sessionUUID
+		for start := 0; start < len(recording); start += recordingChunkSize {
+			end := start + recordingChunkSize
+			if end > len(recording) {
+				end = len(recording)
+			}
+			err := storage.Put(ctx, &logical.StorageEntry{
+				Key:   recordingChunkKey(stored.Location, stored.Chunks),
+				Value: recording[start:end],
+			})
+			if err != nil {
+				return nil, err
+			}
+			stored.Chunks++
+		}
+	case model.RecordingStoreDirectory:
+		stored.Location = filepath.Join(cfg.Directory, sessionUUID+".cast")
+		if err := os.WriteFile(stored.Location, recording, 0o600); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown store %q", cfg.Store)
+	}
+	return stored, nil
+}
+
+func getRecording(ctx context.Context, storage logical.Storage, stored model.SSHSessionRecording) ([]byte, error) {
+	switch stored.Store {
+	case model.RecordingStoreVault:
+		recording := make([]byte, 0, stored.Size)
+		for i := 0; i < stored.Chunks; i++ {
+			entry, err := storage.Get(ctx, recordingChunkKey(stored.Location, i))
+			if err != nil {
+				return nil, err
+			}
+			if entry == nil {
+				return nil, fmt.Errorf("%w: chunk %d", consts.ErrNotFound, i)
+			}
+			recording = append(recording, entry.Value...)
+		}
+		return recording, nil
+	case model.RecordingStoreDirectory:
+		return os.ReadFile(stored.Location)
+	}
+	return nil, fmt.Errorf("unknown store %q", stored.Store)
+}
+
+func recordingChunkKey(location string, chunk int) string {
+	return location + "/" + strconv.Itoa(chunk)
+}
+
+func sshSessionOwner(owner *authn.EntityIDOwner) (string, string, error) {
+	switch owner.OwnerType {
+	case iam_model.UserType:
+		user, ok := owner.Owner.(*iam_model.User)
+		if !ok {
+			return "", "", fmt.Errorf("can't cast, need *model.User, got: %T", owner.Owner)
+		}
+		return user.UUID, user.FullIdentifier, nil
+	case iam_model.ServiceAccountType:
+		sa, ok := owner.Owner.(*iam_model.ServiceAccount)
+		if !ok {
+			return "", "", fmt.Errorf("can't cast, need *model.ServiceAccount, got: %T", owner.Owner)
+		}
+		return sa.UUID, sa.FullIdentifier, nil
+	}
+	return "", "", fmt.Errorf("wrong token owner type: %s", owner.OwnerType)
+}
+
+// parseSSHSessionCertificates passes certificates through json, as TypeSlice contains maps
+func parseSSHSessionCertificates(raw interface{}) ([]model.SSHSessionCertificate, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var certificates []model.SSHSessionCertificate
+	if err = json.Unmarshal(data, &certificates); err != nil {
+		return nil, fmt.Errorf("certificates: %w", err)
+	}
+	for _, cert := range certificates {
+		if cert.Serial == "" || cert.TenantUUID == "" || cert.ProjectUUID == "" || len(cert.ServerUUIDs) == 0 ||
+			cert.Certificate == "" {
+			return nil, fmt.Errorf("certificates: serial, tenant_uuid, project_uuid, server_uuids and certificate are required")
+		}
+	}
+	return certificates, nil
+}
+
+// verifySessionCertificate checks the serial, the signature and principals of the owner at servers of the certificate,
+// valid_before is taken from the certificate. The signer is checked, if caKey is passed
+func verifySessionCertificate(cert *model.SSHSessionCertificate, ownerUUID string, caKey ssh.PublicKey) error {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cert.Certificate))
+	if err != nil {
+		return fmt.Errorf("%w: certificate %s: %s", consts.ErrInvalidArg, cert.Serial, err.Error())
+	}
+	sshCert, ok := key.(*ssh.Certificate)
+	if !ok || sshCert.CertType != ssh.UserCert {
+		return fmt.Errorf("%w: certificate %s is not a user certificate", consts.ErrInvalidArg, cert.Serial)
+	}
+	if strconv.FormatUint(sshCert.Serial, 10) != cert.Serial {
+		return fmt.Errorf("%w: certificate %s has serial %d", consts.ErrInvalidArg, cert.Serial, sshCert.Serial)
+	}
+	if caKey != nil && !bytes.Equal(sshCert.SignatureKey.Marshal(), caKey.Marshal()) {
+		return fmt.Errorf("%w: certificate %s is not signed by the ssh ca", consts.ErrInvalidArg, cert.Serial)
+	}
+
+	// the validity period is not checked, as the certificate can be expired at the last report
+	checker := ssh.CertChecker{Clock: func() time.Time { return time.Unix(int64(sshCert.ValidAfter), 0) }}
+	for _, serverUUID := range cert.ServerUUIDs {
+		if err = checker.CheckCert(sshPrincipal(serverUUID, ownerUUID), sshCert); err != nil {
+			return fmt.Errorf("%w: certificate %s at server %s: %s", consts.ErrInvalidArg, cert.Serial, serverUUID, err.Error())
+		}
+	}
+	cert.ValidBefore = time.Unix(int64(sshCert.ValidBefore), 0).UTC()
+	return nil
+}
+
+// claimCertificateSerial links the serial to the session, the certificate can be reported only by one session
+func claimCertificateSerial(ctx context.Context, storage logical.Storage, serial string, sessionUUID string) error {
+	entry, err := storage.Get(ctx, sshSessionSerialKeyPrefix+serial)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		if string(entry.Value) == sessionUUID {
+			return nil
+		}
+		return fmt.Errorf("%w: certificate %s is reported by another ssh session", consts.ErrInvalidArg, serial)
+	}
+	return storage.Put(ctx, &logical.StorageEntry{Key: sshSessionSerialKeyPrefix + serial, Value: []byte(sessionUUID)})
+}
+
+func checkSessionServers(txn *io.MemoryStoreTxn, cert model.SSHSessionCertificate) error {
+	repo := ext_repo.NewServerRepository(txn)
+	for _, serverUUID := range cert.ServerUUIDs {
+		server, err := repo.GetByUUID(serverUUID)
+		if err != nil && !errors.Is(err, consts.ErrNotFound) {
+			return err
+		}
+		if err != nil || server.TenantUUID != cert.TenantUUID || server.ProjectUUID != cert.ProjectUUID {
+			return fmt.Errorf("%w: server %s is not found at the project %s", consts.ErrInvalidArg, serverUUID, cert.ProjectUUID)
+		}
+	}
+	return nil
+}
+
+// sessionRoleBindings returns the ssh role and rolebindings, which give the owner access to servers of the certificate
+func sessionRoleBindings(ctx context.Context, txn *io.MemoryStoreTxn, storage logical.Storage, ownerType string,
+	ownerUUID string, cert model.SSHSessionCertificate) (string, []string, error) {
+	entry, err := storage.Get(ctx, serverAccessSSHRoleKey)
+	if err != nil {
+		return "", nil, err
+	}
+	if entry == nil {
+		return "", nil, fmt.Errorf("%w: serverAccessSSHRole not defined", consts.ErrNotConfigured)
+	}
+	sshRole := string(entry.Value)
+
+	role, err := iam_repo.NewRoleRepository(txn).GetByID(sshRole)
+	if err != nil {
+		return sshRole, nil, err
+	}
+	roleResolver := iam_usecase.NewRoleResolver(txn)
+
+	var roles []iam_usecase.EffectiveRole
+	switch {
+	case ownerType == iam_model.UserType && role.Scope == iam_model.RoleScopeProject:
+		_, roles, err = roleResolver.CheckUserForRolebindingsAtProject(ownerUUID, sshRole, cert.ProjectUUID)
+	case ownerType == iam_model.UserType:
+		_, roles, err = roleResolver.CheckUserForRolebindingsAtTenant(ownerUUID, sshRole, cert.TenantUUID)
+	case role.Scope == iam_model.RoleScopeProject:
+		_, roles, err = roleResolver.CheckServiceAccountForRolebindingsAtProject(ownerUUID, sshRole, cert.ProjectUUID)
+	default:
+		_, roles, err = roleResolver.CheckServiceAccountForRolebindingsAtTenant(ownerUUID, sshRole, cert.TenantUUID)
+	}
+	if err != nil {
+		return sshRole, nil, err
+	}
+
+	rolebindings := make([]string, 0, len(roles))
+	for _, r := range roles {
+		rolebindings = append(rolebindings, r.RoleBindingUUID)
+	}
+	sort.Strings(rolebindings)
+	return sshRole, rolebindings, nil
+}
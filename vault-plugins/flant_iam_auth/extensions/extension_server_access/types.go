@@ -2,17 +2,26 @@ package extension_server_access
 
 import (
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 
 	"github.com/GehirnInc/crypt"
 	_ "github.com/GehirnInc/crypt/sha512_crypt"
 
+	ext_model "github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/model"
 	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	iam_usecase "github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
 )
 
+const (
+	defaultShell = "/bin/bash"
+	defaultGid   = 999
+)
+
 type ServerAccessConfig struct {
 	RoleForSSHAccess string
 }
@@ -38,40 +47,94 @@ type posixUser struct {
 	Shell    string `json:"shell"`
 	Gecos    string `json:"gecos"`
 	Gid      int    `json:"gid"`
+	// Groups are supplementary groups, they should exist at the server
+	Groups    []string `json:"groups"`
+	SudoRules []string `json:"sudo_rules"`
 }
 
-func (pb *posixUserBuilder) newPosixUser(uid int, principal, name, homeDir, pass string) posixUser {
+func (pb *posixUserBuilder) newPosixUser(uid int, principal, name, homeDir, pass string, attributes ext_model.PosixAttributes) posixUser {
+	shell := attributes.Shell
+	if shell == "" {
+		shell = defaultShell
+	}
 	return posixUser{
 		UID:       uid,
 		Principal: principal,
 		Name:      name,
 		HomeDir:   homeDir,
 		Password:  pass,
-		Shell:     "/bin/bash",
-		Gecos:     "",
-		Gid:       999,
+		Shell:     shell,
+		Gecos:     attributes.Gecos,
+		Gid:       defaultGid,
+		Groups:    attributes.Groups,
+		SudoRules: attributes.SudoRules,
 	}
 }
 
-func (pb *posixUserBuilder) userToPosix(user *iam.User) (posixUser, error) {
+// posixUsersVersion returns the hash of users, it is the same for the same users in any order,
+// so servers can skip syncing of unchanged users
+func posixUsersVersion(users []posixUser) (string, error) {
+	sorted := append([]posixUser{}, users...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].UID < sorted[j].UID })
+	data, err := json.Marshal(sorted)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// userToPosix builds POSIX user, roles are rolebindings of the ssh role, their options override
+// POSIX attributes of the extension
+func (pb *posixUserBuilder) userToPosix(user *iam.User, roles []iam_usecase.EffectiveRole) (posixUser, error) {
 	ext, ok := user.Extensions["server_access"]
 	if !ok {
 		return posixUser{}, fmt.Errorf("server_access extension not found for user: %s", user.FullIdentifier)
 	}
 
-	return pb.buildPosixUser(ext, user.UUID, user.TenantUUID, user.Identifier, user.FullIdentifier)
+	return pb.buildPosixUser(ext, roles, user.UUID, user.TenantUUID, user.Identifier, user.FullIdentifier)
 }
 
-func (pb *posixUserBuilder) serviceAccountToPosix(sa *iam.ServiceAccount) (posixUser, error) {
+func (pb *posixUserBuilder) serviceAccountToPosix(sa *iam.ServiceAccount, roles []iam_usecase.EffectiveRole) (posixUser, error) {
 	ext, ok := sa.Extensions["server_access"]
 	if !ok {
 		return posixUser{}, fmt.Errorf("server_access extension not found for service account: %s", sa.FullIdentifier)
 	}
 
-	return pb.buildPosixUser(ext, sa.UUID, sa.TenantUUID, sa.Identifier, sa.FullIdentifier)
+	return pb.buildPosixUser(ext, roles, sa.UUID, sa.TenantUUID, sa.Identifier, sa.FullIdentifier)
+}
+
+// posixAttributes merges POSIX attributes of the extension and options of rolebindings, rolebindings are
+// applied in order of their UUIDs to get the same shell and gecos at every call
+func posixAttributes(ext *iam.Extension, roles []iam_usecase.EffectiveRole, fullIdentifier string) (ext_model.PosixAttributes, error) {
+	attributes, err := ext_model.ParsePosixAttributes(ext.Attributes)
+	if err != nil {
+		return ext_model.PosixAttributes{}, fmt.Errorf("server_access extension for %q: %w", fullIdentifier, err)
+	}
+
+	sorted := append([]iam_usecase.EffectiveRole{}, roles...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].RoleBindingUUID < sorted[j].RoleBindingUUID })
+	for _, role := range sorted {
+		options, err := ext_model.ParsePosixAttributes(role.Options)
+		if err != nil {
+			return ext_model.PosixAttributes{}, fmt.Errorf("options of rolebinding %s for %q: %w",
+				role.RoleBindingUUID, fullIdentifier, err)
+		}
+		attributes = attributes.Merge(options)
+	}
+	return attributes, nil
 }
 
-func (pb *posixUserBuilder) buildPosixUser(ext *iam.Extension, objectID, objectTenantID, identifier, fullIdentifier string) (posixUser, error) {
+// sshPrincipal returns the principal of the user or the service account at the server, it is passed
+// into ssh certificates, signed for the cli
+func sshPrincipal(serverUUID, objectUUID string) string {
+	principalHash := sha256.New()
+	principalHash.Write([]byte(serverUUID))
+	principalHash.Write([]byte(objectUUID))
+	return fmt.Sprintf("%x", principalHash.Sum(nil))
+}
+
+func (pb *posixUserBuilder) buildPosixUser(ext *iam.Extension, roles []iam_usecase.EffectiveRole, objectID, objectTenantID,
+	identifier, fullIdentifier string) (posixUser, error) {
 	uid, ok := ext.Attributes["UID"]
 	if !ok {
 		return posixUser{}, fmt.Errorf("UID not found in server_access extension for %s", fullIdentifier)
@@ -82,11 +145,7 @@ func (pb *posixUserBuilder) buildPosixUser(ext *iam.Extension, objectID, objectT
 		return posixUser{}, fmt.Errorf("UID is not float64 in server_access extension for %s", fullIdentifier)
 	}
 
-	principalHash := sha256.New()
-	principalHash.Write([]byte(pb.serverID))
-	principalHash.Write([]byte(objectID))
-	principalSum := principalHash.Sum(nil)
-	principal := fmt.Sprintf("%x", principalSum)
+	principal := sshPrincipal(pb.serverID, objectID)
 
 	name := identifier
 	homeDirRelPath := identifier
@@ -136,5 +195,10 @@ func (pb *posixUserBuilder) buildPosixUser(ext *iam.Extension, objectID, objectT
 		return posixUser{}, fmt.Errorf("password crypt failed (%s) for %q", err, fullIdentifier)
 	}
 
-	return pb.newPosixUser(int(fuid), principal, name, homeDir, pass), nil
+	attributes, err := posixAttributes(ext, roles, fullIdentifier)
+	if err != nil {
+		return posixUser{}, err
+	}
+
+	return pb.newPosixUser(int(fuid), principal, name, homeDir, pass, attributes), nil
 }
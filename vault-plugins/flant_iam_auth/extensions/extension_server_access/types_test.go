@@ -11,6 +11,7 @@ import (
 	"github.com/flant/negentropy/vault-plugins/flant_iam/extensions/ext_server_access/model"
 	iam_model "github.com/flant/negentropy/vault-plugins/flant_iam/model"
 	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	iam_usecase "github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
 	"github.com/flant/negentropy/vault-plugins/shared/consts"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
 	"github.com/flant/negentropy/vault-plugins/shared/uuid"
@@ -92,19 +93,72 @@ func TestUserToPosix(t *testing.T) {
 	serverID := "serverX"
 	builder := newPosixUserBuilder(st.Txn(false), serverID, tenant1)
 
-	posix1, _ := builder.userToPosix(user1)
+	posix1, _ := builder.userToPosix(user1, nil)
 	assert.Equal(t, "vasya", posix1.Name)
 	assert.Equal(t, 42, posix1.UID)
 	assert.Equal(t, "/home/vasya", posix1.HomeDir)
 	assert.Contains(t, posix1.Password, "$6$")
+	assert.Equal(t, "/bin/bash", posix1.Shell)
+	assert.Empty(t, posix1.Groups)
 
-	posix2, _ := builder.userToPosix(user2)
+	posix2, _ := builder.userToPosix(user2, nil)
 	assert.Equal(t, "vasya@tenant2", posix2.Name)
 	assert.Equal(t, 56, posix2.UID)
 	assert.Equal(t, "/home/tenant2/vasya", posix2.HomeDir)
 	assert.Contains(t, posix2.Password, "$6$")
 }
 
+func TestUserToPosixAttributes(t *testing.T) {
+	tenantID := uuid.New()
+	attrs, err := marshallUnmarshal(map[string]interface{}{
+		"UID": 42,
+		"passwords": []model.UserServerPassword{
+			{
+				Seed: []byte("1"),
+				Salt: []byte("1"),
+			},
+		},
+		"shell":      "/bin/zsh",
+		"gecos":      "Vasya Pupkin",
+		"groups":     []string{"docker"},
+		"sudo_rules": []string{"ALL=(ALL) NOPASSWD: /usr/bin/systemctl"},
+	})
+	require.NoError(t, err)
+	user := &iam_model.User{
+		UUID:           uuid.New(),
+		TenantUUID:     tenantID,
+		Identifier:     "vasya",
+		FullIdentifier: "vasya@tenant1",
+		Extensions: map[consts.ObjectOrigin]*iam_model.Extension{
+			consts.OriginServerAccess: {
+				Origin:     consts.OriginServerAccess,
+				Attributes: attrs,
+			},
+		},
+	}
+	schema, err := iam_repo.GetSchema()
+	require.NoError(t, err)
+	st, _ := io.NewMemoryStore(schema, nil, hclog.NewNullLogger())
+	builder := newPosixUserBuilder(st.Txn(false), "serverX", tenantID)
+
+	roles := []iam_usecase.EffectiveRole{
+		{RoleBindingUUID: "2", Options: map[string]interface{}{"shell": "/bin/sh", "sudo_rules": true}},
+		{RoleBindingUUID: "1", Options: map[string]interface{}{"shell": "/bin/fish", "groups": []interface{}{"adm", "docker"}}},
+	}
+	posix, err := builder.userToPosix(user, roles)
+	require.NoError(t, err)
+	assert.Equal(t, "/bin/sh", posix.Shell, "the last rolebinding by uuid wins")
+	assert.Equal(t, "Vasya Pupkin", posix.Gecos)
+	assert.Equal(t, 999, posix.Gid)
+	assert.Equal(t, []string{"adm", "docker"}, posix.Groups)
+	assert.Equal(t, []string{"ALL=(ALL) NOPASSWD: /usr/bin/systemctl", model.SudoAllRule}, posix.SudoRules)
+
+	_, err = builder.userToPosix(user, []iam_usecase.EffectiveRole{
+		{RoleBindingUUID: "1", Options: map[string]interface{}{"sudo_rules": []interface{}{"ALL\nroot ALL=(ALL) ALL"}}},
+	})
+	require.Error(t, err)
+}
+
 // emulates pipeline flant_iam -> kafka -> flant_iam_auth
 func marshallUnmarshal(in map[string]interface{}) (map[string]interface{}, error) {
 	tmp, err := json.Marshal(in)
@@ -2,6 +2,7 @@ package fixtures
 
 import (
 	"encoding/hex"
+	"fmt"
 	"math/rand"
 	"time"
 
@@ -20,27 +21,48 @@ func Policies() []model.Policy {
 		{
 			ArchiveMark: memdb.ArchiveMark{},
 			Name:        PolicyName1,
-			Rego:        "regogo1", // TODO
+			Rego:        PolicyRego(PolicyName1),
 			Roles:       []string{iam_fixtures.RoleName1, iam_fixtures.RoleName2},
-			ClaimSchema: "claim_schema1", // TODO
+			ClaimSchema: ClaimSchema,
 		},
 		{
 			ArchiveMark: memdb.ArchiveMark{},
 			Name:        PolicyName2,
-			Rego:        "regogo2", // TODO
+			Rego:        PolicyRego(PolicyName2),
 			Roles:       []string{iam_fixtures.RoleName1, iam_fixtures.RoleName2},
-			ClaimSchema: "claim_schema2", // TODO
+			ClaimSchema: ClaimSchema,
 		},
 	}
 }
 
+const ClaimSchema = `{"type": "object"}`
+
+// PolicyRego returns minimal valid rego for the policy with passed name
+func PolicyRego(policyName string) string {
+	return fmt.Sprintf(`package negentropy.%s
+
+default allow = false
+
+allow {
+	count(data.effective_roles) > 0
+}
+
+rules = [] {allow}
+
+ttl := "600s" {allow}
+
+max_ttl := "1200s" {allow}
+`, policyName)
+}
+
 func RandomPolicyCreatePayload() map[string]interface{} {
 	policySet := Policies()
 	rand.Seed(time.Now().UnixNano())
 	sample := policySet[rand.Intn(len(policySet))]
+	name := "policy_" + RandomStr()
 	return map[string]interface{}{
-		"name":         "policy_" + RandomStr(),
-		"rego":         sample.Rego,
+		"name":         name,
+		"rego":         PolicyRego(name),
 		"roles":        sample.Roles,
 		"claim_schema": sample.ClaimSchema,
 	}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	auth_io "github.com/flant/negentropy/vault-plugins/flant_iam_auth/io"
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/model"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
 	jwtkafka "github.com/flant/negentropy/vault-plugins/shared/jwt/kafka"
@@ -103,10 +104,14 @@ func (mkd *SelfKafkaDestination) simpleObjectKafker(topic string, obj io.MemoryS
 	}
 
 	msg := kafka.Message{
-		Topic:   topic,
-		Key:     key,
-		Value:   data,
-		Headers: map[string][]byte{"signature": sign},
+		Topic: topic,
+		Key:   key,
+		Value: data,
+		Headers: map[string][]byte{
+			"signature":            sign,
+			kafka.KeyIDHeader:      []byte(kafka.KeyID(pub)),
+			io.SchemaVersionHeader: auth_io.SchemaRegistry.VersionHeader(obj.ObjType()),
+		},
 	}
 
 	if chunked {
@@ -4,13 +4,18 @@ import (
 	"crypto/sha256"
 
 	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
 
+	iam_io "github.com/flant/negentropy/vault-plugins/flant_iam/io"
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/io/kafka_handlers/root"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
 	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
 )
 
-func NewRootKafkaSource(kf *sharedkafka.MessageBroker, modelsHandler root.ModelHandler, parentLogger hclog.Logger) *io.KafkaSourceImpl {
+// NewRootKafkaSource returns the source of the replica topic, messages are verified by all trusted keys of the root source,
+// new keys are passed by sharedkafka.RootPublicKeyType messages and are stored into the plugin config at storage
+func NewRootKafkaSource(storage logical.Storage, kf *sharedkafka.MessageBroker, modelsHandler root.ModelHandler,
+	parentLogger hclog.Logger) *io.KafkaSourceImpl {
 	runConsumerGroupIDProvider := func(kf *sharedkafka.MessageBroker) string {
 		return kf.PluginConfig.SelfTopicName
 	}
@@ -19,14 +24,23 @@ func NewRootKafkaSource(kf *sharedkafka.MessageBroker, modelsHandler root.ModelH
 	}
 	verifySign := func(signature []byte, messageValue []byte) error {
 		hashed := sha256.Sum256(messageValue)
-		return sharedkafka.VerifySignature(signature, kf.PluginConfig.RootPublicKey, hashed)
+		return kf.VerifyRootSignature(signature, hashed)
 	}
-	decrypt := func(encryptedMessageValue []byte, chunked bool) ([]byte, error) {
-		return sharedkafka.NewEncrypter().Decrypt(encryptedMessageValue, kf.EncryptionPrivateKey(), chunked)
+	decrypt := func(encryptedMessageValue []byte, chunked bool, keyID string) ([]byte, error) {
+		return kf.Decrypt(encryptedMessageValue, chunked, keyID)
 	}
 	processRunMessage := func(txn io.Txn, msg io.MsgDecoded) error {
+		if handled, err := io.HandleRootPublicKey(kf, storage, msg); handled || err != nil {
+			return err
+		}
 		return root.HandleNewMessageIamRootSource(txn, modelsHandler, msg)
 	}
+	processRestoreMessage := func(txn io.Txn, msg io.MsgDecoded) error {
+		if handled, err := io.HandleRootPublicKey(kf, storage, msg); handled || err != nil {
+			return err
+		}
+		return root.HandleRestoreMessagesRootSource(txn, msg)
+	}
 
 	return &io.KafkaSourceImpl{
 		NameOfSource:              "authRootKafkaSource",
@@ -36,8 +50,9 @@ func NewRootKafkaSource(kf *sharedkafka.MessageBroker, modelsHandler root.ModelH
 		ProvideTopicName:          topicNameProvider,
 		VerifySign:                verifySign,
 		Decrypt:                   decrypt,
+		SchemaRegistry:            iam_io.SchemaRegistry,
 		ProcessRunMessage:         processRunMessage,
-		ProcessRestoreMessage:     root.HandleRestoreMessagesRootSource,
+		ProcessRestoreMessage:     processRestoreMessage,
 		Runnable:                  true,
 	}
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/hashicorp/go-hclog"
 
+	auth_io "github.com/flant/negentropy/vault-plugins/flant_iam_auth/io"
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/io/kafka_handlers/self"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
 	jwtkafka "github.com/flant/negentropy/vault-plugins/shared/jwt/kafka"
@@ -20,10 +21,10 @@ func NewSelfKafkaSource(kf *sharedkafka.MessageBroker, handler self.ModelHandler
 	}
 	verifySign := func(signature []byte, messageValue []byte) error {
 		hashed := sha256.Sum256(messageValue)
-		return sharedkafka.VerifySignature(signature, kf.EncryptionPublicKey(), hashed)
+		return kf.VerifyOwnSignature(signature, hashed)
 	}
-	decrypt := func(encryptedMessageValue []byte, chunked bool) ([]byte, error) {
-		return sharedkafka.NewEncrypter().Decrypt(encryptedMessageValue, kf.EncryptionPrivateKey(), chunked)
+	decrypt := func(encryptedMessageValue []byte, chunked bool, keyID string) ([]byte, error) {
+		return kf.Decrypt(encryptedMessageValue, chunked, keyID)
 	}
 	processRunMessage := func(txn io.Txn, msg io.MsgDecoded) error {
 		return self.HandleNewMessageSelfSource(txn, handler, &msg)
@@ -42,6 +43,7 @@ func NewSelfKafkaSource(kf *sharedkafka.MessageBroker, handler self.ModelHandler
 		ProvideTopicName:          topicNameProvider,
 		VerifySign:                verifySign,
 		Decrypt:                   decrypt,
+		SchemaRegistry:            auth_io.SchemaRegistry,
 		ProcessRunMessage:         processRunMessage,
 		ProcessRestoreMessage:     processRestoreMessage,
 		Runnable:                  true,
@@ -0,0 +1,21 @@
+package io
+
+import (
+	iam_io "github.com/flant/negentropy/vault-plugins/flant_iam/io"
+	sharedio "github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+// authSchemaRegistry keeps upgrades of data of objects, which exist only at flant_iam_auth
+var authSchemaRegistry = sharedio.NewSchemaRegistry()
+
+// SchemaRegistry keeps upgrades of data of all objects at kafka topics of the plugin, including flant_iam objects,
+// as they are replicated into the plugin
+var SchemaRegistry = mustMergeSchemaRegistries(iam_io.SchemaRegistry, authSchemaRegistry)
+
+func mustMergeSchemaRegistries(registries ...*sharedio.SchemaRegistry) *sharedio.SchemaRegistry {
+	registry, err := sharedio.MergeSchemaRegistries(registries...)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
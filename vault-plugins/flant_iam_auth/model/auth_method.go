@@ -50,6 +50,9 @@ type AuthMethod struct {
 	AllowedRedirectURIs []string               `json:"allowed_redirect_uris"`
 	VerboseOIDCLogging  bool                   `json:"verbose_oidc_logging"`
 	MaxAge              time.Duration          `json:"max_age"`
+
+	// TrustedProxyCIDRs are networks of proxies, which pass the client address in the X-Forwarded-For header
+	TrustedProxyCIDRs []string `json:"trusted_proxy_cidrs"`
 }
 
 func (p *AuthMethod) ObjType() string {
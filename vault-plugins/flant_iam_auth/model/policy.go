@@ -7,6 +7,10 @@ import (
 
 const PolicyType = "policy" // also, memdb schema name
 
+// PolicyPreviousVersionsLimit limits count of overwritten versions kept in the policy,
+// the policy is replicated with all its versions, so the oldest versions are dropped
+const PolicyPreviousVersionsLimit = 10
+
 type Policy struct {
 	memdb.ArchiveMark
 
@@ -15,6 +19,35 @@ type Policy struct {
 	Roles              []iam.RoleName `json:"roles"`
 	ClaimSchema        string         `json:"claim_schema"`
 	AllowedAuthMethods []string       `json:"allowed_auth_methods"`
+
+	// Version is a number of the current version of the policy, starting from 1
+	Version int `json:"version"`
+	// VersionCreatedAt is unix time of creating the current version
+	VersionCreatedAt int64 `json:"version_created_at"`
+	// PreviousVersions keeps last PolicyPreviousVersionsLimit overwritten versions of the policy, sorted by version
+	PreviousVersions []PolicyVersion `json:"previous_versions,omitempty"`
+}
+
+// PolicyVersion is a snapshot of the policy content
+type PolicyVersion struct {
+	Version            int            `json:"version"`
+	Rego               string         `json:"rego"`
+	Roles              []iam.RoleName `json:"roles"`
+	ClaimSchema        string         `json:"claim_schema"`
+	AllowedAuthMethods []string       `json:"allowed_auth_methods"`
+	CreatedAt          int64          `json:"created_at"`
+}
+
+// CurrentVersion returns snapshot of the current content of the policy
+func (p *Policy) CurrentVersion() PolicyVersion {
+	return PolicyVersion{
+		Version:            p.Version,
+		Rego:               p.Rego,
+		Roles:              p.Roles,
+		ClaimSchema:        p.ClaimSchema,
+		AllowedAuthMethods: p.AllowedAuthMethods,
+		CreatedAt:          p.VersionCreatedAt,
+	}
 }
 
 func (p *Policy) ObjType() string {
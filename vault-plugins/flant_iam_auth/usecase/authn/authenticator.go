@@ -31,6 +31,17 @@ type Result struct {
 	Claims map[string]interface{}
 }
 
+// PassedMFA returns true, if the login passed multi-factor authentication, it is known only by the "amr" claim of jwt
+func (r *Result) PassedMFA() bool {
+	amr, _ := r.Claims["amr"].([]interface{})
+	for _, method := range amr {
+		if method == "mfa" {
+			return true
+		}
+	}
+	return false
+}
+
 type Authenticator interface {
 	Authenticate(ctx context.Context, d *framework.FieldData) (*Result, error)
 	CanRenew(vaultAuth *logical.Auth) (bool, error)
@@ -260,6 +260,9 @@ func collectProjectUUIDsFromRoleBindings(rbs1 map[iam.RoleBindingUUID]*iam.RoleB
 // processRoleBinding check rb and write to given pointers
 func processRoleBinding(rb *iam.RoleBinding, fullTenants *map[iam.TenantUUID]struct{},
 	projectRepo *iam_repo.ProjectRepository, result *map[iam.ProjectUUID]struct{}) error {
+	if rb.Deny {
+		return nil
+	}
 	if rb.AnyProject {
 		if _, processedTenant := (*fullTenants)[rb.TenantUUID]; !processedTenant {
 			(*fullTenants)[rb.TenantUUID] = struct{}{}
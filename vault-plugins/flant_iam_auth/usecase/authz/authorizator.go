@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff"
@@ -33,6 +35,7 @@ type Authorizator struct {
 	EaRepo                 *repo.EntityAliasRepo
 	RoleRepo               *iam_repo.RoleRepository
 	RoleBindingsRepository *iam_repo.RoleBindingRepository
+	ApprovalRepo           *iam_repo.RoleBindingApprovalRepository
 	PolicyRepo             *repo.PolicyRepository
 	RolesResolver          iam_usecase.RoleResolver
 
@@ -42,6 +45,9 @@ type Authorizator struct {
 
 	MountAccessor *vault.MountAccessorGetter
 
+	// RemoteAddr is a source address of the request, it is used for checking conditions of rolebindings
+	RemoteAddr string
+
 	Logger              hclog.Logger
 	vaultClientProvider client.AccessVaultClientController
 }
@@ -68,6 +74,7 @@ func NewAutorizator(txn *io.MemoryStoreTxn, vaultClientController client.AccessV
 		EntityRepo:             repo.NewEntityRepo(txn),
 		RoleRepo:               iam_repo.NewRoleRepository(txn),
 		RoleBindingsRepository: iam_repo.NewRoleBindingRepository(txn),
+		ApprovalRepo:           iam_repo.NewRoleBindingApprovalRepository(txn),
 		PolicyRepo:             repo.NewPolicyRepository(txn),
 		RolesResolver:          iam_usecase.NewRoleResolver(txn),
 
@@ -91,16 +98,28 @@ type RoleClaimResult struct {
 	RequireMFA        bool   `json:"require_mfa,omitempty"`
 	NeedApprovals     bool   `json:"need_approvals,omitempty"`
 	Err               string `json:"error,omitempty"`
+	// UnsatisfiedConditions are rolebindings, which are ignored due to their conditions
+	UnsatisfiedConditions []UnsatisfiedCondition `json:"unsatisfied_conditions,omitempty"`
+}
+
+// UnsatisfiedCondition describes why condition of the rolebinding is not satisfied
+type UnsatisfiedCondition struct {
+	RoleBindingUUID iam.RoleBindingUUID `json:"rolebinding_uuid"`
+	Err             string              `json:"error"`
 }
 
 func (a *Authorizator) CheckPermissions(authMethodName string, subject model.Subject, roleClaims []model.RoleClaim) []RoleClaimResult {
 	rowResults := a.checkPermissions(authMethodName, subject, roleClaims)
 	results := make([]RoleClaimResult, 0, len(rowResults))
 	for _, rowResult := range rowResults {
+		rolebindingExists := len(rowResult.effectiveRoles) > 0 || len(rowResult.pendingRoles) > 0 ||
+			len(rowResult.unsatisfiedRoles) > 0
 		result := RoleClaimResult{
-			RoleClaim:         rowResult.loginClaim,
-			RolebindingExists: len(rowResult.effectiveRoles) > 0,
-			AllowLogin:        rowResult.regoresult.Allow,
+			RoleClaim:             rowResult.loginClaim,
+			RolebindingExists:     rolebindingExists,
+			AllowLogin:            rowResult.regoresult.Allow,
+			NeedApprovals:         !rowResult.regoresult.Allow && len(rowResult.pendingRoles) > 0,
+			UnsatisfiedConditions: rowResult.unsatisfiedConditions,
 		}
 		if rowResult.err != nil {
 			result.Err = rowResult.err.Error()
@@ -119,7 +138,12 @@ type tryLoginResult struct {
 	loginClaim     model.RoleClaim
 	regoresult     RegoResult
 	effectiveRoles []iam_usecase.EffectiveRole
-	err            error
+	// pendingRoles are effective roles given by rolebindings, which are waiting for approvals, they are ignored
+	pendingRoles []iam_usecase.EffectiveRole
+	// unsatisfiedRoles are effective roles given by rolebindings, which conditions are not satisfied, they are ignored
+	unsatisfiedRoles      []iam_usecase.EffectiveRole
+	unsatisfiedConditions []UnsatisfiedCondition
+	err                   error
 }
 
 // checkPermissions validate all permissions request and store results
@@ -138,12 +162,15 @@ func (a *Authorizator) checkPermissions(authMethodName string, subject model.Sub
 			continue
 		}
 
-		item.effectiveRoles, err = a.checkScopeAndCollectEffectiveRoles(rc, subject)
+		effectiveRoles, err := a.checkScopeAndCollectEffectiveRoles(rc, subject)
 		if err != nil {
 			item.err = err
 			result = append(result, item)
 			continue
 		}
+		loginCtx := iam_usecase.LoginContext{RemoteAddr: a.RemoteAddr, AuthMethod: authMethodName, Time: time.Now()}
+		effectiveRoles, item.unsatisfiedRoles, item.unsatisfiedConditions = splitUnsatisfiedEffectiveRoles(effectiveRoles, loginCtx)
+		item.effectiveRoles, item.pendingRoles = splitPendingEffectiveRoles(effectiveRoles)
 
 		negentropyPolicy, err := a.seekAndValidatePolicy(rc.Role, authMethodName)
 		if err != nil {
@@ -153,12 +180,14 @@ func (a *Authorizator) checkPermissions(authMethodName string, subject model.Sub
 		}
 
 		regoResult, err := a.applyNegentropyPolicy(subject, rc, *negentropyPolicy, item.effectiveRoles)
+		if regoResult != nil {
+			item.regoresult = *regoResult
+		}
 		if err != nil {
 			item.err = err
 			result = append(result, item)
 			continue
 		}
-		item.regoresult = *regoResult
 		result = append(result, item)
 	}
 	return result
@@ -193,12 +222,16 @@ func (a *Authorizator) Authorize(authnResult *authn2.Result, method *model.AuthM
 
 	method.PopulateTokenAuth(authzRes)
 
-	err = a.addDynamicPolicy(authzRes, roleClaims, subject, method.Name)
+	err = a.addDynamicPolicy(authzRes, roleClaims, subject, method.Name, authnResult.PassedMFA())
 	if err != nil {
 		return nil, err
 	}
 
 	authzRes.InternalData["flantIamAuthMethod"] = method.Name
+	if authzRes.Metadata == nil {
+		authzRes.Metadata = map[string]string{}
+	}
+	authzRes.Metadata[mfaMetadataKey] = strconv.FormatBool(authnResult.PassedMFA())
 
 	a.Logger.Debug(fmt.Sprintf("Token auth populated %s", fullId))
 
@@ -251,8 +284,20 @@ func (a *Authorizator) authorizeTokenOwner(subjectDescriptor string, method *mod
 	return authzRes, tokenOwnerFullIdentifier, subjectUUID, nil
 }
 
+const (
+	// mfaMetadataKey is the token metadata key, which keeps the fact of passing MFA by the login
+	mfaMetadataKey = "mfa"
+
+	flantIamPathPrefix       = "flant/"
+	flantIamTenantPathPrefix = flantIamPathPrefix + "tenant/"
+	// flantIamMFAPathPrefix prefixes flant_iam paths, which accept only MFA-verified requests
+	flantIamMFAPathPrefix = flantIamPathPrefix + "mfa/"
+)
+
 // addDynamicPolicy build ONE vault policy for all roleClaims if all are allowed
-func (a *Authorizator) addDynamicPolicy(authzRes *logical.Auth, roleClaims []model.RoleClaim, subject model.Subject, authMethod string) error {
+// for the login passed MFA, rules are duplicated for mfa paths of flant_iam, requests through them are MFA-verified
+func (a *Authorizator) addDynamicPolicy(authzRes *logical.Auth, roleClaims []model.RoleClaim, subject model.Subject,
+	authMethod string, mfa bool) error {
 	loginItems := a.checkPermissions(authMethod, subject, roleClaims)
 	if len(loginItems) == 0 {
 		return nil
@@ -286,6 +331,10 @@ func (a *Authorizator) addDynamicPolicy(authzRes *logical.Auth, roleClaims []mod
 		}
 	}
 
+	if mfa {
+		extraPolicy.Rules = append(extraPolicy.Rules, mfaRules(extraPolicy.Rules)...)
+	}
+
 	extraPolicy.AddValidTillToName(time.Now().Add(maxTTL))
 
 	err := a.createDynamicPolicy(extraPolicy)
@@ -303,6 +352,19 @@ func (a *Authorizator) addDynamicPolicy(authzRes *logical.Auth, roleClaims []mod
 	return nil
 }
 
+// mfaRules returns copies of rules for flant_iam tenant paths, moved under the mfa prefix of flant_iam
+func mfaRules(rules []Rule) []Rule {
+	var result []Rule
+	for _, rule := range rules {
+		if strings.HasPrefix(rule.Path, flantIamTenantPathPrefix) {
+			mfaRule := rule
+			mfaRule.Path = flantIamMFAPathPrefix + strings.TrimPrefix(rule.Path, flantIamPathPrefix)
+			result = append(result, mfaRule)
+		}
+	}
+	return result
+}
+
 func strictTTLValues(authzRes *logical.Auth, ttl time.Duration, maxTTL time.Duration) {
 	if authzRes.TTL > ttl || authzRes.TTL == 0 {
 		authzRes.TTL = ttl
@@ -344,7 +406,8 @@ func (a *Authorizator) applyNegentropyPolicy(subject model.Subject, rc model.Rol
 		err = fmt.Errorf("not allowed: subject_type=%s, subject_uuid=%s, rolename=%s, claims=%v, errors, returned by rego:%v",
 			subject.Type, subject.UUID, rc.Role, rc, regoResult.Errors)
 		a.Logger.Error(err.Error())
-		return nil, err
+		// regoResult is returned for explaining the reason of denial
+		return regoResult, err
 	}
 
 	return regoResult, nil
@@ -397,6 +460,33 @@ func (a *Authorizator) checkScopeAndCollectEffectiveRoles(rc model.RoleClaim, su
 	return effectiveRoles, nil
 }
 
+// splitPendingEffectiveRoles separates effective roles given by rolebindings waiting for approvals
+func splitPendingEffectiveRoles(effectiveRoles []iam_usecase.EffectiveRole) (approved []iam_usecase.EffectiveRole,
+	pending []iam_usecase.EffectiveRole) {
+	for _, er := range effectiveRoles {
+		if er.NeedApprovals > 0 {
+			pending = append(pending, er)
+		} else {
+			approved = append(approved, er)
+		}
+	}
+	return approved, pending
+}
+
+// splitUnsatisfiedEffectiveRoles separates effective roles given by rolebindings with unsatisfied conditions
+func splitUnsatisfiedEffectiveRoles(effectiveRoles []iam_usecase.EffectiveRole, loginCtx iam_usecase.LoginContext) (
+	satisfied []iam_usecase.EffectiveRole, unsatisfied []iam_usecase.EffectiveRole, conditions []UnsatisfiedCondition) {
+	for _, er := range effectiveRoles {
+		if err := iam_usecase.CheckRoleBindingCondition(er.Condition, loginCtx); err != nil {
+			unsatisfied = append(unsatisfied, er)
+			conditions = append(conditions, UnsatisfiedCondition{RoleBindingUUID: er.RoleBindingUUID, Err: err.Error()})
+		} else {
+			satisfied = append(satisfied, er)
+		}
+	}
+	return satisfied, unsatisfied, conditions
+}
+
 const (
 	errorScope = iota
 	globalScope
@@ -589,7 +679,7 @@ func (a *Authorizator) Renew(method *model.AuthMethod, auth *logical.Auth, txn *
 		return nil, fmt.Errorf("tokenOwner is deleted")
 	}
 
-	err = a.checkRolebindings(auth)
+	err = a.checkRolebindings(auth, method.Name)
 	if err != nil {
 		return nil, fmt.Errorf("need relogin: %w", err)
 	}
@@ -688,7 +778,7 @@ func (a *Authorizator) registerUsedRoleBindings(authRes *logical.Auth, loginItem
 }
 
 // checkRolebindings checks are all rolebindings active and didn't changed
-func (a *Authorizator) checkRolebindings(auth *logical.Auth) error {
+func (a *Authorizator) checkRolebindings(auth *logical.Auth, authMethodName string) error {
 	rawRolebindings, exists := auth.InternalData[rolebindingsOfAuth]
 	if !exists {
 		return nil // login was without any rolebindings
@@ -698,6 +788,7 @@ func (a *Authorizator) checkRolebindings(auth *logical.Auth) error {
 		return fmt.Errorf("auth contains wrong type of :%q", rolebindingsOfAuth)
 	}
 	rolebindings := makeRoleBindingVersions(rawRolebindings2)
+	loginCtx := iam_usecase.LoginContext{RemoteAddr: a.RemoteAddr, AuthMethod: authMethodName, Time: time.Now()}
 	errs := multierror.Error{}
 	for _, rbv := range rolebindings {
 		rolebinding, err := a.RoleBindingsRepository.GetByID(rbv.RoleBindingUUID)
@@ -717,6 +808,20 @@ func (a *Authorizator) checkRolebindings(auth *logical.Auth) error {
 			errs.Errors = append(errs.Errors, fmt.Errorf("rolebinding %s is changed", rbv.RoleBindingUUID))
 			continue
 		}
+		pending, err := a.ApprovalRepo.PendingRoleBindingApprovalCount(rbv.RoleBindingUUID)
+		if err != nil {
+			errs.Errors = append(errs.Errors, fmt.Errorf("checking approvals of rolebinding %s: %w", rbv.RoleBindingUUID, err))
+			continue
+		}
+		if pending > 0 {
+			errs.Errors = append(errs.Errors, fmt.Errorf("rolebinding %s is pending approvals", rbv.RoleBindingUUID))
+			continue
+		}
+		if err = iam_usecase.CheckRoleBindingCondition(rolebinding.Condition, loginCtx); err != nil {
+			errs.Errors = append(errs.Errors, fmt.Errorf("condition of rolebinding %s is not satisfied: %w",
+				rbv.RoleBindingUUID, err))
+			continue
+		}
 	}
 	return errs.ErrorOrNil()
 }
@@ -0,0 +1,45 @@
+package authz
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_usecase "github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
+)
+
+func Test_splitUnsatisfiedEffectiveRoles(t *testing.T) {
+	effectiveRoles := []iam_usecase.EffectiveRole{
+		{RoleBindingUUID: "rb1"},
+		{RoleBindingUUID: "rb2", Condition: &iam.RoleBindingCondition{SourceCIDRs: []string{"10.0.0.0/8"}}},
+		{RoleBindingUUID: "rb3", Condition: &iam.RoleBindingCondition{AuthMethods: []string{"okta"}}},
+	}
+
+	satisfied, unsatisfied, conditions := splitUnsatisfiedEffectiveRoles(effectiveRoles,
+		iam_usecase.LoginContext{RemoteAddr: "10.2.3.4", AuthMethod: "multipass", Time: time.Now()})
+
+	require.Len(t, satisfied, 2)
+	require.Equal(t, "rb1", satisfied[0].RoleBindingUUID)
+	require.Equal(t, "rb2", satisfied[1].RoleBindingUUID)
+	require.Len(t, unsatisfied, 1)
+	require.Equal(t, "rb3", unsatisfied[0].RoleBindingUUID)
+	require.Len(t, conditions, 1)
+	require.Equal(t, "rb3", conditions[0].RoleBindingUUID)
+	require.Contains(t, conditions[0].Err, "multipass")
+}
+
+func Test_mfaRules(t *testing.T) {
+	rules := []Rule{
+		{Path: "flant/tenant/t1/rolebinding_approval/*", Update: true},
+		{Path: "auth/flant/tenant/t1/query_server", Read: true},
+	}
+
+	result := mfaRules(rules)
+
+	require.Len(t, result, 1)
+	require.Equal(t, "flant/mfa/tenant/t1/rolebinding_approval/*", result[0].Path)
+	require.True(t, result[0].Update)
+	require.Equal(t, "flant/tenant/t1/rolebinding_approval/*", rules[0].Path)
+}
@@ -248,7 +248,14 @@ func (c *EffectiveRoleChecker) enrichTenantResult(projectsResults map[iam_model.
 			return nil, fmt.Errorf("collecting all projects for tenant: %s: %w", er.TenantUUID, err)
 		}
 	}
+	excluded := map[iam_model.ProjectUUID]struct{}{}
+	for _, projectUUID := range er.ExcludedProjects {
+		excluded[projectUUID] = struct{}{}
+	}
 	for _, projectUUID := range projectsOfER {
+		if _, isExcluded := excluded[projectUUID]; isExcluded {
+			continue
+		}
 		effectiveRoleProjectResult, exists := projectsResults[projectUUID]
 		if !exists {
 			effectiveRoleProjectResult = EffectiveRoleProjectResult{
@@ -35,3 +35,21 @@ func Test_mapToEffectiveRoleResult(t *testing.T) {
 	},
 		results)
 }
+
+func Test_mapToEffectiveRoleResultWithExcludedProjects(t *testing.T) {
+	tx := usecase.RunFixtures(t, usecase.RoleFixture, usecase.TenantFixture, usecase.ProjectFixture).Txn(false)
+	effectiveRoles := map[string][]usecase.EffectiveRole{
+		fixtures.RoleName1: {usecase.EffectiveRole{
+			RoleName: fixtures.RoleName1, RoleBindingUUID: "cbe03126-d3fb-49f1-b098-14a2840e5e0a",
+			TenantUUID: fixtures.TenantUUID1, AnyProject: true, ExcludedProjects: []string{fixtures.ProjectUUID1},
+		}},
+	}
+	checker := NewEffectiveRoleChecker(tx)
+
+	results, err := checker.mapToEffectiveRoleResult(effectiveRoles)
+
+	require.NoError(t, err)
+	projects := results[fixtures.RoleName1][fixtures.TenantUUID1].projects
+	require.NotContains(t, projects, fixtures.ProjectUUID1)
+	require.Contains(t, projects, fixtures.ProjectUUID2)
+}
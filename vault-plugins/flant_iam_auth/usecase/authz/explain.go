@@ -0,0 +1,261 @@
+package authz
+
+import (
+	"fmt"
+	"sort"
+
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	iam_usecase "github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
+	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/model"
+	"github.com/flant/negentropy/vault-plugins/shared/io"
+)
+
+// AccessExplanation represents all collected data of authorization pipeline for one role claim
+type AccessExplanation struct {
+	RoleClaim model.RoleClaim `json:"role_claim"`
+	// Groups are all groups of the subject, including parents
+	Groups []iam.GroupUUID `json:"groups"`
+	// RoleChains are all roles which include claimed role, with options templates chains
+	RoleChains   []RoleChainExplanation        `json:"role_chains"`
+	RoleBindings []RoleBindingExplanation      `json:"role_bindings"`
+	FeatureFlags *FeatureFlagsExplanation      `json:"feature_flags,omitempty"`
+	Policy       *model.Policy                 `json:"policy,omitempty"`
+	RegoResult   interface{}                   `json:"rego_result,omitempty"`
+	RegoErrors   []string                      `json:"rego_errors,omitempty"`
+	BestRole     *iam_usecase.EffectiveRole    `json:"best_effective_role,omitempty"`
+	AllowLogin   bool                          `json:"allow_login"`
+	Err          string                        `json:"error,omitempty"`
+	Stages       []AccessExplanationStageError `json:"stage_errors,omitempty"`
+}
+
+// AccessExplanationStageError is an error of some stage of collecting explanation
+type AccessExplanationStageError struct {
+	Stage string `json:"stage"`
+	Err   string `json:"error"`
+}
+
+type RoleChainExplanation struct {
+	RoleName         iam.RoleName `json:"rolename"`
+	OptionsTemplates []string     `json:"options_templates"`
+}
+
+type RoleBindingExplanation struct {
+	RoleBindingUUID iam.RoleBindingUUID `json:"rolebinding_uuid"`
+	Description     string              `json:"description"`
+	TenantUUID      iam.TenantUUID      `json:"tenant_uuid"`
+	ValidTill       int64               `json:"valid_till"`
+	// BoundRole is a role of the rolebinding, which gives claimed role
+	BoundRole iam.RoleName `json:"bound_role"`
+	// DirectMember is true if the subject is a member of the rolebinding itself
+	DirectMember bool `json:"direct_member"`
+	// ViaGroups are groups of the subject which are members of the rolebinding
+	ViaGroups     []iam.GroupUUID `json:"via_groups"`
+	NeedApprovals int64           `json:"need_approvals"`
+	// Condition is a condition of the rolebinding, ConditionErr is filled if it is not satisfied
+	Condition    *iam.RoleBindingCondition `json:"condition,omitempty"`
+	ConditionErr string                    `json:"condition_error,omitempty"`
+}
+
+type FeatureFlagsExplanation struct {
+	RequireOneOf    []iam.FeatureFlagName `json:"require_one_of"`
+	TenantFlags     []iam.FeatureFlagName `json:"tenant_flags"`
+	ProjectFlags    []iam.FeatureFlagName `json:"project_flags"`
+	Satisfied       bool                  `json:"satisfied"`
+	SatisfiedByFlag iam.FeatureFlagName   `json:"satisfied_by,omitempty"`
+}
+
+// ExplainAccess runs the authorization pipeline for each role claim, and collects all used data without issuing any token
+func (a *Authorizator) ExplainAccess(txn *io.MemoryStoreTxn, authMethodName string, subject model.Subject,
+	roleClaims []model.RoleClaim) []AccessExplanation {
+	explainer := &accessExplainer{
+		authorizator: a,
+		groupRepo:    iam_repo.NewGroupRepository(txn),
+		tenantRepo:   iam_repo.NewTenantRepository(txn),
+		projectRepo:  iam_repo.NewProjectRepository(txn),
+	}
+	loginResults := a.checkPermissions(authMethodName, subject, roleClaims)
+	results := make([]AccessExplanation, 0, len(loginResults))
+	for _, loginResult := range loginResults {
+		results = append(results, explainer.explain(authMethodName, subject, loginResult))
+	}
+	return results
+}
+
+type accessExplainer struct {
+	authorizator *Authorizator
+	groupRepo    *iam_repo.GroupRepository
+	tenantRepo   *iam_repo.TenantRepository
+	projectRepo  *iam_repo.ProjectRepository
+}
+
+func (e *accessExplainer) explain(authMethodName string, subject model.Subject, loginResult tryLoginResult) AccessExplanation {
+	rc := loginResult.loginClaim
+	result := AccessExplanation{
+		RoleClaim:  rc,
+		AllowLogin: loginResult.err == nil && loginResult.regoresult.Allow,
+		RegoResult: loginResult.regoresult.RawResult,
+		RegoErrors: loginResult.regoresult.Errors,
+		BestRole:   loginResult.regoresult.BestEffectiveRole,
+	}
+	if loginResult.err != nil {
+		result.Err = loginResult.err.Error()
+	}
+	addStageErr := func(stage string, err error) {
+		result.Stages = append(result.Stages, AccessExplanationStageError{Stage: stage, Err: err.Error()})
+	}
+
+	groups, err := e.subjectGroups(subject)
+	if err != nil {
+		addStageErr("groups", err)
+	}
+	result.Groups = sortedGroups(groups)
+
+	chains, err := e.authorizator.RoleRepo.FindAllAncestorsRoles(rc.Role)
+	if err != nil {
+		addStageErr("role_chains", err)
+	}
+	result.RoleChains = roleChainExplanations(chains)
+
+	effectiveRoles := append(append([]iam_usecase.EffectiveRole{}, loginResult.effectiveRoles...), loginResult.pendingRoles...)
+	effectiveRoles = append(effectiveRoles, loginResult.unsatisfiedRoles...)
+	result.RoleBindings, err = e.roleBindingExplanations(subject, effectiveRoles, groups, chains,
+		loginResult.unsatisfiedConditions)
+	if err != nil {
+		addStageErr("role_bindings", err)
+	}
+
+	result.FeatureFlags, err = e.featureFlagsExplanation(rc)
+	if err != nil {
+		addStageErr("feature_flags", err)
+	}
+
+	policy, err := e.authorizator.seekAndValidatePolicy(rc.Role, authMethodName)
+	if err != nil {
+		addStageErr("policy", err)
+	}
+	result.Policy = policy
+	return result
+}
+
+func (e *accessExplainer) subjectGroups(subject model.Subject) (map[iam.GroupUUID]struct{}, error) {
+	switch subject.Type {
+	case iam.UserType:
+		return e.groupRepo.FindAllParentGroupsForUserUUID(subject.UUID)
+	case iam.ServiceAccountType:
+		return e.groupRepo.FindAllParentGroupsForServiceAccountUUID(subject.UUID)
+	}
+	return nil, fmt.Errorf("wrong subject type: %s", subject.Type)
+}
+
+func (e *accessExplainer) roleBindingExplanations(subject model.Subject, effectiveRoles []iam_usecase.EffectiveRole,
+	groups map[iam.GroupUUID]struct{}, chains map[iam.RoleName]iam_repo.RoleChain,
+	unsatisfiedConditions []UnsatisfiedCondition) ([]RoleBindingExplanation, error) {
+	conditionErrs := map[iam.RoleBindingUUID]string{}
+	for _, c := range unsatisfiedConditions {
+		conditionErrs[c.RoleBindingUUID] = c.Err
+	}
+	result := make([]RoleBindingExplanation, 0, len(effectiveRoles))
+	for _, er := range effectiveRoles {
+		rb, err := e.authorizator.RoleBindingsRepository.GetByID(er.RoleBindingUUID)
+		if err != nil {
+			return result, fmt.Errorf("getting rolebinding %s:%w", er.RoleBindingUUID, err)
+		}
+		explanation := RoleBindingExplanation{
+			RoleBindingUUID: rb.UUID,
+			Description:     rb.Description,
+			TenantUUID:      rb.TenantUUID,
+			ValidTill:       rb.ValidTill,
+			NeedApprovals:   er.NeedApprovals,
+			ViaGroups:       []iam.GroupUUID{},
+			Condition:       rb.Condition,
+			ConditionErr:    conditionErrs[rb.UUID],
+		}
+		for _, boundRole := range rb.Roles {
+			if _, ok := chains[boundRole.Name]; ok {
+				explanation.BoundRole = boundRole.Name
+				break
+			}
+		}
+		directMembers := rb.Users
+		if subject.Type == iam.ServiceAccountType {
+			directMembers = rb.ServiceAccounts
+		}
+		for _, m := range directMembers {
+			if m == subject.UUID {
+				explanation.DirectMember = true
+			}
+		}
+		for _, g := range rb.Groups {
+			if _, ok := groups[g]; ok {
+				explanation.ViaGroups = append(explanation.ViaGroups, g)
+			}
+		}
+		result = append(result, explanation)
+	}
+	return result, nil
+}
+
+// featureFlagsExplanation checks role.RequireOneOfFeatureFlags against flags of the claimed tenant and project
+func (e *accessExplainer) featureFlagsExplanation(rc model.RoleClaim) (*FeatureFlagsExplanation, error) {
+	role, err := e.authorizator.RoleRepo.GetByID(rc.Role)
+	if err != nil {
+		return nil, err
+	}
+	result := &FeatureFlagsExplanation{
+		RequireOneOf: role.RequireOneOfFeatureFlags,
+		TenantFlags:  []iam.FeatureFlagName{},
+		ProjectFlags: []iam.FeatureFlagName{},
+		Satisfied:    len(role.RequireOneOfFeatureFlags) == 0,
+	}
+	available := map[iam.FeatureFlagName]struct{}{}
+	if rc.TenantUUID != "" {
+		tenant, err := e.tenantRepo.GetByID(rc.TenantUUID)
+		if err != nil {
+			return result, fmt.Errorf("getting tenant %s:%w", rc.TenantUUID, err)
+		}
+		for _, ff := range tenant.FeatureFlags {
+			result.TenantFlags = append(result.TenantFlags, ff.Name)
+			available[ff.Name] = struct{}{}
+		}
+	}
+	if rc.ProjectUUID != "" {
+		project, err := e.projectRepo.GetByID(rc.ProjectUUID)
+		if err != nil {
+			return result, fmt.Errorf("getting project %s:%w", rc.ProjectUUID, err)
+		}
+		for _, ff := range project.FeatureFlags {
+			result.ProjectFlags = append(result.ProjectFlags, ff)
+			available[ff] = struct{}{}
+		}
+	}
+	for _, ff := range role.RequireOneOfFeatureFlags {
+		if _, ok := available[ff]; ok {
+			result.Satisfied = true
+			result.SatisfiedByFlag = ff
+			break
+		}
+	}
+	return result, nil
+}
+
+func sortedGroups(groups map[iam.GroupUUID]struct{}) []iam.GroupUUID {
+	result := make([]iam.GroupUUID, 0, len(groups))
+	for g := range groups {
+		result = append(result, g)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func roleChainExplanations(chains map[iam.RoleName]iam_repo.RoleChain) []RoleChainExplanation {
+	result := make([]RoleChainExplanation, 0, len(chains))
+	for _, chain := range chains {
+		result = append(result, RoleChainExplanation{
+			RoleName:         chain.RoleName,
+			OptionsTemplates: chain.OptionsTemplates,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].RoleName < result[j].RoleName })
+	return result
+}
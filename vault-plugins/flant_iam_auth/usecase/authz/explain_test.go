@@ -0,0 +1,81 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam/fixtures"
+	iam "github.com/flant/negentropy/vault-plugins/flant_iam/model"
+	iam_repo "github.com/flant/negentropy/vault-plugins/flant_iam/repo"
+	iam_usecase "github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
+	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/model"
+)
+
+func Test_ExplainRoleBindings(t *testing.T) {
+	tx := iam_usecase.RunFixtures(t, iam_usecase.TenantFixture, iam_usecase.UserFixture, iam_usecase.ServiceAccountFixture,
+		iam_usecase.GroupFixture, iam_usecase.ProjectFixture, iam_usecase.RoleFixture, iam_usecase.RoleBindingFixture).Txn(false)
+	explainer := &accessExplainer{
+		authorizator: &Authorizator{
+			RoleRepo:               iam_repo.NewRoleRepository(tx),
+			RoleBindingsRepository: iam_repo.NewRoleBindingRepository(tx),
+		},
+		groupRepo:   iam_repo.NewGroupRepository(tx),
+		tenantRepo:  iam_repo.NewTenantRepository(tx),
+		projectRepo: iam_repo.NewProjectRepository(tx),
+	}
+	subject := model.Subject{Type: iam.UserType, UUID: fixtures.UserUUID1, TenantUUID: fixtures.TenantUUID1}
+	groups, err := explainer.subjectGroups(subject)
+	require.NoError(t, err)
+	chains, err := explainer.authorizator.RoleRepo.FindAllAncestorsRoles(fixtures.RoleName1)
+	require.NoError(t, err)
+
+	rbs, err := explainer.roleBindingExplanations(subject,
+		[]iam_usecase.EffectiveRole{{RoleName: fixtures.RoleName1, RoleBindingUUID: fixtures.RbUUID1}}, groups, chains,
+		[]UnsatisfiedCondition{{RoleBindingUUID: fixtures.RbUUID1, Err: "out of time window"}})
+
+	require.NoError(t, err)
+	require.Len(t, rbs, 1)
+	require.Equal(t, "out of time window", rbs[0].ConditionErr)
+	require.Equal(t, fixtures.RoleName1, rbs[0].BoundRole)
+	require.True(t, rbs[0].DirectMember)
+	require.Contains(t, rbs[0].ViaGroups, fixtures.GroupUUID2)
+	require.NotContains(t, rbs[0].ViaGroups, fixtures.GroupUUID3)
+}
+
+func Test_ExplainFeatureFlags(t *testing.T) {
+	tx := iam_usecase.RunFixtures(t, iam_usecase.TenantFixture, iam_usecase.ProjectFixture, iam_usecase.RoleFixture).Txn(true)
+	roleRepo := iam_repo.NewRoleRepository(tx)
+	projectRepo := iam_repo.NewProjectRepository(tx)
+	for _, ff := range []iam.FeatureFlagName{"ff1", "ff2"} {
+		require.NoError(t, iam_repo.NewFeatureFlagRepository(tx).Create(&iam.FeatureFlag{Name: ff}))
+	}
+	role, err := roleRepo.GetByID(fixtures.RoleName1)
+	require.NoError(t, err)
+	updatedRole := *role
+	updatedRole.RequireOneOfFeatureFlags = []iam.FeatureFlagName{"ff1", "ff2"}
+	require.NoError(t, roleRepo.Update(&updatedRole))
+	project, err := projectRepo.GetByID(fixtures.ProjectUUID1)
+	require.NoError(t, err)
+	updatedProject := *project
+	updatedProject.FeatureFlags = []iam.FeatureFlagName{"ff2"}
+	require.NoError(t, projectRepo.Update(&updatedProject))
+	explainer := &accessExplainer{
+		authorizator: &Authorizator{RoleRepo: roleRepo},
+		tenantRepo:   iam_repo.NewTenantRepository(tx),
+		projectRepo:  projectRepo,
+	}
+
+	tenantFF, err := explainer.featureFlagsExplanation(model.RoleClaim{
+		Role: fixtures.RoleName1, TenantUUID: fixtures.TenantUUID1,
+	})
+	require.NoError(t, err)
+	projectFF, err := explainer.featureFlagsExplanation(model.RoleClaim{
+		Role: fixtures.RoleName1, TenantUUID: fixtures.TenantUUID1, ProjectUUID: fixtures.ProjectUUID1,
+	})
+
+	require.NoError(t, err)
+	require.False(t, tenantFF.Satisfied)
+	require.True(t, projectFF.Satisfied)
+	require.Equal(t, "ff2", projectFF.SatisfiedByFlag)
+}
@@ -11,6 +11,7 @@ import (
 
 	iam_usecase "github.com/flant/negentropy/vault-plugins/flant_iam/usecase"
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/model"
+	"github.com/flant/negentropy/vault-plugins/shared/metrics"
 )
 
 type RegoPolicy = string
@@ -26,15 +27,20 @@ type RegoResult struct {
 	VaultRules        []Rule
 	TTL               time.Duration
 	MaxTTL            time.Duration
+	// RawResult is the whole value returned by rego policy
+	RawResult interface{}
 }
 
-type rawRegoResult struct {
+// RawRegoResult is decoded value returned by rego policy
+type RawRegoResult struct {
 	Allow         bool                        `json:"allow"`
 	FilteredRoles []iam_usecase.EffectiveRole `json:"filtered_bindings"`
 	Errors        []string                    `json:"errors"`
 	VaultRules    []Rule                      `json:"rules"`
 	TTL           string                      `json:"ttl"`
 	MaxTTL        string                      `json:"max_ttl"`
+	// Value is the whole not decoded value
+	Value interface{} `json:"-"`
 }
 
 // ApplyRegoPolicy parse all arguments and run rego policy
@@ -42,37 +48,18 @@ type rawRegoResult struct {
 func ApplyRegoPolicy(ctx context.Context, negentropyPolicy model.Policy, subject model.Subject,
 	extensionsData map[string]interface{},
 	effectiveRoles []iam_usecase.EffectiveRole, claims LoginClaims) (*RegoResult, error) {
-	data := map[string]interface{}{"effective_roles": effectiveRoles, "subject": subject}
-	for k, v := range extensionsData {
-		data[k] = v
-	}
-
-	store := inmem.NewFromObject(data)
-	rego := rego.New(
-		rego.Store(store),
-		rego.Query("data.negentropy."+negentropyPolicy.Name),
-		rego.Module("negentropy.rego", negentropyPolicy.Rego),
-		rego.Input(claims),
-	)
-
-	// Run evaluation.
-	rs, err := rego.Eval(ctx)
+	tmp, err := evalRegoPolicy(ctx, negentropyPolicy, subject, extensionsData, effectiveRoles, claims)
 	if err != nil {
 		return nil, err
 	}
-	tmp := rs[0].Expressions[0].Value
-	d, err := json.Marshal(tmp)
-	if err != nil {
-		return nil, err
-	}
-	var rawResult rawRegoResult
-	err = json.Unmarshal(d, &rawResult)
+	rawResult, err := decodeRawRegoResult(tmp)
 	if err != nil {
 		return nil, err
 	}
 	result := RegoResult{
-		Allow:  rawResult.Allow,
-		Errors: rawResult.Errors,
+		Allow:     rawResult.Allow,
+		Errors:    rawResult.Errors,
+		RawResult: rawResult.Value,
 	}
 	if !result.Allow {
 		return &result, nil
@@ -105,6 +92,61 @@ func ApplyRegoPolicy(ctx context.Context, negentropyPolicy model.Policy, subject
 	return &result, nil
 }
 
+// DryRunRegoPolicy runs rego policy with passed data and returns decoded result without any processing
+func DryRunRegoPolicy(ctx context.Context, negentropyPolicy model.Policy, subject model.Subject,
+	extensionsData map[string]interface{},
+	effectiveRoles []iam_usecase.EffectiveRole, claims LoginClaims) (*RawRegoResult, error) {
+	tmp, err := evalRegoPolicy(ctx, negentropyPolicy, subject, extensionsData, effectiveRoles, claims)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRawRegoResult(tmp)
+}
+
+func evalRegoPolicy(ctx context.Context, negentropyPolicy model.Policy, subject model.Subject,
+	extensionsData map[string]interface{},
+	effectiveRoles []iam_usecase.EffectiveRole, claims LoginClaims) (interface{}, error) {
+	data := map[string]interface{}{"effective_roles": effectiveRoles, "subject": subject}
+	for k, v := range extensionsData {
+		data[k] = v
+	}
+
+	store := inmem.NewFromObject(data)
+	rego := rego.New(
+		rego.Store(store),
+		rego.Query("data.negentropy."+negentropyPolicy.Name),
+		rego.Module("negentropy.rego", negentropyPolicy.Rego),
+		rego.Input(claims),
+	)
+
+	// Run evaluation.
+	started := time.Now()
+	rs, err := rego.Eval(ctx)
+	metrics.AddSample("rego_eval_duration_seconds", time.Since(started).Seconds(),
+		metrics.Label{Name: "policy", Value: negentropyPolicy.Name})
+	if err != nil {
+		return nil, err
+	}
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return nil, fmt.Errorf("empty result of policy %s", negentropyPolicy.Name)
+	}
+	return rs[0].Expressions[0].Value, nil
+}
+
+func decodeRawRegoResult(value interface{}) (*RawRegoResult, error) {
+	d, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var rawResult RawRegoResult
+	err = json.Unmarshal(d, &rawResult)
+	if err != nil {
+		return nil, err
+	}
+	rawResult.Value = value
+	return &rawResult, nil
+}
+
 func rangeRoles(rs []iam_usecase.EffectiveRole) (*iam_usecase.EffectiveRole, *iam_usecase.EffectiveRole, *iam_usecase.EffectiveRole) {
 	var goodRolebinding, someRolebinding *iam_usecase.EffectiveRole
 	for _, r := range rs {
@@ -308,3 +308,18 @@ func Test_InvalidServer(t *testing.T) {
 	require.Nil(t, result.BestEffectiveRole)
 	require.Nil(t, result.VaultRules)
 }
+
+func Test_DryRunReturnsRawResult(t *testing.T) {
+	claims := Claims("150s", "300s")
+	ctx := context.TODO()
+
+	result, err := DryRunRegoPolicy(ctx, sshPolicy, subject, serverExtData, effectiveRoles, claims)
+
+	require.NoError(t, err)
+	require.True(t, result.Allow)
+	require.Equal(t, "150s", result.TTL)
+	require.Equal(t, "300s", result.MaxTTL)
+	require.Len(t, result.FilteredRoles, 3)
+	require.Equal(t, sshVaultRules, result.VaultRules)
+	require.NotNil(t, result.Value)
+}
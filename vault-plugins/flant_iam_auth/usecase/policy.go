@@ -3,6 +3,7 @@ package usecase
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/model"
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/repo"
@@ -29,6 +30,12 @@ func (s *PolicyService) Create(policy *model.Policy) error {
 	if err != nil && !errors.Is(err, consts.ErrNotFound) {
 		return err
 	}
+	if err = validatePolicy(policy); err != nil {
+		return err
+	}
+	policy.Version = 1
+	policy.VersionCreatedAt = time.Now().Unix()
+	policy.PreviousVersions = nil
 	return s.repo.Create(policy)
 }
 
@@ -40,7 +47,16 @@ func (s *PolicyService) Update(updated *model.Policy) error {
 	if stored.Archived() {
 		return consts.ErrIsArchived
 	}
-	// Validate
+	if err = validatePolicy(updated); err != nil {
+		return err
+	}
+	updated.Version = stored.Version + 1
+	updated.VersionCreatedAt = time.Now().Unix()
+	previous := append(append([]model.PolicyVersion{}, stored.PreviousVersions...), stored.CurrentVersion())
+	if len(previous) > model.PolicyPreviousVersionsLimit {
+		previous = previous[len(previous)-model.PolicyPreviousVersionsLimit:]
+	}
+	updated.PreviousVersions = previous
 	return s.repo.Update(updated)
 }
 
@@ -70,3 +86,46 @@ func (s *PolicyService) GetByID(name model.PolicyName) (*model.Policy, error) {
 func (s *PolicyService) List(showArchived bool) ([]model.PolicyName, error) {
 	return s.repo.ListIDs(showArchived)
 }
+
+// ListVersions returns kept versions of the policy, including current
+func (s *PolicyService) ListVersions(name model.PolicyName) ([]model.PolicyVersion, error) {
+	policy, err := s.repo.GetByID(name)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]model.PolicyVersion{}, policy.PreviousVersions...), policy.CurrentVersion()), nil
+}
+
+func (s *PolicyService) GetVersion(name model.PolicyName, version int) (*model.PolicyVersion, error) {
+	versions, err := s.ListVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i].Version == version {
+			return &versions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: version %d of policy %s", consts.ErrNotFound, version, name)
+}
+
+// RestoreVersion writes content of the specified version as a new version of the policy
+func (s *PolicyService) RestoreVersion(name model.PolicyName, version int) (*model.Policy, error) {
+	stored, err := s.repo.GetByID(name)
+	if err != nil {
+		return nil, err
+	}
+	restoring, err := s.GetVersion(name, version)
+	if err != nil {
+		return nil, err
+	}
+	updated := *stored
+	updated.Rego = restoring.Rego
+	updated.Roles = restoring.Roles
+	updated.ClaimSchema = restoring.ClaimSchema
+	updated.AllowedAuthMethods = restoring.AllowedAuthMethods
+	if err = s.Update(&updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
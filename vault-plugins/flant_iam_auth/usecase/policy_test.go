@@ -1,6 +1,7 @@
 package usecase
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -8,6 +9,7 @@ import (
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/fixtures"
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/model"
 	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/repo"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
 	"github.com/flant/negentropy/vault-plugins/shared/io"
 )
 
@@ -36,3 +38,87 @@ func Test_PolicyList(t *testing.T) {
 	require.NoError(t, err)
 	require.ElementsMatch(t, []string{fixtures.PolicyName1, fixtures.PolicyName2}, policies)
 }
+
+func Test_PolicyVersions(t *testing.T) {
+	tx := runFixtures(t, roleFixture).Txn(true)
+	service := Policies(tx)
+	policy := fixtures.Policies()[0]
+	require.NoError(t, service.Create(&policy))
+	require.Equal(t, 1, policy.Version)
+	updated := policy
+	updated.Rego = policy.Rego + "\n# changed\n"
+
+	err := service.Update(&updated)
+
+	require.NoError(t, err)
+	require.Equal(t, 2, updated.Version)
+	versions, err := service.ListVersions(policy.Name)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, policy.Rego, versions[0].Rego)
+	require.Equal(t, updated.Rego, versions[1].Rego)
+
+	restored, err := service.RestoreVersion(policy.Name, 1)
+
+	require.NoError(t, err)
+	require.Equal(t, 3, restored.Version)
+	require.Equal(t, policy.Rego, restored.Rego)
+	_, err = service.GetVersion(policy.Name, 4)
+	require.ErrorIs(t, err, consts.ErrNotFound)
+}
+
+func Test_PolicyVersionsLimit(t *testing.T) {
+	tx := runFixtures(t, roleFixture).Txn(true)
+	service := Policies(tx)
+	policy := fixtures.Policies()[0]
+	require.NoError(t, service.Create(&policy))
+
+	for i := 0; i < model.PolicyPreviousVersionsLimit+5; i++ {
+		updated := policy
+		updated.Rego = fmt.Sprintf("%s\n# changed %d\n", policy.Rego, i)
+		require.NoError(t, service.Update(&updated))
+	}
+
+	versions, err := service.ListVersions(policy.Name)
+	require.NoError(t, err)
+	require.Len(t, versions, model.PolicyPreviousVersionsLimit+1)
+	require.Equal(t, 6, versions[0].Version)
+	require.Equal(t, model.PolicyPreviousVersionsLimit+6, versions[len(versions)-1].Version)
+	_, err = service.GetVersion(policy.Name, 5)
+	require.ErrorIs(t, err, consts.ErrNotFound)
+}
+
+func Test_PolicyValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		rego        string
+		claimSchema string
+		errContains string
+	}{
+		{"valid", fixtures.PolicyRego(fixtures.PolicyName1), fixtures.ClaimSchema, ""},
+		{"syntax error", "package negentropy.policy1\n\nallow {\n", fixtures.ClaimSchema, "line 4, column 0: unexpected eof"},
+		{"wrong package", fixtures.PolicyRego(fixtures.PolicyName2), fixtures.ClaimSchema, "wrong package"},
+		{"missed rules", "package negentropy.policy1\n\nallow = true\n", fixtures.ClaimSchema, "rules, ttl, max_ttl"},
+		{"unsafe var", "package negentropy.policy1\n\nallow {\n  x > 1\n}\n", fixtures.ClaimSchema, "line 4, column 3"},
+		{"broken schema", fixtures.PolicyRego(fixtures.PolicyName1), "{\n\"type\": }", "line 2, column"},
+		{"invalid schema", fixtures.PolicyRego(fixtures.PolicyName1), `{"type": "wrong"}`, "claim_schema"},
+		{"legacy schema", fixtures.PolicyRego(fixtures.PolicyName1), "TODO", "claim_schema"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := fixtures.Policies()[0]
+			policy.Rego = tt.rego
+			policy.ClaimSchema = tt.claimSchema
+
+			err := validatePolicy(&policy)
+
+			if tt.errContains == "" {
+				require.NoError(t, err)
+				require.NoError(t, Policies(runFixtures(t, roleFixture).Txn(true)).Create(&policy))
+				return
+			}
+			require.ErrorIs(t, err, consts.ErrInvalidArg)
+			require.Contains(t, err.Error(), tt.errContains)
+		})
+	}
+}
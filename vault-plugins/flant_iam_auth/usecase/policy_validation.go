@@ -0,0 +1,121 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/open-policy-agent/opa/ast"
+
+	"github.com/flant/negentropy/vault-plugins/flant_iam_auth/model"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+)
+
+const regoModuleFileName = "negentropy.rego"
+
+// regoRequiredRules are rules, which are decoded from the result of the policy at login
+var regoRequiredRules = []string{"allow", "rules", "ttl", "max_ttl"}
+
+// validatePolicy checks rego and claim_schema of the policy
+func validatePolicy(policy *model.Policy) error {
+	if err := checkRego(policy.Name, policy.Rego); err != nil {
+		return fmt.Errorf("%w: rego: %s", consts.ErrInvalidArg, err.Error())
+	}
+	if err := checkClaimSchema(policy.ClaimSchema); err != nil {
+		return fmt.Errorf("%w: claim_schema: %s", consts.ErrInvalidArg, err.Error())
+	}
+	return nil
+}
+
+// checkRego compiles rego module, checks the package is data.negentropy.<policyName> and all required rules are defined
+func checkRego(policyName model.PolicyName, rego string) error {
+	module, err := ast.ParseModule(regoModuleFileName, rego)
+	if err != nil {
+		return formatRegoErrors(err)
+	}
+	if module == nil {
+		return fmt.Errorf("empty module")
+	}
+	compiler := ast.NewCompiler()
+	compiler.Compile(map[string]*ast.Module{regoModuleFileName: module})
+	if compiler.Failed() {
+		return formatRegoErrors(compiler.Errors)
+	}
+
+	expectedPackage := "data.negentropy." + policyName
+	if packagePath := module.Package.Path.String(); packagePath != expectedPackage {
+		row, col := 0, 0
+		if module.Package.Location != nil {
+			row, col = module.Package.Location.Row, module.Package.Location.Col
+		}
+		return fmt.Errorf("line %d, column %d: wrong package %q, expected %q", row, col,
+			strings.TrimPrefix(packagePath, "data."), strings.TrimPrefix(expectedPackage, "data."))
+	}
+
+	definedRules := map[string]struct{}{}
+	for _, rule := range module.Rules {
+		definedRules[rule.Head.Name.String()] = struct{}{}
+	}
+	var missedRules []string
+	for _, r := range regoRequiredRules {
+		if _, defined := definedRules[r]; !defined {
+			missedRules = append(missedRules, r)
+		}
+	}
+	if len(missedRules) > 0 {
+		return fmt.Errorf("rules are not defined: %s", strings.Join(missedRules, ", "))
+	}
+	return nil
+}
+
+func formatRegoErrors(err error) error {
+	var astErrs ast.Errors
+	if !errors.As(err, &astErrs) {
+		return err
+	}
+	msgs := make([]string, 0, len(astErrs))
+	for _, e := range astErrs {
+		if e.Location != nil {
+			msgs = append(msgs, fmt.Sprintf("line %d, column %d: %s", e.Location.Row, e.Location.Col, e.Message))
+		} else {
+			msgs = append(msgs, e.Message)
+		}
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// checkClaimSchema checks is claimSchema valid openApi specification
+func checkClaimSchema(claimSchema string) error {
+	if claimSchema == "" {
+		return nil
+	}
+	var schema openapi3.Schema
+	err := json.Unmarshal([]byte(claimSchema), &schema)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			row, col := lineAndColumn(claimSchema, syntaxErr.Offset)
+			return fmt.Errorf("line %d, column %d: %s", row, col, syntaxErr.Error())
+		}
+		return fmt.Errorf("unmarshalling: %w", err)
+	}
+	err = schema.Validate(context.TODO())
+	if err != nil {
+		return fmt.Errorf("validation: %w", err)
+	}
+	return nil
+}
+
+// lineAndColumn returns 1-based line and column of the byte offset at the text
+func lineAndColumn(text string, offset int64) (int, int) {
+	if offset > int64(len(text)) {
+		offset = int64(len(text))
+	}
+	before := text[:offset]
+	row := strings.Count(before, "\n") + 1
+	col := len(before) - strings.LastIndex(before, "\n")
+	return row, col
+}
@@ -67,11 +67,14 @@ func MapErrorToHTTPStatusCode(err error) int {
 
 		consts.ErrBadVersion: http.StatusConflict,
 
-		consts.ErrBadOrigin:   http.StatusForbidden,
-		consts.ErrJwtDisabled: http.StatusForbidden,
+		consts.ErrBadOrigin:       http.StatusForbidden,
+		consts.ErrJwtDisabled:     http.StatusForbidden,
+		consts.ErrAccessForbidden: http.StatusForbidden,
 
 		consts.ErrNotConfigured: http.StatusPreconditionRequired,
 
+		consts.ErrNotReady: http.StatusServiceUnavailable,
+
 		consts.ErrJwtControllerError: http.StatusInternalServerError,
 	}
 	for e, s := range statuses {
@@ -17,6 +17,7 @@ var (
 	ErrWrongType          = fmt.Errorf("wrong type")
 	ErrInvalidArg         = fmt.Errorf("invalid value of argument")
 	ErrNotConfigured      = fmt.Errorf("not configured")
+	ErrNotReady           = fmt.Errorf("not ready yet")
 	ErrAccessForbidden    = fmt.Errorf("access forbidden")
 	ErrNotHandledObject   = fmt.Errorf("type is not handled yet")
 	CriticalCodeError     = fmt.Errorf("CRITICAL ERROR AT CODE")
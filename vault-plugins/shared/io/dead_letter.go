@@ -0,0 +1,324 @@
+package io
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
+)
+
+const (
+	deadLetterErrorHeader         = "dlq_error"
+	deadLetterFailuresHeader      = "dlq_failures"
+	deadLetterSourceTopicHeader   = "dlq_source_topic"
+	deadLetterSourceOffsetHeader  = "dlq_source_offset"
+	deadLetterConsumerGroupHeader = "dlq_consumer_group"
+	deadLetterTimestampHeader     = "dlq_timestamp"
+	// deadLetterReplayedHeader marks the message, which notes that the entry at passed offset is replayed
+	deadLetterReplayedHeader = "dlq_replayed"
+	// deadLetterSkippedHeader is passed with deadLetterReplayedHeader, if the entry is not applied as stale
+	deadLetterSkippedHeader = "dlq_skipped"
+
+	// deadLetterRetention is the retention of dead letter topics, 31 days
+	deadLetterRetention = "2678400000"
+)
+
+// DeadLetterTopicName returns the name of the topic for messages, failed at the topic
+func DeadLetterTopicName(topic string) string {
+	return topic + ".dlq"
+}
+
+// DeadLetterEntry is a message, moved to the dead letter topic
+type DeadLetterEntry struct {
+	// Offset at the dead letter topic
+	Offset       int64     `json:"offset"`
+	Key          string    `json:"key"`
+	SourceTopic  string    `json:"source_topic"`
+	SourceOffset int64     `json:"source_offset"`
+	Error        string    `json:"error"`
+	Failures     int       `json:"failures"`
+	Timestamp    time.Time `json:"timestamp"`
+	Replayed     bool      `json:"replayed"`
+	// Skipped is the reason, by which the replayed entry is not applied
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// deadLetterMaxFailures returns count of failures, after which the message goes to the dead letter topic,
+// zero means dead letter handling is turned off
+func (rk *KafkaSourceImpl) deadLetterMaxFailures() int {
+	return rk.KafkaBroker.PluginConfig.DeadLetterMaxFailures
+}
+
+// DeadLettered returns count of messages, moved to the dead letter topic by the run loop
+func (rk *KafkaSourceImpl) DeadLettered() int64 {
+	return atomic.LoadInt64(&rk.deadLettered)
+}
+
+// deadLetterRetryBackoff returns intervals between retries of the failed message, the count of retries is limited
+// by deadLetterMaxFailures
+func deadLetterRetryBackoff() backoff.BackOff {
+	retryBackoff := backoff.NewExponentialBackOff()
+	retryBackoff.MaxInterval = time.Second * 30
+	retryBackoff.MaxElapsedTime = 0
+	retryBackoff.Reset()
+	return retryBackoff
+}
+
+// handleRunFailure retries the failed message with growing intervals, and after max failures moves it into the dead letter topic,
+// committing its offset at the same transaction
+func (rk *KafkaSourceImpl) handleRunFailure(store *MemoryStore, consumer *kafka.Consumer, msg *kafka.Message, err error) error {
+	maxFailures := rk.deadLetterMaxFailures()
+	if maxFailures <= 0 {
+		return err
+	}
+	retryBackoff := deadLetterRetryBackoff()
+	failures := 1
+	for ; err != nil && failures < maxFailures; failures++ {
+		wait := retryBackoff.NextBackOff()
+		rk.Logger.Warn(fmt.Sprintf("msg: %s: failure %d of %d, retry in %s: %s", string(msg.Key), failures, maxFailures,
+			wait, err.Error()))
+		time.Sleep(wait)
+		err = rk.msgRunHandler(store, consumer, msg)
+	}
+	if err == nil {
+		return nil
+	}
+
+	source, sourceErr := sharedkafka.NewSourceInputMessage(consumer, msg.TopicPartition)
+	if sourceErr != nil {
+		return fmt.Errorf("build source message failed: %w", sourceErr)
+	}
+	if dlqErr := rk.sendToDeadLetter(msg, failures, err, source); dlqErr != nil {
+		return fmt.Errorf("%s, moving to dead letter topic: %w", err.Error(), dlqErr)
+	}
+	atomic.AddInt64(&rk.deadLettered, 1)
+	observeDeadLettered(rk.Name())
+	rk.Logger.Error(fmt.Sprintf("msg: %s: moved to dead letter topic after %d failures: %s", string(msg.Key), failures, err.Error()))
+	return nil
+}
+
+func (rk *KafkaSourceImpl) sendToDeadLetter(msg *kafka.Message, failures int, err error, source *sharedkafka.SourceInputMessage) error {
+	topicName := rk.ProvideTopicName(rk.KafkaBroker)
+	dlqTopicName := DeadLetterTopicName(topicName)
+	if !rk.deadLetterTopicCreated {
+		err := rk.KafkaBroker.CreateTopic(context.Background(), dlqTopicName, map[string]string{
+			"cleanup.policy": "delete",
+			"retention.ms":   deadLetterRetention,
+		})
+		if err != nil {
+			return fmt.Errorf("creating topic: %w", err)
+		}
+		rk.deadLetterTopicCreated = true
+	}
+
+	headers := map[string][]byte{}
+	for _, header := range msg.Headers {
+		headers[header.Key] = header.Value
+	}
+	headers[deadLetterErrorHeader] = []byte(err.Error())
+	headers[deadLetterFailuresHeader] = []byte(strconv.Itoa(failures))
+	headers[deadLetterSourceTopicHeader] = []byte(topicName)
+	headers[deadLetterSourceOffsetHeader] = []byte(strconv.FormatInt(int64(msg.TopicPartition.Offset), 10))
+	headers[deadLetterConsumerGroupHeader] = []byte(rk.ProvideRunConsumerGroupID(rk.KafkaBroker))
+	headers[deadLetterTimestampHeader] = []byte(time.Now().UTC().Format(time.RFC3339))
+
+	return rk.KafkaBroker.SendMessages([]sharedkafka.Message{{
+		Topic:   dlqTopicName,
+		Key:     string(msg.Key),
+		Value:   msg.Value,
+		Headers: headers,
+	}}, source)
+}
+
+// readDeadLetters passes messages of the dead letter topic, written by the consumer group of the source, to handler
+func (rk *KafkaSourceImpl) readDeadLetters(handler func(msg *kafka.Message, headers map[string]string) error) error {
+	dlqTopicName := DeadLetterTopicName(rk.ProvideTopicName(rk.KafkaBroker))
+	exists, err := rk.KafkaBroker.TopicExists(dlqTopicName)
+	if err != nil || !exists {
+		return err
+	}
+	empty, err := rk.topicIsEmpty(dlqTopicName)
+	if err != nil || empty {
+		return err
+	}
+	lastOffset, err := rk.lastOffset(dlqTopicName)
+	if err != nil {
+		return err
+	}
+	groupID := rk.ProvideRunConsumerGroupID(rk.KafkaBroker)
+	return rk.readTopic(dlqTopicName, 0, lastOffset, func(msg *kafka.Message) error {
+		headers := map[string]string{}
+		for _, header := range msg.Headers {
+			headers[header.Key] = string(header.Value)
+		}
+		if headers[deadLetterConsumerGroupHeader] != groupID {
+			return nil
+		}
+		return handler(msg, headers)
+	}, rk.Logger.Named("DeadLetters"))
+}
+
+// topicIsEmpty is needed as lastOffset returns zero both for empty topic and topic with one message
+func (rk *KafkaSourceImpl) topicIsEmpty(topicName string) (bool, error) {
+	consumer, err := rk.KafkaBroker.GetRestorationReader()
+	if err != nil {
+		return false, err
+	}
+	defer sharedkafka.DeferredСlose(consumer, rk.Logger)
+	_, nextOffset, err := getNextWritingOffsetByMetaData(consumer, topicName)
+	if err != nil {
+		return false, err
+	}
+	time.Sleep(time.Nanosecond) // to guarantee getting definitely new RestorationReader
+	return nextOffset == 0, nil
+}
+
+// DeadLetterEntries returns messages, moved to the dead letter topic by the source
+func (rk *KafkaSourceImpl) DeadLetterEntries() ([]DeadLetterEntry, error) {
+	var entries []DeadLetterEntry
+	replayed := map[int64]bool{}
+	skipped := map[int64]string{}
+	err := rk.readDeadLetters(func(msg *kafka.Message, headers map[string]string) error {
+		if rawOffset, ok := headers[deadLetterReplayedHeader]; ok {
+			offset, err := strconv.ParseInt(rawOffset, 10, 64)
+			if err != nil {
+				return fmt.Errorf("header %s: %w", deadLetterReplayedHeader, err)
+			}
+			replayed[offset] = true
+			skipped[offset] = headers[deadLetterSkippedHeader]
+			return nil
+		}
+		entry := DeadLetterEntry{
+			Offset:      int64(msg.TopicPartition.Offset),
+			Key:         string(msg.Key),
+			SourceTopic: headers[deadLetterSourceTopicHeader],
+			Error:       headers[deadLetterErrorHeader],
+		}
+		entry.SourceOffset, _ = strconv.ParseInt(headers[deadLetterSourceOffsetHeader], 10, 64)
+		entry.Failures, _ = strconv.Atoi(headers[deadLetterFailuresHeader])
+		entry.Timestamp, _ = time.Parse(time.RFC3339, headers[deadLetterTimestampHeader])
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].Replayed = replayed[entries[i].Offset]
+		entries[i].Skipped = skipped[entries[i].Offset]
+	}
+	return entries, nil
+}
+
+// ReplayDeadLetter processes the entry of the dead letter topic at offset again, as at the run loop.
+// The entry is stale and is not applied, if the source topic has a newer message with the same key,
+// it returns whether the entry is applied
+func (rk *KafkaSourceImpl) ReplayDeadLetter(store *MemoryStore, offset int64) (bool, error) {
+	var found *kafka.Message
+	err := rk.readDeadLetters(func(msg *kafka.Message, headers map[string]string) error {
+		if int64(msg.TopicPartition.Offset) != offset {
+			return nil
+		}
+		if _, ok := headers[deadLetterReplayedHeader]; ok {
+			return nil
+		}
+		original := *msg
+		original.Headers = nil
+		for _, header := range msg.Headers {
+			if !isDeadLetterHeader(header.Key) {
+				original.Headers = append(original.Headers, header)
+			}
+		}
+		sourceTopic := headers[deadLetterSourceTopicHeader]
+		sourceOffset, _ := strconv.ParseInt(headers[deadLetterSourceOffsetHeader], 10, 64)
+		original.TopicPartition = kafka.TopicPartition{Topic: &sourceTopic, Offset: kafka.Offset(sourceOffset)}
+		found = &original
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if found == nil {
+		return false, fmt.Errorf("dead letter entry at offset %d: %w", offset, consts.ErrNotFound)
+	}
+
+	newerOffset, stale, err := rk.newerSourceMessage(*found.TopicPartition.Topic, int64(found.TopicPartition.Offset), found.Key)
+	if err != nil {
+		return false, fmt.Errorf("checking newer messages: %w", err)
+	}
+	if stale {
+		skipped := fmt.Sprintf("stale, newer message at offset %d", newerOffset)
+		rk.Logger.Warn(fmt.Sprintf("dead letter entry at offset %d: %s", offset, skipped))
+		return false, rk.markReplayed(found.Key, offset, skipped)
+	}
+
+	decoded, err := rk.decodeMessageAndCheck(found)
+	if err != nil {
+		return false, fmt.Errorf("decoding and checking message: %w", err)
+	}
+	txn := store.Txn(true)
+	defer txn.Abort()
+	if err = rk.ProcessRunMessage(txn, *decoded); err != nil {
+		return false, err
+	}
+	if err = txn.Commit(); err != nil {
+		return false, err
+	}
+	return true, rk.markReplayed(found.Key, offset, "")
+}
+
+// newerSourceMessage looks for the message with the key, written to the source topic after sourceOffset,
+// it returns the offset of the last such message
+func (rk *KafkaSourceImpl) newerSourceMessage(sourceTopic string, sourceOffset int64, key []byte) (int64, bool, error) {
+	lastOffset, err := rk.lastOffset(sourceTopic)
+	if err != nil {
+		return 0, false, err
+	}
+	if lastOffset <= sourceOffset {
+		return 0, false, nil
+	}
+	var newerOffset int64
+	found := false
+	err = rk.readTopic(sourceTopic, sourceOffset+1, lastOffset, func(msg *kafka.Message) error {
+		if bytes.Equal(msg.Key, key) {
+			newerOffset = int64(msg.TopicPartition.Offset)
+			found = true
+		}
+		return nil
+	}, rk.Logger.Named("DeadLetters"))
+	return newerOffset, found, err
+}
+
+// markReplayed writes the message, which notes that the entry at offset is replayed, skipped is passed for
+// not applied entry
+func (rk *KafkaSourceImpl) markReplayed(key []byte, offset int64, skipped string) error {
+	headers := map[string][]byte{
+		deadLetterReplayedHeader:      []byte(strconv.FormatInt(offset, 10)),
+		deadLetterConsumerGroupHeader: []byte(rk.ProvideRunConsumerGroupID(rk.KafkaBroker)),
+	}
+	if skipped != "" {
+		headers[deadLetterSkippedHeader] = []byte(skipped)
+	}
+	return rk.KafkaBroker.SendMessages([]sharedkafka.Message{{
+		Topic:   DeadLetterTopicName(rk.ProvideTopicName(rk.KafkaBroker)),
+		Key:     string(key),
+		Headers: headers,
+	}}, nil)
+}
+
+func isDeadLetterHeader(key string) bool {
+	switch key {
+	case deadLetterErrorHeader, deadLetterFailuresHeader, deadLetterSourceTopicHeader, deadLetterSourceOffsetHeader,
+		deadLetterConsumerGroupHeader, deadLetterTimestampHeader, deadLetterReplayedHeader, deadLetterSkippedHeader:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package io
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+
+	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
+)
+
+func TestDeadLetterTurnedOffReturnsError(t *testing.T) {
+	source := &KafkaSourceImpl{
+		KafkaBroker: &sharedkafka.MessageBroker{},
+		Logger:      hclog.NewNullLogger(),
+	}
+	processingErr := errors.New("processing failed")
+
+	err := source.handleRunFailure(nil, nil, &kafka.Message{Key: []byte("tenant/1")}, processingErr)
+
+	require.ErrorIs(t, err, processingErr)
+	require.Equal(t, int64(0), source.DeadLettered())
+}
+
+func TestDeadLetterHeaders(t *testing.T) {
+	require.Equal(t, "root_source.dlq", DeadLetterTopicName("root_source"))
+	require.True(t, isDeadLetterHeader(deadLetterErrorHeader))
+	require.True(t, isDeadLetterHeader(deadLetterReplayedHeader))
+	require.True(t, isDeadLetterHeader(deadLetterSkippedHeader))
+	require.False(t, isDeadLetterHeader("signature"))
+	require.False(t, isDeadLetterHeader(sharedkafka.KeyIDHeader))
+}
+
+func TestDeadLetterRetryBackoff(t *testing.T) {
+	retryBackoff := deadLetterRetryBackoff()
+	require.Less(t, retryBackoff.NextBackOff(), time.Second)
+	for i := 0; i < 20; i++ {
+		wait := retryBackoff.NextBackOff()
+		require.NotEqual(t, backoff.Stop, wait, "retries are limited by max failures only")
+		require.LessOrEqual(t, wait, 45*time.Second)
+	}
+}
@@ -0,0 +1,82 @@
+package io
+
+import (
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	hcmemdb "github.com/hashicorp/go-memdb"
+
+	"github.com/flant/negentropy/vault-plugins/shared/metrics"
+)
+
+// rowsCounter keeps count of rows at memdb tables, it is updated by changes of committed transactions,
+// so rows are not scanned at reading metrics
+type rowsCounter struct {
+	mutex sync.Mutex
+	rows  map[string]int64 // by table
+}
+
+// countChanges applies changes of the committed transaction
+func (c *rowsCounter) countChanges(changes hcmemdb.Changes) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.rows == nil {
+		c.rows = map[string]int64{}
+	}
+	for _, change := range changes {
+		switch {
+		case change.Created():
+			c.rows[change.Table]++
+		case change.Deleted():
+			c.rows[change.Table]--
+		}
+	}
+}
+
+// collectMetrics updates gauges of the store, which is exposed by the metrics endpoint
+func (ms *MemoryStore) collectMetrics() {
+	ms.rows.mutex.Lock()
+	defer ms.rows.mutex.Unlock()
+	for table := range ms.MemDB.DBSchema().Tables {
+		// count of rows at memdb table
+		metrics.SetGauge("memdb_rows", float64(ms.rows.rows[table]), metrics.Label{Name: "table", Value: table})
+	}
+}
+
+// observeProcessed updates metrics of the source by the message, processed by the run loop
+func (rk *KafkaSourceImpl) observeProcessed(consumer *kafka.Consumer, msg *kafka.Message, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	// count of messages, processed by the run loop of the source
+	metrics.IncrCounter("kafka_processed_messages_total", metrics.Label{Name: "source", Value: rk.Name()},
+		metrics.Label{Name: "outcome", Value: outcome})
+	if msg.TopicPartition.Topic == nil {
+		return
+	}
+	// watermarks are cached by the consumer, so it doesn't request the broker
+	_, high, err := consumer.GetWatermarkOffsets(*msg.TopicPartition.Topic, msg.TopicPartition.Partition)
+	if err != nil {
+		return
+	}
+	lag := high - int64(msg.TopicPartition.Offset) - 1
+	if lag < 0 {
+		lag = 0
+	}
+	// count of messages at the topic after the last processed by the run loop of the source,
+	// the lag counts commit markers of kafka transactions, so it can be 1 for the caught up consumer
+	metrics.SetGauge("kafka_consumer_lag", float64(lag), metrics.Label{Name: "source", Value: rk.Name()})
+}
+
+// observeDeadLettered counts messages, moved to the dead letter topic by the run loop of the source
+func observeDeadLettered(sourceName string) {
+	metrics.IncrCounter("kafka_dead_lettered_messages_total", metrics.Label{Name: "source", Value: sourceName})
+}
+
+// observeRestoreDuration sets duration of the last restoration of memdb from the source topic
+func observeRestoreDuration(sourceName string, started time.Time) {
+	metrics.SetGauge("restore_duration_seconds", time.Since(started).Seconds(),
+		metrics.Label{Name: "source", Value: sourceName})
+}
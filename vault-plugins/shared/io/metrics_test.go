@@ -0,0 +1,28 @@
+package io
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowsAreCountedByCommittedChanges(t *testing.T) {
+	mem, err := NewMemoryStore(testSchema(), nil, hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	txn := mem.Txn(true)
+	require.NoError(t, txn.Insert("user", &user{UUID: "1"}))
+	require.NoError(t, txn.Insert("user", &user{UUID: "2"}))
+	require.NoError(t, txn.Commit())
+	txn = mem.Txn(true)
+	require.NoError(t, txn.Insert("user", &user{UUID: "1", Groups: []string{"g"}}))
+	require.NoError(t, txn.Delete("user", &user{UUID: "2"}))
+	require.NoError(t, txn.Insert("user", &user{UUID: "3"}))
+	require.NoError(t, txn.Commit())
+	txn = mem.Txn(true)
+	require.NoError(t, txn.Insert("user", &user{UUID: "4"}))
+	txn.Abort()
+
+	require.Equal(t, int64(2), mem.rows.rows["user"])
+}
@@ -0,0 +1,116 @@
+package io
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
+	"github.com/flant/negentropy/vault-plugins/shared/consts"
+)
+
+type deadLetterBackend struct {
+	storage *MemoryStore
+}
+
+// DeadLetterPaths returns paths to list and replay messages, moved to dead letter topics by run loops of the storage
+func DeadLetterPaths(storage *MemoryStore) []*framework.Path {
+	b := deadLetterBackend{storage: storage}
+	return []*framework.Path{
+		{
+			Pattern: "kafka/dead_letter/?",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Summary:  "List messages at dead letter topics of kafka sources",
+					Callback: b.handleList,
+				},
+			},
+		},
+		{
+			Pattern: "kafka/dead_letter/" + framework.GenericNameRegex("source_name") + "/" +
+				framework.GenericNameRegex("offset") + "/replay",
+			Fields: map[string]*framework.FieldSchema{
+				"source_name": {
+					Type:        framework.TypeString,
+					Required:    true,
+					Description: "Name of the kafka source",
+				},
+				"offset": {
+					Type:        framework.TypeInt,
+					Required:    true,
+					Description: "Offset of the message at the dead letter topic",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Summary:  "Process the message from the dead letter topic again, the message is skipped, if the source topic has a newer one with the same key",
+					Callback: b.handleReplay,
+				},
+			},
+		},
+	}
+}
+
+// runnableSources returns sources with run loops, only they move messages to dead letter topics
+func (ms *MemoryStore) runnableSources() []*KafkaSourceImpl {
+	ms.kafkaMutex.RLock()
+	defer ms.kafkaMutex.RUnlock()
+	var sources []*KafkaSourceImpl
+	for _, ks := range ms.kafkaSources {
+		if source, ok := ks.(*KafkaSourceImpl); ok && source.Runnable {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+func (b *deadLetterBackend) handleList(_ context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	if !b.storage.kafkaConnection.Configured() {
+		return backentutils.ResponseErr(req, fmt.Errorf("kafka: %w", consts.ErrNotConfigured))
+	}
+	sources := []map[string]interface{}{}
+	for _, source := range b.storage.runnableSources() {
+		entries, err := source.DeadLetterEntries()
+		if err != nil {
+			return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+		}
+		if entries == nil {
+			entries = []DeadLetterEntry{}
+		}
+		sources = append(sources, map[string]interface{}{
+			"name":          source.Name(),
+			"topic":         DeadLetterTopicName(source.ProvideTopicName(source.KafkaBroker)),
+			"dead_lettered": source.DeadLettered(),
+			"entries":       entries,
+		})
+	}
+
+	resp := &logical.Response{Data: map[string]interface{}{
+		"max_failures": b.storage.kafkaConnection.PluginConfig.DeadLetterMaxFailures,
+		"sources":      sources,
+	}}
+	return logical.RespondWithStatusCode(resp, req, http.StatusOK)
+}
+
+func (b *deadLetterBackend) handleReplay(_ context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if !b.storage.kafkaConnection.Configured() {
+		return backentutils.ResponseErr(req, fmt.Errorf("kafka: %w", consts.ErrNotConfigured))
+	}
+	sourceName := data.Get("source_name").(string)
+	for _, source := range b.storage.runnableSources() {
+		if source.Name() != sourceName {
+			continue
+		}
+		applied, err := source.ReplayDeadLetter(b.storage, int64(data.Get("offset").(int)))
+		if err != nil {
+			return backentutils.ResponseErr(req, err)
+		}
+		return logical.RespondWithStatusCode(&logical.Response{Data: map[string]interface{}{
+			"applied": applied,
+		}}, req, http.StatusOK)
+	}
+	return backentutils.ResponseErr(req, fmt.Errorf("kafka source %q: %w", sourceName, consts.ErrNotFound))
+}
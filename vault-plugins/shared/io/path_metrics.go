@@ -0,0 +1,46 @@
+package io
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	backentutils "github.com/flant/negentropy/vault-plugins/shared/backent-utils"
+	"github.com/flant/negentropy/vault-plugins/shared/metrics"
+)
+
+type metricsBackend struct {
+	storage *MemoryStore
+}
+
+// MetricsPaths returns the path to read metrics of the plugin in the Prometheus text format
+func MetricsPaths(storage *MemoryStore) []*framework.Path {
+	b := metricsBackend{storage: storage}
+	return []*framework.Path{
+		{
+			Pattern: "metrics/?",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Summary:  "Read metrics of the plugin in the Prometheus text format",
+					Callback: b.handleRead,
+				},
+			},
+		},
+	}
+}
+
+func (b *metricsBackend) handleRead(_ context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.storage.collectMetrics()
+	buf := &bytes.Buffer{}
+	if err := metrics.WritePrometheus(buf); err != nil {
+		return backentutils.ResponseErrMessage(req, err.Error(), http.StatusInternalServerError)
+	}
+	return &logical.Response{Data: map[string]interface{}{
+		logical.HTTPContentType: "text/plain; version=0.0.4",
+		logical.HTTPRawBody:     buf.Bytes(),
+		logical.HTTPStatusCode:  http.StatusOK,
+	}}, nil
+}
@@ -0,0 +1,72 @@
+package io
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+var ErrRewriteIsRunning = errors.New("rewriting is running")
+
+// RewriteStatus is a state of the last run of RewriteJob
+type RewriteStatus struct {
+	Running    bool      `json:"running"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Objects    int       `json:"objects"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// RewriteJob runs MemoryStore.RewriteAll in background, only one run at a time is allowed,
+// it is used to re-encrypt topics after promoting a new encryption key
+type RewriteJob struct {
+	ms     *MemoryStore
+	logger hclog.Logger
+
+	mutex  sync.Mutex
+	status RewriteStatus
+}
+
+func NewRewriteJob(ms *MemoryStore, parentLogger hclog.Logger) *RewriteJob {
+	return &RewriteJob{
+		ms:     ms,
+		logger: parentLogger.Named("RewriteJob"),
+	}
+}
+
+// Start runs rewriting in background, returns ErrRewriteIsRunning if the previous run is not finished
+func (j *RewriteJob) Start() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.status.Running {
+		return ErrRewriteIsRunning
+	}
+	j.status = RewriteStatus{Running: true, StartedAt: time.Now()}
+	go j.run()
+	return nil
+}
+
+func (j *RewriteJob) run() {
+	j.logger.Info("started")
+	count, err := j.ms.RewriteAll()
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status.Running = false
+	j.status.FinishedAt = time.Now()
+	j.status.Objects = count
+	if err != nil {
+		j.status.Error = err.Error()
+		j.logger.Error("failed", "err", err)
+		return
+	}
+	j.logger.Info("finished", "objects", count)
+}
+
+func (j *RewriteJob) Status() RewriteStatus {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.status
+}
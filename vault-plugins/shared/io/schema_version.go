@@ -0,0 +1,129 @@
+package io
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// SchemaVersionHeader is the header of kafka message, which contains version of the schema of the object data,
+// data without the header has version 0
+const SchemaVersionHeader = "schema_version"
+
+var ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+
+// SchemaUpgrade converts object data of some version into data of the next version
+type SchemaUpgrade func(data []byte) ([]byte, error)
+
+// SchemaRegistry keeps upgrades of objects data by object type, the current version of the type is the count
+// of its upgrades
+type SchemaRegistry struct {
+	mutex    sync.RWMutex
+	upgrades map[string][]SchemaUpgrade
+}
+
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{upgrades: map[string][]SchemaUpgrade{}}
+}
+
+// MergeSchemaRegistries returns a new registry with upgrades of all passed registries,
+// the same type can't have upgrades at several registries
+func MergeSchemaRegistries(registries ...*SchemaRegistry) (*SchemaRegistry, error) {
+	result := NewSchemaRegistry()
+	for _, r := range registries {
+		r.mutex.RLock()
+		for objType, upgrades := range r.upgrades {
+			if _, ok := result.upgrades[objType]; ok {
+				r.mutex.RUnlock()
+				return nil, fmt.Errorf("upgrades of %q are registered at several registries", objType)
+			}
+			result.upgrades[objType] = append([]SchemaUpgrade{}, upgrades...)
+		}
+		r.mutex.RUnlock()
+	}
+	return result, nil
+}
+
+// Register adds upgrade of objType data from fromVersion to fromVersion+1, upgrades should be registered in order
+func (r *SchemaRegistry) Register(objType string, fromVersion int, upgrade SchemaUpgrade) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if fromVersion != len(r.upgrades[objType]) {
+		return fmt.Errorf("upgrade of %q from version %d: expected upgrade from version %d",
+			objType, fromVersion, len(r.upgrades[objType]))
+	}
+	r.upgrades[objType] = append(r.upgrades[objType], upgrade)
+	return nil
+}
+
+// MustRegister is like Register but panics if the upgrade is out of order, it is used to fill package registries
+func (r *SchemaRegistry) MustRegister(objType string, fromVersion int, upgrade SchemaUpgrade) *SchemaRegistry {
+	if err := r.Register(objType, fromVersion, upgrade); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// CurrentVersion returns version of objType data, written by the plugin
+func (r *SchemaRegistry) CurrentVersion(objType string) int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.upgrades[objType])
+}
+
+// VersionHeader returns value of SchemaVersionHeader for messages with objType data
+func (r *SchemaRegistry) VersionHeader(objType string) []byte {
+	return []byte(strconv.Itoa(r.CurrentVersion(objType)))
+}
+
+// Upgrade converts data of version into data of the current version of objType,
+// data of versions newer than current can't be processed
+func (r *SchemaRegistry) Upgrade(objType string, version int, data []byte) ([]byte, error) {
+	r.mutex.RLock()
+	upgrades := r.upgrades[objType]
+	r.mutex.RUnlock()
+	if version < 0 || version > len(upgrades) {
+		return nil, fmt.Errorf("%w: %q of version %d, current version is %d",
+			ErrUnsupportedSchemaVersion, objType, version, len(upgrades))
+	}
+	var err error
+	for v := version; v < len(upgrades); v++ {
+		if data, err = upgrades[v](data); err != nil {
+			return nil, fmt.Errorf("upgrade %q from version %d: %w", objType, v, err)
+		}
+	}
+	return data, nil
+}
+
+// schemaVersion returns version of the message data
+func schemaVersion(msg *kafka.Message) (int, error) {
+	for _, header := range msg.Headers {
+		if header.Key == SchemaVersionHeader {
+			version, err := strconv.Atoi(string(header.Value))
+			if err != nil {
+				return 0, fmt.Errorf("header %s: %w", SchemaVersionHeader, err)
+			}
+			return version, nil
+		}
+	}
+	return 0, nil
+}
+
+// currentSchemaVersion returns version of objType data, produced by the source
+func (rk *KafkaSourceImpl) currentSchemaVersion(objType string) int {
+	if rk.SchemaRegistry == nil {
+		return 0
+	}
+	return rk.SchemaRegistry.CurrentVersion(objType)
+}
+
+// upgradeSchema converts data to the current version, data is passed as is, if the source has no registry
+func (rk *KafkaSourceImpl) upgradeSchema(objType string, version int, data []byte) ([]byte, error) {
+	if rk.SchemaRegistry == nil || len(data) == 0 {
+		return data, nil
+	}
+	return rk.SchemaRegistry.Upgrade(objType, version, data)
+}
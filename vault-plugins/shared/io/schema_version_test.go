@@ -0,0 +1,115 @@
+package io
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+// renameField returns upgrade, which renames the field at the top level of the object
+func renameField(from, to string) SchemaUpgrade {
+	return func(data []byte) ([]byte, error) {
+		obj := map[string]interface{}{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil, err
+		}
+		if value, ok := obj[from]; ok {
+			obj[to] = value
+			delete(obj, from)
+		}
+		return json.Marshal(obj)
+	}
+}
+
+func schemaTestRegistry() *SchemaRegistry {
+	return NewSchemaRegistry().
+		MustRegister("role", 0, renameField("forbindden_direct_use", "forbidden_direct_use")).
+		MustRegister("role", 1, renameField("description", "summary"))
+}
+
+func TestSchemaRegistryUpgrade(t *testing.T) {
+	r := schemaTestRegistry()
+	require.Equal(t, 2, r.CurrentVersion("role"))
+	require.Equal(t, 0, r.CurrentVersion("user"))
+
+	data, err := r.Upgrade("role", 0, []byte(`{"description":"d","forbindden_direct_use":true}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"summary":"d","forbidden_direct_use":true}`, string(data))
+
+	data, err = r.Upgrade("role", 1, []byte(`{"description":"d","forbidden_direct_use":true}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"summary":"d","forbidden_direct_use":true}`, string(data))
+
+	data, err = r.Upgrade("user", 0, []byte(`{"login":"l"}`))
+	require.NoError(t, err)
+	require.Equal(t, `{"login":"l"}`, string(data))
+
+	_, err = r.Upgrade("role", 3, []byte(`{}`))
+	require.ErrorIs(t, err, ErrUnsupportedSchemaVersion)
+}
+
+func TestSchemaRegistryRegisterOutOfOrder(t *testing.T) {
+	r := NewSchemaRegistry()
+	require.Error(t, r.Register("role", 1, renameField("a", "b")))
+	require.NoError(t, r.Register("role", 0, renameField("a", "b")))
+	require.Error(t, r.Register("role", 0, renameField("b", "c")))
+}
+
+func TestMergeSchemaRegistries(t *testing.T) {
+	users := NewSchemaRegistry().MustRegister("user", 0, renameField("a", "b"))
+
+	merged, err := MergeSchemaRegistries(schemaTestRegistry(), users)
+	require.NoError(t, err)
+	require.Equal(t, 2, merged.CurrentVersion("role"))
+	require.Equal(t, 1, merged.CurrentVersion("user"))
+
+	_, err = MergeSchemaRegistries(users, users)
+	require.Error(t, err)
+}
+
+func TestDecodeMessageUpgradesSchema(t *testing.T) {
+	topic := "root_source"
+	source := &KafkaSourceImpl{Logger: hclog.NewNullLogger(), SchemaRegistry: schemaTestRegistry()}
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Key:            []byte("role/ssh"),
+		Value:          []byte(`{"forbindden_direct_use":true}`),
+	}
+
+	decoded, err := source.decodeMessageAndCheck(msg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"forbidden_direct_use":true}`, string(decoded.Data), "message without header has version 0")
+
+	msg.Headers = []kafka.Header{{Key: SchemaVersionHeader, Value: []byte("2")}}
+	msg.Value = []byte(`{"summary":"s"}`)
+	decoded, err = source.decodeMessageAndCheck(msg)
+	require.NoError(t, err)
+	require.Equal(t, `{"summary":"s"}`, string(decoded.Data))
+
+	msg.Headers = []kafka.Header{{Key: SchemaVersionHeader, Value: []byte("3")}}
+	_, err = source.decodeMessageAndCheck(msg)
+	require.ErrorIs(t, err, ErrUnsupportedSchemaVersion, "message of a newer plugin")
+
+	source.SchemaRegistry = nil
+	decoded, err = source.decodeMessageAndCheck(msg)
+	require.NoError(t, err)
+	require.Equal(t, `{"summary":"s"}`, string(decoded.Data), "source without registry passes data as is")
+}
+
+func TestSnapshotUpgradesSchema(t *testing.T) {
+	source, writer := snapshotTestSource(t)
+	snapshot := &Snapshot{Offset: 10, Records: map[string][]byte{"role/ssh": []byte(`{"forbindden_direct_use":true}`)}}
+	// the snapshot is written by the plugin without upgrades
+	msgs, err := snapshot.messages("snapshot.root_source", writer.Sign, writer.Encrypt, source.currentSchemaVersion)
+	require.NoError(t, err)
+
+	source.SchemaRegistry = schemaTestRegistry()
+	collector := newSnapshotCollector(source)
+	for _, msg := range snapshotTestKafkaMessages(msgs) {
+		require.NoError(t, collector.add(msg))
+	}
+	require.JSONEq(t, `{"forbidden_direct_use":true}`, string(collector.latest.Records["role/ssh"]))
+}
@@ -0,0 +1,349 @@
+package io
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/hashicorp/go-hclog"
+
+	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
+)
+
+const (
+	// SnapshotPartSize is the maximum size of records at one snapshot message, before encryption
+	SnapshotPartSize = 512 * 1024
+	// DefaultSnapshotInterval is the minimal interval between writing snapshots
+	DefaultSnapshotInterval = 10 * time.Minute
+
+	snapshotOffsetHeader = "snapshot_offset"
+	snapshotPartHeader   = "snapshot_part"
+	snapshotPartsHeader  = "snapshot_parts"
+)
+
+// Snapshot is a compacted state of the topic: the last value of every not deleted key, till Offset inclusive
+type Snapshot struct {
+	// Offset of the last message at the source topic, included into the snapshot
+	Offset int64
+	// Records is data of messages by message key
+	Records map[string][]byte
+}
+
+type snapshotRecord struct {
+	Key  string `json:"key"`
+	Data []byte `json:"data"`
+	// version of the data schema, records are collected at the current versions of the writer
+	SchemaVersion int `json:"schema_version,omitempty"`
+}
+
+// messages splits snapshot into parts, every part is signed and encrypted separately, parts are keyed by number,
+// so the compacted snapshot topic keeps only the latest part of every number
+func (s *Snapshot) messages(topic string, sign func(data []byte) ([]byte, error),
+	encrypt func(data []byte) ([]byte, bool, string, error), schemaVersion func(objType string) int) ([]sharedkafka.Message, error) {
+	keys := make([]string, 0, len(s.Records))
+	for key := range s.Records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts [][]snapshotRecord
+	var part []snapshotRecord
+	partSize := 0
+	for _, key := range keys {
+		record := snapshotRecord{Key: key, Data: s.Records[key], SchemaVersion: schemaVersion(strings.Split(key, "/")[0])}
+		if len(part) > 0 && partSize+len(record.Key)+len(record.Data) > SnapshotPartSize {
+			parts = append(parts, part)
+			part, partSize = nil, 0
+		}
+		part = append(part, record)
+		partSize += len(record.Key) + len(record.Data)
+	}
+	parts = append(parts, part)
+
+	msgs := make([]sharedkafka.Message, 0, len(parts))
+	for i, part := range parts {
+		data, err := json.Marshal(part)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := sign(data)
+		if err != nil {
+			return nil, fmt.Errorf("signing: %w", err)
+		}
+		headers := map[string][]byte{
+			"signature":          signature,
+			snapshotOffsetHeader: []byte(strconv.FormatInt(s.Offset, 10)),
+			snapshotPartHeader:   []byte(strconv.Itoa(i)),
+			snapshotPartsHeader:  []byte(strconv.Itoa(len(parts))),
+		}
+		if encrypt != nil {
+			var chunked bool
+			var keyID string
+			if data, chunked, keyID, err = encrypt(data); err != nil {
+				return nil, fmt.Errorf("encryption: %w", err)
+			}
+			if chunked {
+				headers["chunked"] = []byte("true")
+			}
+			headers[sharedkafka.KeyIDHeader] = []byte(keyID)
+		}
+		msgs = append(msgs, sharedkafka.Message{
+			Topic:   topic,
+			Key:     fmt.Sprintf("part/%d", i),
+			Value:   data,
+			Headers: headers,
+		})
+	}
+	return msgs, nil
+}
+
+// snapshotCollector collects parts of snapshots, written into the snapshot topic
+type snapshotCollector struct {
+	source *KafkaSourceImpl
+	// collected parts by snapshot offset
+	parts  map[int64]map[int][]snapshotRecord
+	latest *Snapshot
+}
+
+func newSnapshotCollector(source *KafkaSourceImpl) *snapshotCollector {
+	return &snapshotCollector{source: source, parts: map[int64]map[int][]snapshotRecord{}}
+}
+
+func (c *snapshotCollector) add(msg *kafka.Message) error {
+	var signature []byte
+	var chunked bool
+	var keyID string
+	var offset int64
+	var part, parts int
+	var err error
+	for _, header := range msg.Headers {
+		switch header.Key {
+		case "signature":
+			signature = header.Value
+		case "chunked":
+			chunked = true
+		case sharedkafka.KeyIDHeader:
+			keyID = string(header.Value)
+		case snapshotOffsetHeader:
+			offset, err = strconv.ParseInt(string(header.Value), 10, 64)
+		case snapshotPartHeader:
+			part, err = strconv.Atoi(string(header.Value))
+		case snapshotPartsHeader:
+			parts, err = strconv.Atoi(string(header.Value))
+		}
+		if err != nil {
+			return fmt.Errorf("header %s: %w", header.Key, err)
+		}
+	}
+	if parts == 0 || part >= parts {
+		return fmt.Errorf("wrong snapshot part %d of %d", part, parts)
+	}
+	if c.latest != nil && offset <= c.latest.Offset {
+		return nil
+	}
+
+	data := msg.Value
+	if c.source.Decrypt != nil {
+		if data, err = c.source.Decrypt(msg.Value, chunked, keyID); err != nil {
+			return fmt.Errorf("decryption: %w", err)
+		}
+	}
+	if c.source.VerifySign != nil {
+		if err = c.source.VerifySign(signature, data); err != nil {
+			return fmt.Errorf("%w: snapshot part %d of %d", errWrongSignature, part, parts)
+		}
+	}
+	var records []snapshotRecord
+	if err = json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+
+	if c.parts[offset] == nil {
+		c.parts[offset] = map[int][]snapshotRecord{}
+	}
+	c.parts[offset][part] = records
+	if len(c.parts[offset]) < parts {
+		return nil
+	}
+
+	snapshot := &Snapshot{Offset: offset, Records: map[string][]byte{}}
+	for _, records := range c.parts[offset] {
+		for _, record := range records {
+			data, err := c.source.upgradeSchema(strings.Split(record.Key, "/")[0], record.SchemaVersion, record.Data)
+			if err != nil {
+				return fmt.Errorf("snapshot record %s: %w", record.Key, err)
+			}
+			snapshot.Records[record.Key] = data
+		}
+	}
+	c.latest = snapshot
+	for o := range c.parts {
+		if o <= offset {
+			delete(c.parts, o)
+		}
+	}
+	return nil
+}
+
+func (rk *KafkaSourceImpl) snapshotTopicExists() (bool, error) {
+	if rk.ProvideSnapshotTopicName == nil {
+		return false, nil
+	}
+	return rk.KafkaBroker.TopicExists(rk.ProvideSnapshotTopicName(rk.KafkaBroker))
+}
+
+// LatestSnapshot reads the snapshot topic and returns the latest complete snapshot, nil if there is no snapshot
+func (rk *KafkaSourceImpl) LatestSnapshot() (*Snapshot, error) {
+	exists, err := rk.snapshotTopicExists()
+	if err != nil || !exists {
+		return nil, err
+	}
+	topicName := rk.ProvideSnapshotTopicName(rk.KafkaBroker)
+	lastOffset, err := rk.lastOffset(topicName)
+	if err != nil {
+		return nil, err
+	}
+	collector := newSnapshotCollector(rk)
+	logger := rk.Logger.Named("LatestSnapshot")
+	// the topic is compacted, so the first message can be not at zero offset, the loop reads it from the beginning
+	err = rk.readTopic(topicName, 0, lastOffset, func(msg *kafka.Message) error {
+		err := collector.add(msg)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("snapshot part at offset %d skipped: %s", msg.TopicPartition.Offset, err.Error()))
+		}
+		return nil
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+	if collector.latest != nil {
+		logger.Info("snapshot found", "offset", collector.latest.Offset, "records", len(collector.latest.Records))
+	}
+	return collector.latest, nil
+}
+
+func (rk *KafkaSourceImpl) restoreSnapshot(txn Txn, snapshot *Snapshot) error {
+	for key, data := range snapshot.Records {
+		splitted := strings.Split(key, "/")
+		if len(splitted) != 2 {
+			return fmt.Errorf("key %q has wong format", key)
+		}
+		err := rk.ProcessRestoreMessage(txn, MsgDecoded{Type: splitted[0], ID: splitted[1], Offset: snapshot.Offset, Data: data})
+		if err != nil {
+			return fmt.Errorf("key: %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// SnapshotWriter periodically writes snapshots of the source topic into the snapshot topic of the source
+type SnapshotWriter struct {
+	source *KafkaSourceImpl
+	// Sign returns signature of data, mandatory
+	Sign func(data []byte) ([]byte, error)
+	// Encrypt data, returns id of the used key, nil if topic not encrypted
+	Encrypt func(data []byte) (encrypted []byte, chunked bool, keyID string, err error)
+	// Interval is the minimal interval between writing snapshots
+	Interval time.Duration
+
+	mutex        sync.Mutex
+	topicCreated bool
+	snapshot     *Snapshot
+	lastWrite    time.Time
+	logger       hclog.Logger
+}
+
+func NewSnapshotWriter(source *KafkaSourceImpl, sign func(data []byte) ([]byte, error),
+	encrypt func(data []byte) ([]byte, bool, string, error), parentLogger hclog.Logger) *SnapshotWriter {
+	return &SnapshotWriter{
+		source:   source,
+		Sign:     sign,
+		Encrypt:  encrypt,
+		Interval: DefaultSnapshotInterval,
+		logger:   parentLogger.Named("SnapshotWriter"),
+	}
+}
+
+// OnPeriodical writes a new snapshot, if the interval is passed and the source topic has new messages
+func (w *SnapshotWriter) OnPeriodical() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if !w.source.KafkaBroker.Configured() || time.Since(w.lastWrite) < w.Interval {
+		return nil
+	}
+
+	topicName := w.source.ProvideSnapshotTopicName(w.source.KafkaBroker)
+	if !w.topicCreated {
+		if err := w.source.KafkaBroker.CreateTopic(context.Background(), topicName, nil); err != nil {
+			return fmt.Errorf("creating snapshot topic: %w", err)
+		}
+		w.topicCreated = true
+	}
+	if w.snapshot == nil {
+		snapshot, err := w.source.LatestSnapshot()
+		if err != nil {
+			return fmt.Errorf("loading snapshot: %w", err)
+		}
+		if snapshot == nil {
+			snapshot = &Snapshot{Offset: -1, Records: map[string][]byte{}}
+		}
+		w.snapshot = snapshot
+	}
+
+	snapshot, err := w.collectTail()
+	if err != nil {
+		return fmt.Errorf("reading topic: %w", err)
+	}
+	if snapshot.Offset == w.snapshot.Offset {
+		w.logger.Debug("no new messages")
+		return nil
+	}
+
+	msgs, err := snapshot.messages(topicName, w.Sign, w.Encrypt, w.source.currentSchemaVersion)
+	if err != nil {
+		return err
+	}
+	if err = w.source.KafkaBroker.SendMessages(msgs, nil); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	w.logger.Info("snapshot written", "offset", snapshot.Offset, "records", len(snapshot.Records), "parts", len(msgs))
+	w.snapshot = snapshot
+	w.lastWrite = time.Now()
+	return nil
+}
+
+// collectTail returns a copy of the current snapshot, supplemented by messages written after it
+func (w *SnapshotWriter) collectTail() (*Snapshot, error) {
+	snapshot := &Snapshot{Offset: w.snapshot.Offset, Records: make(map[string][]byte, len(w.snapshot.Records))}
+	for key, data := range w.snapshot.Records {
+		snapshot.Records[key] = data
+	}
+	handler := func(_ Txn, msg *kafka.Message, _ hclog.Logger) error {
+		snapshot.Offset = int64(msg.TopicPartition.Offset)
+		decoded, err := w.source.decodeMessageAndCheck(msg)
+		if err != nil {
+			if errors.Is(err, errWrongSignature) && w.source.SkipRestorationOnWrongSignature {
+				return nil
+			}
+			return err
+		}
+		if decoded.IsDeleted() {
+			delete(snapshot.Records, decoded.Key())
+		} else {
+			snapshot.Records[decoded.Key()] = decoded.Data
+		}
+		return nil
+	}
+	err := w.source.runRestorationLoop(nil, handler, w.logger, w.snapshot.Offset+1)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
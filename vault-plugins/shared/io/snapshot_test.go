@@ -0,0 +1,105 @@
+package io
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+
+	sharedkafka "github.com/flant/negentropy/vault-plugins/shared/kafka"
+)
+
+func snapshotTestSource(t *testing.T) (*KafkaSourceImpl, *SnapshotWriter) {
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	encrypter := sharedkafka.NewEncrypter()
+	source := &KafkaSourceImpl{
+		Logger: hclog.NewNullLogger(),
+		VerifySign: func(signature []byte, messageValue []byte) error {
+			hashed := sha256.Sum256(messageValue)
+			return rsa.VerifyPKCS1v15(&pk.PublicKey, crypto.SHA256, hashed[:], signature)
+		},
+		Decrypt: func(encryptedMessageValue []byte, chunked bool, _ string) ([]byte, error) {
+			return encrypter.Decrypt(encryptedMessageValue, pk, chunked)
+		},
+	}
+	sign := func(data []byte) ([]byte, error) {
+		hashed := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, pk, crypto.SHA256, hashed[:])
+	}
+	encrypt := func(data []byte) ([]byte, bool, string, error) {
+		encrypted, chunked, err := encrypter.Encrypt(data, &pk.PublicKey)
+		return encrypted, chunked, sharedkafka.KeyID(&pk.PublicKey), err
+	}
+	return source, NewSnapshotWriter(source, sign, encrypt, hclog.NewNullLogger())
+}
+
+func snapshotTestKafkaMessages(msgs []sharedkafka.Message) []*kafka.Message {
+	var result []*kafka.Message
+	for i := range msgs {
+		msg := &kafka.Message{Key: []byte(msgs[i].Key), Value: msgs[i].Value}
+		for k, v := range msgs[i].Headers {
+			msg.Headers = append(msg.Headers, kafka.Header{Key: k, Value: v})
+		}
+		result = append(result, msg)
+	}
+	return result
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	source, writer := snapshotTestSource(t)
+	snapshot := &Snapshot{Offset: 1000, Records: map[string][]byte{}}
+	for i := 0; i < 50; i++ {
+		// about 20kb per record, to split the snapshot into several parts
+		snapshot.Records[fmt.Sprintf("tenant/%d", i)] = []byte(fmt.Sprintf("{\"data\":%q}", strings.Repeat("x", 20*1024)))
+	}
+
+	msgs, err := snapshot.messages("snapshot.root_source", writer.Sign, writer.Encrypt, source.currentSchemaVersion)
+	require.NoError(t, err)
+	require.Greater(t, len(msgs), 1)
+
+	collector := newSnapshotCollector(source)
+	for _, msg := range snapshotTestKafkaMessages(msgs) {
+		require.NoError(t, collector.add(msg))
+	}
+	require.Equal(t, snapshot, collector.latest)
+}
+
+func TestSnapshotIncompleteIsIgnored(t *testing.T) {
+	source, writer := snapshotTestSource(t)
+	first := &Snapshot{Offset: 10, Records: map[string][]byte{"tenant/1": []byte("{}")}}
+	second := &Snapshot{Offset: 20, Records: map[string][]byte{"tenant/1": []byte("{}"), "tenant/2": []byte("{}")}}
+	firstMsgs, err := first.messages("snapshot.root_source", writer.Sign, writer.Encrypt, source.currentSchemaVersion)
+	require.NoError(t, err)
+	secondMsgs, err := second.messages("snapshot.root_source", writer.Sign, writer.Encrypt, source.currentSchemaVersion)
+	require.NoError(t, err)
+	// the second snapshot pretends to have one more part, which is not written
+	for i := range secondMsgs {
+		secondMsgs[i].Headers[snapshotPartsHeader] = []byte("2")
+	}
+
+	collector := newSnapshotCollector(source)
+	for _, msg := range snapshotTestKafkaMessages(append(firstMsgs, secondMsgs...)) {
+		require.NoError(t, collector.add(msg))
+	}
+	require.Equal(t, first, collector.latest)
+}
+
+func TestSnapshotWrongSignature(t *testing.T) {
+	source, _ := snapshotTestSource(t)
+	_, stranger := snapshotTestSource(t)
+	snapshot := &Snapshot{Offset: 10, Records: map[string][]byte{"tenant/1": []byte("{}")}}
+	msgs, err := snapshot.messages("snapshot.root_source", stranger.Sign, nil, source.currentSchemaVersion)
+	require.NoError(t, err)
+	source.Decrypt = nil
+
+	err = newSnapshotCollector(source).add(snapshotTestKafkaMessages(msgs)[0])
+	require.ErrorIs(t, err, errWrongSignature)
+}
@@ -21,6 +21,7 @@ type MsgDecoded struct {
 	Type      string
 	ID        string
 	TimeStamp time.Time
+	Offset    int64
 	Data      []byte
 }
 
@@ -50,10 +51,19 @@ type KafkaSourceImpl struct {
 	ProvideRunConsumerGroupID func(kf *sharedkafka.MessageBroker) string
 	// topicName, mandatory
 	ProvideTopicName func(kf *sharedkafka.MessageBroker) string
+	// offset of the last processed message, if passed, run consumer starts after it instead of the committed offset of
+	// the group, ok=false means reading from the beginning of the topic, optional
+	ProvideLastProcessedOffset func(kf *sharedkafka.MessageBroker) (offset int64, ok bool)
+	// topic with snapshots of the topic, written by SnapshotWriter, if passed, Restore loads the latest snapshot
+	// and reads only messages after it, optional
+	ProvideSnapshotTopicName func(kf *sharedkafka.MessageBroker) string
 	// check msg signature, mandatory
 	VerifySign func(signature []byte, messageValue []byte) error
-	// Decrypt message, nil if topic not encrypted
-	Decrypt func(encryptedMessageValue []byte, chunked bool) ([]byte, error)
+	// Decrypt message, keyID is passed by the message header and can be empty, nil if topic not encrypted
+	Decrypt func(encryptedMessageValue []byte, chunked bool, keyID string) ([]byte, error)
+	// upgrades data of messages from the version at SchemaVersionHeader to the current one,
+	// nil if data is passed as is, optional
+	SchemaRegistry *SchemaRegistry
 	// process MsgDecoded at normal reading loop, if RestoreStrictlyTillRunConsumer=true,
 	// should be idempotent to situation when ProcessRestoreMessage works first on message
 	ProcessRunMessage func(txn Txn, m MsgDecoded) error
@@ -76,6 +86,10 @@ type KafkaSourceImpl struct {
 	RestoreStrictlyTillRunConsumer bool
 	// can be nil if not RestoreStrictlyTillRunConsumer
 	Storage logical.Storage
+
+	// count of messages, moved to the dead letter topic
+	deadLettered           int64
+	deadLetterTopicCreated bool
 }
 
 func (rk *KafkaSourceImpl) Name() string {
@@ -97,7 +111,7 @@ func (rk *KafkaSourceImpl) Run(store *MemoryStore) {
 
 	rk.Logger.Debug("Watcher - start", "groupID", rk.ProvideRunConsumerGroupID(rk.KafkaBroker))
 	defer rk.Logger.Debug("Watcher - stop", "groupID", rk.ProvideRunConsumerGroupID(rk.KafkaBroker))
-	runConsumer, err := rk.KafkaBroker.GetSubscribedRunConsumer(rk.ProvideRunConsumerGroupID(rk.KafkaBroker), rk.ProvideTopicName(rk.KafkaBroker))
+	runConsumer, err := rk.runConsumer()
 	if err != nil {
 		// it is critical error, if it happens, there is no way to restart it without repairing
 		rk.Logger.Error(fmt.Sprintf("critical error: %s", err.Error()))
@@ -108,6 +122,29 @@ func (rk *KafkaSourceImpl) Run(store *MemoryStore) {
 	rk.runMessageLoop(store, runConsumer)
 }
 
+func (rk *KafkaSourceImpl) runConsumer() (*kafka.Consumer, error) {
+	groupID := rk.ProvideRunConsumerGroupID(rk.KafkaBroker)
+	topicName := rk.ProvideTopicName(rk.KafkaBroker)
+	if rk.ProvideLastProcessedOffset == nil {
+		return rk.KafkaBroker.GetSubscribedRunConsumer(groupID, topicName)
+	}
+	consumer, err := rk.KafkaBroker.GetUnsubscribedRunConsumer(groupID)
+	if err != nil {
+		return nil, err
+	}
+	startOffset := kafka.OffsetBeginning
+	if lastOffset, ok := rk.ProvideLastProcessedOffset(rk.KafkaBroker); ok {
+		startOffset = kafka.Offset(lastOffset + 1)
+	}
+	rk.Logger.Info("run consumer starts", "topicName", topicName, "offset", startOffset.String())
+	// topics are allowed to have only 1 partition
+	err = consumer.Assign([]kafka.TopicPartition{{Topic: &topicName, Partition: 0, Offset: startOffset}})
+	if err != nil {
+		return nil, err
+	}
+	return consumer, nil
+}
+
 func (rk *KafkaSourceImpl) runMessageLoop(store *MemoryStore, consumer *kafka.Consumer) {
 	logger := rk.Logger.Named("RunMessageLoop")
 	logger.Info("start")
@@ -131,6 +168,10 @@ func (rk *KafkaSourceImpl) runMessageLoop(store *MemoryStore, consumer *kafka.Co
 					rk.Logger.Debug(fmt.Sprintf("%s: message %q skiped", err.Error(), msgKey))
 					err = nil
 				}
+				if err != nil {
+					err = rk.handleRunFailure(store, consumer, e, err)
+				}
+				rk.observeProcessed(consumer, e, err)
 				if err != nil {
 					rk.Logger.Error(fmt.Sprintf("msg: %s: %s", msgKey, err.Error()))
 				}
@@ -184,7 +225,7 @@ func (rk *KafkaSourceImpl) msgRunHandler(store *MemoryStore, sourceConsumer *kaf
 var errWrongSignature = fmt.Errorf("wrong signature")
 
 func (rk *KafkaSourceImpl) decodeMessageAndCheck(msg *kafka.Message) (*MsgDecoded, error) {
-	result := &MsgDecoded{TimeStamp: msg.Timestamp}
+	result := &MsgDecoded{TimeStamp: msg.Timestamp, Offset: int64(msg.TopicPartition.Offset)}
 	splitted := strings.Split(string(msg.Key), "/")
 	if len(splitted) != 2 {
 		return nil, fmt.Errorf("key %q has wong format", string(msg.Key))
@@ -193,6 +234,7 @@ func (rk *KafkaSourceImpl) decodeMessageAndCheck(msg *kafka.Message) (*MsgDecode
 
 	var signature []byte
 	var chunked bool
+	var keyID string
 	for _, header := range msg.Headers {
 		switch header.Key {
 		case "signature":
@@ -200,13 +242,16 @@ func (rk *KafkaSourceImpl) decodeMessageAndCheck(msg *kafka.Message) (*MsgDecode
 
 		case "chunked":
 			chunked = true
+
+		case sharedkafka.KeyIDHeader:
+			keyID = string(header.Value)
 		}
 	}
 
 	var err error
 	result.Data = msg.Value
 	if rk.Decrypt != nil && len(msg.Value) > 0 {
-		result.Data, err = rk.Decrypt(msg.Value, chunked)
+		result.Data, err = rk.Decrypt(msg.Value, chunked, keyID)
 		if err != nil {
 			return nil, fmt.Errorf("decryption: %w", err)
 		}
@@ -222,6 +267,15 @@ func (rk *KafkaSourceImpl) decodeMessageAndCheck(msg *kafka.Message) (*MsgDecode
 		}
 	}
 
+	version, err := schemaVersion(msg)
+	if err != nil {
+		return nil, err
+	}
+	result.Data, err = rk.upgradeSchema(result.Type, version, result.Data)
+	if err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
@@ -230,15 +284,58 @@ func (rk *KafkaSourceImpl) Restore(txn *memdb.Txn) error {
 		return fmt.Errorf("%s has unstopped main reading loop", rk.Name())
 	}
 
-	return rk.RunRestorationLoop(txn, rk.msgRestoreHandler, rk.Logger)
+	var startOffset int64
+	if rk.ProvideSnapshotTopicName != nil {
+		snapshot, err := rk.LatestSnapshot()
+		if err != nil {
+			return fmt.Errorf("loading snapshot: %w", err)
+		}
+		if snapshot != nil {
+			if err = rk.restoreSnapshot(txn, snapshot); err != nil {
+				return fmt.Errorf("restoring snapshot: %w", err)
+			}
+			startOffset = snapshot.Offset + 1
+		}
+	}
+
+	return rk.runRestorationLoop(txn, rk.msgRestoreHandler, rk.Logger, startOffset)
+}
+
+// errTillReached stops reading of the topic by RestoreTill
+var errTillReached = errors.New("till is reached")
+
+// RestoreTill fills txn by messages, written into the topic not later than till, it is used for point-in-time reads,
+// so the topic should not be compacted. Returns the moment of the first message of the topic, zero for empty topic
+func (rk *KafkaSourceImpl) RestoreTill(txn *memdb.Txn, till time.Time) (time.Time, error) {
+	var first time.Time
+	handler := func(txn Txn, msg *kafka.Message, logger hclog.Logger) error {
+		if first.IsZero() {
+			first = msg.Timestamp
+		}
+		if msg.Timestamp.After(till) {
+			return errTillReached
+		}
+		return rk.msgRestoreHandler(txn, msg, logger)
+	}
+	err := rk.RunRestorationLoop(txn, handler, rk.Logger)
+	if errors.Is(err, errTillReached) {
+		err = nil
+	}
+	return first, err
 }
 
 // RunRestorationLoop read from topic untill runConsumer or untill the end of topic
 func (rk *KafkaSourceImpl) RunRestorationLoop(txn Txn, handler func(txn Txn, msg *kafka.Message,
 	logger hclog.Logger) error, logger hclog.Logger) error {
+	return rk.runRestorationLoop(txn, handler, logger, 0)
+}
+
+// runRestorationLoop read from topic, starting from startOffset, untill runConsumer or untill the end of topic
+func (rk *KafkaSourceImpl) runRestorationLoop(txn Txn, handler func(txn Txn, msg *kafka.Message,
+	logger hclog.Logger) error, logger hclog.Logger, startOffset int64) error {
 	logger = logger.Named("RunRestorationLoop")
 	topicName := rk.ProvideTopicName(rk.KafkaBroker)
-	logger.Debug("started", "topicName", topicName)
+	logger.Debug("started", "topicName", topicName, "startOffset", startOffset)
 	defer logger.Debug("exit")
 	runConsumerID := rk.ProvideRunConsumerGroupID(rk.KafkaBroker)
 
@@ -250,30 +347,56 @@ func (rk *KafkaSourceImpl) RunRestorationLoop(txn Txn, handler func(txn Txn, msg
 			return fmt.Errorf("getting last offset from storage:%w", err)
 		}
 	} else {
-		newConsumer, err := rk.KafkaBroker.GetRestorationReader()
+		lastProcessedOffset, err = rk.lastOffset(topicName)
 		if err != nil {
 			return err
 		}
-
-		lastProcessedOffset, _, err = LastOffsetByNewConsumer(newConsumer, topicName)
-		if err != nil {
-			return fmt.Errorf("getting offset by newConsumer:%w", err)
-		}
-		defer sharedkafka.DeferredСlose(newConsumer, rk.Logger)
-		time.Sleep(time.Nanosecond) // to guarantee getting definitely new RestorationReader
 	}
 
 	if lastProcessedOffset <= 0 {
 		logger.Debug("normal finish: no messages", "topicName", topicName)
 		return nil
 	}
+	if lastProcessedOffset < startOffset {
+		logger.Debug("normal finish: no new messages", "topicName", topicName)
+		return nil
+	}
+
+	return rk.readTopic(topicName, startOffset, lastProcessedOffset, func(msg *kafka.Message) error {
+		return handler(txn, msg, logger)
+	}, logger)
+}
+
+// lastOffset returns offset of the last message at the topic
+func (rk *KafkaSourceImpl) lastOffset(topicName string) (int64, error) {
+	newConsumer, err := rk.KafkaBroker.GetRestorationReader()
+	if err != nil {
+		return 0, err
+	}
+	defer sharedkafka.DeferredСlose(newConsumer, rk.Logger)
+
+	lastOffset, _, err := LastOffsetByNewConsumer(newConsumer, topicName)
+	if err != nil {
+		return 0, fmt.Errorf("getting offset by newConsumer:%w", err)
+	}
+	time.Sleep(time.Nanosecond) // to guarantee getting definitely new RestorationReader
+	return lastOffset, nil
+}
 
+// readTopic passes messages of the topic from startOffset till lastOffset inclusive to handler
+func (rk *KafkaSourceImpl) readTopic(topicName string, startOffset int64, lastOffset int64,
+	handler func(msg *kafka.Message) error, logger hclog.Logger) error {
 	newConsumer, err := rk.KafkaBroker.GetRestorationReader()
 	if err != nil {
 		return err
 	}
 
-	err = newConsumer.Subscribe(topicName, nil)
+	if startOffset > 0 {
+		// topics are allowed to have only 1 partition
+		err = newConsumer.Assign([]kafka.TopicPartition{{Topic: &topicName, Partition: 0, Offset: kafka.Offset(startOffset)}})
+	} else {
+		err = newConsumer.Subscribe(topicName, nil)
+	}
 	if err != nil {
 		return err
 	}
@@ -293,12 +416,12 @@ func (rk *KafkaSourceImpl) RunRestorationLoop(txn Txn, handler func(txn Txn, msg
 			continue
 		}
 		currentMessageOffset := int64(msg.TopicPartition.Offset)
-		err = handler(txn, msg, logger)
+		err = handler(msg)
 		consumed++
 		if err != nil {
 			return fmt.Errorf("key: %s, offset: %d: %w", msg.Key, msg.TopicPartition.Offset, err)
 		}
-		if currentMessageOffset == lastProcessedOffset {
+		if currentMessageOffset >= lastOffset {
 			logger.Info(fmt.Sprintf("topicName: %s - normal finish, consumed %d", topicName, consumed))
 			return nil
 		}
@@ -311,6 +434,11 @@ func (rk *KafkaSourceImpl) msgRestoreHandler(txn Txn, msg *kafka.Message, _ hclo
 		rk.Logger.Debug(fmt.Sprintf("decoding and checking message: %s: message skiped", err.Error()))
 		return nil
 	}
+	if errors.Is(err, sharedkafka.ErrEncryptionKeyRetired) {
+		// the topic is re-encrypted before retiring the key, so the message has a newer copy
+		rk.Logger.Warn(fmt.Sprintf("decoding and checking message: %s: message skiped", err.Error()))
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("decoding and checking message: %w", err)
 	}
@@ -407,3 +535,19 @@ func HandleTombStone(db Txn, msg MsgDecoded) (bool, error) {
 	}
 	return true, nil
 }
+
+// HandleRootPublicKey trusts the public key of the root source, passed by kafka.RootPublicKeyType message,
+// if storage is passed, the key is stored into the plugin config
+func HandleRootPublicKey(kf *sharedkafka.MessageBroker, storage logical.Storage, msg MsgDecoded) (bool, error) {
+	if msg.Type != sharedkafka.RootPublicKeyType {
+		return false, nil
+	}
+	if msg.IsDeleted() {
+		return true, nil
+	}
+	pub, err := sharedkafka.ParseRootPublicKey(msg.Data)
+	if err != nil {
+		return false, fmt.Errorf("parsing root public key %q: %w", msg.Key(), err)
+	}
+	return true, kf.TrustRootPublicKey(context.Background(), storage, pub)
+}
@@ -94,7 +94,7 @@ func TestKafkaSourceRunAndRestore(t *testing.T) {
 					VerifySign: func(signature []byte, messageValue []byte) error {
 						return nil
 					},
-					Decrypt: func(encryptedMessageValue []byte, chunked bool) ([]byte, error) {
+					Decrypt: func(encryptedMessageValue []byte, chunked bool, _ string) ([]byte, error) {
 						return encryptedMessageValue, nil
 					},
 					ProcessRunMessage: func(txn Txn, m MsgDecoded) error {
@@ -1,11 +1,14 @@
 package io
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
+	hcmemdb "github.com/hashicorp/go-memdb"
 
 	"github.com/flant/negentropy/vault-plugins/shared/kafka"
 	"github.com/flant/negentropy/vault-plugins/shared/memdb"
@@ -54,6 +57,8 @@ type MemoryStore struct {
 
 	hookMutex sync.Mutex
 	hooks     map[string][]ObjectHook // by objectType
+
+	rows rowsCounter
 	// vaultStorage      VaultStorage
 	// downstreamApis    []DownstreamApi
 	logger hclog.Logger
@@ -63,6 +68,8 @@ type MemoryStoreTxn struct {
 	*memdb.Txn
 
 	memstore *MemoryStore // crosslink
+
+	afterCommit []func()
 }
 
 func (ms *MemoryStore) Txn(write bool) *MemoryStoreTxn {
@@ -70,7 +77,7 @@ func (ms *MemoryStore) Txn(write bool) *MemoryStoreTxn {
 	if write {
 		mTxn.TrackChanges()
 	}
-	return &MemoryStoreTxn{mTxn, ms}
+	return &MemoryStoreTxn{Txn: mTxn, memstore: ms}
 }
 
 func (mst *MemoryStoreTxn) Insert(table string, obj interface{}) error {
@@ -189,10 +196,21 @@ func (mst *MemoryStoreTxn) Commit(sourceMsg ...*kafka.SourceInputMessage) error
 	}
 
 	mst.memstore.logger.Debug("Commit transaction")
+	changes := mst.Txn.Changes()
 	mst.Txn.Commit()
+	mst.memstore.rows.countChanges(changes)
+	for _, fn := range mst.afterCommit {
+		fn()
+	}
 	return nil
 }
 
+// AfterCommit registers fn to be called after successful commit of the transaction,
+// functions are called in the order of registration, aborted transaction doesn't call them
+func (mst *MemoryStoreTxn) AfterCommit(fn func()) {
+	mst.afterCommit = append(mst.afterCommit, fn)
+}
+
 func (mst *MemoryStoreTxn) Abort() {
 	mst.Txn.Abort()
 }
@@ -213,6 +231,7 @@ func NewMemoryStore(schema *memdb.DBSchema, conn *kafka.MessageBroker, parentLog
 		make(map[string]KafkaDestination),
 		sync.Mutex{},
 		make(map[string][]ObjectHook),
+		rowsCounter{},
 		parentLogger.Named("MemStore"),
 	}, nil
 }
@@ -348,15 +367,19 @@ func (ms *MemoryStore) Restore() error {
 	defer ms.kafkaMutex.RUnlock()
 	for _, ks := range ms.kafkaSources {
 		ms.logger.Debug(fmt.Sprintf("kafka_source %#v start", ks))
+		started := time.Now()
 		err := ks.Restore(txn)
 		if err != nil {
 			logger.Error("restoring", "error", err.Error())
 			txn.Abort()
 			return err
 		}
+		observeRestoreDuration(ks.Name(), started)
 		ms.logger.Debug(fmt.Sprintf("kafka_source %#v end", ks))
 	}
+	changes := txn.Changes()
 	txn.Commit()
+	ms.rows.countChanges(changes)
 	logger.Debug("normal finish")
 	return nil
 }
@@ -401,3 +424,69 @@ func CommitWithLog(txn *MemoryStoreTxn, logger hclog.Logger) error {
 	}
 	return nil
 }
+
+// rewriteBatchSize is the count of objects, rewritten by one transaction of RewriteAll
+const rewriteBatchSize = 1000
+
+// RewriteAll sends all objects to kafka destinations again, it is used to rewrite compacted topics under the
+// current encryption keys, hooks are not triggered. Objects are rewritten by batches, each batch is committed
+// by its own transaction, so writing is not blocked for the whole rewriting. It returns count of rewritten objects
+func (ms *MemoryStore) RewriteAll() (int, error) {
+	if !ms.kafkaConnection.Configured() {
+		return 0, fmt.Errorf("kafka is not configured")
+	}
+	count := 0
+	for table, tableSchema := range ms.MemDB.DBSchema().Tables {
+		indexer, ok := tableSchema.Indexes["id"].Indexer.(hcmemdb.SingleIndexer)
+		if !ok {
+			return count, fmt.Errorf("%s: id index is not single", table)
+		}
+		var lastKey []byte
+		for {
+			n, key, err := ms.rewriteBatch(table, indexer, lastKey)
+			if err != nil {
+				return count, fmt.Errorf("%s: %w", table, err)
+			}
+			count += n
+			if n < rewriteBatchSize {
+				break
+			}
+			lastKey = key
+		}
+	}
+	return count, nil
+}
+
+// rewriteBatch rewrites up to rewriteBatchSize objects of the table, which id index keys are greater than lastKey,
+// it returns count of rewritten objects and the key of the last one
+func (ms *MemoryStore) rewriteBatch(table string, indexer hcmemdb.SingleIndexer, lastKey []byte) (int, []byte, error) {
+	txn := ms.Txn(true)
+	defer txn.Abort()
+	iter, err := txn.Get(table, "id")
+	if err != nil {
+		return 0, nil, err
+	}
+	var objs []interface{}
+	var key []byte
+	for obj := iter.Next(); obj != nil && len(objs) < rewriteBatchSize; obj = iter.Next() {
+		_, objKey, err := indexer.FromObject(obj)
+		if err != nil {
+			return 0, nil, err
+		}
+		if lastKey != nil && bytes.Compare(objKey, lastKey) <= 0 {
+			continue
+		}
+		objs = append(objs, obj)
+		key = objKey
+	}
+	for _, obj := range objs {
+		// insert into the underlying txn to skip hooks and checks, the change is tracked anyway
+		if err = txn.Txn.Txn.Insert(table, obj); err != nil {
+			return 0, nil, err
+		}
+	}
+	if err = txn.Commit(); err != nil {
+		return 0, nil, err
+	}
+	return len(objs), key, nil
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/flant/negentropy/vault-plugins/shared/jwt/backend"
 	"github.com/flant/negentropy/vault-plugins/shared/jwt/model"
 	"github.com/flant/negentropy/vault-plugins/shared/jwt/usecase"
+	"github.com/flant/negentropy/vault-plugins/shared/metrics"
 )
 
 type Controller struct {
@@ -113,6 +114,30 @@ func (c *Controller) OnPeriodical(txn *sharedio.MemoryStoreTxn) error {
 	return nil
 }
 
+// CollectMetrics updates age of the newest own key, used for signing JWT, it is called before reading metrics
+func (c *Controller) CollectMetrics(txn *sharedio.MemoryStoreTxn) error {
+	stateRepo, err := c.deps.StateRepo(txn)
+	if err != nil {
+		return err
+	}
+	keyPair, err := stateRepo.GetKeyPair()
+	if err != nil {
+		return err
+	}
+	if keyPair == nil || keyPair.PublicKeys == nil || len(keyPair.PublicKeys.Keys) == 0 {
+		return nil
+	}
+	newest := keyPair.PublicKeys.Keys[0].GenerateTime
+	for _, key := range keyPair.PublicKeys.Keys {
+		if key.GenerateTime.After(newest) {
+			newest = key.GenerateTime
+		}
+	}
+	// the gauge is not labeled by key ids, as series of rotated keys can't be dropped
+	metrics.SetGauge("jwks_key_age_seconds", c.deps.Now().Sub(newest).Seconds())
+	return nil
+}
+
 func (c *Controller) GetConfig(txn *sharedio.MemoryStoreTxn) (*model.Config, error) {
 	return c.deps.ConfigRepo(txn).Get()
 }
@@ -16,6 +16,7 @@ import (
 	log "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	"github.com/flant/negentropy/vault-plugins/shared/metrics"
 	"github.com/flant/negentropy/vault-plugins/shared/utils"
 )
 
@@ -67,9 +68,19 @@ type MessageBroker struct {
 	transProducerSync sync.Once
 	transProducer     *kafka.Producer
 
+	// keysMutex guards encryption keys at KafkaConfig
+	keysMutex sync.RWMutex
+
 	KafkaConfig  BrokerConfig
 	PluginConfig PluginConfig
 
+	// OnEncryptionKeyPromoting is called before the key becomes primary, while the previous primary key is signing,
+	// if it fails, the key is not promoted
+	OnEncryptionKeyPromoting func(key EncryptionKey) error
+	// OnEncryptionKeyRetiring is called before the private part of the key is dropped,
+	// if it fails, the key is not retired
+	OnEncryptionKeyRetiring func(key EncryptionKey) error
+
 	Logger log.Logger
 }
 
@@ -91,6 +102,7 @@ func NewMessageBroker(ctx context.Context, storage logical.Storage, parentLogger
 		}
 
 		mb.KafkaConfig = config
+		mb.KafkaConfig.initEncryptionKeys()
 	}
 
 	se, err = storage.Get(ctx, PluginConfigPath)
@@ -172,6 +184,9 @@ type BrokerConfig struct {
 	// Self pair of keys from this vault plugin instance (or plugin privateKey and root publicKey for root source reading)
 	EncryptionPrivateKey *rsa.PrivateKey `json:"encrypt_private_key,omitempty"`
 	EncryptionPublicKey  *rsa.PublicKey  `json:"encrypt_public_key,omitempty"`
+
+	// All versions of self keys, EncryptionPrivateKey and EncryptionPublicKey are the primary one
+	EncryptionKeys []EncryptionKey `json:"encryption_keys,omitempty"`
 }
 
 type unmarshalablePrivateKey ecdsa.PrivateKey
@@ -199,6 +214,8 @@ func (bc *BrokerConfig) UnmarshalJSON(data []byte) error {
 
 		EncryptionPrivateKey *rsa.PrivateKey `json:"encrypt_private_key,omitempty"`
 		EncryptionPublicKey  *rsa.PublicKey  `json:"encrypt_public_key,omitempty"`
+
+		EncryptionKeys []EncryptionKey `json:"encryption_keys,omitempty"`
 	}{}
 
 	err := json.Unmarshal(data, &s)
@@ -210,6 +227,7 @@ func (bc *BrokerConfig) UnmarshalJSON(data []byte) error {
 	bc.Endpoints = s.Endpoints
 	bc.EncryptionPrivateKey = s.EncryptionPrivateKey
 	bc.EncryptionPublicKey = s.EncryptionPublicKey
+	bc.EncryptionKeys = s.EncryptionKeys
 
 	return nil
 }
@@ -219,12 +237,19 @@ type PluginConfig struct {
 	SelfTopicName string `json:"self_topic_name"`
 	RootTopicName string `json:"root_topic_name"`
 	// RootPublicKey public rsa key from Root Source vault
-	RootPublicKey     *rsa.PublicKey   `json:"root_public_key,omitempty"`
+	RootPublicKey *rsa.PublicKey `json:"root_public_key,omitempty"`
+	// RootPublicKeys are trusted public keys of Root Source vault, passed by RootPublicKeyType messages before rotation
+	RootPublicKeys    []*rsa.PublicKey `json:"root_public_keys,omitempty"`
 	PeersPublicKeys   []*rsa.PublicKey `json:"peers_public_keys,omitempty"`
 	PublishQuotaUsage bool             `json:"publish_quota,omitempty"`
+	// DeadLetterMaxFailures is count of failures of message processing at run loops, after which the message
+	// is moved to the dead letter topic, zero turns off dead letter handling
+	DeadLetterMaxFailures int `json:"dead_letter_max_failures,omitempty"`
 }
 
 func (mb *MessageBroker) EncryptionPrivateKey() *rsa.PrivateKey {
+	mb.keysMutex.RLock()
+	defer mb.keysMutex.RUnlock()
 	return mb.KafkaConfig.EncryptionPrivateKey
 }
 
@@ -233,11 +258,13 @@ func (mb *MessageBroker) GetEndpoints() []string {
 }
 
 func (mb *MessageBroker) EncryptionPublicKey() *rsa.PublicKey {
+	mb.keysMutex.RLock()
+	defer mb.keysMutex.RUnlock()
 	return mb.KafkaConfig.EncryptionPublicKey
 }
 
 func (mb *MessageBroker) GetEncryptionPublicKeyStrict() (string, error) {
-	k := mb.KafkaConfig.EncryptionPublicKey
+	k := mb.EncryptionPublicKey()
 	if k == nil {
 		return "", fmt.Errorf("cannot getting kafka public key. may be kafka is not configure")
 	}
@@ -381,6 +408,10 @@ func (mb *MessageBroker) sendMessages(msgs []Message, source *SourceInputMessage
 		// treat all other errors as fatal errors
 		return err
 	}
+	for _, msg := range msgs {
+		// count of messages, written into kafka topics
+		metrics.IncrCounter("kafka_messages_sent_total", metrics.Label{Name: "topic", Value: msg.Topic})
+	}
 	return nil
 }
 
@@ -0,0 +1,262 @@
+package kafka
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// KeyIDHeader is the header of kafka message, which contains id of the key, used for encryption of the message
+const KeyIDHeader = "key_id"
+
+type EncryptionKeyState string
+
+const (
+	// EncryptionKeyPrimary is used for encryption and decryption, only one key can be primary
+	EncryptionKeyPrimary EncryptionKeyState = "primary"
+	// EncryptionKeyActive is used only for decryption
+	EncryptionKeyActive EncryptionKeyState = "active"
+	// EncryptionKeyRetired is not used at all, the private part of the key is dropped
+	EncryptionKeyRetired EncryptionKeyState = "retired"
+)
+
+var (
+	ErrEncryptionKeyNotFound = errors.New("encryption key not found")
+	ErrEncryptionKeyRetired  = errors.New("encryption key is retired")
+	ErrEncryptionKeyPrimary  = errors.New("encryption key is primary")
+	ErrEncryptionKeyInUse    = errors.New("encryption key is in use")
+)
+
+// EncryptionKey is a version of the plugin encryption keys
+type EncryptionKey struct {
+	ID         string             `json:"id"`
+	State      EncryptionKeyState `json:"state"`
+	PrivateKey *rsa.PrivateKey    `json:"private_key,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// KeyID returns id of the public key, it is the same at all plugins, so it is used to mark messages
+func KeyID(pub *rsa.PublicKey) string {
+	hashed := sha256.Sum256(x509.MarshalPKCS1PublicKey(pub))
+	return hex.EncodeToString(hashed[:8])
+}
+
+func newEncryptionKey(pk *rsa.PrivateKey, state EncryptionKeyState) EncryptionKey {
+	return EncryptionKey{
+		ID:         KeyID(&pk.PublicKey),
+		State:      state,
+		PrivateKey: pk,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// initEncryptionKeys makes the primary key from the single pair of keys, stored before versioning
+func (bc *BrokerConfig) initEncryptionKeys() {
+	if len(bc.EncryptionKeys) == 0 && bc.EncryptionPrivateKey != nil {
+		bc.EncryptionKeys = []EncryptionKey{newEncryptionKey(bc.EncryptionPrivateKey, EncryptionKeyPrimary)}
+	}
+}
+
+// EncryptionKeys returns copy of all keys, including retired
+func (mb *MessageBroker) EncryptionKeys() []EncryptionKey {
+	mb.keysMutex.RLock()
+	defer mb.keysMutex.RUnlock()
+	return append([]EncryptionKey{}, mb.KafkaConfig.EncryptionKeys...)
+}
+
+// decryptionKeys returns not retired private keys, the primary key is the first
+func (mb *MessageBroker) decryptionKeys() []*rsa.PrivateKey {
+	mb.keysMutex.RLock()
+	defer mb.keysMutex.RUnlock()
+	if len(mb.KafkaConfig.EncryptionKeys) == 0 {
+		if mb.KafkaConfig.EncryptionPrivateKey == nil {
+			return nil
+		}
+		return []*rsa.PrivateKey{mb.KafkaConfig.EncryptionPrivateKey}
+	}
+	var keys []*rsa.PrivateKey
+	for _, key := range mb.KafkaConfig.EncryptionKeys {
+		switch key.State {
+		case EncryptionKeyPrimary:
+			keys = append([]*rsa.PrivateKey{key.PrivateKey}, keys...)
+		case EncryptionKeyActive:
+			keys = append(keys, key.PrivateKey)
+		}
+	}
+	return keys
+}
+
+func (mb *MessageBroker) findEncryptionKey(keyID string) (EncryptionKey, error) {
+	mb.keysMutex.RLock()
+	defer mb.keysMutex.RUnlock()
+	for _, key := range mb.KafkaConfig.EncryptionKeys {
+		if key.ID == keyID {
+			if key.State == EncryptionKeyRetired {
+				return EncryptionKey{}, fmt.Errorf("%w: %s", ErrEncryptionKeyRetired, keyID)
+			}
+			return key, nil
+		}
+	}
+	if len(mb.KafkaConfig.EncryptionKeys) == 0 && mb.KafkaConfig.EncryptionPrivateKey != nil &&
+		KeyID(&mb.KafkaConfig.EncryptionPrivateKey.PublicKey) == keyID {
+		return newEncryptionKey(mb.KafkaConfig.EncryptionPrivateKey, EncryptionKeyPrimary), nil
+	}
+	return EncryptionKey{}, fmt.Errorf("%w: %s", ErrEncryptionKeyNotFound, keyID)
+}
+
+// Decrypt decrypts data by the key with keyID, if keyID is empty, all not retired keys are tried
+func (mb *MessageBroker) Decrypt(data []byte, chunked bool, keyID string) ([]byte, error) {
+	encrypter := NewEncrypter()
+	if keyID != "" {
+		key, err := mb.findEncryptionKey(keyID)
+		if err != nil {
+			return nil, err
+		}
+		return encrypter.Decrypt(data, key.PrivateKey, chunked)
+	}
+	var err error
+	for _, pk := range mb.decryptionKeys() {
+		var decrypted []byte
+		if decrypted, err = encrypter.Decrypt(data, pk, chunked); err == nil {
+			return decrypted, nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("%w: no keys", ErrEncryptionKeyNotFound)
+	}
+	return nil, err
+}
+
+// VerifyOwnSignature checks signature by all not retired keys of the plugin
+func (mb *MessageBroker) VerifyOwnSignature(signature []byte, hashed [32]byte) error {
+	var err error
+	for _, pk := range mb.decryptionKeys() {
+		if err = VerifySignature(signature, &pk.PublicKey, hashed); err == nil {
+			return nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("%w: no keys", ErrEncryptionKeyNotFound)
+	}
+	return err
+}
+
+// AddEncryptionKey generates a new active key, it is used for decryption only till promoting
+func (mb *MessageBroker) AddEncryptionKey(ctx context.Context, storage logical.Storage) (EncryptionKey, error) {
+	pk, err := rsa.GenerateKey(rand.Reader, 4096)
+	if err != nil {
+		return EncryptionKey{}, err
+	}
+	key := newEncryptionKey(pk, EncryptionKeyActive)
+	err = mb.updateEncryptionKeys(ctx, storage, func(keys []EncryptionKey) ([]EncryptionKey, error) {
+		return append(keys, key), nil
+	})
+	return key, err
+}
+
+// PromoteEncryptionKey makes the key primary, the previous primary key becomes active
+func (mb *MessageBroker) PromoteEncryptionKey(ctx context.Context, storage logical.Storage, keyID string) error {
+	if mb.OnEncryptionKeyPromoting != nil {
+		keys := mb.EncryptionKeys()
+		index, err := encryptionKeyIndex(keys, keyID)
+		if err != nil {
+			return err
+		}
+		if err = mb.OnEncryptionKeyPromoting(keys[index]); err != nil {
+			return fmt.Errorf("preparing promotion: %w", err)
+		}
+	}
+	return mb.updateEncryptionKeys(ctx, storage, func(keys []EncryptionKey) ([]EncryptionKey, error) {
+		index, err := encryptionKeyIndex(keys, keyID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range keys {
+			if keys[i].State == EncryptionKeyPrimary {
+				keys[i].State = EncryptionKeyActive
+			}
+		}
+		keys[index].State = EncryptionKeyPrimary
+		return keys, nil
+	})
+}
+
+// RetireEncryptionKey drops the private part of the key, messages encrypted by the key become unreadable
+func (mb *MessageBroker) RetireEncryptionKey(ctx context.Context, storage logical.Storage, keyID string) error {
+	if mb.OnEncryptionKeyRetiring != nil {
+		keys := mb.EncryptionKeys()
+		index, err := encryptionKeyIndex(keys, keyID)
+		if err != nil {
+			return err
+		}
+		if err = mb.OnEncryptionKeyRetiring(keys[index]); err != nil {
+			return fmt.Errorf("preparing retirement: %w", err)
+		}
+	}
+	return mb.updateEncryptionKeys(ctx, storage, func(keys []EncryptionKey) ([]EncryptionKey, error) {
+		index, err := encryptionKeyIndex(keys, keyID)
+		if err != nil {
+			return nil, err
+		}
+		if keys[index].State == EncryptionKeyPrimary {
+			return nil, fmt.Errorf("%w: promote another key before retiring", ErrEncryptionKeyPrimary)
+		}
+		keys[index].State = EncryptionKeyRetired
+		keys[index].PrivateKey = nil
+		return keys, nil
+	})
+}
+
+func encryptionKeyIndex(keys []EncryptionKey, keyID string) (int, error) {
+	for i := range keys {
+		if keys[i].ID == keyID {
+			if keys[i].State == EncryptionKeyRetired {
+				return 0, fmt.Errorf("%w: %s", ErrEncryptionKeyRetired, keyID)
+			}
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrEncryptionKeyNotFound, keyID)
+}
+
+// updateEncryptionKeys applies change to the copy of keys, stores the config and switches the primary pair of keys
+func (mb *MessageBroker) updateEncryptionKeys(ctx context.Context, storage logical.Storage,
+	change func(keys []EncryptionKey) ([]EncryptionKey, error)) error {
+	mb.keysMutex.Lock()
+	defer mb.keysMutex.Unlock()
+	if len(mb.KafkaConfig.EncryptionKeys) == 0 {
+		return fmt.Errorf("%w: kafka is not configured", ErrEncryptionKeyNotFound)
+	}
+	keys, err := change(append([]EncryptionKey{}, mb.KafkaConfig.EncryptionKeys...))
+	if err != nil {
+		return err
+	}
+
+	config := mb.KafkaConfig
+	config.EncryptionKeys = keys
+	for _, key := range keys {
+		if key.State == EncryptionKeyPrimary {
+			config.EncryptionPrivateKey = key.PrivateKey
+			config.EncryptionPublicKey = &key.PrivateKey.PublicKey
+		}
+	}
+	d, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	err = storage.Put(ctx, &logical.StorageEntry{Key: kafkaConfigPath, Value: d, SealWrap: true})
+	if err != nil {
+		return err
+	}
+	mb.KafkaConfig = config
+	return nil
+}
@@ -0,0 +1,136 @@
+package kafka
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptionKeysRotation(t *testing.T) {
+	b, storage := generateBackend(t)
+	tb := b.(testBackend)
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tb.broker.KafkaConfig.EncryptionPrivateKey = pk
+	tb.broker.KafkaConfig.EncryptionPublicKey = &pk.PublicKey
+	tb.broker.KafkaConfig.initEncryptionKeys()
+	oldKeyID := KeyID(&pk.PublicKey)
+	oldData, _, err := NewEncrypter().Encrypt([]byte("old"), tb.broker.EncryptionPublicKey())
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(cctx, &logical.Request{
+		Storage: storage, Operation: logical.UpdateOperation, Path: "kafka/encryption_keys",
+	})
+	require.NoError(t, err)
+	newKeyID := resp.Data["key_id"].(string)
+	require.Equal(t, EncryptionKeyActive, resp.Data["state"])
+	require.Equal(t, oldKeyID, KeyID(tb.broker.EncryptionPublicKey()), "a new key is not used till promoting")
+
+	_, err = b.HandleRequest(cctx, &logical.Request{
+		Storage: storage, Operation: logical.UpdateOperation, Path: "kafka/encryption_keys/" + newKeyID + "/promote",
+	})
+	require.NoError(t, err)
+	require.Equal(t, newKeyID, KeyID(tb.broker.EncryptionPublicKey()))
+
+	decrypted, err := tb.broker.Decrypt(oldData, false, oldKeyID)
+	require.NoError(t, err)
+	require.Equal(t, "old", string(decrypted))
+	decrypted, err = tb.broker.Decrypt(oldData, false, "")
+	require.NoError(t, err, "message without key id is decrypted by any active key")
+	require.Equal(t, "old", string(decrypted))
+	newData, _, err := NewEncrypter().Encrypt([]byte("new"), tb.broker.EncryptionPublicKey())
+	require.NoError(t, err)
+	hashed := sha256.Sum256([]byte("old"))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, pk, crypto.SHA256, hashed[:])
+	require.NoError(t, err)
+	require.NoError(t, tb.broker.VerifyOwnSignature(signature, hashed), "signature by active key is valid")
+
+	_, err = b.HandleRequest(cctx, &logical.Request{
+		Storage: storage, Operation: logical.UpdateOperation, Path: "kafka/encryption_keys/" + newKeyID + "/retire",
+	})
+	require.Error(t, err, "primary key can't be retired")
+	tb.broker.OnEncryptionKeyRetiring = func(key EncryptionKey) error {
+		return fmt.Errorf("%w: %s", ErrEncryptionKeyInUse, key.ID)
+	}
+	_, err = b.HandleRequest(cctx, &logical.Request{
+		Storage: storage, Operation: logical.UpdateOperation, Path: "kafka/encryption_keys/" + oldKeyID + "/retire",
+	})
+	require.Error(t, err, "key in use can't be retired")
+	_, err = tb.broker.Decrypt(oldData, false, oldKeyID)
+	require.NoError(t, err)
+	tb.broker.OnEncryptionKeyRetiring = nil
+	_, err = b.HandleRequest(cctx, &logical.Request{
+		Storage: storage, Operation: logical.UpdateOperation, Path: "kafka/encryption_keys/" + oldKeyID + "/retire",
+	})
+	require.NoError(t, err)
+
+	mb, err := NewMessageBroker(cctx, storage, log.NewNullLogger())
+	require.NoError(t, err)
+	_, err = mb.Decrypt(oldData, false, oldKeyID)
+	require.ErrorIs(t, err, ErrEncryptionKeyRetired)
+	decrypted, err = mb.Decrypt(newData, false, newKeyID)
+	require.NoError(t, err)
+	require.Equal(t, "new", string(decrypted))
+	_, err = mb.Decrypt(newData, false, "unknown")
+	require.ErrorIs(t, err, ErrEncryptionKeyNotFound)
+
+	resp, err = b.HandleRequest(cctx, &logical.Request{
+		Storage: storage, Operation: logical.ReadOperation, Path: "kafka/encryption_keys",
+	})
+	require.NoError(t, err)
+	keys := resp.Data["keys"].([]map[string]interface{})
+	require.Len(t, keys, 2)
+	require.Equal(t, EncryptionKeyRetired, keys[0]["state"])
+	require.NotContains(t, keys[0], "public_key")
+	require.Equal(t, EncryptionKeyPrimary, keys[1]["state"])
+}
+
+func TestRootPublicKeyIsTrustedByMessage(t *testing.T) {
+	root, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newRoot, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	replica, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rootMB := &MessageBroker{KafkaConfig: BrokerConfig{EncryptionPrivateKey: root, EncryptionPublicKey: &root.PublicKey}}
+	rootMB.KafkaConfig.initEncryptionKeys()
+	storage := &logical.InmemStorage{}
+	replicaMB := &MessageBroker{
+		Logger: log.NewNullLogger(),
+		KafkaConfig: BrokerConfig{
+			EncryptionPrivateKey: replica, EncryptionPublicKey: &replica.PublicKey,
+		},
+		PluginConfig: PluginConfig{RootPublicKey: &root.PublicKey},
+	}
+	replicaMB.KafkaConfig.initEncryptionKeys()
+
+	msg, err := rootMB.RootPublicKeyMessage("root_source.replica", &newRoot.PublicKey, &replica.PublicKey)
+	require.NoError(t, err)
+	data, err := replicaMB.Decrypt(msg.Value, msg.Headers["chunked"] != nil, string(msg.Headers[KeyIDHeader]))
+	require.NoError(t, err)
+	hashed := sha256.Sum256(data)
+	require.NoError(t, replicaMB.VerifyRootSignature(msg.Headers["signature"], hashed), "message is signed by the current key")
+	pub, err := ParseRootPublicKey(data)
+	require.NoError(t, err)
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, newRoot, crypto.SHA256, hashed[:])
+	require.NoError(t, err)
+	require.Error(t, replicaMB.VerifyRootSignature(signature, hashed), "the new key is not trusted yet")
+	require.NoError(t, replicaMB.TrustRootPublicKey(cctx, storage, pub))
+	require.NoError(t, replicaMB.VerifyRootSignature(signature, hashed))
+
+	se, err := storage.Get(cctx, PluginConfigPath)
+	require.NoError(t, err)
+	var stored PluginConfig
+	require.NoError(t, json.Unmarshal(se.Value, &stored))
+	require.Len(t, stored.RootPublicKeys, 1)
+	require.True(t, stored.RootPublicKeys[0].Equal(&newRoot.PublicKey))
+}
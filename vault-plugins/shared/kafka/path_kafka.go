@@ -7,6 +7,7 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -21,23 +22,90 @@ const (
 )
 
 func (mb *MessageBroker) handlePublicKeyRead(_ context.Context, _ *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
-	if mb.KafkaConfig.EncryptionPublicKey == nil {
+	pub := mb.EncryptionPublicKey()
+	if pub == nil {
 		return nil, logical.CodedError(http.StatusNotFound, "public key does not exist. Run /kafka/configure_access first")
 	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": encodePublicKey(pub),
+			"key_id":     KeyID(pub),
+		},
+	}, nil
+}
+
+func encodePublicKey(pub *rsa.PublicKey) string {
 	pemdata := pem.EncodeToMemory(
 		&pem.Block{
 			Type:  "RSA PUBLIC KEY",
-			Bytes: x509.MarshalPKCS1PublicKey(mb.KafkaConfig.EncryptionPublicKey),
+			Bytes: x509.MarshalPKCS1PublicKey(pub),
 		},
 	)
+	return strings.ReplaceAll(string(pemdata), "\n", "\\n")
+}
+
+func (mb *MessageBroker) handleEncryptionKeysList(_ context.Context, _ *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	keys := []map[string]interface{}{}
+	for _, key := range mb.EncryptionKeys() {
+		item := map[string]interface{}{
+			"key_id":     key.ID,
+			"state":      key.State,
+			"created_at": key.CreatedAt,
+		}
+		if key.PrivateKey != nil {
+			item["public_key"] = encodePublicKey(&key.PrivateKey.PublicKey)
+		}
+		keys = append(keys, item)
+	}
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"public_key": strings.ReplaceAll(string(pemdata), "\n", "\\n"),
+			"keys": keys,
 		},
 	}, nil
 }
 
+func (mb *MessageBroker) handleEncryptionKeyCreate(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	key, err := mb.AddEncryptionKey(ctx, req.Storage)
+	if err != nil {
+		return nil, encryptionKeyError(err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_id":     key.ID,
+			"state":      key.State,
+			"public_key": encodePublicKey(&key.PrivateKey.PublicKey),
+		},
+	}, nil
+}
+
+func (mb *MessageBroker) handleEncryptionKeyPromote(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := mb.PromoteEncryptionKey(ctx, req.Storage, data.Get("key_id").(string)); err != nil {
+		return nil, encryptionKeyError(err)
+	}
+	return nil, nil
+}
+
+func (mb *MessageBroker) handleEncryptionKeyRetire(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := mb.RetireEncryptionKey(ctx, req.Storage, data.Get("key_id").(string)); err != nil {
+		return nil, encryptionKeyError(err)
+	}
+	return nil, nil
+}
+
+func encryptionKeyError(err error) error {
+	switch {
+	case errors.Is(err, ErrEncryptionKeyNotFound):
+		return logical.CodedError(http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrEncryptionKeyRetired), errors.Is(err, ErrEncryptionKeyPrimary),
+		errors.Is(err, ErrEncryptionKeyInUse):
+		return logical.CodedError(http.StatusBadRequest, err.Error())
+	}
+	return logical.CodedError(http.StatusInternalServerError, err.Error())
+}
+
 func (mb *MessageBroker) handleConfigureAccess(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	// kafka backends
 	endpoints := data.Get("kafka_endpoints").([]string)
@@ -61,6 +129,7 @@ func (mb *MessageBroker) handleConfigureAccess(ctx context.Context, req *logical
 		mb.KafkaConfig.EncryptionPrivateKey = pk
 		mb.KafkaConfig.EncryptionPublicKey = &pk.PublicKey
 	}
+	mb.KafkaConfig.initEncryptionKeys()
 
 	d, err := json.Marshal(mb.KafkaConfig)
 	if err != nil {
@@ -103,6 +172,51 @@ func (mb *MessageBroker) KafkaPaths() []*framework.Path {
 				},
 			},
 		},
+		{
+			Pattern: "kafka/encryption_keys/?",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Summary:  "List versions of encryption keys",
+					Callback: mb.handleEncryptionKeysList,
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Summary:  "Add a new encryption key, it is used only for decryption till promoting",
+					Callback: mb.handleEncryptionKeyCreate,
+				},
+			},
+		},
+		{
+			Pattern: "kafka/encryption_keys/" + framework.GenericNameRegex("key_id") + "/promote",
+			Fields: map[string]*framework.FieldSchema{
+				"key_id": {
+					Type:        framework.TypeString,
+					Required:    true,
+					Description: "ID of the encryption key",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Summary:  "Make the key primary, messages are encrypted by the primary key",
+					Callback: mb.handleEncryptionKeyPromote,
+				},
+			},
+		},
+		{
+			Pattern: "kafka/encryption_keys/" + framework.GenericNameRegex("key_id") + "/retire",
+			Fields: map[string]*framework.FieldSchema{
+				"key_id": {
+					Type:        framework.TypeString,
+					Required:    true,
+					Description: "ID of the encryption key",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Summary:  "Drop the private part of the key, topics should be re-encrypted before",
+					Callback: mb.handleEncryptionKeyRetire,
+				},
+			},
+		},
 		{
 			Pattern: "kafka/configure_access",
 			Fields: map[string]*framework.FieldSchema{
@@ -0,0 +1,129 @@
+package kafka
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/flant/negentropy/vault-plugins/shared/utils"
+)
+
+// RootPublicKeyType is the type of messages, which pass a new public key of the root source to replicas,
+// such message is signed by the current key and is sent before the new key is promoted,
+// so replicas trust the new key before it signs
+const RootPublicKeyType = "root_public_key"
+
+// RootPublicKey is the body of RootPublicKeyType message
+type RootPublicKey struct {
+	ID        string `json:"id"`
+	PublicKey string `json:"public_key"` // PEM
+}
+
+// RootPublicKeyMessage returns RootPublicKeyType message for the topic of the replica, it is signed by the primary key
+// and encrypted by the public key of the replica
+func (mb *MessageBroker) RootPublicKeyMessage(topic string, key *rsa.PublicKey, replicaKey *rsa.PublicKey) (Message, error) {
+	keyID := KeyID(key)
+	data, err := json.Marshal(RootPublicKey{ID: keyID, PublicKey: utils.DecodePemKey(key)})
+	if err != nil {
+		return Message{}, err
+	}
+	hashed := sha256.Sum256(data)
+	sign, err := rsa.SignPKCS1v15(rand.Reader, mb.EncryptionPrivateKey(), crypto.SHA256, hashed[:])
+	if err != nil {
+		return Message{}, err
+	}
+	data, chunked, err := NewEncrypter().Encrypt(data, replicaKey)
+	if err != nil {
+		return Message{}, err
+	}
+	msg := Message{
+		Topic: topic,
+		Key:   RootPublicKeyType + "/" + keyID,
+		Value: data,
+		Headers: map[string][]byte{
+			"signature": sign,
+			KeyIDHeader: []byte(KeyID(replicaKey)),
+		},
+	}
+	if chunked {
+		msg.Headers["chunked"] = []byte("true")
+	}
+	return msg, nil
+}
+
+// ParseRootPublicKey returns the key, passed by RootPublicKeyType message
+func ParseRootPublicKey(data []byte) (*rsa.PublicKey, error) {
+	var rootKey RootPublicKey
+	if err := json.Unmarshal(data, &rootKey); err != nil {
+		return nil, err
+	}
+	pub, err := utils.ParsePubkey(rootKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if KeyID(pub) != rootKey.ID {
+		return nil, fmt.Errorf("wrong id of the root public key: %s", rootKey.ID)
+	}
+	return pub, nil
+}
+
+// RootPublicKeys returns all trusted public keys of the root source
+func (mb *MessageBroker) RootPublicKeys() []*rsa.PublicKey {
+	mb.keysMutex.RLock()
+	defer mb.keysMutex.RUnlock()
+	var keys []*rsa.PublicKey
+	if mb.PluginConfig.RootPublicKey != nil {
+		keys = append(keys, mb.PluginConfig.RootPublicKey)
+	}
+	return append(keys, mb.PluginConfig.RootPublicKeys...)
+}
+
+// VerifyRootSignature checks signature by all trusted public keys of the root source
+func (mb *MessageBroker) VerifyRootSignature(signature []byte, hashed [32]byte) error {
+	var err error
+	for _, pub := range mb.RootPublicKeys() {
+		if err = VerifySignature(signature, pub, hashed); err == nil {
+			return nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("%w: no root public keys", ErrEncryptionKeyNotFound)
+	}
+	return err
+}
+
+// TrustRootPublicKey adds the key to trusted public keys of the root source, if storage is passed,
+// the plugin config is stored
+func (mb *MessageBroker) TrustRootPublicKey(ctx context.Context, storage logical.Storage, key *rsa.PublicKey) error {
+	mb.keysMutex.Lock()
+	defer mb.keysMutex.Unlock()
+	if mb.PluginConfig.RootPublicKey != nil && mb.PluginConfig.RootPublicKey.Equal(key) {
+		return nil
+	}
+	for _, pub := range mb.PluginConfig.RootPublicKeys {
+		if pub.Equal(key) {
+			return nil
+		}
+	}
+	config := mb.PluginConfig
+	config.RootPublicKeys = append(append([]*rsa.PublicKey{}, config.RootPublicKeys...), key)
+	if storage != nil {
+		d, err := json.Marshal(config)
+		if err != nil {
+			return err
+		}
+		err = storage.Put(ctx, &logical.StorageEntry{Key: PluginConfigPath, Value: d, SealWrap: true})
+		if err != nil {
+			return err
+		}
+	}
+	mb.PluginConfig = config
+	mb.Logger.Info(fmt.Sprintf("root public key %s is trusted", KeyID(key)))
+	return nil
+}
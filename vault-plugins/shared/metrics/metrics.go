@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	gometrics "github.com/armon/go-metrics"
+	prommetrics "github.com/armon/go-metrics/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Namespace is the prefix of all metrics names
+const Namespace = "negentropy"
+
+// Label is a label of the metric
+type Label = gometrics.Label
+
+var (
+	// registry is the registry of the plugin process, it is exposed by the metrics endpoint
+	registry = prometheus.NewRegistry()
+	sink     = newSink(registry)
+	global   = newMetrics(sink)
+
+	collectorsMutex sync.Mutex
+	collectors      = map[string]func(){}
+)
+
+func newSink(registry *prometheus.Registry) *prommetrics.PrometheusSink {
+	// metrics are never expired, gauges are updated at changes or by collectors
+	sink, err := prommetrics.NewPrometheusSinkFrom(prommetrics.PrometheusOpts{Registerer: registry})
+	if err != nil {
+		panic(err)
+	}
+	return sink
+}
+
+func newMetrics(sink gometrics.MetricSink) *gometrics.Metrics {
+	config := gometrics.DefaultConfig(Namespace)
+	config.EnableHostname = false
+	config.EnableRuntimeMetrics = false
+	config.EnableTypePrefix = false
+	m, err := gometrics.New(config, sink)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// IncrCounter increments the counter with the name
+func IncrCounter(name string, labels ...Label) {
+	global.IncrCounterWithLabels([]string{name}, 1, labels)
+}
+
+// SetGauge sets the gauge with the name
+func SetGauge(name string, value float64, labels ...Label) {
+	global.SetGaugeWithLabels([]string{name}, float32(value), labels)
+}
+
+// AddSample adds the observation into the summary with the name
+func AddSample(name string, value float64, labels ...Label) {
+	global.AddSampleWithLabels([]string{name}, float32(value), labels)
+}
+
+// SetCollector registers fn, which is called before every writing of metrics, the previous collector
+// with the same name is replaced
+func SetCollector(name string, fn func()) {
+	collectorsMutex.Lock()
+	defer collectorsMutex.Unlock()
+	collectors[name] = fn
+}
+
+// WritePrometheus runs collectors and writes all metrics in the Prometheus text format
+func WritePrometheus(w io.Writer) error {
+	collectorsMutex.Lock()
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fns := make([]func(), 0, len(names))
+	for _, name := range names {
+		fns = append(fns, collectors[name])
+	}
+	collectorsMutex.Unlock()
+	for _, collect := range fns {
+		collect()
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+	encoder := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, family := range families {
+		if err = encoder.Encode(family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	IncrCounter("test_messages_sent_total", Label{Name: "topic", Value: "root_source"})
+	IncrCounter("test_messages_sent_total", Label{Name: "topic", Value: "root_source"})
+	IncrCounter("test_messages_sent_total", Label{Name: "topic", Value: "with \"quotes\""})
+	SetCollector("test_rows", func() { SetGauge("test_memdb_rows", 5, Label{Name: "table", Value: "user"}) })
+	AddSample("test_eval_seconds", 0.5)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WritePrometheus(buf))
+
+	require.Contains(t, buf.String(), "# TYPE negentropy_test_messages_sent_total counter\n")
+	require.Contains(t, buf.String(), `negentropy_test_messages_sent_total{topic="root_source"} 2`)
+	require.Contains(t, buf.String(), `negentropy_test_messages_sent_total{topic="with \"quotes\""} 1`)
+	require.Contains(t, buf.String(), `negentropy_test_memdb_rows{table="user"} 5`)
+	require.Contains(t, buf.String(), "# TYPE negentropy_test_eval_seconds summary\n")
+	require.Contains(t, buf.String(), "negentropy_test_eval_seconds_count 1\n")
+}